@@ -0,0 +1,62 @@
+// apikey.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// apikeyUsage describes the apikey subcommand.
+const apikeyUsage = `usage: apikey <command>
+
+commands:
+  create NAME SUBJECT   create a key acting as SUBJECT and print it; the key
+                        cannot be shown again. SUBJECT is recorded as a user,
+                        so tasks can be assigned to it right away
+  list                  list the keys, without their secrets
+  revoke ID             stop accepting the key with the given ID`
+
+// runAPIKey executes an apikey subcommand and reports what it did on out.
+func runAPIKey(ctx context.Context, keys repo.APIKeyRepository, users repo.UserRepository, args []string, out io.Writer) error {
+	switch {
+	case len(args) == 3 && args[0] == "create":
+		key, stored, err := auth.NewAPIKey(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		user, err := users.EnsureUser(ctx, auth.APIKeyUser(stored.Subject))
+		if err != nil {
+			return err
+		}
+		if err := keys.CreateAPIKey(ctx, stored); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "created key %s for %s (user %d):\n%s\n", stored.ID, stored.Subject, user.ID, key)
+		return nil
+	case len(args) == 1 && args[0] == "list":
+		list, err := keys.ListAPIKeys(ctx)
+		for _, k := range list {
+			state := "active"
+			if k.Revoked() {
+				state = "revoked " + k.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%s  %-20s %-20s created %s  %s\n", k.ID, k.Name, k.Subject, k.CreatedAt.Format(time.RFC3339), state)
+		}
+		return err
+	case len(args) == 2 && args[0] == "revoke":
+		if err := keys.RevokeAPIKey(ctx, args[1], time.Now()); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "revoked key %s\n", args[1])
+		return nil
+	default:
+		return errors.New(apikeyUsage)
+	}
+}
@@ -3,62 +3,328 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/DimWebDev/task-manager-tool/internal/api"
 	myhandlers "github.com/DimWebDev/task-manager-tool/internal/api/handlers"
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/config"
+	"github.com/DimWebDev/task-manager-tool/internal/health"
+	"github.com/DimWebDev/task-manager-tool/internal/logging"
+	"github.com/DimWebDev/task-manager-tool/internal/metrics"
+	"github.com/DimWebDev/task-manager-tool/internal/migrations"
+	"github.com/DimWebDev/task-manager-tool/internal/policy"
 	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/DimWebDev/task-manager-tool/internal/server"
+	"github.com/DimWebDev/task-manager-tool/internal/tracing"
 	_ "github.com/lib/pq"
 )
 
-const (
-    host   = "localhost"
-    port   = 5432
-    dbname = "task_manager"
-)
-
 func main() {
-    // Retrieve the username and password from the environment variables
-    user := os.Getenv("POSTGRES_USER")
-  
-    if user == ""  {
-        log.Fatal("The POSTGRES_USER environment variable is not set.")
-    }
-
-    // Construct the connection string
-    psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
-        "dbname=%s sslmode=disable", host, port, user, dbname)
-
-    // Open a connection to the database
-    db, err := sql.Open("postgres", psqlInfo)
-    if err != nil {
-        log.Fatalf("Error opening connection: %s", err)
-    }
-    defer db.Close()
-
-    // Test the connection
-    err = db.Ping()
-    if err != nil {
-        log.Fatalf("Error pinging database: %s", err)
-    }
-    fmt.Println("Successfully connected to database!")
-
-    // Initialize the repository
-    taskRepo := repo.NewTaskRepo(db)
-
-    // Initialize the handler with the repository
-    taskHandler := myhandlers.NewTaskHandler(taskRepo)
-
-    // Set up the router with the task handler
-    router := api.NewRouter(taskHandler)
-
-
-    // Start the HTTP server with the router
-    httpAddress := ":8080"
-    fmt.Printf("Starting server on %s\n", httpAddress)
-    log.Fatal(http.ListenAndServe(httpAddress, router))
-}
\ No newline at end of file
+	// Usage: main [flags]                   run the server
+	//        main [flags] migrate <command> manage the schema, see runMigrate
+	//        main [flags] apikey <command>  manage API keys, see runAPIKey
+	//        main [flags] user <command>    manage user roles, see runUser
+	//        main [flags] workspace <command>
+	//                                       manage workspaces, see runWorkspace
+	// Run with -h to list the flags; see internal/config for the file and
+	// environment variables.
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	slog.Info("Effective configuration", slog.String("config", cfg.String()))
+
+	var taskRepo repo.TaskRepository
+	var keyRepo repo.APIKeyRepository
+	var userRepo repo.UserRepository
+	var workspaceRepo repo.WorkspaceRepository
+	var projectRepo repo.ProjectRepository
+	var tagRepo repo.TagRepository
+	var db *sql.DB
+	var migrator *migrations.Migrator
+	switch cfg.Storage.Driver {
+	case config.DriverMemory:
+		if len(args) > 0 && args[0] == "migrate" {
+			fatal("The in-memory storage has no schema to migrate", nil)
+		}
+		if len(args) > 0 && args[0] == "apikey" {
+			fatal("The in-memory storage cannot hold API keys", nil)
+		}
+		if len(args) > 0 && args[0] == "user" {
+			fatal("The in-memory storage forgets users when the server stops", nil)
+		}
+		if len(args) > 0 && args[0] == "workspace" {
+			fatal("The in-memory storage only has the default workspace", nil)
+		}
+		slog.Warn("Using in-memory storage; tasks are lost when the server stops")
+		memoryTasks := repo.NewMemoryTaskRepo()
+		taskRepo = memoryTasks
+		userRepo = repo.NewMemoryUserRepo()
+		workspaceRepo = repo.NewMemoryWorkspaceRepo()
+		projectRepo = repo.NewMemoryProjectRepo(memoryTasks)
+		tagRepo = repo.NewMemoryTagRepo(memoryTasks)
+	case config.DriverPostgres:
+		db = openPostgres(cfg.Storage)
+		migrator = migrations.New(db, loadMigrations(migrations.Postgres))
+		taskRepo = repo.NewTaskRepo(db)
+		keyRepo = repo.NewAPIKeyRepo(db)
+		userRepo = repo.NewUserRepo(db)
+		workspaceRepo = repo.NewWorkspaceRepo(db)
+		projectRepo = repo.NewProjectRepo(db)
+		tagRepo = repo.NewTagRepo(db)
+	case config.DriverSQLite:
+		db = openSQLite(cfg.Storage.SQLite.DSN)
+		migrator = migrations.NewSQLite(db, loadMigrations(migrations.SQLite))
+		taskRepo = repo.NewSQLiteTaskRepo(db)
+		keyRepo = repo.NewSQLiteAPIKeyRepo(db)
+		userRepo = repo.NewSQLiteUserRepo(db)
+		workspaceRepo = repo.NewSQLiteWorkspaceRepo(db)
+		projectRepo = repo.NewSQLiteProjectRepo(db)
+		tagRepo = repo.NewSQLiteTagRepo(db)
+	}
+
+	if db != nil {
+		if len(args) > 0 && args[0] == "migrate" {
+			err := runMigrate(context.Background(), migrator, args[1:], os.Stdout)
+			db.Close()
+			if err != nil {
+				fatal("Migration failed", err)
+			}
+			return
+		}
+		if len(args) > 0 && args[0] == "apikey" {
+			err := runAPIKey(context.Background(), keyRepo, userRepo, args[1:], os.Stdout)
+			db.Close()
+			if err != nil {
+				fatal("API key command failed", err)
+			}
+			return
+		}
+		if len(args) > 0 && args[0] == "user" {
+			err := runUser(context.Background(), userRepo, args[1:], os.Stdout)
+			db.Close()
+			if err != nil {
+				fatal("User command failed", err)
+			}
+			return
+		}
+		if len(args) > 0 && args[0] == "workspace" {
+			err := runWorkspace(context.Background(), workspaceRepo, userRepo, args[1:], os.Stdout)
+			db.Close()
+			if err != nil {
+				fatal("Workspace command failed", err)
+			}
+			return
+		}
+		if cfg.Features.AutoMigrate {
+			if err := runMigrate(context.Background(), migrator, []string{"up"}, os.Stdout); err != nil {
+				fatal("Migration failed", err)
+			}
+		}
+	}
+
+	// Collect metrics of the requests, the repository calls and the
+	// connection pool, served at /metrics.
+	registry := metrics.NewRegistry()
+	tracer := newTracer(cfg.Tracing)
+	if tracer != nil {
+		taskRepo = repo.Observed(taskRepo, tracing.Repo{})
+	}
+	taskRepo = repo.Observed(taskRepo, metrics.NewRepo(registry))
+	if cfg.Auth.Enabled {
+		// Outermost, so that denied calls are not counted as repository
+		// calls; the reads made to authorize a change are.
+		taskRepo = policy.Enforce(taskRepo, userRepo)
+		projectRepo = policy.EnforceProjects(projectRepo, userRepo)
+		tagRepo = policy.EnforceTags(tagRepo, userRepo)
+	}
+	if db != nil {
+		metrics.RegisterDBStats(registry, db)
+	}
+
+	// Initialize the handler with the repository
+	taskHandler := myhandlers.NewTaskHandler(taskRepo)
+	taskHandler.Workflow = &cfg.Workflow
+	taskHandler.Users = userRepo
+	taskHandler.Workspaces = workspaceRepo
+	taskHandler.Projects = projectRepo
+	taskHandler.Tags = tagRepo
+
+	// Set up the router with the task handler
+	router := api.NewRouter(taskHandler)
+	if tracer != nil {
+		// First, so that the request log line carries the trace ID.
+		router.Use(api.Tracing(tracer))
+	}
+	router.Use(api.Logging(logger))
+	router.Use(api.Metrics(metrics.NewHTTP(registry)))
+	router.Use(api.QueryTimeout(cfg.Server.QueryTimeout))
+	if cfg.Auth.Enabled {
+		// After QueryTimeout, which also bounds the lookup of API keys.
+		keys, tokens := newAuthenticators(cfg.Auth, keyRepo)
+		router.Use(api.Authenticate(keys, tokens, "/healthz", "/readyz", "/metrics"))
+	} else {
+		slog.Warn("Authentication is disabled; every client can read and change the tasks, whatever its role")
+	}
+	router.Handle("/metrics", registry.Handler()).Methods(http.MethodGet)
+	srv := server.New(cfg.Server, router)
+
+	// Probes: /healthz while the process runs, /readyz while it can serve
+	// tasks and is not shutting down.
+	checks := []health.Check{health.Shutdown(srv.ShuttingDown)}
+	if db != nil {
+		checks = append(checks, health.Database(db), health.Migrations(migrator))
+	}
+	router.Handle("/healthz", health.Liveness()).Methods(http.MethodGet, http.MethodHead)
+	router.Handle("/readyz", health.Readiness(health.DefaultCheckTimeout, checks...)).Methods(http.MethodGet, http.MethodHead)
+
+	// Serve until SIGINT or SIGTERM, then shut down gracefully. Once the
+	// shutdown has started, a second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if db != nil {
+		srv.OnShutdown("database", func(context.Context) error { return db.Close() })
+	}
+	if tracer != nil {
+		// Registered after the database, so the last spans are exported
+		// before it is closed.
+		srv.Go("tracing", tracer.Run)
+		srv.OnShutdown("tracing", tracer.Shutdown)
+	}
+	scheme := "HTTP"
+	if cfg.Server.TLS.Enabled() {
+		scheme = "HTTPS"
+	}
+	slog.Info("Starting server", slog.String("addr", cfg.Server.Addr), slog.String("scheme", scheme))
+	if err := srv.Run(ctx); err != nil {
+		fatal("Server failed", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// fatal logs msg and err, if any, and exits with status 1.
+func fatal(msg string, err error) {
+	if err != nil {
+		slog.Error(msg, slog.Any("error", err))
+	} else {
+		slog.Error(msg)
+	}
+	os.Exit(1)
+}
+
+// openPostgres connects to the configured PostgreSQL database and sizes its
+// connection pool.
+func openPostgres(cfg config.Storage) *sql.DB {
+	// Open a connection to the database
+	db, err := sql.Open("postgres", cfg.Postgres.ConnString())
+	if err != nil {
+		fatal("Error opening connection", err)
+	}
+	db.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+
+	// Test the connection
+	err = db.Ping()
+	if err != nil {
+		fatal("Error pinging database", err)
+	}
+	slog.Info("Connected to PostgreSQL")
+	return db
+}
+
+// openSQLite opens the SQLite database named by dsn, creating the file if
+// needed.
+func openSQLite(dsn string) *sql.DB {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		fatal("Error opening database", err)
+	}
+	// SQLite allows a single writer at a time, so one connection avoids
+	// "database is locked" errors. It also keeps a ":memory:" database,
+	// which exists per connection, alive for the lifetime of the server.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		fatal("Error opening database", err)
+	}
+	slog.Info("Using SQLite database", slog.String("dsn", dsn))
+	return db
+}
+
+// newTracer returns a Tracer for the configured exporter, or nil when
+// tracing is off.
+func newTracer(cfg config.Tracing) *tracing.Tracer {
+	var exp tracing.Exporter
+	switch cfg.Exporter {
+	case config.ExporterNone:
+		return nil
+	case config.ExporterStdout:
+		exp = tracing.NewWriterExporter(os.Stdout)
+	case config.ExporterFile:
+		fileExp, err := tracing.NewFileExporter(cfg.File)
+		if err != nil {
+			fatal("Error opening the trace file", err)
+		}
+		exp = fileExp
+	case config.ExporterOTLP:
+		exp = tracing.NewOTLPExporter(cfg.OTLPEndpoint, cfg.ServiceName)
+	}
+	slog.Info("Tracing requests", slog.String("exporter", cfg.Exporter))
+	return tracing.NewTracer(exp)
+}
+
+// newAuthenticators returns the authenticators of the configured methods,
+// nil for those that are disabled. API keys need keyRepo, which the memory
+// storage does not provide.
+func newAuthenticators(cfg config.Auth, keyRepo repo.APIKeyRepository) (*auth.APIKeys, *auth.Tokens) {
+	var keys *auth.APIKeys
+	var tokens *auth.Tokens
+	var methods []string
+	if cfg.APIKeys && keyRepo != nil {
+		keys = auth.NewAPIKeys(keyRepo)
+		methods = append(methods, auth.MethodAPIKey)
+	}
+	if cfg.JWT.KeySetFile != "" {
+		keySet, err := auth.LoadKeySet(cfg.JWT.KeySetFile)
+		if err != nil {
+			fatal("Error loading the JWT key set", err)
+		}
+		tokens = &auth.Tokens{Keys: keySet, Issuer: cfg.JWT.Issuer, Audience: cfg.JWT.Audience, Leeway: cfg.JWT.Leeway}
+		methods = append(methods, auth.MethodJWT)
+	}
+	slog.Info("Authenticating requests", slog.Any("methods", methods))
+	return keys, tokens
+}
+
+// loadMigrations returns the migrations of a schema, as loaded by load.
+func loadMigrations(load func() ([]migrations.Migration, error)) []migrations.Migration {
+	schema, err := load()
+	if err != nil {
+		fatal("Error loading migrations", err)
+	}
+	return schema
+}
@@ -0,0 +1,68 @@
+// migrate.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/migrations"
+)
+
+// migrateUsage describes the migrate subcommand.
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  up       apply all pending migrations
+  down     revert the most recently applied migration
+  status   list the migrations and whether they are applied
+  to N     migrate up or down to version N (0 reverts everything)`
+
+// runMigrate executes a migrate subcommand and reports what it did on out.
+func runMigrate(ctx context.Context, m *migrations.Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	var done []migrations.Migration
+	var err error
+	verb := "applied"
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		done, err = m.Up(ctx)
+	case args[0] == "down" && len(args) == 1:
+		verb = "reverted"
+		done, err = m.Down(ctx)
+	case args[0] == "to" && len(args) == 2:
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil || version < 0 {
+			return fmt.Errorf("migrate to: invalid version %q", args[1])
+		}
+		verb = "migrated"
+		done, err = m.To(ctx, version)
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := m.Status(ctx)
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%-40s %s\n", s.Migration, applied)
+		}
+		return err
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	for _, mig := range done {
+		fmt.Fprintf(out, "%s %s\n", verb, mig)
+	}
+	if err == nil && len(done) == 0 {
+		fmt.Fprintln(out, "schema is up to date")
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+// user.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// userUsage describes the user subcommand.
+const userUsage = `usage: user <command>
+
+commands:
+  list                  list the users and their roles
+  role SUBJECT ROLE     give the user SUBJECT the role viewer, member or
+                        admin, recording the user first if needed; takes
+                        effect on the next request
+  project-role SUBJECT PROJECT ROLE
+                        give the user SUBJECT the role ROLE in the project
+                        with the ID PROJECT, replacing their own role there,
+                        or withdraw it with the role none
+
+SUBJECT is the subject of an API key, or jwt:ISSUER|SUB for the user of
+tokens with those iss and sub claims.`
+
+// runUser executes a user subcommand and reports what it did on out.
+func runUser(ctx context.Context, users repo.UserRepository, args []string, out io.Writer) error {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		list, err := users.ListUsers(ctx)
+		for _, u := range list {
+			fmt.Fprintf(out, "%5d  %-30s %-8s created %s%s\n", u.ID, u.Subject, u.Role, u.CreatedAt.Format(time.RFC3339), projectRoles(u))
+		}
+		return err
+	case len(args) == 3 && args[0] == "role":
+		user, err := users.EnsureUser(ctx, userSubject(args[1]))
+		if err != nil {
+			return err
+		}
+		user, err = users.SetRole(ctx, user.ID, model.Role(args[2]))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s (user %d) is now %s\n", user.Subject, user.ID, user.Role)
+		return nil
+	case len(args) == 4 && args[0] == "project-role":
+		project, err := strconv.Atoi(args[2])
+		if err != nil || project < 1 {
+			return fmt.Errorf("invalid project ID %q", args[2])
+		}
+		role := model.Role(args[3])
+		if role == "none" {
+			role = ""
+		}
+		user, err := users.EnsureUser(ctx, userSubject(args[1]))
+		if err != nil {
+			return err
+		}
+		user, err = users.SetProjectRole(ctx, user.ID, project, role)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s (user %d) is now %s in project %d\n", user.Subject, user.ID, user.RoleIn(&project), project)
+		return nil
+	default:
+		return errors.New(userUsage)
+	}
+}
+
+// userSubject returns the user subject named by a SUBJECT argument: one
+// already namespaced by method is kept, any other is the subject of an API
+// key.
+func userSubject(arg string) string {
+	if strings.HasPrefix(arg, "apikey:") || strings.HasPrefix(arg, "jwt:") {
+		return arg
+	}
+	return auth.APIKeyUser(arg)
+}
+
+// projectRoles lists the roles granted to user in projects, ordered by
+// project ID, for the user list.
+func projectRoles(user model.User) string {
+	projects := make([]int, 0, len(user.ProjectRoles))
+	for project := range user.ProjectRoles {
+		projects = append(projects, project)
+	}
+	sort.Ints(projects)
+	var b strings.Builder
+	for _, project := range projects {
+		fmt.Fprintf(&b, ", %s in project %d", user.ProjectRoles[project], project)
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+// workspace.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// workspaceUsage describes the workspace subcommand.
+const workspaceUsage = `usage: workspace <command>
+
+commands:
+  list                  list the workspaces
+  create SLUG NAME      create a workspace, served below /workspaces/SLUG
+  add SLUG SUBJECT      make the user SUBJECT a member of the workspace,
+                        recording the user first if needed
+  remove SLUG SUBJECT   remove the user SUBJECT from the workspace
+
+SUBJECT is the subject of an API key, or jwt:ISSUER|SUB for the user of
+tokens with those iss and sub claims.`
+
+// runWorkspace executes a workspace subcommand and reports what it did on
+// out.
+func runWorkspace(ctx context.Context, workspaces repo.WorkspaceRepository, users repo.UserRepository, args []string, out io.Writer) error {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		list, err := workspaces.ListWorkspaces(ctx, 0)
+		for _, ws := range list {
+			fmt.Fprintf(out, "%5d  %-20s %-30s created %s\n", ws.ID, ws.Slug, ws.Name, ws.CreatedAt.Format(time.RFC3339))
+		}
+		return err
+	case len(args) == 3 && args[0] == "create":
+		ws, err := workspaces.CreateWorkspace(ctx, model.Workspace{Slug: args[1], Name: args[2]})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created workspace %s (%d)\n", ws.Slug, ws.ID)
+		return nil
+	case len(args) == 3 && (args[0] == "add" || args[0] == "remove"):
+		ws, err := workspaces.GetWorkspace(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if ws.Slug == model.DefaultWorkspace {
+			return errors.New("every user may use the default workspace")
+		}
+		user, err := users.EnsureUser(ctx, userSubject(args[2]))
+		if err != nil {
+			return err
+		}
+		if args[0] == "add" {
+			if err := workspaces.AddMember(ctx, ws.ID, user.ID); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%s (user %d) is now a member of %s\n", user.Subject, user.ID, ws.Slug)
+			return nil
+		}
+		if err := workspaces.RemoveMember(ctx, ws.ID, user.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s (user %d) is no longer a member of %s\n", user.Subject, user.ID, ws.Slug)
+		return nil
+	default:
+		return errors.New(workspaceUsage)
+	}
+}
@@ -3,36 +3,77 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/DimWebDev/task-manager-tool/internal/model"
 )
 
-// CreateTaskHandler handles the creation of a new task
+// CreateTaskHandler handles the creation of a new task. The task records the
+// authenticated user as its creator; watchers are added afterwards. Tags
+// the task names that the workspace does not have yet are created. A task
+// naming a parentId is created as a subtask of that task.
 func (h *TaskHandler) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var newTask model.Task
 	err := json.NewDecoder(r.Body).Decode(&newTask)
 	if err != nil {
-		http.Error(w, "Invalid task format", http.StatusBadRequest)
+		writeDecodeError(w, r, err, "Invalid task format")
 		return
 	}
 
 	// Validate the task as needed
 	// For example: check if the title is not empty
 	if newTask.Title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Title is required",
+			FieldError{Field: "title", Message: "is required"})
 		return
 	}
 
+	if !checkPriority(w, r, newTask.Priority) {
+		return
+	}
+
+	// New tasks start in the workflow's default status unless they name
+	// another initial status.
+	wf := h.workflow()
+	if newTask.Status == "" {
+		newTask.Status = wf.Initial[0]
+	}
+	if !h.checkStatus(w, r, newTask.Status) {
+		return
+	}
+	if !wf.IsInitial(newTask.Status) {
+		writeProblem(w, r, http.StatusConflict, CodeInvalidTransition,
+			"New tasks must start in one of "+joinStatuses(wf.Initial))
+		return
+	}
+
+	if !h.checkAssignee(w, r, "assigneeId", newTask.AssigneeID) {
+		return
+	}
+	user, ok, err := h.currentUser(r)
+	if err != nil {
+		writeRepoError(w, r, err, "Failed to create task")
+		return
+	}
+	newTask.CreatedBy, newTask.Watchers = nil, nil
+	if ok {
+		newTask.CreatedBy = &user.ID
+	}
+
 	// Call the repository function to insert the new task
-	err = h.Repo.Create(newTask)
+	created, err := h.Repo.Create(r.Context(), newTask)
 	if err != nil {
-		http.Error(w, "Failed to create task", http.StatusInternalServerError)
+		writeRepoError(w, r, err, "Failed to create task")
 		return
 	}
 
-	// If creation is successful, return the new task with StatusCreated
+	// If creation is successful, return the stored task with StatusCreated
+	// and point the client at its canonical URL, in the workspace it was
+	// created in
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(r.URL.Path, "/"), created.ID))
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(newTask)
+	encodeJSON(w, r, created)
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -23,11 +24,13 @@ func TestCreateTaskHandler_ValidInput(t *testing.T) {
         Title:       "Test Task",
         Description: "Test Description",
         DueDate:     &dueDate,
-        Priority:    "High",
-        Status:      "New",
+		Priority:    model.PriorityHigh,
+		Status:      "Pending",
     }
 
-    mockRepo.On("Create", mock.AnythingOfType("model.Task")).Return(nil)
+	created := task
+	created.ID = 7
+	mockRepo.On("Create", mock.AnythingOfType("model.Task")).Return(created, nil)
 
     taskJSON, _ := json.Marshal(task)
     req, _ := http.NewRequest("POST", "/tasks", bytes.NewBuffer(taskJSON))
@@ -45,7 +48,14 @@ func TestCreateTaskHandler_ValidInput(t *testing.T) {
         t.Fatalf("could not unmarshal response body: %v", err)
     }
 
-    // Compare all fields.
+	if location := rr.Header().Get("Location"); location != "/tasks/7" {
+		t.Errorf("handler returned unexpected Location header: got %q want %q", location, "/tasks/7")
+	}
+
+	// Compare all fields, including the ID assigned by the repository.
+	if returnedTask.ID != created.ID {
+		t.Errorf("handler returned unexpected id: got %v want %v", returnedTask.ID, created.ID)
+	}
     if returnedTask.Title != task.Title {
         t.Errorf("handler returned unexpected title: got %v want %v", returnedTask.Title, task.Title)
     }
@@ -85,3 +95,43 @@ func TestCreateTaskHandler_InvalidJSON(t *testing.T) {
 	// Ensure no expectations were met as the handler should have responded before calling repo.Create
 	mockRepo.AssertNotCalled(t, "Create")
 }
+
+func TestCreateTaskHandler_RepoError(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	testHandler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("Create", mock.AnythingOfType("model.Task")).Return(model.Task{}, errors.New("db down"))
+
+	req, _ := http.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Test Task"}`))
+	rr := httptest.NewRecorder()
+
+	testHandler.CreateTaskHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusInternalServerError)
+	}
+	if location := rr.Header().Get("Location"); location != "" {
+		t.Errorf("handler should not set Location on failure, got %q", location)
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTaskHandler_InvalidPriority(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	testHandler := NewTaskHandler(mockRepo)
+
+	req, _ := http.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Test Task","priority":"Blocker"}`))
+	rr := httptest.NewRecorder()
+
+	testHandler.CreateTaskHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if problem := decodeProblem(t, rr); len(problem.Errors) != 1 || problem.Errors[0].Field != "priority" {
+		t.Errorf("expected a priority field error, got %+v", problem.Errors)
+	}
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
@@ -2,32 +2,89 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
 	"github.com/gorilla/mux"
 )
 
-// DeleteTask is an HTTP handler for deleting a task.
+// DeleteTask is an HTTP handler for deleting a task. With an If-Match
+// header the task is only deleted while it is still at that version. A task
+// with subtasks is only deleted when the query string says what happens to
+// them:
+//
+//	subtasks=cascade          delete the subtasks, and theirs, too
+//	subtasks=move             move the subtasks up to the task's parent
+//	subtasks=move&moveTo=ID   move the subtasks below another task
+//
+// Without that choice, deleting a task with subtasks fails with 409
+// Conflict.
 func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	// Extract the task ID from the URL.
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		// If the ID is not an integer, return a bad request response.
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid task ID")
+		return
+	}
+
+	subtasks, fieldErrors := taskSubtasks(r)
+	if len(fieldErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid task deletion", fieldErrors...)
+		return
+	}
+
+	version, ifMatch, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to delete task")
 		return
 	}
 
 	// Call the Delete method on the repository.
-	err = h.Repo.Delete(id)
+	err = h.Repo.Delete(r.Context(), id, version, subtasks)
+	if errors.Is(err, repo.ErrHasSubtasks) {
+		writeProblem(w, r, http.StatusConflict, CodeConflict,
+			"Task still has subtasks; delete them with subtasks=cascade or move them with subtasks=move")
+		return
+	}
 	if err != nil {
 		// If there is an error deleting the task (e.g., task not found),
-		// return an internal server error response.
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		// return the matching error response.
+		writeConditionalError(w, r, err, ifMatch, "Failed to delete task")
 		return
 	}
 
 	// If the task was successfully deleted, return a no content response.
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// taskSubtasks reads what DeleteTask does with the subtasks of the task
+// from the query string.
+func taskSubtasks(r *http.Request) (repo.TaskSubtasks, []FieldError) {
+	values := r.URL.Query()
+	var subtasks repo.TaskSubtasks
+	var fieldErrors []FieldError
+	switch values.Get("subtasks") {
+	case "":
+	case "cascade":
+		subtasks.Cascade = true
+	case "move":
+		subtasks.Move = true
+	default:
+		fieldErrors = append(fieldErrors, FieldError{Field: "subtasks", Message: "must be cascade or move"})
+	}
+	if raw := values.Get("moveTo"); raw != "" {
+		moveTo, err := strconv.Atoi(raw)
+		switch {
+		case err != nil || moveTo < 1:
+			fieldErrors = append(fieldErrors, FieldError{Field: "moveTo", Message: "must be a task ID"})
+		case !subtasks.Move:
+			fieldErrors = append(fieldErrors, FieldError{Field: "moveTo", Message: "requires subtasks=move"})
+		}
+		subtasks.MoveTo = &moveTo
+	}
+	return subtasks, fieldErrors
+}
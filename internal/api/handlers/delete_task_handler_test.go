@@ -1,12 +1,13 @@
 package handlers
 
 import (
-	"database/sql"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
 
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
@@ -18,7 +19,7 @@ func TestDeleteTaskHandler_Success(t *testing.T) {
 	handler := NewTaskHandler(mockRepo)
 
 	taskID := 1
-	mockRepo.On("Delete", taskID).Return(nil)
+	mockRepo.On("Delete", taskID, 0, repo.TaskSubtasks{}).Return(nil)
 
 	req, _ := http.NewRequest("DELETE", "/tasks/"+strconv.Itoa(taskID), nil)
 	r := mux.NewRouter()
@@ -54,7 +55,7 @@ func TestDeleteTaskHandler_NotFound(t *testing.T) {
 	handler := NewTaskHandler(mockRepo)
 
 	taskID := 1
-	mockRepo.On("Delete", taskID).Return(sql.ErrNoRows) // Simulate not found error
+	mockRepo.On("Delete", taskID, 0, repo.TaskSubtasks{}).Return(fmt.Errorf("%w: task %d", repo.ErrNotFound, taskID)) // Simulate not found error
 
 	req, _ := http.NewRequest("DELETE", "/tasks/"+strconv.Itoa(taskID), nil)
 	r := mux.NewRouter()
@@ -64,8 +65,27 @@ func TestDeleteTaskHandler_NotFound(t *testing.T) {
 	req = mux.SetURLVars(req, map[string]string{"id": strconv.Itoa(taskID)})
 	r.ServeHTTP(rr, req)
 
-	assert.Equal(t, http.StatusInternalServerError, rr.Code, "handler should return 500 Internal Server Error if task not found")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "handler should return 404 Not Found if task not found")
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestDeleteTaskHandler_StorageUnavailable(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	taskID := 1
+	mockRepo.On("Delete", taskID, 0, repo.TaskSubtasks{}).Return(fmt.Errorf("%w: connection refused", repo.ErrUnavailable))
+
+	req, _ := http.NewRequest("DELETE", "/tasks/"+strconv.Itoa(taskID), nil)
+	r := mux.NewRouter()
+	r.HandleFunc("/tasks/{id}", handler.DeleteTask).Methods("DELETE")
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "handler should return 503 when storage is unavailable")
+	assert.NotContains(t, rr.Body.String(), "connection refused", "driver details should not leak to clients")
+
+	mockRepo.AssertExpectations(t)
+}
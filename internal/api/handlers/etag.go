@@ -0,0 +1,101 @@
+// internal/api/handlers/etag.go
+// The etag.go implements the conditional request headers (RFC 9110) used
+// for optimistic concurrency: tasks are tagged with their version, and
+// writes carrying If-Match only succeed while the task is at that version.
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// errPreconditionFailed reports an If-Match header that matches no
+// version of the task.
+var errPreconditionFailed = errors.New("precondition failed")
+
+// taskETag returns the strong entity tag of a task version.
+func taskETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseETags splits an If-Match or If-None-Match header into its entity
+// tags. The wildcard is returned as "*".
+func parseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ifMatchVersion resolves the If-Match header of a write into the version
+// the task must be at. present reports whether the header was sent; version
+// is 0 when any version is acceptable. When the header lists several tags,
+// the current task is loaded to find out which one applies. It returns
+// errPreconditionFailed when no listed tag can match.
+func (h *TaskHandler) ifMatchVersion(r *http.Request, id int) (version int, present bool, err error) {
+	tags := parseETags(r.Header.Get("If-Match"))
+	if len(tags) == 0 {
+		return 0, false, nil
+	}
+
+	var versions []int
+	for _, tag := range tags {
+		if tag == "*" {
+			return 0, true, nil
+		}
+		// If-Match uses the strong comparison, so weak tags never match.
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if v, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil && v > 0 {
+			versions = append(versions, v)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, true, errPreconditionFailed
+	case 1:
+		return versions[0], true, nil
+	}
+	current, err := h.Repo.GetByID(r.Context(), id)
+	if err != nil {
+		return 0, true, err
+	}
+	for _, v := range versions {
+		if v == current.Version {
+			return v, true, nil
+		}
+	}
+	return 0, true, errPreconditionFailed
+}
+
+// ifNoneMatch reports whether the If-None-Match header of a read matches
+// the given entity tag, using the weak comparison.
+func ifNoneMatch(r *http.Request, etag string) bool {
+	for _, tag := range parseETags(r.Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConditionalError sends the response for a failed conditional write.
+// A version mismatch is a 412 when the client asked for a specific version
+// with If-Match, and a 409 when it only sent the version in the body.
+func writeConditionalError(w http.ResponseWriter, r *http.Request, err error, ifMatch bool, fallback string) {
+	if errors.Is(err, errPreconditionFailed) || (ifMatch && errors.Is(err, repo.ErrVersionMismatch)) {
+		writeProblem(w, r, http.StatusPreconditionFailed, CodePreconditionFailed,
+			"Task does not match the If-Match header; fetch it again and retry")
+		return
+	}
+	writeRepoError(w, r, err, fallback)
+}
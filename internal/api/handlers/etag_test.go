@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTaskByID_ETag(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Version: 3}, nil)
+
+	t.Run("sets ETag", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/tasks/1", nil), map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+
+		handler.GetTaskByID(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+	})
+
+	for _, header := range []string{`"3"`, `W/"3"`, `"2", "3"`, `*`} {
+		t.Run("If-None-Match "+header, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/tasks/1", nil), map[string]string{"id": "1"})
+			req.Header.Set("If-None-Match", header)
+			rr := httptest.NewRecorder()
+
+			handler.GetTaskByID(rr, req)
+
+			assert.Equal(t, http.StatusNotModified, rr.Code)
+			assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+			assert.Empty(t, rr.Body.String())
+		})
+	}
+
+	t.Run("If-None-Match stale", func(t *testing.T) {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/tasks/1", nil), map[string]string{"id": "1"})
+		req.Header.Set("If-None-Match", `"2"`)
+		rr := httptest.NewRecorder()
+
+		handler.GetTaskByID(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestUpdateTask_IfMatch(t *testing.T) {
+	task := model.Task{ID: 1, Title: "Task"}
+
+	t.Run("matching version", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		expected := task
+		expected.Version = 3
+		stored := task
+		stored.Version = 4
+		mockRepo.On("GetByID", 1).Return(expected, nil)
+		mockRepo.On("Update", expected).Return(stored, nil)
+
+		req := mux.SetURLVars(httptest.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(`{"title":"Task"}`)), map[string]string{"id": "1"})
+		req.Header.Set("If-Match", `"3"`)
+		rr := httptest.NewRecorder()
+
+		handler.UpdateTask(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"4"`, rr.Header().Get("ETag"))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		current := task
+		current.Version = 3
+		mockRepo.On("GetByID", 1).Return(current, nil)
+
+		req := mux.SetURLVars(httptest.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(`{"title":"Task"}`)), map[string]string{"id": "1"})
+		req.Header.Set("If-Match", `"2"`)
+		rr := httptest.NewRecorder()
+
+		handler.UpdateTask(rr, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+		assert.Equal(t, CodePreconditionFailed, decodeProblem(t, rr).Code)
+		mockRepo.AssertNotCalled(t, "Update")
+	})
+
+	t.Run("stale version in body", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		current := task
+		current.Version = 3
+		expected := task
+		expected.Version = 2
+		mockRepo.On("GetByID", 1).Return(current, nil)
+		mockRepo.On("Update", expected).Return(model.Task{}, fmt.Errorf("%w: task 1", repo.ErrVersionMismatch))
+
+		req := mux.SetURLVars(httptest.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(`{"title":"Task","version":2}`)), map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+
+		handler.UpdateTask(rr, req)
+
+		// Without If-Match a lost race is a plain conflict
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("weak tag never matches", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		req := mux.SetURLVars(httptest.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(`{"title":"Task"}`)), map[string]string{"id": "1"})
+		req.Header.Set("If-Match", `W/"3"`)
+		rr := httptest.NewRecorder()
+
+		handler.UpdateTask(rr, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+		mockRepo.AssertNotCalled(t, "Update")
+	})
+
+	t.Run("several tags", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Version: 5}, nil)
+
+		req := mux.SetURLVars(httptest.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(`{"title":"Task"}`)), map[string]string{"id": "1"})
+		req.Header.Set("If-Match", `"3", "4"`)
+		rr := httptest.NewRecorder()
+
+		handler.UpdateTask(rr, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+		mockRepo.AssertNotCalled(t, "Update")
+	})
+}
+
+func TestPatchTask_IfMatch(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	status := model.StatusCompleted
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Status: model.StatusInProgress, Version: 7}, nil)
+	mockRepo.On("Patch", 1, repo.TaskPatch{Status: &status, Version: 7}).
+		Return(model.Task{ID: 1, Title: "Task", Status: status, Version: 8}, nil)
+
+	req := newPatchRequest("1", MergePatchContentType, `{"status":"Completed"}`)
+	req.Header.Set("If-Match", `"7"`)
+	rr := httptest.NewRecorder()
+
+	handler.PatchTask(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"8"`, rr.Header().Get("ETag"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchTask_JSONPatchWithStaleIfMatch(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Version: 8}, nil)
+
+	req := newPatchRequest("1", JSONPatchContentType, `[{"op":"replace","path":"/title","value":"New"}]`)
+	req.Header.Set("If-Match", `"7"`)
+	rr := httptest.NewRecorder()
+
+	handler.PatchTask(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	mockRepo.AssertNotCalled(t, "Patch")
+}
+
+func TestDeleteTask_IfMatch(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("Delete", 1, 3, repo.TaskSubtasks{}).Return(fmt.Errorf("%w: task 1", repo.ErrVersionMismatch))
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/tasks/1", nil), map[string]string{"id": "1"})
+	req.Header.Set("If-Match", `"3"`)
+	rr := httptest.NewRecorder()
+
+	handler.DeleteTask(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
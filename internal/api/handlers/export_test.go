@@ -0,0 +1,8 @@
+package handlers
+
+// Helpers of the tests of package handlers_test, which serve the handlers
+// through api.NewRouter and so cannot be in package handlers.
+var (
+	DecodeProblem = decodeProblem
+	DecodeTask    = decodeTask
+)
@@ -2,26 +2,227 @@
 package handlers
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
 )
 
-// GetAllTasks handles the HTTP request for retrieving all tasks.
+// GetAllTasks handles the HTTP request for listing tasks. The query string
+// selects the page:
+//
+//	status, priority     filter by one or more values (repeat or comma-separate)
+//	minPriority,         inclusive priority range, e.g. minPriority=High for
+//	maxPriority          High and Critical tasks
+//	dueAfter, dueBefore  inclusive due date range (YYYY-MM-DD or RFC 3339)
+//	assignee, createdBy, the user a task is assigned to, created by or
+//	watcher              watched by: a user ID, or "me" for the
+//	                     authenticated user
+//	project              the ID of the project tasks belong to
+//	includeArchived      "true" to include the tasks of archived projects,
+//	                     which are left out otherwise
+//	tag                  one or more tag names (repeat or comma-separate)
+//	tagMatch             "any" (default) for tasks with any of the tags,
+//	                     "all" for tasks with all of them
+//	sort                 field to order by, prefixed with "-" for descending
+//	limit                page size, 1 to repo.MaxPageSize
+//	cursor               continue a previous listing
+//
+// The link to the following page is sent in a Link header with rel="next".
 func (h *TaskHandler) GetAllTasks(w http.ResponseWriter, r *http.Request) {
-	// Invoke the GetAll method to retrieve tasks
-	tasks, err := h.Repo.GetAll()
+	h.listTasks(w, r, r.URL.Query())
+}
+
+// listTasks sends the page of tasks selected by the query parameters in
+// values.
+func (h *TaskHandler) listTasks(w http.ResponseWriter, r *http.Request, values url.Values) {
+	query, fieldErrors := parseTaskQuery(values)
+	if len(fieldErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid task query", fieldErrors...)
+		return
+	}
+	if err := h.resolveMe(r, values, &query); err != nil {
+		writeUserError(w, r, err, "Internal server error")
+		return
+	}
+
+	// Invoke the List method to retrieve the requested page of tasks
+	page, err := h.Repo.List(r.Context(), query)
 	if err != nil {
-		// If an error occurs, send an internal server error response
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		// If an error occurs, send the matching error response
+		writeRepoError(w, r, err, "Internal server error")
 		return
 	}
 
+	if page.Next != nil {
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextPageURL(r.URL, page.Next)))
+	}
 	// Set the Content-Type as application/json
 	w.Header().Set("Content-Type", "application/json")
 	// Write the HTTP status code
 	w.WriteHeader(http.StatusOK)
 	// Encode and send the tasks as a JSON response
-	if err := json.NewEncoder(w).Encode(tasks); err != nil {
-		http.Error(w, "Failed to encode tasks", http.StatusInternalServerError)
+	encodeJSON(w, r, page.Tasks)
+}
+
+// parseTaskQuery turns the query string of GET /tasks into a repo.TaskQuery,
+// collecting one FieldError per invalid parameter.
+func parseTaskQuery(values url.Values) (repo.TaskQuery, []FieldError) {
+	var q repo.TaskQuery
+	var fieldErrors []FieldError
+
+	for _, s := range splitValues(values["status"]) {
+		status, err := model.ParseStatus(s)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "status", Message: fmt.Sprintf("unknown status %q", s)})
+			continue
+		}
+		q.Statuses = append(q.Statuses, status)
+	}
+	for _, s := range splitValues(values["priority"]) {
+		priority, err := model.ParsePriority(s)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "priority", Message: fmt.Sprintf("unknown priority %q", s)})
+			continue
+		}
+		q.Priorities = append(q.Priorities, priority)
+	}
+	for _, p := range []struct {
+		name string
+		dst  *model.Priority
+	}{{"minPriority", &q.MinPriority}, {"maxPriority", &q.MaxPriority}} {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		priority, err := model.ParsePriority(raw)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: p.name, Message: fmt.Sprintf("unknown priority %q", raw)})
+			continue
+		}
+		*p.dst = priority
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"dueAfter", &q.DueAfter}, {"dueBefore", &q.DueBefore}} {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseDate(raw)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: p.name, Message: "must be a date (YYYY-MM-DD) or RFC 3339 timestamp"})
+			continue
+		}
+		*p.dst = &t
+	}
+
+	// "me" is resolved by the handler, which knows the request.
+	for _, f := range userFilters(&q) {
+		raw := values.Get(f.name)
+		if raw == "" || raw == me {
+			continue
+		}
+		id, err := strconv.Atoi(raw)
+		if err != nil || id < 1 {
+			fieldErrors = append(fieldErrors, FieldError{Field: f.name, Message: `must be a user ID or "me"`})
+			continue
+		}
+		*f.dst = id
+	}
+
+	if raw := values.Get("project"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id < 1 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "project", Message: "must be a project ID"})
+		}
+		q.ProjectID = id
+	}
+	includeArchived, err := parseBool(values.Get("includeArchived"))
+	if err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "includeArchived", Message: "must be true or false"})
+	}
+	q.IncludeArchived = includeArchived
+
+	q.Tags = splitValues(values["tag"])
+	switch values.Get("tagMatch") {
+	case "", "any":
+	case "all":
+		q.AllTags = true
+	default:
+		fieldErrors = append(fieldErrors, FieldError{Field: "tagMatch", Message: "must be any or all"})
 	}
+
+	if sort := values.Get("sort"); sort != "" {
+		q.Descending = strings.HasPrefix(sort, "-")
+		field, err := repo.ParseSortField(strings.TrimPrefix(sort, "-"))
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "sort", Message: "is not a sortable field"})
+		}
+		q.SortBy = field
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > repo.MaxPageSize {
+			fieldErrors = append(fieldErrors, FieldError{Field: "limit", Message: fmt.Sprintf("must be an integer between 1 and %d", repo.MaxPageSize)})
+		}
+		q.Limit = limit
+	}
+
+	if raw := values.Get("cursor"); raw != "" {
+		cursor, err := repo.DecodeCursor(raw)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "cursor", Message: "is malformed"})
+		}
+		q.After = cursor
+	}
+	return q, fieldErrors
+}
+
+// userFilter is a query parameter naming a user, and the TaskQuery field
+// it sets.
+type userFilter struct {
+	name string
+	dst  *int
+}
+
+// userFilters returns the user filters of q.
+func userFilters(q *repo.TaskQuery) []userFilter {
+	return []userFilter{{"assignee", &q.AssigneeID}, {"createdBy", &q.CreatedBy}, {"watcher", &q.Watcher}}
+}
+
+// splitValues flattens repeated and comma-separated query values.
+func splitValues(raw []string) []string {
+	var values []string
+	for _, r := range raw {
+		for _, v := range strings.Split(r, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// parseDate accepts either a calendar date or a full RFC 3339 timestamp.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// nextPageURL returns the request URL with its cursor replaced by next.
+func nextPageURL(u *url.URL, next *repo.Cursor) string {
+	values := u.Query()
+	values.Set("cursor", next.Encode())
+	return (&url.URL{Path: u.Path, RawQuery: values.Encode()}).String()
 }
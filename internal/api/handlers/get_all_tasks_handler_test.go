@@ -4,52 +4,138 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestGetAllTasks(t *testing.T) {
+	repoMock := new(MockTaskRepository)
+	handler := NewTaskHandler(repoMock)
 
+	tasks := []model.Task{
+		{
+			ID:          1,
+			Title:       "Task 1",
+			Description: "Description 1",
+			DueDate:     &time.Time{},
+			Priority:    model.PriorityHigh,
+			Status:      "Pending",
+		},
+		{
+			ID:          2,
+			Title:       "Task 2",
+			Description: "Description 2",
+			DueDate:     &time.Time{},
+			Priority:    model.PriorityMedium,
+			Status:      "In Progress",
+		},
+	}
 
-func TestGetAllTasks(t *testing.T) {
-    repoMock := new(MockTaskRepository)
-    handler := NewTaskHandler(repoMock)
-
-    tasks := []model.Task{
-        {
-            ID:          1,
-            Title:       "Task 1",
-            Description: "Description 1",
-            DueDate:     &time.Time{},
-            Priority:    "High",
-            Status:      "New",
-        },
-        {
-            ID:          2,
-            Title:       "Task 2",
-            Description: "Description 2",
-            DueDate:     &time.Time{},
-            Priority:    "Medium",
-            Status:      "In Progress",
-        },
-    }
-
-    repoMock.On("GetAll").Return(tasks, nil)
-
-    req := httptest.NewRequest("GET", "/tasks", nil)
-    rr := httptest.NewRecorder()
-
-    handler.GetAllTasks(rr, req)
-
-    assert.Equal(t, http.StatusOK, rr.Code)
-    assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
-
-    var returnedTasks []model.Task
-    err := json.Unmarshal(rr.Body.Bytes(), &returnedTasks)
-    assert.NoError(t, err)
-    assert.Equal(t, tasks, returnedTasks)
-
-    repoMock.AssertExpectations(t)
+	repoMock.On("List", repo.TaskQuery{}).Return(repo.TaskPage{Tasks: tasks}, nil)
+
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	rr := httptest.NewRecorder()
+
+	handler.GetAllTasks(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var returnedTasks []model.Task
+	err := json.Unmarshal(rr.Body.Bytes(), &returnedTasks)
+	assert.NoError(t, err)
+	assert.Equal(t, tasks, returnedTasks)
+	assert.Empty(t, rr.Header().Get("Link"), "the last page must not link to a next page")
+
+	repoMock.AssertExpectations(t)
+}
+
+func TestGetAllTasks_QueryParameters(t *testing.T) {
+	repoMock := new(MockTaskRepository)
+	handler := NewTaskHandler(repoMock)
+
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	cursor := repo.Cursor{SortBy: repo.SortByDueDate, Descending: true, ID: 9}
+	next := repo.Cursor{SortBy: repo.SortByDueDate, Descending: true, ID: 4}
+
+	expected := repo.TaskQuery{
+		Statuses:   []model.Status{model.StatusPending, model.StatusInProgress},
+		Priorities: []model.Priority{model.PriorityHigh},
+		DueAfter:   &after,
+		DueBefore:  &before,
+		SortBy:     repo.SortByDueDate,
+		Descending: true,
+		Limit:      2,
+		After:      &cursor,
+	}
+	repoMock.On("List", expected).Return(repo.TaskPage{
+		Tasks: []model.Task{{ID: 7, Title: "Task 7"}, {ID: 4, Title: "Task 4"}},
+		Next:  &next,
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/tasks?status=Pending,In+Progress&priority=High"+
+		"&dueAfter=2024-01-01&dueBefore=2024-03-31&sort=-dueDate&limit=2&cursor="+cursor.Encode(), nil)
+	rr := httptest.NewRecorder()
+
+	handler.GetAllTasks(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	link := rr.Header().Get("Link")
+	assert.True(t, strings.HasSuffix(link, `>; rel="next"`), "unexpected Link header %q", link)
+	nextURL, err := url.Parse(strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`))
+	assert.NoError(t, err)
+	assert.Equal(t, "/tasks", nextURL.Path)
+	assert.Equal(t, next.Encode(), nextURL.Query().Get("cursor"))
+	assert.Equal(t, "-dueDate", nextURL.Query().Get("sort"), "the next link must keep the other parameters")
+
+	repoMock.AssertExpectations(t)
+}
+
+func TestGetAllTasks_InvalidQueryParameters(t *testing.T) {
+	repoMock := new(MockTaskRepository)
+	handler := NewTaskHandler(repoMock)
+
+	req := httptest.NewRequest("GET", "/tasks?sort=color&limit=1000&dueAfter=yesterday&cursor=!!&project=0&includeArchived=maybe&tagMatch=some", nil)
+	rr := httptest.NewRecorder()
+
+	handler.GetAllTasks(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	problem := decodeProblem(t, rr)
+	fields := make([]string, 0, len(problem.Errors))
+	for _, e := range problem.Errors {
+		fields = append(fields, e.Field)
+	}
+	assert.ElementsMatch(t, []string{"sort", "limit", "dueAfter", "cursor", "project", "includeArchived", "tagMatch"}, fields)
+
+	repoMock.AssertNotCalled(t, "List")
+}
+
+func TestGetAllTasks_PriorityRange(t *testing.T) {
+	repoMock := new(MockTaskRepository)
+	handler := NewTaskHandler(repoMock)
+
+	expected := repo.TaskQuery{MinPriority: model.PriorityHigh, SortBy: repo.SortByPriority, Descending: true}
+	repoMock.On("List", expected).Return(repo.TaskPage{}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.GetAllTasks(rr, httptest.NewRequest("GET", "/tasks?minPriority=high&sort=-priority", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	repoMock.AssertExpectations(t)
+
+	rr = httptest.NewRecorder()
+	handler.GetAllTasks(rr, httptest.NewRequest("GET", "/tasks?maxPriority=soon", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "maxPriority", decodeProblem(t, rr).Errors[0].Field)
 }
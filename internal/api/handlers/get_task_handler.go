@@ -1,41 +1,47 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
-// GetTaskByID is the handler for retrieving a task by its ID
+// GetTaskByID is the handler for retrieving a task by its ID. The response
+// carries the task's ETag; a request whose If-None-Match header matches it is
+// answered with 304 Not Modified.
 func (h *TaskHandler) GetTaskByID(w http.ResponseWriter, r *http.Request) {
 	// Extracting the task ID from the URL path
 	vars := mux.Vars(r)
 	idStr, ok := vars["id"]
 	if !ok {
-		http.Error(w, "Task ID is missing", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Task ID is missing")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid Task ID format", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid Task ID format")
 		return
 	}
 
 	// Retrieve the task from the repository
-	task, err := h.Repo.GetByID(id)
+	task, err := h.Repo.GetByID(r.Context(), id)
 	if err != nil {
-		// Handle the case where the task is not found
-		http.Error(w, "Task not found", http.StatusNotFound)
+		// Only a missing task is a 404; storage failures are reported as such
+		writeRepoError(w, r, err, "Failed to retrieve task")
+		return
+	}
+
+	etag := taskETag(task.Version)
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	// Respond with the task in JSON format
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(task); err != nil {
-		http.Error(w, "Failed to encode task", http.StatusInternalServerError)
-	}
+	encodeJSON(w, r, task)
 }
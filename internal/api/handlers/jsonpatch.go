@@ -0,0 +1,327 @@
+// internal/api/handlers/jsonpatch.go
+// The jsonpatch.go implements the two patch formats accepted by PATCH
+// endpoints: JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902).
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of the supported patch documents.
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// errPatchTestFailed reports a JSON Patch "test" operation that did not
+// match the current document.
+var errPatchTestFailed = errors.New("patch test operation failed")
+
+// patchOperation is a single JSON Patch operation. Value is nil only when
+// the operation has no "value" member; a null value decodes to the raw
+// bytes null, as json.RawMessage is handed JSON nulls too.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies a JSON Patch document to the JSON object doc and
+// returns the patched object. The operations are applied atomically: if any
+// of them fails, doc is left unchanged and an error is returned.
+func applyJSONPatch(doc map[string]any, patch []byte) (map[string]any, error) {
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("patch must be a JSON array of operations: %w", err)
+	}
+
+	// Work on a deep copy, so a failing operation leaves doc untouched.
+	var root any = deepCopyJSON(doc)
+	for i, op := range ops {
+		var err error
+		root, err = applyOperation(root, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	result, ok := root.(map[string]any)
+	if !ok {
+		return nil, errors.New("patch must leave an object at the document root")
+	}
+	return result, nil
+}
+
+func applyOperation(root any, op patchOperation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.New(`missing "value"`)
+		}
+		value, err := decodeJSONValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return addValue(root, path, value)
+		case "replace":
+			if _, err := getValue(root, path); err != nil {
+				return nil, err
+			}
+			if root, err = removeValue(root, path); err != nil {
+				return nil, err
+			}
+			return addValue(root, path, value)
+		default:
+			current, err := getValue(root, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, errPatchTestFailed
+			}
+			return root, nil
+		}
+	case "remove":
+		return removeValue(root, path)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := getValue(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if isPrefix(from, path) && len(from) < len(path) {
+				return nil, errors.New("cannot move a value into one of its children")
+			}
+			if root, err = removeValue(root, from); err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopyJSON(value)
+		}
+		return addValue(root, path, value)
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses an array index token. "-" is only allowed when adding
+// and designates the position after the last element.
+func arrayIndex(token string, length int, adding bool) (int, error) {
+	if token == "-" && adding {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	max := length - 1
+	if adding {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func getValue(node any, path []string) (any, error) {
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q does not exist", token)
+			}
+			node = v
+		case []any:
+			i, err := arrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse into %q", token)
+		}
+	}
+	return node, nil
+}
+
+// addValue sets the value at path and returns the (possibly new) root.
+func addValue(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := getValue(root, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+		return root, nil
+	case []any:
+		i, err := arrayIndex(last, len(p), true)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, nil)
+		copy(p[i+1:], p[i:])
+		p[i] = value
+		return setValue(root, parentPath, p)
+	default:
+		return nil, fmt.Errorf("cannot add %q to a scalar", last)
+	}
+}
+
+// removeValue deletes the value at path and returns the (possibly new) root.
+func removeValue(root any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the document root")
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := getValue(root, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch p := parent.(type) {
+	case map[string]any:
+		if _, ok := p[last]; !ok {
+			return nil, fmt.Errorf("path member %q does not exist", last)
+		}
+		delete(p, last)
+		return root, nil
+	case []any:
+		i, err := arrayIndex(last, len(p), false)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p[:i:i], p[i+1:]...)
+		return setValue(root, parentPath, p)
+	default:
+		return nil, fmt.Errorf("cannot remove %q from a scalar", last)
+	}
+}
+
+// setValue overwrites the value at path, which must exist or be the root.
+func setValue(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parentPath, last := path[:len(path)-1], path[len(path)-1]
+	parent, err := getValue(root, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(p), false)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = value
+	}
+	return root, nil
+}
+
+// decodeJSONValue decodes a JSON value keeping numbers as json.Number, so
+// that "test" compares them exactly.
+func decodeJSONValue(raw []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func deepCopyJSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(t))
+		for k, e := range t {
+			c[k] = deepCopyJSON(e)
+		}
+		return c
+	case []any:
+		c := make([]any, len(t))
+		for i, e := range t {
+			c[i] = deepCopyJSON(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// changedMembers compares two JSON objects and returns the top-level members
+// of after that differ from before, in merge patch form: a removed member is
+// reported as null.
+func changedMembers(before, after map[string]any) (map[string]json.RawMessage, error) {
+	changes := make(map[string]json.RawMessage)
+	for key, value := range after {
+		if old, ok := before[key]; ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		changes[key] = raw
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changes[key] = json.RawMessage("null")
+		}
+	}
+	return changes, nil
+}
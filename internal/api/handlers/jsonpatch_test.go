@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustDocument(t *testing.T, raw string) map[string]any {
+	t.Helper()
+	v, err := decodeJSONValue([]byte(raw))
+	if err != nil {
+		t.Fatalf("invalid test document %s: %v", raw, err)
+	}
+	return v.(map[string]any)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	cases := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"append array element", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"replace with null", `{"baz":"qux"}`, `[{"op":"replace","path":"/baz","value":null},{"op":"test","path":"/baz","value":null}]`, `{"baz":null}`},
+		{"move", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`},
+		{"test passes", `{"n":1,"s":"x"}`, `[{"op":"test","path":"/n","value":1},{"op":"test","path":"/s","value":"x"}]`, `{"n":1,"s":"x"}`},
+		{"escaped pointer", `{"a/b":1,"m~n":2}`, `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, `{"a/b":3}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := applyJSONPatch(mustDocument(t, tc.doc), []byte(tc.patch))
+			assert.NoError(t, err)
+			assert.Equal(t, mustDocument(t, tc.want), got)
+		})
+	}
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	cases := map[string]string{
+		"not an array":         `{"op":"add"}`,
+		"unknown op":           `[{"op":"frobnicate","path":"/a"}]`,
+		"missing value":        `[{"op":"add","path":"/a"}]`,
+		"replace missing":      `[{"op":"replace","path":"/missing","value":1}]`,
+		"remove missing":       `[{"op":"remove","path":"/missing"}]`,
+		"bad pointer":          `[{"op":"add","path":"a","value":1}]`,
+		"index out of range":   `[{"op":"add","path":"/list/5","value":1}]`,
+		"leading zero index":   `[{"op":"remove","path":"/list/01"}]`,
+		"move into child":      `[{"op":"move","from":"/obj","path":"/obj/child"}]`,
+		"replace root by list": `[{"op":"replace","path":"","value":[]}]`,
+	}
+
+	for name, patch := range cases {
+		t.Run(name, func(t *testing.T) {
+			doc := mustDocument(t, `{"a":1,"list":[1,2],"obj":{}}`)
+			_, err := applyJSONPatch(doc, []byte(patch))
+			assert.Error(t, err)
+			assert.False(t, errors.Is(err, errPatchTestFailed))
+			// A failed patch must leave the original document untouched
+			assert.Equal(t, mustDocument(t, `{"a":1,"list":[1,2],"obj":{}}`), doc)
+		})
+	}
+}
+
+func TestApplyJSONPatchTestFailure(t *testing.T) {
+	doc := mustDocument(t, `{"status":"Pending"}`)
+	patch := `[{"op":"test","path":"/status","value":"Completed"},{"op":"replace","path":"/status","value":"Pending"}]`
+
+	_, err := applyJSONPatch(doc, []byte(patch))
+	assert.True(t, errors.Is(err, errPatchTestFailed), "expected errPatchTestFailed, got %v", err)
+}
+
+func TestChangedMembers(t *testing.T) {
+	before := mustDocument(t, `{"id":1,"title":"a","dueDate":"2024-01-01T00:00:00Z","status":"Pending"}`)
+	after := mustDocument(t, `{"id":1,"title":"b","status":"Pending","priority":"High"}`)
+
+	changes, err := changedMembers(before, after)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{
+		"title":    json.RawMessage(`"b"`),
+		"dueDate":  json.RawMessage(`null`),
+		"priority": json.RawMessage(`"High"`),
+	}, changes)
+}
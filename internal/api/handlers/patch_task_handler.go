@@ -0,0 +1,257 @@
+// internal/api/handlers/patch_task_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/logging"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+)
+
+// acceptPatch lists the patch formats understood by PatchTask, as sent in
+// the Accept-Patch header (RFC 5789).
+var acceptPatch = strings.Join([]string{MergePatchContentType, JSONPatchContentType}, ", ")
+
+// PatchTask partially updates an existing task. The body is either a JSON
+// Merge Patch (application/merge-patch+json), where members set to null are
+// cleared, or a JSON Patch (application/json-patch+json) applied to the
+// current representation of the task. Only the fields changed by the patch
+// are written. Like UpdateTask, the write honours If-Match and status changes
+// must follow the workflow. Without If-Match the patch is still applied
+// conditionally on the version it was checked against, so a concurrent
+// change makes it fail rather than be overwritten.
+func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) {
+	// Get the task ID from the URL.
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid task ID")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchContentType && mediaType != JSONPatchContentType {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		writeProblem(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMedia,
+			fmt.Sprintf("Content-Type must be %s or %s", MergePatchContentType, JSONPatchContentType))
+		return
+	}
+
+	version, ifMatch, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to update task")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "Could not read request body")
+		return
+	}
+	defer r.Body.Close()
+
+	// Reduce both formats to the set of changed top-level members.
+	var changes map[string]json.RawMessage
+	if mediaType == MergePatchContentType {
+		if err := json.Unmarshal(body, &changes); err != nil || changes == nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "Merge patch must be a JSON object")
+			return
+		}
+	}
+	// The patch is made against the current task, and a status change is
+	// checked against it; it must not be written over a task that changed in
+	// the meantime.
+	current, ok := h.currentTask(w, r, id, version, ifMatch)
+	if !ok {
+		return
+	}
+	version = current.Version
+	if mediaType == JSONPatchContentType {
+		doc, err := taskDocument(current)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to encode task", slog.Any("error", err))
+			writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "Failed to encode task")
+			return
+		}
+		patched, err := applyJSONPatch(doc, body)
+		if errors.Is(err, errPatchTestFailed) {
+			writeProblem(w, r, http.StatusConflict, CodePatchConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid JSON patch: "+err.Error())
+			return
+		}
+		if changes, err = changedMembers(doc, patched); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to compute patch", slog.Any("error", err))
+			writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "Failed to compute patch")
+			return
+		}
+		// The version is managed by the server, not patched.
+		delete(changes, "version")
+	}
+
+	patch, fieldErrors := taskPatchFromMembers(changes)
+	if len(fieldErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid task patch", fieldErrors...)
+		return
+	}
+
+	if patch.SetAssignee && !h.checkAssignee(w, r, "assigneeId", patch.AssigneeID) {
+		return
+	}
+
+	if patch.Status != nil {
+		if *patch.Status != current.Status && !h.checkStatus(w, r, *patch.Status) {
+			return
+		}
+		if !h.checkTransition(w, r, current.Status, *patch.Status) {
+			return
+		}
+	}
+
+	if ifMatch || patch.Version == 0 {
+		patch.Version = version
+	}
+
+	task, err := h.Repo.Patch(r.Context(), id, patch)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to update task")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(task.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, task)
+}
+
+// taskDocument returns the JSON object representation of a task, with
+// numbers kept as json.Number so JSON Patch "test" compares them exactly.
+func taskDocument(task any) (map[string]any, error) {
+	raw, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeJSONValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]any), nil
+}
+
+// taskPatchFromMembers converts changed task members, in merge patch form,
+// into a repository patch. A null member clears the field where that is
+// allowed.
+func taskPatchFromMembers(members map[string]json.RawMessage) (repo.TaskPatch, []FieldError) {
+	var patch repo.TaskPatch
+	var fieldErrors []FieldError
+
+	// optionalString decodes a string member; null clears it to "".
+	optionalString := func(field string, raw json.RawMessage) *string {
+		var s *string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a string or null"})
+			return nil
+		}
+		if s == nil {
+			s = new(string)
+		}
+		return s
+	}
+
+	for field, raw := range members {
+		switch field {
+		case "id", "createdBy", "watchers", "progress":
+			// The ID is taken from the URL, the creator is set once,
+			// watchers have endpoints of their own and the progress is
+			// counted from the subtasks.
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "cannot be changed"})
+		case "version":
+			// Like in a PUT body, the version names the expected version.
+			if err := json.Unmarshal(raw, &patch.Version); err != nil || patch.Version < 1 {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a positive integer"})
+			}
+		case "title":
+			var title string
+			if err := json.Unmarshal(raw, &title); err != nil || string(raw) == "null" {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a string"})
+				continue
+			}
+			patch.Title = &title
+		case "description":
+			patch.Description = optionalString(field, raw)
+		case "dueDate":
+			var due *time.Time
+			if err := json.Unmarshal(raw, &due); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be an RFC 3339 timestamp or null"})
+				continue
+			}
+			patch.SetDueDate, patch.DueDate = true, due
+		case "priority":
+			// null clears the priority.
+			var priority model.Priority
+			if err := json.Unmarshal(raw, &priority); err != nil || (priority != model.PriorityNone && !priority.Valid()) {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: priorityMessage})
+				continue
+			}
+			patch.Priority = &priority
+		case "status":
+			var status model.Status
+			if err := json.Unmarshal(raw, &status); err != nil || string(raw) == "null" {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a string"})
+				continue
+			}
+			patch.Status = &status
+		case "assigneeId":
+			// null unassigns the task.
+			var assignee *int
+			if err := json.Unmarshal(raw, &assignee); err != nil || (assignee != nil && *assignee < 1) {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a user ID or null"})
+				continue
+			}
+			patch.SetAssignee, patch.AssigneeID = true, assignee
+		case "projectId":
+			// null takes the task out of its project.
+			var project *int
+			if err := json.Unmarshal(raw, &project); err != nil || (project != nil && *project < 1) {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a project ID or null"})
+				continue
+			}
+			patch.SetProject, patch.ProjectID = true, project
+		case "parentId":
+			// null makes the task a top-level task; the parent is checked
+			// by the repository.
+			var parent *int
+			if err := json.Unmarshal(raw, &parent); err != nil || (parent != nil && *parent < 1) {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a task ID or null"})
+				continue
+			}
+			patch.SetParent, patch.ParentID = true, parent
+		case "tags":
+			// null removes every tag; the names are checked by the
+			// repository.
+			var tags []string
+			if err := json.Unmarshal(raw, &tags); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be an array of tag names or null"})
+				continue
+			}
+			patch.SetTags, patch.Tags = true, tags
+		default:
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "is not a task field"})
+		}
+	}
+	sort.Slice(fieldErrors, func(i, j int) bool { return fieldErrors[i].Field < fieldErrors[j].Field })
+	return patch, fieldErrors
+}
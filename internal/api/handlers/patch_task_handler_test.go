@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPatchRequest(id, contentType, body string) *http.Request {
+	req := httptest.NewRequest("PATCH", "/tasks/"+id, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	return mux.SetURLVars(req, map[string]string{"id": id})
+}
+
+func TestPatchTask_MergePatchOnlyChangesGivenFields(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	status := model.StatusCompleted
+	current := model.Task{ID: 1, Title: "Keep me", Description: "Keep me too", Status: model.StatusInProgress, Version: 2}
+	updated := current
+	updated.Status, updated.Version = status, 3
+	mockRepo.On("GetByID", 1).Return(current, nil)
+	mockRepo.On("Patch", 1, repo.TaskPatch{Status: &status, Version: 2}).Return(updated, nil)
+
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", MergePatchContentType, `{"status":"Completed"}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var returned model.Task
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &returned))
+	assert.Equal(t, updated, returned)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchTask_MergePatchNullClearsDueDate(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Version: 1}, nil)
+	mockRepo.On("Patch", 1, repo.TaskPatch{SetDueDate: true, Version: 1}).Return(model.Task{ID: 1, Title: "Task", Version: 2}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", MergePatchContentType+"; charset=utf-8", `{"dueDate":null}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchTask_MergePatchPinnedToVersionRead(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	title := "New"
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Version: 5}, nil)
+	mockRepo.On("Patch", 1, repo.TaskPatch{Title: &title, Version: 5}).
+		Return(model.Task{}, fmt.Errorf("%w: task 1", repo.ErrVersionMismatch))
+
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", MergePatchContentType, `{"title":"New"}`))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchTask_JSONPatch(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	due := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	current := model.Task{ID: 1, Title: "Task", Description: "Old", DueDate: &due, Priority: model.PriorityLow, Status: "Pending"}
+	mockRepo.On("GetByID", 1).Return(current, nil)
+
+	title, priority := "Renamed", model.PriorityHigh
+	expected := repo.TaskPatch{Title: &title, Priority: &priority, SetDueDate: true}
+	mockRepo.On("Patch", 1, expected).Return(expected.Apply(current), nil)
+
+	body := `[
+		{"op": "test", "path": "/status", "value": "Pending"},
+		{"op": "replace", "path": "/title", "value": "Renamed"},
+		{"op": "replace", "path": "/priority", "value": "High"},
+		{"op": "remove", "path": "/dueDate"}
+	]`
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", JSONPatchContentType, body))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var returned model.Task
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &returned))
+	assert.Equal(t, "Renamed", returned.Title)
+	assert.Nil(t, returned.DueDate)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchTask_JSONPatchNullClearsFields(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	due := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	current := model.Task{ID: 1, Title: "Task", Description: "Old", DueDate: &due, Status: "Pending"}
+	mockRepo.On("GetByID", 1).Return(current, nil)
+
+	empty := ""
+	expected := repo.TaskPatch{Description: &empty, SetDueDate: true}
+	mockRepo.On("Patch", 1, expected).Return(expected.Apply(current), nil)
+
+	body := `[
+		{"op": "replace", "path": "/dueDate", "value": null},
+		{"op": "replace", "path": "/description", "value": null}
+	]`
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", JSONPatchContentType, body))
+
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var returned model.Task
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &returned))
+	assert.Nil(t, returned.DueDate)
+	assert.Empty(t, returned.Description)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchTask_JSONPatchTestFailure(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Status: "Completed"}, nil)
+
+	body := `[{"op": "test", "path": "/status", "value": "Pending"}, {"op": "replace", "path": "/status", "value": "In Progress"}]`
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", JSONPatchContentType, body))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, CodePatchConflict, decodeProblem(t, rr).Code)
+	mockRepo.AssertNotCalled(t, "Patch")
+}
+
+func TestPatchTask_UnsupportedContentType(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", "application/json", `{"status":"Completed"}`))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Equal(t, acceptPatch, rr.Header().Get("Accept-Patch"))
+	assert.Equal(t, CodeUnsupportedMedia, decodeProblem(t, rr).Code)
+	mockRepo.AssertNotCalled(t, "Patch")
+}
+
+func TestPatchTask_InvalidMembers(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Version: 1}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", MergePatchContentType, `{"title":null,"dueDate":"tomorrow","color":"red"}`))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "color", Message: "is not a task field"},
+		{Field: "dueDate", Message: "must be an RFC 3339 timestamp or null"},
+		{Field: "title", Message: "must be a string"},
+	}, decodeProblem(t, rr).Errors)
+	mockRepo.AssertNotCalled(t, "Patch")
+}
+
+func TestPatchTask_NotFound(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("GetByID", 9).Return(model.Task{}, repo.ErrNotFound)
+
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("9", JSONPatchContentType, `[]`))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertNotCalled(t, "Patch")
+}
@@ -0,0 +1,177 @@
+// internal/api/handlers/problem.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/DimWebDev/task-manager-tool/internal/logging"
+	"github.com/DimWebDev/task-manager-tool/internal/policy"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// RequestIDHeader carries the identifier used to correlate a request with
+// its error responses and logs.
+const RequestIDHeader = "X-Request-ID"
+
+// StatusClientClosedRequest is the non-standard status (introduced by nginx)
+// recorded for requests the client abandoned before the response was ready.
+// The client never sees it, but logs and metrics can tell such requests
+// apart from server failures.
+const StatusClientClosedRequest = 499
+
+// problemTypeBase prefixes the error code to build the problem type URI.
+const problemTypeBase = "urn:task-manager:problem:"
+
+// Machine-readable error codes sent in the "code" member of a Problem.
+const (
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeInvalidID          = "invalid-id"
+	CodeInvalidBody        = "invalid-body"
+	CodeValidation         = "validation-failed"
+	CodeNotFound           = "not-found"
+	CodeConflict           = "conflict"
+	CodePatchConflict      = "patch-conflict"
+	CodeInvalidTransition  = "invalid-transition"
+	CodePreconditionFailed = "precondition-failed"
+	CodeUnsupportedMedia   = "unsupported-media-type"
+	CodeUnavailable        = "storage-unavailable"
+	CodeTimeout            = "timeout"
+	CodeCanceled           = "request-canceled"
+	CodeInternal           = "internal-error"
+)
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object. It is a superset of the
+// ErrorResponse schema of the OpenAPI contract: Message repeats the human
+// readable explanation so existing clients keep working.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Message   string       `json:"message"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// writeProblem sends a problem+json response for the given request.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, fieldErrors ...FieldError) {
+	problem := Problem{
+		Type:      problemTypeBase + code,
+		Title:     statusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Message:   detail,
+		Code:      code,
+		RequestID: requestID(r),
+		Errors:    fieldErrors,
+	}
+	if problem.Message == "" {
+		problem.Message = problem.Title
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if problem.RequestID != "" {
+		w.Header().Set(RequestIDHeader, problem.RequestID)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// WriteProblem sends a problem+json response, so that the middlewares of
+// package api answer like the handlers.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblem(w, r, status, code, detail)
+}
+
+// WriteRepoError sends the problem matching a repository error, see
+// writeRepoError.
+func WriteRepoError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	writeRepoError(w, r, err, fallback)
+}
+
+// requestID returns the ID the logging middleware gave r, falling back to
+// the X-Request-ID header when the handler is served without it.
+func requestID(r *http.Request) string {
+	if id := logging.RequestID(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
+}
+
+// statusText is http.StatusText extended with StatusClientClosedRequest.
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+// writeRepoError sends the problem matching a repository error, including
+// a policy.Denial of a repository enforcing the access policy. Internal
+// errors are reported with the fallback message so driver details do not leak.
+func writeRepoError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var validationErr *repo.ValidationError
+	var denial *policy.Denial
+	switch {
+	case errors.As(err, &denial):
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, denial.Reason)
+	case errors.Is(err, repo.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "Task not found")
+	case errors.As(err, &validationErr):
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Task failed validation",
+			FieldError{Field: validationErr.Field, Message: validationErr.Message})
+	case errors.Is(err, repo.ErrValidation):
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Task failed validation")
+	case errors.Is(err, repo.ErrConflict):
+		writeProblem(w, r, http.StatusConflict, CodeConflict, "Task was modified concurrently")
+	case errors.Is(err, repo.ErrUnavailable):
+		logging.FromContext(r.Context()).Warn("task storage is unavailable", slog.Any("error", err))
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Task storage is unavailable")
+	case errors.Is(err, repo.ErrTimeout):
+		logging.FromContext(r.Context()).Warn("task storage timed out", slog.Any("error", err))
+		writeProblem(w, r, http.StatusGatewayTimeout, CodeTimeout, "Task storage did not answer in time")
+	case errors.Is(err, repo.ErrCanceled):
+		writeProblem(w, r, StatusClientClosedRequest, CodeCanceled, "Request was canceled")
+	default:
+		logging.FromContext(r.Context()).Error(fallback, slog.Any("error", err))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, fallback)
+	}
+}
+
+// writeDecodeError sends the problem for a request body that could not be
+// decoded, naming the offending field when the decoder reports one.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, detail,
+			FieldError{Field: typeErr.Field, Message: fmt.Sprintf("must be a %s", typeErr.Type)})
+	case errors.As(err, &syntaxErr):
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody,
+			fmt.Sprintf("%s: malformed JSON at offset %d", detail, syntaxErr.Offset))
+	case errors.Is(err, io.EOF):
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, detail+": request body is empty")
+	default:
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, detail)
+	}
+}
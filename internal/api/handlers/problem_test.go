@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/logging"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	assert.Equal(t, ProblemContentType, rr.Header().Get("Content-Type"))
+
+	var problem Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("could not unmarshal problem body %q: %v", rr.Body.String(), err)
+	}
+	return problem
+}
+
+func TestWriteProblem(t *testing.T) {
+	req := httptest.NewRequest("GET", "/tasks/5", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	rr := httptest.NewRecorder()
+
+	writeProblem(rr, req, http.StatusNotFound, CodeNotFound, "Task not found")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "req-123", rr.Header().Get(RequestIDHeader))
+
+	problem := decodeProblem(t, rr)
+	assert.Equal(t, Problem{
+		Type:      "urn:task-manager:problem:not-found",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "Task not found",
+		Instance:  "/tasks/5",
+		Message:   "Task not found",
+		Code:      CodeNotFound,
+		RequestID: "req-123",
+	}, problem)
+}
+
+func TestWriteRepoError(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", fmt.Errorf("%w: task 1", repo.ErrNotFound), http.StatusNotFound, CodeNotFound},
+		{"validation", repo.ErrValidation, http.StatusBadRequest, CodeValidation},
+		{"conflict", repo.ErrConflict, http.StatusConflict, CodeConflict},
+		{"unavailable", repo.ErrUnavailable, http.StatusServiceUnavailable, CodeUnavailable},
+		{"timeout", fmt.Errorf("%w: %w", repo.ErrTimeout, context.DeadlineExceeded), http.StatusGatewayTimeout, CodeTimeout},
+		{"canceled", fmt.Errorf("%w: %w", repo.ErrCanceled, context.Canceled), StatusClientClosedRequest, CodeCanceled},
+		{"unknown", errors.New("pq: secret detail"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/tasks", nil)
+			rr := httptest.NewRecorder()
+
+			writeRepoError(rr, req, tc.err, "Something failed")
+
+			assert.Equal(t, tc.status, rr.Code)
+			problem := decodeProblem(t, rr)
+			assert.Equal(t, tc.code, problem.Code)
+			assert.NotEmpty(t, problem.Message)
+			assert.NotContains(t, rr.Body.String(), "secret detail")
+		})
+	}
+}
+
+func TestCreateTaskHandler_ValidationProblem(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("Create", mock.AnythingOfType("model.Task")).
+		Return(model.Task{}, &repo.ValidationError{Field: "title", Message: "must be at most 255 characters"})
+
+	req := httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"too long"}`))
+	rr := httptest.NewRecorder()
+
+	handler.CreateTaskHandler(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	problem := decodeProblem(t, rr)
+	assert.Equal(t, CodeValidation, problem.Code)
+	assert.Equal(t, []FieldError{{Field: "title", Message: "must be at most 255 characters"}}, problem.Errors)
+}
+
+func TestCreateTaskHandler_MissingTitleProblem(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	req := httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"description":"no title"}`))
+	rr := httptest.NewRecorder()
+
+	handler.CreateTaskHandler(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	problem := decodeProblem(t, rr)
+	assert.Equal(t, CodeValidation, problem.Code)
+	assert.Equal(t, []FieldError{{Field: "title", Message: "is required"}}, problem.Errors)
+	mockRepo.AssertNotCalled(t, "Create")
+}
+
+func TestUpdateTask_WrongFieldTypeProblem(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	req := httptest.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(`{"title": 42}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handler.UpdateTask(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	problem := decodeProblem(t, rr)
+	assert.Equal(t, CodeInvalidBody, problem.Code)
+	assert.Equal(t, []FieldError{{Field: "title", Message: "must be a string"}}, problem.Errors)
+	mockRepo.AssertNotCalled(t, "Update")
+}
+
+func TestDeleteTask_InvalidIDProblem(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	req := httptest.NewRequest("DELETE", "/tasks/abc", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "abc"})
+	rr := httptest.NewRecorder()
+
+	handler.DeleteTask(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, CodeInvalidID, decodeProblem(t, rr).Code)
+}
+
+func TestGetTaskByID_ContextDone(t *testing.T) {
+	// The memory repository honours the request context like the SQL ones.
+	taskRepo := repo.NewMemoryTaskRepo()
+	task, err := taskRepo.Create(inDefault, model.Task{Title: "Task"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewTaskHandler(taskRepo)
+
+	canceled, cancel := context.WithCancel(inDefault)
+	cancel()
+	expired, cancel := context.WithTimeout(inDefault, -1)
+	defer cancel()
+
+	for _, tc := range []struct {
+		ctx    context.Context
+		status int
+		code   string
+	}{
+		{canceled, StatusClientClosedRequest, CodeCanceled},
+		{expired, http.StatusGatewayTimeout, CodeTimeout},
+	} {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/tasks/%d", task.ID), nil).WithContext(tc.ctx)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(task.ID)})
+		rr := httptest.NewRecorder()
+
+		handler.GetTaskByID(rr, req)
+
+		assert.Equal(t, tc.status, rr.Code)
+		assert.Equal(t, tc.code, decodeProblem(t, rr).Code)
+	}
+}
+
+func TestWriteProblem_RequestIDFromContext(t *testing.T) {
+	req := httptest.NewRequest("GET", "/tasks/1", nil)
+	req = req.WithContext(logging.WithRequestID(req.Context(), "generated-id"))
+	rr := httptest.NewRecorder()
+
+	writeProblem(rr, req, http.StatusNotFound, CodeNotFound, "Task not found")
+
+	assert.Equal(t, "generated-id", decodeProblem(t, rr).RequestID)
+}
@@ -0,0 +1,285 @@
+// internal/api/handlers/project_handler.go
+// The project_handler.go serves the projects tasks are grouped in: their
+// CRUD endpoints and the listing of the tasks of a project.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+)
+
+// writeProjectError sends the problem matching a ProjectRepository error.
+// Errors that are not about the project itself are sent like task errors.
+func writeProjectError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var validationErr *repo.ValidationError
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "Project not found")
+	case errors.Is(err, repo.ErrProjectNotEmpty):
+		writeProblem(w, r, http.StatusConflict, CodeConflict,
+			"Project still has tasks; delete them with tasks=cascade or move them with tasks=move")
+	case errors.As(err, &validationErr):
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Project failed validation",
+			FieldError{Field: validationErr.Field, Message: validationErr.Message})
+	default:
+		writeRepoError(w, r, err, fallback)
+	}
+}
+
+// projectID returns the project ID in the URL, writing a problem when it is
+// malformed.
+func projectID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid project ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// writeProject sends a project with the given status.
+func writeProject(w http.ResponseWriter, r *http.Request, status int, project model.Project) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encodeJSON(w, r, project)
+}
+
+// CreateProject creates a project in the workspace of the request.
+func (h *TaskHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
+	var project model.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		writeDecodeError(w, r, err, "Invalid project format")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := h.Projects.CreateProject(r.Context(), project)
+	if err != nil {
+		writeProjectError(w, r, err, "Failed to create project")
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(r.URL.Path, "/"), created.ID))
+	writeProject(w, r, http.StatusCreated, created)
+}
+
+// ListProjects returns the projects of the workspace, ordered by ID.
+// Archived projects are left out unless includeArchived=true.
+func (h *TaskHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	includeArchived, err := parseBool(r.URL.Query().Get("includeArchived"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid project query",
+			FieldError{Field: "includeArchived", Message: "must be true or false"})
+		return
+	}
+	projects, err := h.Projects.ListProjects(r.Context(), includeArchived)
+	if err != nil {
+		writeProjectError(w, r, err, "Failed to list projects")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, projects)
+}
+
+// GetProject returns a project by ID.
+func (h *TaskHandler) GetProject(w http.ResponseWriter, r *http.Request) {
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+	project, err := h.Projects.GetProject(r.Context(), id)
+	if err != nil {
+		writeProjectError(w, r, err, "Failed to retrieve project")
+		return
+	}
+	writeProject(w, r, http.StatusOK, project)
+}
+
+// UpdateProject replaces the name, description, color and archived flag of
+// a project with those of the body.
+func (h *TaskHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+	var project model.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		writeDecodeError(w, r, err, "Invalid project format")
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := h.Projects.PatchProject(r.Context(), id, repo.ProjectPatch{
+		Name:        &project.Name,
+		Description: &project.Description,
+		Color:       &project.Color,
+		Archived:    &project.Archived,
+	})
+	if err != nil {
+		writeProjectError(w, r, err, "Failed to update project")
+		return
+	}
+	writeProject(w, r, http.StatusOK, updated)
+}
+
+// PatchProject changes the members of a project present in a JSON Merge
+// Patch body; null clears the description and the color. Archiving a
+// project is a patch of "archived".
+func (h *TaskHandler) PatchProject(w http.ResponseWriter, r *http.Request) {
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != MergePatchContentType {
+		w.Header().Set("Accept-Patch", MergePatchContentType)
+		writeProblem(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMedia,
+			"Content-Type must be "+MergePatchContentType)
+		return
+	}
+	var members map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&members); err != nil || members == nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "Merge patch must be a JSON object")
+		return
+	}
+	defer r.Body.Close()
+
+	patch, fieldErrors := projectPatchFromMembers(members)
+	if len(fieldErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid project patch", fieldErrors...)
+		return
+	}
+	updated, err := h.Projects.PatchProject(r.Context(), id, patch)
+	if err != nil {
+		writeProjectError(w, r, err, "Failed to update project")
+		return
+	}
+	writeProject(w, r, http.StatusOK, updated)
+}
+
+// projectPatchFromMembers converts the members of a merge patch into a
+// repository patch.
+func projectPatchFromMembers(members map[string]json.RawMessage) (repo.ProjectPatch, []FieldError) {
+	var patch repo.ProjectPatch
+	var fieldErrors []FieldError
+	for field, raw := range members {
+		switch field {
+		case "id", "createdAt":
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "cannot be changed"})
+		case "name":
+			var name string
+			if err := json.Unmarshal(raw, &name); err != nil || string(raw) == "null" {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a string"})
+				continue
+			}
+			patch.Name = &name
+		case "description", "color":
+			// null clears the field.
+			var s *string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a string or null"})
+				continue
+			}
+			if s == nil {
+				s = new(string)
+			}
+			if field == "color" {
+				patch.Color = s
+			} else {
+				patch.Description = s
+			}
+		case "archived":
+			var archived bool
+			if err := json.Unmarshal(raw, &archived); err != nil || string(raw) == "null" {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be true or false"})
+				continue
+			}
+			patch.Archived = &archived
+		default:
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "is not a project field"})
+		}
+	}
+	sort.Slice(fieldErrors, func(i, j int) bool { return fieldErrors[i].Field < fieldErrors[j].Field })
+	return patch, fieldErrors
+}
+
+// DeleteProject deletes a project. A project with tasks is only deleted
+// when the query string says what happens to them:
+//
+//	tasks=cascade          delete the tasks too
+//	tasks=move             take the tasks out of any project
+//	tasks=move&moveTo=ID   move the tasks to another project
+//
+// Without that choice, deleting a project with tasks fails with 409
+// Conflict.
+func (h *TaskHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+	values := r.URL.Query()
+	var tasks repo.ProjectTasks
+	var fieldErrors []FieldError
+	switch values.Get("tasks") {
+	case "":
+	case "cascade":
+		tasks.Cascade = true
+	case "move":
+		tasks.Move = true
+	default:
+		fieldErrors = append(fieldErrors, FieldError{Field: "tasks", Message: "must be cascade or move"})
+	}
+	if raw := values.Get("moveTo"); raw != "" {
+		moveTo, err := strconv.Atoi(raw)
+		switch {
+		case err != nil || moveTo < 1:
+			fieldErrors = append(fieldErrors, FieldError{Field: "moveTo", Message: "must be a project ID"})
+		case !tasks.Move:
+			fieldErrors = append(fieldErrors, FieldError{Field: "moveTo", Message: "requires tasks=move"})
+		}
+		tasks.MoveTo = &moveTo
+	}
+	if len(fieldErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid project deletion", fieldErrors...)
+		return
+	}
+
+	if err := h.Projects.DeleteProject(r.Context(), id, tasks); err != nil {
+		writeProjectError(w, r, err, "Failed to delete project")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetProjectTasks lists the tasks of a project, archived or not. It takes
+// the query parameters of GetAllTasks.
+func (h *TaskHandler) GetProjectTasks(w http.ResponseWriter, r *http.Request) {
+	id, ok := projectID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.Projects.GetProject(r.Context(), id); err != nil {
+		writeProjectError(w, r, err, "Failed to list tasks")
+		return
+	}
+	values := r.URL.Query()
+	values.Set("project", strconv.Itoa(id))
+	h.listTasks(w, r, values)
+}
+
+// parseBool parses an optional boolean query parameter; empty is false.
+func parseBool(raw string) (bool, error) {
+	if raw == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(raw)
+}
@@ -0,0 +1,135 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/api/handlers"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeProject(t *testing.T, rr *httptest.ResponseRecorder) model.Project {
+	t.Helper()
+	var project model.Project
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &project))
+	return project
+}
+
+func taskTitles(t *testing.T, rr *httptest.ResponseRecorder) []string {
+	t.Helper()
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var tasks []model.Task
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tasks))
+	titles := []string{}
+	for _, task := range tasks {
+		titles = append(titles, task.Title)
+	}
+	return titles
+}
+
+func TestProjectCRUD(t *testing.T) {
+	router := newRouter()
+
+	rr := serve(router, "POST", "/projects", "", `{"name":"Launch","color":"#1e90ff"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, "/projects/1", rr.Header().Get("Location"))
+	project := decodeProject(t, rr)
+	assert.Equal(t, "Launch", project.Name)
+
+	rr = serve(router, "POST", "/projects", "", `{"name":"Bad","color":"blue"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "color", handlers.DecodeProblem(t, rr).Errors[0].Field)
+
+	rr = serve(router, "PUT", "/projects/1", "", `{"name":"Launch v2","description":"Second try"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	project = decodeProject(t, rr)
+	assert.Equal(t, "Launch v2", project.Name)
+	assert.Empty(t, project.Color, "PUT replaces every field")
+
+	rr = serve(router, "PATCH", "/projects/1", handlers.MergePatchContentType, `{"archived":true,"description":null}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	project = decodeProject(t, rr)
+	assert.True(t, project.Archived)
+	assert.Empty(t, project.Description)
+	assert.Equal(t, "Launch v2", project.Name)
+
+	rr = serve(router, "PATCH", "/projects/1", handlers.MergePatchContentType, `{"id":3,"owner":"bob"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Len(t, handlers.DecodeProblem(t, rr).Errors, 2)
+	assert.Equal(t, http.StatusUnsupportedMediaType, serve(router, "PATCH", "/projects/1", "application/json", `{}`).Code)
+
+	var projects []model.Project
+	rr = serve(router, "GET", "/projects", "", "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &projects))
+	assert.Empty(t, projects, "archived projects are not listed by default")
+	rr = serve(router, "GET", "/projects?includeArchived=true", "", "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &projects))
+	assert.Len(t, projects, 1)
+
+	rr = serve(router, "GET", "/projects/2", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "Project not found", handlers.DecodeProblem(t, rr).Detail)
+}
+
+func TestProjectTasks(t *testing.T) {
+	router := newRouter()
+	serve(router, "POST", "/projects", "", `{"name":"Launch"}`)
+	serve(router, "POST", "/projects", "", `{"name":"Old"}`)
+
+	rr := serve(router, "POST", "/tasks", "", `{"title":"Launch task","projectId":1}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, 1, *handlers.DecodeTask(t, rr).ProjectID)
+	serve(router, "POST", "/tasks", "", `{"title":"Old task","projectId":2}`)
+	serve(router, "POST", "/tasks", "", `{"title":"Loose task"}`)
+
+	rr = serve(router, "POST", "/tasks", "", `{"title":"Lost","projectId":9}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "is not a project of the workspace", handlers.DecodeProblem(t, rr).Errors[0].Message)
+
+	assert.Equal(t, []string{"Launch task"}, taskTitles(t, serve(router, "GET", "/projects/1/tasks", "", "")))
+	assert.Equal(t, []string{"Launch task"}, taskTitles(t, serve(router, "GET", "/tasks?project=1", "", "")))
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/projects/9/tasks", "", "").Code)
+
+	// Archiving hides the tasks of a project from the default listing only.
+	serve(router, "PATCH", "/projects/2", handlers.MergePatchContentType, `{"archived":true}`)
+	assert.Equal(t, []string{"Launch task", "Loose task"}, taskTitles(t, serve(router, "GET", "/tasks", "", "")))
+	assert.Equal(t, []string{"Launch task", "Old task", "Loose task"}, taskTitles(t, serve(router, "GET", "/tasks?includeArchived=true", "", "")))
+	assert.Equal(t, []string{"Old task"}, taskTitles(t, serve(router, "GET", "/projects/2/tasks", "", "")))
+
+	// A merge patch moves a task between projects; null takes it out.
+	rr = serve(router, "PATCH", "/tasks/3", handlers.MergePatchContentType, `{"projectId":1}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, 1, *handlers.DecodeTask(t, rr).ProjectID)
+	rr = serve(router, "PATCH", "/tasks/3", handlers.MergePatchContentType, `{"projectId":null}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Nil(t, handlers.DecodeTask(t, rr).ProjectID)
+	rr = serve(router, "PATCH", "/tasks/3", handlers.MergePatchContentType, `{"projectId":9}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestDeleteProject(t *testing.T) {
+	router := newRouter()
+	serve(router, "POST", "/projects", "", `{"name":"From"}`)
+	serve(router, "POST", "/projects", "", `{"name":"To"}`)
+	serve(router, "POST", "/tasks", "", `{"title":"Task","projectId":1}`)
+
+	rr := serve(router, "DELETE", "/projects/1", "", "")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, handlers.DecodeProblem(t, rr).Detail, "tasks=cascade")
+
+	for _, query := range []string{"?tasks=archive", "?moveTo=2", "?tasks=move&moveTo=x", "?tasks=move&moveTo=1", "?tasks=move&moveTo=9"} {
+		rr := serve(router, "DELETE", "/projects/1"+query, "", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve(router, "DELETE", "/projects/1?tasks=move&moveTo=2", "", "").Code)
+	assert.Equal(t, []string{"Task"}, taskTitles(t, serve(router, "GET", "/projects/2/tasks", "", "")))
+
+	assert.Equal(t, http.StatusNoContent, serve(router, "DELETE", "/projects/2?tasks=cascade", "", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/tasks/1", "", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/projects/2", "", "").Code)
+}
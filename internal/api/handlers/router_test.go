@@ -0,0 +1,34 @@
+package handlers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/DimWebDev/task-manager-tool/internal/api"
+	"github.com/DimWebDev/task-manager-tool/internal/api/handlers"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+)
+
+// newRouter returns the router of api.NewRouter, serving a handler on empty
+// in-memory repositories with projects and tags.
+func newRouter() *mux.Router {
+	tasks := repo.NewMemoryTaskRepo()
+	h := handlers.NewTaskHandler(tasks)
+	h.Projects = repo.NewMemoryProjectRepo(tasks)
+	h.Tags = repo.NewMemoryTagRepo(tasks)
+	return api.NewRouter(h)
+}
+
+// serve sends a request with the given body to router and returns the
+// recorded response.
+func serve(router http.Handler, method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
@@ -0,0 +1,61 @@
+// internal/api/handlers/subtask_handler.go
+// The subtask_handler.go serves the subtasks of a task as a tree. Tasks name
+// their parent themselves; deleting a task with subtasks is DeleteTask's.
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/gorilla/mux"
+)
+
+// GetSubtasks lists the subtasks of a task as trees, each subtask holding
+// its own subtasks. The depth query parameter says how many levels below
+// the task are listed: 1, the default, lists only its direct subtasks and 0
+// lists all of them.
+func (h *TaskHandler) GetSubtasks(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid task ID")
+		return
+	}
+	depth := 1
+	if raw := r.URL.Query().Get("depth"); raw != "" {
+		if depth, err = strconv.Atoi(raw); err != nil || depth < 0 {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid subtask query",
+				FieldError{Field: "depth", Message: "must be a non-negative integer"})
+			return
+		}
+	}
+
+	subtasks, err := h.Repo.Subtasks(r.Context(), id, depth)
+	if err != nil {
+		writeRepoError(w, r, err, "Failed to list subtasks")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, taskTrees(id, subtasks))
+}
+
+// taskTrees nests the subtasks of the task id below their parents, keeping
+// their order.
+func taskTrees(id int, subtasks []model.Task) []model.TaskTree {
+	children := make(map[int][]model.Task)
+	for _, task := range subtasks {
+		if task.ParentID != nil {
+			children[*task.ParentID] = append(children[*task.ParentID], task)
+		}
+	}
+	var grow func(parent int) []model.TaskTree
+	grow = func(parent int) []model.TaskTree {
+		trees := make([]model.TaskTree, 0, len(children[parent]))
+		for _, task := range children[parent] {
+			trees = append(trees, model.TaskTree{Task: task, Subtasks: grow(task.ID)})
+		}
+		return trees
+	}
+	return grow(id)
+}
@@ -0,0 +1,98 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/api/handlers"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeTrees(t *testing.T, rr *httptest.ResponseRecorder) []model.TaskTree {
+	t.Helper()
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var trees []model.TaskTree
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &trees))
+	return trees
+}
+
+func TestSubtasks(t *testing.T) {
+	router := newRouter()
+	serve(router, "POST", "/tasks", "", `{"title":"Release"}`)
+	serve(router, "POST", "/tasks", "", `{"title":"Build","parentId":1}`)
+	serve(router, "POST", "/tasks", "", `{"title":"Ship","parentId":1}`)
+	rr := serve(router, "POST", "/tasks", "", `{"title":"Compile","parentId":2}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(router, "PATCH", "/tasks/2", handlers.MergePatchContentType, `{"status":"Completed"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = serve(router, "POST", "/tasks", "", `{"title":"Orphan","parentId":9}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "parentId", handlers.DecodeProblem(t, rr).Errors[0].Field)
+
+	var task model.Task
+	rr = serve(router, "GET", "/tasks/1", "", "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &task))
+	assert.Equal(t, &model.Progress{Completed: 1, Total: 2}, task.Progress)
+
+	trees := decodeTrees(t, serve(router, "GET", "/tasks/1/subtasks", "", ""))
+	require.Len(t, trees, 2)
+	assert.Equal(t, "Build", trees[0].Title)
+	assert.Empty(t, trees[0].Subtasks)
+	assert.Equal(t, &model.Progress{Total: 1}, trees[0].Progress)
+
+	trees = decodeTrees(t, serve(router, "GET", "/tasks/1/subtasks?depth=0", "", ""))
+	require.Len(t, trees, 2)
+	require.Len(t, trees[0].Subtasks, 1)
+	assert.Equal(t, "Compile", trees[0].Subtasks[0].Title)
+	assert.Empty(t, decodeTrees(t, serve(router, "GET", "/tasks/4/subtasks", "", "")))
+
+	for _, query := range []string{"?depth=-1", "?depth=x"} {
+		assert.Equal(t, http.StatusBadRequest, serve(router, "GET", "/tasks/1/subtasks"+query, "", "").Code, query)
+	}
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/tasks/9/subtasks", "", "").Code)
+
+	// A task cannot move below its own subtasks; null makes it top-level.
+	rr = serve(router, "PATCH", "/tasks/1", handlers.MergePatchContentType, `{"parentId":4}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "parentId", handlers.DecodeProblem(t, rr).Errors[0].Field)
+	rr = serve(router, "PATCH", "/tasks/1", handlers.MergePatchContentType, `{"parentId":0}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serve(router, "PATCH", "/tasks/1", handlers.MergePatchContentType, `{"progress":null}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serve(router, "PATCH", "/tasks/4", handlers.MergePatchContentType, `{"parentId":null}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Len(t, decodeTrees(t, serve(router, "GET", "/tasks/1/subtasks?depth=0", "", "")), 2)
+}
+
+func TestDeleteTaskWithSubtasks(t *testing.T) {
+	router := newRouter()
+	serve(router, "POST", "/tasks", "", `{"title":"Release"}`)
+	serve(router, "POST", "/tasks", "", `{"title":"Build","parentId":1}`)
+	serve(router, "POST", "/tasks", "", `{"title":"Compile","parentId":2}`)
+	serve(router, "POST", "/tasks", "", `{"title":"Other"}`)
+
+	rr := serve(router, "DELETE", "/tasks/2", "", "")
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Contains(t, handlers.DecodeProblem(t, rr).Detail, "subtasks=cascade")
+
+	for _, query := range []string{"?subtasks=keep", "?moveTo=4", "?subtasks=move&moveTo=x", "?subtasks=move&moveTo=2", "?subtasks=move&moveTo=3", "?subtasks=move&moveTo=9"} {
+		rr := serve(router, "DELETE", "/tasks/2"+query, "", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve(router, "DELETE", "/tasks/2?subtasks=move", "", "").Code)
+	trees := decodeTrees(t, serve(router, "GET", "/tasks/1/subtasks", "", ""))
+	require.Len(t, trees, 1)
+	assert.Equal(t, "Compile", trees[0].Title)
+
+	assert.Equal(t, http.StatusNoContent, serve(router, "DELETE", "/tasks/1?subtasks=move&moveTo=4", "", "").Code)
+	assert.Len(t, decodeTrees(t, serve(router, "GET", "/tasks/4/subtasks", "", "")), 1)
+
+	assert.Equal(t, http.StatusNoContent, serve(router, "DELETE", "/tasks/4?subtasks=cascade", "", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/tasks/3", "", "").Code)
+}
@@ -0,0 +1,203 @@
+// internal/api/handlers/tag_handler.go
+// The tag_handler.go serves the tags tasks are labelled with. Tasks name
+// their tags themselves; these endpoints list, create, rename, recolor and
+// delete the tags of a workspace.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+)
+
+// writeTagError sends the problem matching a TagRepository error. Errors
+// that are not about the tag itself are sent like task errors.
+func writeTagError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var validationErr *repo.ValidationError
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "Tag not found")
+	case errors.Is(err, repo.ErrTagExists):
+		writeProblem(w, r, http.StatusConflict, CodeConflict, "Another tag already has this name")
+	case errors.As(err, &validationErr):
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Tag failed validation",
+			FieldError{Field: validationErr.Field, Message: validationErr.Message})
+	default:
+		writeRepoError(w, r, err, fallback)
+	}
+}
+
+// tagID returns the tag ID in the URL, writing a problem when it is
+// malformed.
+func tagID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid tag ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// writeTag sends a tag with the given status.
+func writeTag(w http.ResponseWriter, r *http.Request, status int, tag model.Tag) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encodeJSON(w, r, tag)
+}
+
+// CreateTag creates a tag in the workspace of the request. Tags are also
+// created by naming them on a task; creating one up front sets its color.
+func (h *TaskHandler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	var tag model.Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		writeDecodeError(w, r, err, "Invalid tag format")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := h.Tags.CreateTag(r.Context(), tag)
+	if err != nil {
+		writeTagError(w, r, err, "Failed to create tag")
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(r.URL.Path, "/"), created.ID))
+	writeTag(w, r, http.StatusCreated, created)
+}
+
+// ListTags returns the tags of the workspace, ordered by name.
+func (h *TaskHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.Tags.ListTags(r.Context())
+	if err != nil {
+		writeTagError(w, r, err, "Failed to list tags")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, tags)
+}
+
+// GetTag returns a tag by ID.
+func (h *TaskHandler) GetTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := tagID(w, r)
+	if !ok {
+		return
+	}
+	tag, err := h.Tags.GetTag(r.Context(), id)
+	if err != nil {
+		writeTagError(w, r, err, "Failed to retrieve tag")
+		return
+	}
+	writeTag(w, r, http.StatusOK, tag)
+}
+
+// UpdateTag replaces the name and color of a tag with those of the body.
+// A new name renames the tag on every task it labels.
+func (h *TaskHandler) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := tagID(w, r)
+	if !ok {
+		return
+	}
+	var tag model.Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		writeDecodeError(w, r, err, "Invalid tag format")
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := h.Tags.PatchTag(r.Context(), id, repo.TagPatch{Name: &tag.Name, Color: &tag.Color})
+	if err != nil {
+		writeTagError(w, r, err, "Failed to update tag")
+		return
+	}
+	writeTag(w, r, http.StatusOK, updated)
+}
+
+// PatchTag changes the members of a tag present in a JSON Merge Patch body;
+// null clears the color. Renaming a tag is a patch of "name".
+func (h *TaskHandler) PatchTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := tagID(w, r)
+	if !ok {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != MergePatchContentType {
+		w.Header().Set("Accept-Patch", MergePatchContentType)
+		writeProblem(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMedia,
+			"Content-Type must be "+MergePatchContentType)
+		return
+	}
+	var members map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&members); err != nil || members == nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "Merge patch must be a JSON object")
+		return
+	}
+	defer r.Body.Close()
+
+	patch, fieldErrors := tagPatchFromMembers(members)
+	if len(fieldErrors) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid tag patch", fieldErrors...)
+		return
+	}
+	updated, err := h.Tags.PatchTag(r.Context(), id, patch)
+	if err != nil {
+		writeTagError(w, r, err, "Failed to update tag")
+		return
+	}
+	writeTag(w, r, http.StatusOK, updated)
+}
+
+// tagPatchFromMembers converts the members of a merge patch into a
+// repository patch.
+func tagPatchFromMembers(members map[string]json.RawMessage) (repo.TagPatch, []FieldError) {
+	var patch repo.TagPatch
+	var fieldErrors []FieldError
+	for field, raw := range members {
+		switch field {
+		case "id":
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "cannot be changed"})
+		case "name":
+			var name string
+			if err := json.Unmarshal(raw, &name); err != nil || string(raw) == "null" {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a string"})
+				continue
+			}
+			patch.Name = &name
+		case "color":
+			// null clears the color.
+			var color *string
+			if err := json.Unmarshal(raw, &color); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "must be a string or null"})
+				continue
+			}
+			if color == nil {
+				color = new(string)
+			}
+			patch.Color = color
+		default:
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "is not a tag field"})
+		}
+	}
+	sort.Slice(fieldErrors, func(i, j int) bool { return fieldErrors[i].Field < fieldErrors[j].Field })
+	return patch, fieldErrors
+}
+
+// DeleteTag deletes a tag and removes it from the tasks it labels.
+func (h *TaskHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := tagID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Tags.DeleteTag(r.Context(), id); err != nil {
+		writeTagError(w, r, err, "Failed to delete tag")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/api/handlers"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagCRUD(t *testing.T) {
+	router := newRouter()
+
+	rr := serve(router, "POST", "/tags", "", `{"name":"bug","color":"#ff0000"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, "/tags/1", rr.Header().Get("Location"))
+
+	rr = serve(router, "POST", "/tags", "", `{"name":"bug"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = serve(router, "POST", "/tags", "", `{"name":"Needs Triage"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "name", handlers.DecodeProblem(t, rr).Errors[0].Field)
+
+	rr = serve(router, "PATCH", "/tags/1", handlers.MergePatchContentType, `{"name":"defect","color":null}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var tag model.Tag
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tag))
+	assert.Equal(t, model.Tag{ID: 1, Name: "defect"}, tag)
+
+	rr = serve(router, "PATCH", "/tags/1", handlers.MergePatchContentType, `{"id":2,"label":"x"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Len(t, handlers.DecodeProblem(t, rr).Errors, 2)
+
+	rr = serve(router, "PUT", "/tags/1", "", `{"name":"bug","color":"#00ff00"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tag))
+	assert.Equal(t, model.Tag{ID: 1, Name: "bug", Color: "#00ff00"}, tag)
+
+	serve(router, "POST", "/tags", "", `{"name":"backend"}`)
+	var tags []model.Tag
+	rr = serve(router, "GET", "/tags", "", "")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tags))
+	assert.Equal(t, []model.Tag{{ID: 2, Name: "backend"}, {ID: 1, Name: "bug", Color: "#00ff00"}}, tags)
+
+	rr = serve(router, "PATCH", "/tags/2", handlers.MergePatchContentType, `{"name":"bug"}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "Another tag already has this name", handlers.DecodeProblem(t, rr).Detail)
+
+	assert.Equal(t, http.StatusNoContent, serve(router, "DELETE", "/tags/2", "", "").Code)
+	rr = serve(router, "GET", "/tags/2", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "Tag not found", handlers.DecodeProblem(t, rr).Detail)
+}
+
+func TestTaskTags(t *testing.T) {
+	router := newRouter()
+
+	rr := serve(router, "POST", "/tasks", "", `{"title":"Crash","tags":["bug","infra"]}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, []string{"bug", "infra"}, handlers.DecodeTask(t, rr).Tags)
+	serve(router, "POST", "/tasks", "", `{"title":"Typo","tags":["bug"]}`)
+	serve(router, "POST", "/tasks", "", `{"title":"Deploy","tags":["infra"]}`)
+
+	rr = serve(router, "POST", "/tasks", "", `{"title":"Bad","tags":["Bug"]}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "tags", handlers.DecodeProblem(t, rr).Errors[0].Field)
+
+	assert.Equal(t, []string{"Crash", "Typo", "Deploy"}, taskTitles(t, serve(router, "GET", "/tasks?tag=bug,infra", "", "")))
+	assert.Equal(t, []string{"Crash"}, taskTitles(t, serve(router, "GET", "/tasks?tag=bug&tag=infra&tagMatch=all", "", "")))
+	assert.Equal(t, http.StatusBadRequest, serve(router, "GET", "/tasks?tag=a,,b,Bug", "", "").Code)
+
+	// PUT keeps the tags unless the body has them; a merge patch replaces
+	// them, null removes them all.
+	rr = serve(router, "PUT", "/tasks/2", "", `{"title":"Typo fix"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []string{"bug"}, handlers.DecodeTask(t, rr).Tags)
+	rr = serve(router, "PUT", "/tasks/2", "", `{"title":"Typo fix","tags":["docs"]}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []string{"docs"}, handlers.DecodeTask(t, rr).Tags)
+	rr = serve(router, "PATCH", "/tasks/2", handlers.MergePatchContentType, `{"tags":["docs","bug","docs"]}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []string{"bug", "docs"}, handlers.DecodeTask(t, rr).Tags)
+	rr = serve(router, "PATCH", "/tasks/2", handlers.MergePatchContentType, `{"tags":null}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Nil(t, handlers.DecodeTask(t, rr).Tags)
+	rr = serve(router, "PATCH", "/tasks/2", handlers.MergePatchContentType, `{"tags":"bug"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Tags named on tasks exist as tags; renaming one relabels its tasks.
+	var tags []model.Tag
+	require.NoError(t, json.Unmarshal(serve(router, "GET", "/tags", "", "").Body.Bytes(), &tags))
+	require.Equal(t, []model.Tag{{ID: 1, Name: "bug"}, {ID: 3, Name: "docs"}, {ID: 2, Name: "infra"}}, tags)
+	rr = serve(router, "PATCH", "/tags/2", handlers.MergePatchContentType, `{"name":"ops"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []string{"bug", "ops"}, handlers.DecodeTask(t, serve(router, "GET", "/tasks/1", "", "")).Tags)
+	assert.Equal(t, []string{"Crash", "Deploy"}, taskTitles(t, serve(router, "GET", "/tasks?tag=ops", "", "")))
+}
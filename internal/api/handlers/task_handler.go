@@ -1,15 +1,67 @@
 package handlers
 
 import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
 	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/DimWebDev/task-manager-tool/internal/tracing"
 )
 
 // TaskHandler holds the methods to handle task-related requests. Each of these method is defined inside the specific handler files
 type TaskHandler struct {
-    Repo repo.TaskRepository
+	Repo repo.TaskRepository
+	// Workflow decides which status changes are allowed; nil means
+	// model.DefaultWorkflow.
+	Workflow *model.Workflow
+	// Users records the authenticated users, so that tasks can name who
+	// created them; nil leaves tasks without creator and makes "me"
+	// unusable.
+	Users repo.UserRepository
+	// Workspaces holds the workspaces tasks are kept apart in; nil leaves
+	// only the default workspace.
+	Workspaces repo.WorkspaceRepository
+	// Projects holds the projects tasks are grouped in; nil leaves tasks
+	// without projects, and api.NewRouter then serves no project routes.
+	Projects repo.ProjectRepository
+	// Tags holds the tags of the workspaces; nil makes api.NewRouter serve
+	// no tag routes. Tasks are tagged through Repo either way.
+	Tags repo.TagRepository
 }
 
 // NewTaskHandler creates a new TaskHandler with the given repository
 func NewTaskHandler(r repo.TaskRepository) *TaskHandler {
-    return &TaskHandler{Repo: r}
+	return &TaskHandler{Repo: r, Workflow: model.DefaultWorkflow()}
+}
+
+// priorityMessage explains which priorities are accepted.
+const priorityMessage = "must be one of Low, Medium, High, Critical or null"
+
+// checkPriority reports whether a task's priority is unset or known,
+// writing a validation problem otherwise.
+func checkPriority(w http.ResponseWriter, r *http.Request, p model.Priority) bool {
+	if p == model.PriorityNone || p.Valid() {
+		return true
+	}
+	writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid task priority",
+		FieldError{Field: "priority", Message: priorityMessage})
+	return false
+}
+
+// workflow returns the workflow enforced by the handler.
+func (h *TaskHandler) workflow() *model.Workflow {
+	if h.Workflow == nil {
+		return model.DefaultWorkflow()
+	}
+	return h.Workflow
+}
+
+// encodeJSON writes v as the JSON response body, in a span of its own so
+// that traces tell encoding time apart from storage time. The status line is
+// already sent, so an encoding failure cannot be reported.
+func encodeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	_, span := tracing.Start(r.Context(), "encode JSON", tracing.SpanKindInternal)
+	defer span.End()
+	span.SetError(json.NewEncoder(w).Encode(v))
 }
@@ -1,42 +1,72 @@
 package handlers
 
 import (
+	"context"
 	"testing"
 
 	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
 
 	"github.com/stretchr/testify/mock"
 )
 
+// MockTaskRepository records the calls made by the handlers. The context
+// is not part of the recorded arguments; handler tests that care about it
+// use a real repository instead.
 type MockTaskRepository struct {
     mock.Mock
 }
 
-func (m *MockTaskRepository) GetByID(id int) (model.Task, error) {
+func (m *MockTaskRepository) GetByID(ctx context.Context, id int) (model.Task, error) {
     args := m.Called(id)
     return args.Get(0).(model.Task), args.Error(1)
 }
 
-func (m *MockTaskRepository) Create(task model.Task) error {
+func (m *MockTaskRepository) Create(ctx context.Context, task model.Task) (model.Task, error) {
     args := m.Called(task)
-    return args.Error(0)
+	return args.Get(0).(model.Task), args.Error(1)
 }
 
-func (m *MockTaskRepository) GetAll() ([]model.Task, error) {
+func (m *MockTaskRepository) GetAll(ctx context.Context) ([]model.Task, error) {
     args := m.Called()
     return args.Get(0).([]model.Task), args.Error(1)
 }
 
-func (m *MockTaskRepository) Update(task model.Task) error {
+func (m *MockTaskRepository) List(ctx context.Context, q repo.TaskQuery) (repo.TaskPage, error) {
+	args := m.Called(q)
+	return args.Get(0).(repo.TaskPage), args.Error(1)
+}
+
+func (m *MockTaskRepository) Update(ctx context.Context, task model.Task) (model.Task, error) {
     args := m.Called(task)
-    return args.Error(0)
+	return args.Get(0).(model.Task), args.Error(1)
 }
 
-func (m *MockTaskRepository) Delete(id int) error {
-    args := m.Called(id)
+func (m *MockTaskRepository) Patch(ctx context.Context, id int, patch repo.TaskPatch) (model.Task, error) {
+	args := m.Called(id, patch)
+	return args.Get(0).(model.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) Delete(ctx context.Context, id int, version int, subtasks repo.TaskSubtasks) error {
+	args := m.Called(id, version, subtasks)
     return args.Error(0)
 }
 
+func (m *MockTaskRepository) Subtasks(ctx context.Context, id, depth int) ([]model.Task, error) {
+	args := m.Called(id, depth)
+	return args.Get(0).([]model.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) AddWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	args := m.Called(id, userID, version)
+	return args.Get(0).(model.Task), args.Error(1)
+}
+
+func (m *MockTaskRepository) RemoveWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	args := m.Called(id, userID, version)
+	return args.Get(0).(model.Task), args.Error(1)
+}
+
 func TestNewTaskHandler(t *testing.T) {
     // Instantiate the mock repository
     mockRepo := new(MockTaskRepository)
@@ -2,7 +2,6 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -11,41 +10,77 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// UpdateTask updates an existing task.
+// UpdateTask updates an existing task. The write is conditional when the
+// request carries an If-Match header, or otherwise a non-zero version in the
+// body, so concurrent edits cannot silently overwrite each other. A status
+// change must follow the workflow; without any version from the client, the
+// write is conditional on the version the change was checked against.
+// The creator, assignee, project, parent and watchers of the task are not
+// replaced: they are changed with PatchTask and the assignee and watcher
+// endpoints.
+// Its tags are replaced when the body has "tags" and kept otherwise.
 func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	// Get the task ID from the URL.
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid task ID")
 		return
 	}
 
 	// Decode the request body into a Task struct.
 	var task model.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeDecodeError(w, r, err, "Invalid request body")
 		return
 	}
 	defer r.Body.Close()
 
 	// Set the task ID from the URL.
 	task.ID = id
+	if !checkPriority(w, r, task.Priority) {
+		return
+	}
+
+	// If-Match takes precedence over the version sent in the body.
+	version, ifMatch, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Internal server error")
+		return
+	}
+	if ifMatch {
+		task.Version = version
+	}
+
+	// Check the status change against the stored task.
+	current, ok := h.currentTask(w, r, id, version, ifMatch)
+	if !ok {
+		return
+	}
+	if task.Status == "" {
+		task.Status = current.Status
+	}
+	if task.Status != current.Status && !h.checkStatus(w, r, task.Status) {
+		return
+	}
+	if !h.checkTransition(w, r, current.Status, task.Status) {
+		return
+	}
+	if task.Version == 0 {
+		task.Version = current.Version
+	}
 
 	// Call the Update method on the repo.
-	if err := h.Repo.Update(task); err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Task not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+	updated, err := h.Repo.Update(r.Context(), task)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Internal server error")
 		return
 	}
+	task = updated
 
 	// If successful, encode and return the updated task.
+	w.Header().Set("ETag", taskETag(task.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(task); err != nil {
-		http.Error(w, "Error encoding response object", http.StatusInternalServerError)
-	}
+	encodeJSON(w, r, task)
 }
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"bytes"
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
@@ -26,7 +26,16 @@ func TestUpdateTaskHandler_Success(t *testing.T) {
 		Description: "Updated Description",
 	}
 
-	mockRepo.On("Update", task).Return(nil)
+	// Without a version from the client, the update is conditional on the
+	// version the status change was checked against
+	current := task
+	current.Title, current.Version = "Task", 1
+	expected := task
+	expected.Version = 1
+	stored := task
+	stored.Version = 2
+	mockRepo.On("GetByID", taskID).Return(current, nil)
+	mockRepo.On("Update", expected).Return(stored, nil)
 
 	taskJSON, _ := json.Marshal(task)
 	req, _ := http.NewRequest("PUT", "/tasks/"+strconv.Itoa(taskID), bytes.NewBuffer(taskJSON))
@@ -51,6 +60,8 @@ func TestUpdateTaskHandler_Success(t *testing.T) {
 	assert.Equal(t, taskID, updatedTask.ID, "handler returned incorrect ID")
 	assert.Equal(t, task.Title, updatedTask.Title, "handler returned incorrect Title")
 	assert.Equal(t, task.Description, updatedTask.Description, "handler returned incorrect Description")
+	assert.Equal(t, 2, updatedTask.Version, "handler should return the new version")
+	assert.Equal(t, `"2"`, rr.Header().Get("ETag"), "handler should return the ETag of the new version")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -109,7 +120,7 @@ func TestUpdateTaskHandler_FailNotFound(t *testing.T) {
 		Description: "Updated Description",
 	}
 
-	mockRepo.On("Update", task).Return(sql.ErrNoRows) // Simulate task not found
+	mockRepo.On("GetByID", taskID).Return(model.Task{}, repo.ErrNotFound) // Simulate task not found
 
 	taskJSON, _ := json.Marshal(task)
 	req, _ := http.NewRequest("PUT", "/tasks/"+strconv.Itoa(taskID), bytes.NewBuffer(taskJSON))
@@ -126,4 +137,5 @@ func TestUpdateTaskHandler_FailNotFound(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, rr.Code, "should return 404 when task not found")
 
 	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "Update")
 }
@@ -0,0 +1,292 @@
+// internal/api/handlers/user_handler.go
+// The user_handler.go ties tasks to users: it resolves the authenticated
+// principal into a user, lists users, and assigns and watches tasks.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+)
+
+// me stands for the authenticated user wherever a user ID is expected in a
+// URL, e.g. GET /tasks?assignee=me.
+const me = "me"
+
+// errNoUser reports that a request refers to the authenticated user, but
+// was not authenticated or users are not tracked.
+var errNoUser = errors.New("the request has no authenticated user")
+
+// currentUser returns the user the request was authenticated as, recording
+// it on its first request. ok is false when there is none: authentication
+// is disabled, or the handler has no user repository.
+func (h *TaskHandler) currentUser(r *http.Request) (user model.User, ok bool, err error) {
+	p, authenticated := auth.PrincipalFromContext(r.Context())
+	if !authenticated || h.Users == nil {
+		return model.User{}, false, nil
+	}
+	user, err = h.Users.EnsureUser(r.Context(), p.UserSubject())
+	return user, err == nil, err
+}
+
+// resolveUser turns a user reference from a URL, a user ID or "me", into a
+// user ID. It fails with errNoUser for "me" without an authenticated user,
+// and with a ValidationError for the field when raw is neither.
+func (h *TaskHandler) resolveUser(r *http.Request, field, raw string) (int, error) {
+	if raw == me {
+		user, ok, err := h.currentUser(r)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, errNoUser
+		}
+		return user.ID, nil
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 1 {
+		return 0, &repo.ValidationError{Field: field, Message: `must be a user ID or "me"`}
+	}
+	return id, nil
+}
+
+// writeUserError sends the problem for a failed resolveUser or a failed
+// lookup of the current user.
+func writeUserError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var validationErr *repo.ValidationError
+	switch {
+	case errors.Is(err, errNoUser):
+		writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "This request needs an authenticated user")
+	case errors.As(err, &validationErr):
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid user",
+			FieldError{Field: validationErr.Field, Message: validationErr.Message})
+	default:
+		writeRepoError(w, r, err, fallback)
+	}
+}
+
+// checkAssignee reports whether id, when set, names a known user who may
+// use the workspace of the request, writing a validation problem otherwise.
+// Without a user repository any ID is accepted.
+func (h *TaskHandler) checkAssignee(w http.ResponseWriter, r *http.Request, field string, id *int) bool {
+	if id == nil || h.Users == nil {
+		return true
+	}
+	_, err := h.Users.GetUser(r.Context(), *id)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Unknown assignee",
+			FieldError{Field: field, Message: "is not a known user"})
+		return false
+	}
+	if err != nil {
+		writeRepoError(w, r, err, "Failed to look up the assignee")
+		return false
+	}
+	member, err := h.isWorkspaceMember(r, *id)
+	if err != nil {
+		writeRepoError(w, r, err, "Failed to look up the assignee")
+		return false
+	}
+	if !member {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Unknown assignee",
+			FieldError{Field: field, Message: "is not a member of the workspace"})
+		return false
+	}
+	return true
+}
+
+// GetCurrentUser returns the user the request was authenticated as.
+func (h *TaskHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user, ok, err := h.currentUser(r)
+	if err == nil && !ok {
+		err = errNoUser
+	}
+	if err != nil {
+		writeUserError(w, r, err, "Failed to retrieve user")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, user)
+}
+
+// GetUser returns a user by ID.
+func (h *TaskHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid user ID")
+		return
+	}
+	if h.Users == nil {
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "User not found")
+		return
+	}
+	user, err := h.Users.GetUser(r.Context(), id)
+	if errors.Is(err, repo.ErrNotFound) {
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		writeRepoError(w, r, err, "Failed to retrieve user")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, user)
+}
+
+// ListUsers returns every user, ordered by ID.
+func (h *TaskHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users := []model.User{}
+	if h.Users != nil {
+		list, err := h.Users.ListUsers(r.Context())
+		if err != nil {
+			writeRepoError(w, r, err, "Failed to list users")
+			return
+		}
+		users = append(users, list...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, users)
+}
+
+// GetMyTasks lists the tasks assigned to the authenticated user. It takes
+// the query parameters of GetAllTasks.
+func (h *TaskHandler) GetMyTasks(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	values.Set("assignee", me)
+	h.listTasks(w, r, values)
+}
+
+// assignment is the body of AssignTask.
+type assignment struct {
+	UserID *int `json:"userId"`
+}
+
+// AssignTask assigns a task to the user named in the body, replacing its
+// previous assignee. Like UpdateTask, it honours If-Match.
+func (h *TaskHandler) AssignTask(w http.ResponseWriter, r *http.Request) {
+	var body assignment
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeDecodeError(w, r, err, "Invalid assignment")
+		return
+	}
+	defer r.Body.Close()
+	if body.UserID == nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidation, "User ID is required",
+			FieldError{Field: "userId", Message: "is required"})
+		return
+	}
+	if !h.checkAssignee(w, r, "userId", body.UserID) {
+		return
+	}
+	h.setAssignee(w, r, body.UserID)
+}
+
+// UnassignTask removes the assignee of a task. Like UpdateTask, it honours
+// If-Match.
+func (h *TaskHandler) UnassignTask(w http.ResponseWriter, r *http.Request) {
+	h.setAssignee(w, r, nil)
+}
+
+// setAssignee patches the assignee of the task named in the URL.
+func (h *TaskHandler) setAssignee(w http.ResponseWriter, r *http.Request, assigneeID *int) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid task ID")
+		return
+	}
+	version, ifMatch, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to update task")
+		return
+	}
+
+	task, err := h.Repo.Patch(r.Context(), id, repo.TaskPatch{SetAssignee: true, AssigneeID: assigneeID, Version: version})
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to update task")
+		return
+	}
+	writeTask(w, r, task)
+}
+
+// WatchTask makes the user in the URL, a user ID or "me", watch a task.
+// Like UpdateTask, it honours If-Match.
+func (h *TaskHandler) WatchTask(w http.ResponseWriter, r *http.Request) {
+	h.setWatcher(w, r, h.Repo.AddWatcher)
+}
+
+// UnwatchTask stops the user in the URL, a user ID or "me", from watching a
+// task. Like UpdateTask, it honours If-Match.
+func (h *TaskHandler) UnwatchTask(w http.ResponseWriter, r *http.Request) {
+	h.setWatcher(w, r, h.Repo.RemoveWatcher)
+}
+
+// watcherChange is TaskRepository.AddWatcher or RemoveWatcher.
+type watcherChange func(ctx context.Context, id, userID, version int) (model.Task, error)
+
+// setWatcher applies change to the task and user named in the URL.
+func (h *TaskHandler) setWatcher(w http.ResponseWriter, r *http.Request, change watcherChange) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid task ID")
+		return
+	}
+	userID, err := h.resolveUser(r, "user", vars["user"])
+	if err != nil {
+		writeUserError(w, r, err, "Failed to update task")
+		return
+	}
+	if vars["user"] != me && !h.checkAssignee(w, r, "user", &userID) {
+		return
+	}
+	version, ifMatch, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to update task")
+		return
+	}
+
+	task, err := change(r.Context(), id, userID, version)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to update task")
+		return
+	}
+	writeTask(w, r, task)
+}
+
+// writeTask sends a task written by the request, with its new ETag.
+func writeTask(w http.ResponseWriter, r *http.Request, task model.Task) {
+	w.Header().Set("ETag", taskETag(task.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, task)
+}
+
+// resolveMe sets the user filters of q that are "me" in the query string
+// to the authenticated user; parseTaskQuery handles the others.
+func (h *TaskHandler) resolveMe(r *http.Request, values url.Values, q *repo.TaskQuery) error {
+	for _, f := range userFilters(q) {
+		if values.Get(f.name) != me {
+			continue
+		}
+		user, ok, err := h.currentUser(r)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errNoUser
+		}
+		*f.dst = user.ID
+	}
+	return nil
+}
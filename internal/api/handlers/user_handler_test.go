@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/policy"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUserHandler returns a handler on empty in-memory repositories.
+func newUserHandler() *TaskHandler {
+	h := NewTaskHandler(repo.NewMemoryTaskRepo())
+	h.Users = repo.NewMemoryUserRepo()
+	return h
+}
+
+// inDefault confines the calls of the tests to the default workspace.
+var inDefault = repo.WithWorkspace(context.Background(), model.DefaultWorkspaceID)
+
+// newRequest returns a request to a task route of the default workspace, as
+// InWorkspace passes it on.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body).WithContext(inDefault)
+}
+
+// as returns r authenticated as subject.
+func as(r *http.Request, subject string) *http.Request {
+	return r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Subject: subject, Method: auth.MethodAPIKey}))
+}
+
+func decodeTask(t *testing.T, rr *httptest.ResponseRecorder) model.Task {
+	t.Helper()
+	var task model.Task
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &task))
+	return task
+}
+
+func TestCreateTask_RecordsCreator(t *testing.T) {
+	h := newUserHandler()
+
+	rr := httptest.NewRecorder()
+	h.CreateTaskHandler(rr, as(newRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Task","createdBy":42,"watchers":[42]}`)), "alice"))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	created := decodeTask(t, rr)
+	alice, err := h.Users.EnsureUser(inDefault, auth.APIKeyUser("alice"))
+	require.NoError(t, err)
+	assert.Equal(t, &alice.ID, created.CreatedBy, "the creator is the authenticated user")
+	assert.Nil(t, created.Watchers)
+
+	// Without authentication the task has no creator.
+	rr = httptest.NewRecorder()
+	h.CreateTaskHandler(rr, newRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Task"}`)))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Nil(t, decodeTask(t, rr).CreatedBy)
+
+	rr = httptest.NewRecorder()
+	h.CreateTaskHandler(rr, newRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Task","assigneeId":99}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "assigneeId", decodeProblem(t, rr).Errors[0].Field)
+}
+
+func TestAssignTask(t *testing.T) {
+	h := newUserHandler()
+	bob, _ := h.Users.EnsureUser(inDefault, auth.APIKeyUser("bob"))
+	task, _ := h.Repo.Create(inDefault, model.Task{Title: "Task"})
+	vars := map[string]string{"id": "1"}
+
+	rr := httptest.NewRecorder()
+	h.AssignTask(rr, mux.SetURLVars(newRequest("PUT", "/tasks/1/assignee", bytes.NewBufferString(`{"userId":99}`)), vars))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "userId", decodeProblem(t, rr).Errors[0].Field)
+
+	req := newRequest("PUT", "/tasks/1/assignee", bytes.NewBufferString(`{"userId":1}`))
+	req.Header.Set("If-Match", taskETag(task.Version))
+	rr = httptest.NewRecorder()
+	h.AssignTask(rr, mux.SetURLVars(req, vars))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, &bob.ID, decodeTask(t, rr).AssigneeID)
+	assert.Equal(t, taskETag(2), rr.Header().Get("ETag"))
+
+	// The version the client saw is gone.
+	rr = httptest.NewRecorder()
+	h.UnassignTask(rr, mux.SetURLVars(req, vars))
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.UnassignTask(rr, mux.SetURLVars(newRequest("DELETE", "/tasks/1/assignee", nil), vars))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, decodeTask(t, rr).AssigneeID)
+
+	// PATCH can assign too.
+	req = newRequest("PATCH", "/tasks/1", bytes.NewBufferString(`{"assigneeId":1}`))
+	req.Header.Set("Content-Type", MergePatchContentType)
+	rr = httptest.NewRecorder()
+	h.PatchTask(rr, mux.SetURLVars(req, vars))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, &bob.ID, decodeTask(t, rr).AssigneeID)
+}
+
+func TestWatchTask(t *testing.T) {
+	h := newUserHandler()
+	h.Repo.Create(inDefault, model.Task{Title: "Task"})
+	bob, _ := h.Users.EnsureUser(inDefault, auth.APIKeyUser("bob"))
+	watch := func(method, user string, authenticated bool) *httptest.ResponseRecorder {
+		req := newRequest(method, "/tasks/1/watchers/"+user, nil)
+		if authenticated {
+			req = as(req, "alice")
+		}
+		rr := httptest.NewRecorder()
+		handler := h.WatchTask
+		if method == http.MethodDelete {
+			handler = h.UnwatchTask
+		}
+		handler(rr, mux.SetURLVars(req, map[string]string{"id": "1", "user": user}))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, watch("PUT", "me", false).Code)
+	assert.Equal(t, http.StatusBadRequest, watch("PUT", "99", true).Code)
+	assert.Equal(t, http.StatusBadRequest, watch("PUT", "someone", true).Code)
+
+	rr := watch("PUT", "me", true)
+	require.Equal(t, http.StatusOK, rr.Code)
+	alice, _ := h.Users.EnsureUser(inDefault, auth.APIKeyUser("alice"))
+	assert.Equal(t, []int{alice.ID}, decodeTask(t, rr).Watchers)
+
+	rr = watch("PUT", "1", false)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []int{bob.ID, alice.ID}, decodeTask(t, rr).Watchers)
+
+	rr = watch("DELETE", "me", true)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []int{bob.ID}, decodeTask(t, rr).Watchers)
+}
+
+func TestListTasks_ByUser(t *testing.T) {
+	h := newUserHandler()
+	alice, _ := h.Users.EnsureUser(inDefault, auth.APIKeyUser("alice"))
+	bob, _ := h.Users.EnsureUser(inDefault, auth.APIKeyUser("bob"))
+	h.Repo.Create(inDefault, model.Task{Title: "For alice", CreatedBy: &bob.ID, AssigneeID: &alice.ID})
+	h.Repo.Create(inDefault, model.Task{Title: "For bob", CreatedBy: &alice.ID, AssigneeID: &bob.ID})
+
+	list := func(r *http.Request, handler http.HandlerFunc) ([]model.Task, *httptest.ResponseRecorder) {
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		var tasks []model.Task
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tasks))
+		}
+		return tasks, rr
+	}
+
+	tasks, rr := list(as(newRequest("GET", "/users/me/tasks", nil), "alice"), h.GetMyTasks)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "For alice", tasks[0].Title)
+
+	tasks, rr = list(as(newRequest("GET", "/tasks?createdBy=me", nil), "alice"), h.GetAllTasks)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "For bob", tasks[0].Title)
+
+	tasks, rr = list(newRequest("GET", "/tasks?assignee=2", nil), h.GetAllTasks)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "For bob", tasks[0].Title)
+
+	_, rr = list(newRequest("GET", "/users/me/tasks", nil), h.GetMyTasks)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, CodeUnauthorized, decodeProblem(t, rr).Code)
+
+	_, rr = list(newRequest("GET", "/tasks?watcher=nobody", nil), h.GetAllTasks)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "watcher", decodeProblem(t, rr).Errors[0].Field)
+}
+
+func TestGetCurrentUser(t *testing.T) {
+	h := newUserHandler()
+
+	rr := httptest.NewRecorder()
+	h.GetCurrentUser(rr, as(newRequest("GET", "/users/me", nil), "alice"))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var user model.User
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &user))
+	assert.Equal(t, "apikey:alice", user.Subject)
+
+	rr = httptest.NewRecorder()
+	h.GetUser(rr, mux.SetURLVars(newRequest("GET", "/users/2", nil), map[string]string{"id": "2"}))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.ListUsers(rr, newRequest("GET", "/users", nil))
+	var users []model.User
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &users))
+	assert.Equal(t, []model.User{user}, users)
+}
+
+func TestCurrentUser_TokenDoesNotActAsAPIKeyUser(t *testing.T) {
+	h := newUserHandler()
+	key, _, err := h.currentUser(as(newRequest("GET", "/users/me", nil), "alice"))
+	require.NoError(t, err)
+
+	// A token whose sub claim names the subject of the API key is another user.
+	token := auth.Principal{Subject: "alice", Method: auth.MethodJWT, Issuer: "https://issuer.example"}
+	r := newRequest("GET", "/users/me", nil)
+	user, _, err := h.currentUser(r.WithContext(auth.WithPrincipal(r.Context(), token)))
+	require.NoError(t, err)
+	assert.NotEqual(t, key.ID, user.ID)
+	assert.Equal(t, "jwt:https://issuer.example|alice", user.Subject)
+}
+
+func TestPolicyDenialIsForbidden(t *testing.T) {
+	h := newUserHandler()
+	h.Repo = policy.Enforce(h.Repo, h.Users)
+	viewer, _ := h.Users.EnsureUser(inDefault, auth.APIKeyUser("viewer"))
+	h.Users.SetRole(inDefault, viewer.ID, model.RoleViewer)
+	h.Repo.Create(inDefault, model.Task{Title: "Task"})
+	vars := map[string]string{"id": "1"}
+
+	rr := httptest.NewRecorder()
+	h.DeleteTask(rr, mux.SetURLVars(as(newRequest("DELETE", "/tasks/1", nil), "viewer"), vars))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	problem := decodeProblem(t, rr)
+	assert.Equal(t, CodeForbidden, problem.Code)
+	assert.Equal(t, "Viewers cannot delete tasks", problem.Detail)
+
+	rr = httptest.NewRecorder()
+	h.CreateTaskHandler(rr, as(newRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Task"}`)), "viewer"))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.GetTaskByID(rr, mux.SetURLVars(as(newRequest("GET", "/tasks/1", nil), "viewer"), vars))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// A member may change the task once it is assigned to them.
+	req := newRequest("PATCH", "/tasks/1", bytes.NewBufferString(`{"title":"Changed"}`))
+	req.Header.Set("Content-Type", MergePatchContentType)
+	rr = httptest.NewRecorder()
+	h.PatchTask(rr, mux.SetURLVars(as(req, "member"), vars))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	member, _ := h.Users.EnsureUser(inDefault, auth.APIKeyUser("member"))
+	h.Repo.Patch(inDefault, 1, repo.TaskPatch{SetAssignee: true, AssigneeID: &member.ID})
+	req = newRequest("PATCH", "/tasks/1", bytes.NewBufferString(`{"title":"Changed"}`))
+	req.Header.Set("Content-Type", MergePatchContentType)
+	rr = httptest.NewRecorder()
+	h.PatchTask(rr, mux.SetURLVars(as(req, "member"), vars))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
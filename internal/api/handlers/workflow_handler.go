@@ -0,0 +1,120 @@
+// internal/api/handlers/workflow_handler.go
+// The workflow_handler.go exposes the task status workflow and enforces it
+// on writes: new tasks start in an initial status, status edits must follow
+// a transition, and explicit transitions such as "reopen" are performed by
+// name.
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+)
+
+// GetWorkflow returns the statuses and transitions of the task workflow, so
+// clients can offer only the actions that are valid for a task.
+func (h *TaskHandler) GetWorkflow(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, h.workflow())
+}
+
+// TransitionTask performs the named workflow transition on a task. Like
+// UpdateTask, it honours If-Match; without it the transition is applied
+// conditionally on the version it was checked against.
+func (h *TaskHandler) TransitionTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid task ID")
+		return
+	}
+	transition, ok := h.workflow().Find(vars["transition"])
+	if !ok {
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("Unknown transition %q", vars["transition"]))
+		return
+	}
+
+	version, ifMatch, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to update task")
+		return
+	}
+	current, ok := h.currentTask(w, r, id, version, ifMatch)
+	if !ok {
+		return
+	}
+	if !transition.CanApply(current.Status) {
+		writeProblem(w, r, http.StatusConflict, CodeInvalidTransition,
+			fmt.Sprintf("Transition %q cannot be applied to a task that is %s", transition.Name, current.Status))
+		return
+	}
+
+	task, err := h.Repo.Patch(r.Context(), id, repo.TaskPatch{Status: &transition.To, Version: current.Version})
+	if err != nil {
+		writeConditionalError(w, r, err, ifMatch, "Failed to update task")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(task.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, task)
+}
+
+// currentTask loads the task a conditional write is checked against. It
+// writes the error response and returns false when the task cannot be
+// loaded or is not at the version required by If-Match.
+func (h *TaskHandler) currentTask(w http.ResponseWriter, r *http.Request, id, version int, ifMatch bool) (model.Task, bool) {
+	current, err := h.Repo.GetByID(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, r, err, "Failed to retrieve task")
+		return model.Task{}, false
+	}
+	if ifMatch && version > 0 && current.Version != version {
+		writeConditionalError(w, r, errPreconditionFailed, ifMatch, "Failed to update task")
+		return model.Task{}, false
+	}
+	return current, true
+}
+
+// checkStatus reports whether status is part of the workflow, writing a
+// validation problem otherwise.
+func (h *TaskHandler) checkStatus(w http.ResponseWriter, r *http.Request, status model.Status) bool {
+	wf := h.workflow()
+	if wf.Has(status) {
+		return true
+	}
+	writeProblem(w, r, http.StatusBadRequest, CodeValidation, "Invalid task status",
+		FieldError{Field: "status", Message: "must be one of " + joinStatuses(wf.Statuses)})
+	return false
+}
+
+// checkTransition reports whether a status edit from one status to another
+// is allowed, writing a 409 problem otherwise.
+func (h *TaskHandler) checkTransition(w http.ResponseWriter, r *http.Request, from, to model.Status) bool {
+	wf := h.workflow()
+	if wf.Allows(from, to) {
+		return true
+	}
+	detail := fmt.Sprintf("A task cannot move from %s to %s", from, to)
+	if available := wf.Available(from, to); len(available) > 0 {
+		detail += fmt.Sprintf("; use the %q transition instead", available[0].Name)
+	}
+	writeProblem(w, r, http.StatusConflict, CodeInvalidTransition, detail)
+	return false
+}
+
+// joinStatuses lists statuses for use in an error message.
+func joinStatuses(statuses []model.Status) string {
+	names := make([]string, len(statuses))
+	for i, s := range statuses {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
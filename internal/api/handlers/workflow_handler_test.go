@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetWorkflow(t *testing.T) {
+	handler := NewTaskHandler(new(MockTaskRepository))
+
+	rr := httptest.NewRecorder()
+	handler.GetWorkflow(rr, httptest.NewRequest("GET", "/workflow", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var returned model.Workflow
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &returned))
+	assert.Equal(t, *model.DefaultWorkflow(), returned)
+}
+
+func TestCreateTask_Workflow(t *testing.T) {
+	t.Run("defaults to the initial status", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		expected := model.Task{Title: "Task", Status: model.StatusPending}
+		mockRepo.On("Create", expected).Return(model.Task{ID: 1, Title: "Task", Status: model.StatusPending, Version: 1}, nil)
+
+		rr := httptest.NewRecorder()
+		handler.CreateTaskHandler(rr, httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Task"}`)))
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("rejects a non-initial status", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		rr := httptest.NewRecorder()
+		handler.CreateTaskHandler(rr, httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Task","status":"done"}`)))
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, CodeInvalidTransition, decodeProblem(t, rr).Code)
+		mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+
+	t.Run("rejects an unknown status", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		rr := httptest.NewRecorder()
+		handler.CreateTaskHandler(rr, httptest.NewRequest("POST", "/tasks", bytes.NewBufferString(`{"title":"Task","status":"Blocked"}`)))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "status", decodeProblem(t, rr).Errors[0].Field)
+	})
+}
+
+func TestUpdateTask_IllegalTransition(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Status: model.StatusCompleted, Version: 4}, nil)
+
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/tasks/1", bytes.NewBufferString(`{"title":"Task","status":"Pending"}`)), map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handler.UpdateTask(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	problem := decodeProblem(t, rr)
+	assert.Equal(t, CodeInvalidTransition, problem.Code)
+	assert.Contains(t, problem.Detail, `"reopen"`)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestPatchTask_IllegalTransition(t *testing.T) {
+	mockRepo := new(MockTaskRepository)
+	handler := NewTaskHandler(mockRepo)
+
+	mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Status: model.StatusCancelled, Version: 2}, nil)
+
+	rr := httptest.NewRecorder()
+	handler.PatchTask(rr, newPatchRequest("1", MergePatchContentType, `{"status":"In Progress"}`))
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, CodeInvalidTransition, decodeProblem(t, rr).Code)
+	mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything)
+}
+
+func newTransitionRequest(id, transition string) *http.Request {
+	req := httptest.NewRequest("POST", "/tasks/"+id+"/transitions/"+transition, nil)
+	return mux.SetURLVars(req, map[string]string{"id": id, "transition": transition})
+}
+
+func TestTransitionTask(t *testing.T) {
+	t.Run("reopen", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		pending := model.StatusPending
+		mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Status: model.StatusCompleted, Version: 4}, nil)
+		mockRepo.On("Patch", 1, repo.TaskPatch{Status: &pending, Version: 4}).
+			Return(model.Task{ID: 1, Title: "Task", Status: pending, Version: 5}, nil)
+
+		rr := httptest.NewRecorder()
+		handler.TransitionTask(rr, newTransitionRequest("1", "reopen"))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"5"`, rr.Header().Get("ETag"))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("not applicable", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		mockRepo.On("GetByID", 1).Return(model.Task{ID: 1, Title: "Task", Status: model.StatusPending, Version: 1}, nil)
+
+		rr := httptest.NewRecorder()
+		handler.TransitionTask(rr, newTransitionRequest("1", "reopen"))
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, CodeInvalidTransition, decodeProblem(t, rr).Code)
+		mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown transition", func(t *testing.T) {
+		mockRepo := new(MockTaskRepository)
+		handler := NewTaskHandler(mockRepo)
+
+		rr := httptest.NewRecorder()
+		handler.TransitionTask(rr, newTransitionRequest("1", "archive"))
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		mockRepo.AssertNotCalled(t, "GetByID", mock.Anything)
+	})
+}
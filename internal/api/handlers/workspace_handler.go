@@ -0,0 +1,99 @@
+// internal/api/handlers/workspace_handler.go
+// The workspace_handler.go confines task requests to the workspace named in
+// their URL and lists the workspaces a user may use.
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+)
+
+// errNotMember reports that the user may not use the workspace.
+var errNotMember = errors.New("not a member of the workspace")
+
+// InWorkspace confines next to the workspace named by the "ws" URL
+// variable, or to the default workspace for routes without one. Requests
+// for a workspace that does not exist, or that the authenticated user is
+// not a member of, fail with 404 Not Found, so that workspaces cannot be
+// probed. Without authentication every workspace may be used.
+func (h *TaskHandler) InWorkspace(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := h.workspace(r)
+		if errors.Is(err, repo.ErrNotFound) || errors.Is(err, errNotMember) {
+			writeProblem(w, r, http.StatusNotFound, CodeNotFound, "Workspace not found")
+			return
+		}
+		if err != nil {
+			writeRepoError(w, r, err, "Failed to look up the workspace")
+			return
+		}
+		next(w, r.WithContext(repo.WithWorkspace(r.Context(), ws.ID)))
+	}
+}
+
+// workspace returns the workspace the request names, once the current user
+// is known to be allowed to use it. Without a workspace repository only the
+// default workspace exists.
+func (h *TaskHandler) workspace(r *http.Request) (model.Workspace, error) {
+	slug := mux.Vars(r)["ws"]
+	if slug == "" {
+		slug = model.DefaultWorkspace
+	}
+	if h.Workspaces == nil {
+		if slug != model.DefaultWorkspace {
+			return model.Workspace{}, repo.ErrNotFound
+		}
+		return model.Workspace{ID: model.DefaultWorkspaceID, Slug: slug}, nil
+	}
+	ws, err := h.Workspaces.GetWorkspace(r.Context(), slug)
+	if err != nil || ws.Slug == model.DefaultWorkspace {
+		return ws, err
+	}
+	user, ok, err := h.currentUser(r)
+	if err != nil || !ok {
+		return ws, err
+	}
+	member, err := h.Workspaces.IsMember(r.Context(), ws.ID, user.ID)
+	if err == nil && !member {
+		err = errNotMember
+	}
+	return ws, err
+}
+
+// isWorkspaceMember reports whether the user may use the workspace the
+// request is confined to: everyone may use the default workspace.
+func (h *TaskHandler) isWorkspaceMember(r *http.Request, userID int) (bool, error) {
+	wsID, ok := repo.WorkspaceFromContext(r.Context())
+	if !ok || wsID == model.DefaultWorkspaceID || h.Workspaces == nil {
+		return true, nil
+	}
+	return h.Workspaces.IsMember(r.Context(), wsID, userID)
+}
+
+// ListWorkspaces returns the workspaces the authenticated user may use,
+// ordered by ID; without authentication it returns every workspace.
+func (h *TaskHandler) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	workspaces := []model.Workspace{}
+	if h.Workspaces == nil {
+		workspaces = append(workspaces, model.Workspace{ID: model.DefaultWorkspaceID, Slug: model.DefaultWorkspace})
+	} else {
+		user, _, err := h.currentUser(r)
+		if err != nil {
+			writeRepoError(w, r, err, "Failed to list workspaces")
+			return
+		}
+		list, err := h.Workspaces.ListWorkspaces(r.Context(), user.ID)
+		if err != nil {
+			writeRepoError(w, r, err, "Failed to list workspaces")
+			return
+		}
+		workspaces = append(workspaces, list...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encodeJSON(w, r, workspaces)
+}
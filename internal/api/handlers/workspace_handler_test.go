@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWorkspaceRouter serves a few task routes of h in the default workspace
+// and below /workspaces/{ws}, like api.NewRouter.
+func newWorkspaceRouter(h *TaskHandler) *mux.Router {
+	router := mux.NewRouter()
+	for _, prefix := range []string{"", "/workspaces/{ws}"} {
+		router.HandleFunc(prefix+"/tasks", h.InWorkspace(h.CreateTaskHandler)).Methods(http.MethodPost)
+		router.HandleFunc(prefix+"/tasks", h.InWorkspace(h.GetAllTasks)).Methods(http.MethodGet)
+		router.HandleFunc(prefix+"/tasks/{id:[0-9]+}", h.InWorkspace(h.GetTaskByID)).Methods(http.MethodGet)
+	}
+	return router
+}
+
+func TestInWorkspace(t *testing.T) {
+	h := newUserHandler()
+	h.Workspaces = repo.NewMemoryWorkspaceRepo()
+	ctx := context.Background()
+	team, err := h.Workspaces.CreateWorkspace(ctx, model.Workspace{Slug: "team", Name: "Team"})
+	require.NoError(t, err)
+	alice, _ := h.Users.EnsureUser(ctx, auth.APIKeyUser("alice"))
+	bob, _ := h.Users.EnsureUser(ctx, auth.APIKeyUser("bob"))
+	require.NoError(t, h.Workspaces.AddMember(ctx, team.ID, alice.ID))
+	router := newWorkspaceRouter(h)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	create := func(target, subject, body string) *httptest.ResponseRecorder {
+		return serve(as(httptest.NewRequest("POST", target, bytes.NewBufferString(body)), subject))
+	}
+
+	rr := create("/workspaces/team/tasks", "alice", `{"title":"Team task"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "/workspaces/team/tasks/1", rr.Header().Get("Location"))
+	rr = create("/tasks", "bob", `{"title":"Default task"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "/tasks/2", rr.Header().Get("Location"))
+
+	// Each workspace lists only its own tasks.
+	list := func(target, subject string) []model.Task {
+		rr := serve(as(httptest.NewRequest("GET", target, nil), subject))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var tasks []model.Task
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tasks))
+		return tasks
+	}
+	if tasks := list("/workspaces/team/tasks", "alice"); assert.Len(t, tasks, 1) {
+		assert.Equal(t, "Team task", tasks[0].Title)
+	}
+	if tasks := list("/tasks", "alice"); assert.Len(t, tasks, 1) {
+		assert.Equal(t, "Default task", tasks[0].Title)
+	}
+	assert.Equal(t, http.StatusNotFound, serve(as(httptest.NewRequest("GET", "/tasks/1", nil), "alice")).Code)
+
+	// Workspaces the user is not a member of look like missing ones.
+	for _, target := range []string{"/workspaces/team/tasks", "/workspaces/team/tasks/1", "/workspaces/other/tasks"} {
+		rr := serve(as(httptest.NewRequest("GET", target, nil), "bob"))
+		assert.Equal(t, http.StatusNotFound, rr.Code, target)
+		assert.Equal(t, "Workspace not found", decodeProblem(t, rr).Detail, target)
+	}
+
+	// Tasks can only be assigned to members.
+	rr = create("/workspaces/team/tasks", "alice", `{"title":"For bob","assigneeId":2}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "is not a member of the workspace", decodeProblem(t, rr).Errors[0].Message)
+	rr = create("/tasks", "alice", `{"title":"For bob","assigneeId":2}`)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, &bob.ID, decodeTask(t, rr).AssigneeID)
+}
+
+func TestListWorkspaces(t *testing.T) {
+	h := newUserHandler()
+	h.Workspaces = repo.NewMemoryWorkspaceRepo()
+	ctx := context.Background()
+	team, _ := h.Workspaces.CreateWorkspace(ctx, model.Workspace{Slug: "team", Name: "Team"})
+	h.Workspaces.CreateWorkspace(ctx, model.Workspace{Slug: "other", Name: "Other"})
+	alice, _ := h.Users.EnsureUser(ctx, auth.APIKeyUser("alice"))
+	h.Workspaces.AddMember(ctx, team.ID, alice.ID)
+
+	slugs := func(req *http.Request) []string {
+		rr := httptest.NewRecorder()
+		h.ListWorkspaces(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var workspaces []model.Workspace
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &workspaces))
+		slugs := []string{}
+		for _, ws := range workspaces {
+			slugs = append(slugs, ws.Slug)
+		}
+		return slugs
+	}
+
+	assert.Equal(t, []string{"default", "team"}, slugs(as(httptest.NewRequest("GET", "/workspaces", nil), "alice")))
+	assert.Equal(t, []string{"default"}, slugs(as(httptest.NewRequest("GET", "/workspaces", nil), "bob")))
+	assert.Equal(t, []string{"default", "team", "other"}, slugs(httptest.NewRequest("GET", "/workspaces", nil)))
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/api/handlers"
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/logging"
+	"github.com/DimWebDev/task-manager-tool/internal/metrics"
+	"github.com/DimWebDev/task-manager-tool/internal/tracing"
+	"github.com/gorilla/mux"
+)
+
+// QueryTimeout gives the context of every request a deadline d from its
+// arrival. Handlers pass that context to the repository, so a slow query is
+// abandoned and answered with 504 Gateway Timeout instead of holding a
+// database connection indefinitely. A d of zero or less sets no deadline.
+func QueryTimeout(d time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Metrics records every request routed by the router in m, labeled with the
+// template of the matched route rather than the raw path, so that
+// /tasks/1 and /tasks/2 count as the same route.
+func Metrics(m *metrics.HTTP) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			m.ObserveRequest(routeTemplate(r), r.Method, rec.Status(), time.Since(start))
+		})
+	}
+}
+
+// routeTemplate returns the path template of the route matching r, without
+// the patterns of its variables: "/tasks/{id:[0-9]+}" becomes "/tasks/{id}".
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "unmatched"
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return "unmatched"
+	}
+	return routeVarPattern.ReplaceAllString(tmpl, "{$1}")
+}
+
+var routeVarPattern = regexp.MustCompile(`\{([^:}]+):[^}]*\}`)
+
+// responseRecorder remembers the status code and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Status returns the status code sent, 200 when the handler wrote nothing.
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Logging gives every request an ID and a logger, and logs one line per
+// request once it is served. The ID is taken from the X-Request-ID header
+// when the client or a proxy sent a usable one, and generated otherwise; it
+// is echoed in the response header. The request context carries the ID and
+// a logger annotated with it, and with the trace of the request when Tracing
+// runs first, see logging.FromContext.
+func Logging(logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(handlers.RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(handlers.RequestIDHeader, id)
+
+			reqLogger := logger.With(slog.String("request_id", id))
+			if sc := tracing.SpanContextFromContext(r.Context()); sc.IsValid() {
+				reqLogger = reqLogger.With(slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
+			}
+			ctx := logging.WithRequestID(logging.WithLogger(r.Context(), reqLogger), id)
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			level := slog.LevelInfo
+			if rec.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			reqLogger.LogAttrs(ctx, level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", routeTemplate(r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Int64("bytes", rec.bytes),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			)
+		})
+	}
+}
+
+// maxRequestIDLength bounds the length of a request ID accepted from a
+// client.
+const maxRequestIDLength = 128
+
+// validRequestID reports whether a request ID received from a client can be
+// used as is. Only a conservative set of characters is accepted, so that the
+// ID cannot forge log lines or response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit request ID.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Tracing starts a server span for every request routed by the router,
+// named after the method and route template. The request joins the trace of
+// its W3C traceparent header when it has a valid one, and starts a new trace
+// otherwise. Handlers and the repository add child spans through the request
+// context.
+func Tracing(t *tracing.Tracer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeTemplate(r)
+			ctx, span := t.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+route, tracing.SpanKindServer,
+				tracing.String("http.request.method", r.Method),
+				tracing.String("http.route", route),
+				tracing.String("url.path", r.URL.Path),
+			)
+			defer span.End()
+
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			span.SetAttributes(tracing.Int("http.response.status_code", rec.Status()))
+			if rec.Status() >= http.StatusInternalServerError {
+				span.SetErrorMessage(http.StatusText(rec.Status()))
+			}
+		})
+	}
+}
+
+// APIKeyHeader carries the API key of a request.
+const APIKeyHeader = "X-API-Key"
+
+// authRealm is the protection space named in WWW-Authenticate challenges.
+const authRealm = "task-manager"
+
+// Authenticate requires every request routed by the router, except those to
+// the public route templates, to authenticate with an API key in the
+// X-API-Key header or a bearer token in the Authorization header. A nil keys
+// or tokens disables that method. Requests without valid credentials are
+// answered with 401 and a WWW-Authenticate challenge per enabled method.
+// The context of an authenticated request carries its auth.Principal, and
+// its logger and span record the subject.
+func Authenticate(keys *auth.APIKeys, tokens *auth.Tokens, public ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(public, routeTemplate(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var p auth.Principal
+			var err error
+			key := r.Header.Get(APIKeyHeader)
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			bearer := strings.EqualFold(scheme, "Bearer") && token != "" && tokens != nil
+			switch {
+			case key != "" && keys != nil:
+				bearer = false
+				p, err = keys.Authenticate(r.Context(), key)
+			case bearer:
+				p, err = tokens.Authenticate(strings.TrimSpace(token))
+			default:
+				challenge(w, keys, tokens, nil)
+				handlers.WriteProblem(w, r, http.StatusUnauthorized, handlers.CodeUnauthorized, "Authentication is required")
+				return
+			}
+			var credErr *auth.CredentialsError
+			if errors.As(err, &credErr) {
+				var tokenErr *auth.CredentialsError
+				if bearer {
+					tokenErr = credErr
+				}
+				challenge(w, keys, tokens, tokenErr)
+				handlers.WriteProblem(w, r, http.StatusUnauthorized, handlers.CodeUnauthorized, "Invalid credentials: "+credErr.Reason)
+				return
+			}
+			if err != nil {
+				handlers.WriteRepoError(w, r, err, "Failed to authenticate the request")
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), p)
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With(slog.String("subject", p.Subject)))
+			tracing.SpanFromContext(ctx).SetAttributes(tracing.String("enduser.id", p.Subject))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// challenge sets the WWW-Authenticate challenges of the enabled methods.
+// When a bearer token was rejected, tokenErr tells why and the Bearer
+// challenge carries the reason as described by RFC 6750.
+func challenge(w http.ResponseWriter, keys *auth.APIKeys, tokens *auth.Tokens, tokenErr *auth.CredentialsError) {
+	if tokens != nil {
+		c := `Bearer realm="` + authRealm + `"`
+		if tokenErr != nil {
+			c += `, error="invalid_token", error_description="` + tokenErr.Reason + `"`
+		}
+		w.Header().Add("WWW-Authenticate", c)
+	}
+	if keys != nil {
+		w.Header().Add("WWW-Authenticate", `APIKey realm="`+authRealm+`"`)
+	}
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/api/handlers"
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/logging"
+	"github.com/DimWebDev/task-manager-tool/internal/metrics"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/DimWebDev/task-manager-tool/internal/tracing"
+	"github.com/gorilla/mux"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		timeout      time.Duration
+		wantDeadline bool
+	}{
+		{time.Minute, true},
+		{0, false},
+	} {
+		var deadline time.Time
+		var hasDeadline bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline, hasDeadline = r.Context().Deadline()
+		})
+
+		start := time.Now()
+		QueryTimeout(tc.timeout)(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tasks", nil))
+
+		if hasDeadline != tc.wantDeadline {
+			t.Errorf("timeout %s: expected deadline %v, got %v", tc.timeout, tc.wantDeadline, hasDeadline)
+		}
+		if hasDeadline && deadline.Before(start.Add(tc.timeout)) {
+			t.Errorf("timeout %s: deadline %s is too early", tc.timeout, deadline)
+		}
+	}
+}
+
+func TestMetricsUsesRouteTemplate(t *testing.T) {
+	reg := metrics.NewRegistry()
+	router := mux.NewRouter()
+	router.Use(Metrics(metrics.NewHTTP(reg)))
+	router.HandleFunc("/tasks/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "2" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods(http.MethodGet)
+
+	for _, path := range []string{"/tasks/1", "/tasks/3", "/tasks/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	var out strings.Builder
+	reg.WriteTo(&out)
+	for _, line := range []string{
+		`http_requests_total{route="/tasks/{id}",method="GET",status="200"} 2`,
+		`http_requests_total{route="/tasks/{id}",method="GET",status="404"} 1`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("expected %s in\n%s", line, out.String())
+		}
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	router := mux.NewRouter()
+	router.Use(Logging(logger))
+	var ctxID string
+	router.HandleFunc("/tasks/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		ctxID = logging.RequestID(r.Context())
+		logging.FromContext(r.Context()).Info("handler ran")
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "short and stout")
+	})
+
+	for _, tc := range []struct {
+		sent      string
+		propagate bool
+	}{
+		{"req-123", true},
+		{"", false},
+		{"bad id\nwith newline", false},
+		{strings.Repeat("x", maxRequestIDLength+1), false},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/tasks/7", nil)
+		if tc.sent != "" {
+			req.Header.Set(handlers.RequestIDHeader, tc.sent)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		id := rr.Header().Get(handlers.RequestIDHeader)
+		if tc.propagate && id != tc.sent {
+			t.Errorf("sent %q: expected it to be kept, got %q", tc.sent, id)
+		}
+		if !tc.propagate && (id == tc.sent || len(id) != 32) {
+			t.Errorf("sent %q: expected a generated ID, got %q", tc.sent, id)
+		}
+		if ctxID != id {
+			t.Errorf("sent %q: expected the context to carry %q, got %q", tc.sent, id, ctxID)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected the handler line and the request line, got %q", buf.String())
+		}
+		var handlerLine, requestLine map[string]any
+		json.Unmarshal([]byte(lines[0]), &handlerLine)
+		json.Unmarshal([]byte(lines[1]), &requestLine)
+		if handlerLine["request_id"] != id {
+			t.Errorf("expected the handler to log with request_id %q, got %v", id, handlerLine)
+		}
+		for key, want := range map[string]any{
+			"msg":        "request",
+			"request_id": id,
+			"method":     "GET",
+			"route":      "/tasks/{id}",
+			"path":       "/tasks/7",
+			"status":     float64(http.StatusTeapot),
+			"bytes":      float64(len("short and stout")),
+		} {
+			if requestLine[key] != want {
+				t.Errorf("expected %s=%v, got %v", key, want, requestLine[key])
+			}
+		}
+		if _, ok := requestLine["duration_ms"].(float64); !ok {
+			t.Errorf("expected a duration_ms, got %v", requestLine)
+		}
+	}
+}
+
+// spanRecorder is a tracing.Exporter keeping the spans in memory.
+type spanRecorder struct{ spans []tracing.SpanData }
+
+func (r *spanRecorder) Export(ctx context.Context, spans []tracing.SpanData) error {
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *spanRecorder) Shutdown(ctx context.Context) error { return nil }
+
+func TestTracing(t *testing.T) {
+	rec := &spanRecorder{}
+	tracer := tracing.NewTracer(rec)
+	var logBuf bytes.Buffer
+	router := mux.NewRouter()
+	router.Use(Tracing(tracer))
+	router.Use(Logging(slog.New(slog.NewJSONHandler(&logBuf, nil))))
+	router.HandleFunc("/tasks/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "handler work", tracing.SpanKindInternal)
+		span.End()
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/tasks/7", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	tracer.Flush(context.Background())
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("expected the handler and server spans, got %+v", rec.spans)
+	}
+	child, server := rec.spans[0], rec.spans[1]
+	if server.Name != "GET /tasks/{id}" || server.Kind != tracing.SpanKindServer || !server.Failed {
+		t.Errorf("expected a failed server span named after the route, got %+v", server)
+	}
+	if server.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentID.String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the server span to continue the inbound trace, got %+v", server)
+	}
+	if child.ParentID != server.SpanID {
+		t.Error("expected the handler span below the server span")
+	}
+	found := false
+	for _, a := range server.Attributes {
+		found = found || a == tracing.Int("http.response.status_code", http.StatusInternalServerError)
+	}
+	if !found {
+		t.Errorf("expected the status code attribute, got %v", server.Attributes)
+	}
+	if !strings.Contains(logBuf.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`) {
+		t.Errorf("expected the request log to carry the trace ID, got %s", logBuf.String())
+	}
+}
+
+// keyStore is an auth.KeyStore backed by a map.
+type keyStore map[string]model.APIKey
+
+func (s keyStore) GetAPIKey(ctx context.Context, id string) (model.APIKey, error) {
+	if key, ok := s[id]; ok {
+		return key, nil
+	}
+	return model.APIKey{}, repo.ErrNotFound
+}
+
+// hs256Token returns a token for subject signed with secret.
+func hs256Token(secret []byte, subject string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	input := enc([]byte(`{"alg":"HS256"}`)) + "." +
+		enc([]byte(fmt.Sprintf(`{"sub":%q,"exp":%d}`, subject, time.Now().Add(time.Hour).Unix())))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return input + "." + enc(mac.Sum(nil))
+}
+
+func TestAuthenticate(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keySet, err := auth.ParseKeySet([]byte(fmt.Sprintf(`{"keys": [{"kty": "oct", "k": %q}]}`, base64.RawURLEncoding.EncodeToString(secret))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey, stored, err := auth.NewAPIKey("ci", "build-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.Use(Authenticate(auth.NewAPIKeys(keyStore{stored.ID: stored}), &auth.Tokens{Keys: keySet}, "/healthz"))
+	var principal auth.Principal
+	router.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = auth.PrincipalFromContext(r.Context())
+	})
+	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		principal = auth.Principal{}
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v[0])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("/healthz", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected the public route to be served, got %d", rec.Code)
+	}
+
+	rec := serve("/tasks", nil)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Content-Type") != handlers.ProblemContentType {
+		t.Fatalf("expected a 401 problem, got %d %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Values("WWW-Authenticate"); len(got) != 2 || got[0] != `Bearer realm="task-manager"` || got[1] != `APIKey realm="task-manager"` {
+		t.Errorf("expected a challenge per method, got %q", got)
+	}
+
+	rec = serve("/tasks", http.Header{"Authorization": {"Bearer " + hs256Token(secret, "alice")}})
+	if rec.Code != http.StatusOK || principal != (auth.Principal{Subject: "alice", Method: auth.MethodJWT}) {
+		t.Errorf("expected the token to authenticate alice, got %d %+v", rec.Code, principal)
+	}
+	rec = serve("/tasks", http.Header{APIKeyHeader: {apiKey}})
+	if rec.Code != http.StatusOK || principal.Subject != "build-bot" || principal.KeyID != stored.ID {
+		t.Errorf("expected the API key to authenticate build-bot, got %d %+v", rec.Code, principal)
+	}
+
+	rec = serve("/tasks", http.Header{"Authorization": {"Bearer " + hs256Token([]byte("another secret of 32 bytes......"), "mallory")}})
+	if want := `Bearer realm="task-manager", error="invalid_token", error_description="invalid token signature"`; rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != want {
+		t.Errorf("expected the forged token to be rejected with %s, got %d %q", want, rec.Code, rec.Header().Values("WWW-Authenticate"))
+	}
+	rec = serve("/tasks", http.Header{APIKeyHeader: {apiKey + "x"}})
+	var problem handlers.Problem
+	json.NewDecoder(rec.Body).Decode(&problem)
+	if rec.Code != http.StatusUnauthorized || problem.Code != handlers.CodeUnauthorized || problem.Detail != "Invalid credentials: unknown API key" {
+		t.Errorf("expected the wrong key to be rejected, got %d %+v", rec.Code, problem)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer realm="task-manager"` {
+		t.Errorf("expected no token error for a rejected key, got %q", got)
+	}
+}
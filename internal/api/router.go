@@ -7,26 +7,103 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// workspacePrefix is the prefix of the task, project and tag routes of a
+// workspace. The same routes without it serve the default workspace.
+const workspacePrefix = "/workspaces/{ws:[a-z0-9-]+}"
 
+func NewRouter(taskHandler *handlers.TaskHandler) *mux.Router {
+	router := mux.NewRouter()
 
+	for _, prefix := range []string{"", workspacePrefix} {
+		workspaceRoutes(router, prefix, taskHandler)
+		tagRoutes(router, prefix, taskHandler)
+	}
 
-func NewRouter(taskHandler *handlers.TaskHandler) *mux.Router {
-    router := mux.NewRouter()
+	router.HandleFunc("/workspaces", taskHandler.ListWorkspaces).Methods(http.MethodGet)
+
+	router.HandleFunc("/workflow", taskHandler.GetWorkflow).Methods(http.MethodGet)
+
+	router.HandleFunc("/users", taskHandler.ListUsers).Methods(http.MethodGet)
 
-    router.HandleFunc("/tasks", taskHandler.CreateTaskHandler).Methods(http.MethodPost)
+	router.HandleFunc("/users/me", taskHandler.GetCurrentUser).Methods(http.MethodGet)
 
+	router.HandleFunc("/users/{id:[0-9]+}", taskHandler.GetUser).Methods(http.MethodGet)
 
-	router.HandleFunc("/tasks/{id:[0-9]+}", taskHandler.GetTaskByID).Methods(http.MethodGet)
+	return router
+}
 
-	router.HandleFunc("/tasks/{id:[0-9]+}", taskHandler.UpdateTask).Methods(http.MethodPut)
+// workspaceRoutes registers the routes reaching tasks and projects below
+// prefix, each confined to the workspace the prefix names.
+func workspaceRoutes(router *mux.Router, prefix string, taskHandler *handlers.TaskHandler) {
+	handle := func(path string, handler http.HandlerFunc, method string) {
+		router.HandleFunc(prefix+path, taskHandler.InWorkspace(handler)).Methods(method)
+	}
 
-	router.HandleFunc("/tasks/{id:[0-9]+}", taskHandler.DeleteTask).Methods(http.MethodDelete)
+	handle("/tasks", taskHandler.CreateTaskHandler, http.MethodPost)
 
-	router.HandleFunc("/tasks", taskHandler.GetAllTasks).Methods(http.MethodGet)
+	handle("/tasks/{id:[0-9]+}", taskHandler.GetTaskByID, http.MethodGet)
 
+	handle("/tasks/{id:[0-9]+}", taskHandler.UpdateTask, http.MethodPut)
 
+	handle("/tasks/{id:[0-9]+}", taskHandler.PatchTask, http.MethodPatch)
 
-	return router
+	handle("/tasks/{id:[0-9]+}", taskHandler.DeleteTask, http.MethodDelete)
+
+	handle("/tasks", taskHandler.GetAllTasks, http.MethodGet)
+
+	handle("/tasks/{id:[0-9]+}/transitions/{transition}", taskHandler.TransitionTask, http.MethodPost)
+
+	handle("/tasks/{id:[0-9]+}/assignee", taskHandler.AssignTask, http.MethodPut)
+
+	handle("/tasks/{id:[0-9]+}/assignee", taskHandler.UnassignTask, http.MethodDelete)
+
+	handle("/tasks/{id:[0-9]+}/watchers/{user}", taskHandler.WatchTask, http.MethodPut)
+
+	handle("/tasks/{id:[0-9]+}/watchers/{user}", taskHandler.UnwatchTask, http.MethodDelete)
+
+	handle("/tasks/{id:[0-9]+}/subtasks", taskHandler.GetSubtasks, http.MethodGet)
+
+	handle("/users/me/tasks", taskHandler.GetMyTasks, http.MethodGet)
+
+	if taskHandler.Projects == nil {
+		return
+	}
+
+	handle("/projects", taskHandler.CreateProject, http.MethodPost)
+
+	handle("/projects", taskHandler.ListProjects, http.MethodGet)
+
+	handle("/projects/{id:[0-9]+}", taskHandler.GetProject, http.MethodGet)
+
+	handle("/projects/{id:[0-9]+}", taskHandler.UpdateProject, http.MethodPut)
+
+	handle("/projects/{id:[0-9]+}", taskHandler.PatchProject, http.MethodPatch)
+
+	handle("/projects/{id:[0-9]+}", taskHandler.DeleteProject, http.MethodDelete)
+
+	handle("/projects/{id:[0-9]+}/tasks", taskHandler.GetProjectTasks, http.MethodGet)
 }
 
+// tagRoutes registers the routes reaching the tags of the workspace named
+// by prefix, like workspaceRoutes.
+func tagRoutes(router *mux.Router, prefix string, taskHandler *handlers.TaskHandler) {
+	if taskHandler.Tags == nil {
+		return
+	}
 
+	handle := func(path string, handler http.HandlerFunc, method string) {
+		router.HandleFunc(prefix+path, taskHandler.InWorkspace(handler)).Methods(method)
+	}
+
+	handle("/tags", taskHandler.CreateTag, http.MethodPost)
+
+	handle("/tags", taskHandler.ListTags, http.MethodGet)
+
+	handle("/tags/{id:[0-9]+}", taskHandler.GetTag, http.MethodGet)
+
+	handle("/tags/{id:[0-9]+}", taskHandler.UpdateTag, http.MethodPut)
+
+	handle("/tags/{id:[0-9]+}", taskHandler.PatchTag, http.MethodPatch)
+
+	handle("/tags/{id:[0-9]+}", taskHandler.DeleteTag, http.MethodDelete)
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// An API key reads tm_<id>_<secret>: a fixed prefix that secret scanners
+// can match, the 16 hex digits of the ID under which the key is stored,
+// and a random 256-bit secret in unpadded base64url.
+const (
+	apiKeyPrefix   = "tm_"
+	apiKeyIDLength = 16
+	secretBytes    = 32
+)
+
+// NewAPIKey generates a key for subject. It returns the key, to hand over to
+// the client, and the record to store, which only holds a hash of it.
+func NewAPIKey(name, subject string) (string, model.APIKey, error) {
+	var id [apiKeyIDLength / 2]byte
+	var secret [secretBytes]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", model.APIKey{}, err
+	}
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", model.APIKey{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret[:])
+	stored := model.APIKey{
+		ID:      hex.EncodeToString(id[:]),
+		Name:    name,
+		Subject: subject,
+		Hash:    hashSecret(encoded),
+	}
+	return apiKeyPrefix + stored.ID + "_" + encoded, stored, nil
+}
+
+// hashSecret returns the hex SHA-256 hash of the secret part of a key. The
+// secret is random and long, so a fast unsalted hash is enough: there is no
+// dictionary to try, unlike with passwords.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAPIKey splits a key into its ID and secret.
+func parseAPIKey(key string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok || len(rest) < apiKeyIDLength+2 || rest[apiKeyIDLength] != '_' {
+		return "", "", false
+	}
+	id, secret = rest[:apiKeyIDLength], rest[apiKeyIDLength+1:]
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// KeyStore looks up stored API keys; repo.APIKeyRepository implements it.
+type KeyStore interface {
+	GetAPIKey(ctx context.Context, id string) (model.APIKey, error)
+}
+
+// APIKeys authenticates requests with the keys of a KeyStore.
+type APIKeys struct {
+	store KeyStore
+}
+
+// NewAPIKeys returns an authenticator checking keys against store.
+func NewAPIKeys(store KeyStore) *APIKeys {
+	return &APIKeys{store: store}
+}
+
+// Authenticate returns the principal of key. It fails with a
+// CredentialsError when the key is malformed, unknown, revoked or does
+// not match its stored hash, and with the error of the store when the key
+// cannot be looked up.
+func (a *APIKeys) Authenticate(ctx context.Context, key string) (Principal, error) {
+	id, secret, ok := parseAPIKey(key)
+	if !ok {
+		return Principal{}, &CredentialsError{Reason: "malformed API key"}
+	}
+	stored, err := a.store.GetAPIKey(ctx, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		return Principal{}, &CredentialsError{Reason: "unknown API key"}
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	// Compared in constant time, so response times tell nothing about how
+	// much of a guessed secret is right.
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(stored.Hash)) != 1 {
+		return Principal{}, &CredentialsError{Reason: "unknown API key"}
+	}
+	if stored.Revoked() {
+		return Principal{}, &CredentialsError{Reason: "API key was revoked"}
+	}
+	return Principal{Subject: stored.Subject, Method: MethodAPIKey, KeyID: stored.ID}, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// keyStore is a KeyStore backed by a map.
+type keyStore map[string]model.APIKey
+
+func (s keyStore) GetAPIKey(ctx context.Context, id string) (model.APIKey, error) {
+	key, ok := s[id]
+	if !ok {
+		return model.APIKey{}, fmt.Errorf("%w: API key %s", repo.ErrNotFound, id)
+	}
+	return key, nil
+}
+
+func TestAPIKeysAuthenticate(t *testing.T) {
+	key, stored, err := NewAPIKey("ci", "build-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, "tm_"+stored.ID+"_") || strings.Contains(stored.Hash, key[len(key)-10:]) {
+		t.Fatalf("unexpected key %q for %+v", key, stored)
+	}
+	store := keyStore{stored.ID: stored}
+	keys := NewAPIKeys(store)
+
+	p, err := keys.Authenticate(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != (Principal{Subject: "build-bot", Method: MethodAPIKey, KeyID: stored.ID}) {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	other, _, _ := NewAPIKey("other", "build-bot")
+	for name, k := range map[string]string{
+		"malformed":    "not-a-key",
+		"short":        "tm_" + stored.ID,
+		"unknown":      other,
+		"wrong secret": key[:len(key)-1] + "x",
+	} {
+		if _, err := keys.Authenticate(context.Background(), k); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: expected invalid credentials, got %v", name, err)
+		}
+	}
+
+	revokedAt := time.Now()
+	stored.RevokedAt = &revokedAt
+	store[stored.ID] = stored
+	if _, err := keys.Authenticate(context.Background(), key); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected a revoked key to be rejected, got %v", err)
+	}
+}
+
+// failingStore fails every lookup.
+type failingStore struct{ err error }
+
+func (s failingStore) GetAPIKey(ctx context.Context, id string) (model.APIKey, error) {
+	return model.APIKey{}, s.err
+}
+
+func TestAPIKeysReportStorageErrors(t *testing.T) {
+	key, _, _ := NewAPIKey("ci", "build-bot")
+	keys := NewAPIKeys(failingStore{err: repo.ErrUnavailable})
+	_, err := keys.Authenticate(context.Background(), key)
+	if !errors.Is(err, repo.ErrUnavailable) || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected the storage error, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+// Package auth authenticates the clients of the API, with API keys stored in
+// the database or with JSON Web Tokens signed by a trusted issuer. The
+// request context of an authenticated request carries its Principal.
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// Authentication methods recorded in Principal.Method.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal is the authenticated client a request acts for.
+type Principal struct {
+	// Subject identifies the client: the subject of an API key or the sub
+	// claim of a token.
+	Subject string
+	// Method is MethodAPIKey or MethodJWT.
+	Method string
+	// KeyID is the ID of the API key used, empty for tokens.
+	KeyID string
+	// Issuer is the iss claim of the token, empty for API keys.
+	Issuer string
+}
+
+// UserSubject returns the subject the principal is recorded under as a
+// user. Subjects are namespaced by authentication method, and tokens by
+// issuer as well, so that a token whose sub claim names the subject of an
+// API key does not act as the key's user.
+func (p Principal) UserSubject() string {
+	if p.Method == MethodJWT {
+		return JWTUser(p.Issuer, p.Subject)
+	}
+	return APIKeyUser(p.Subject)
+}
+
+// APIKeyUser returns the user subject of API keys acting as subject.
+func APIKeyUser(subject string) string {
+	return "apikey:" + subject
+}
+
+// JWTUser returns the user subject of tokens with the given iss and sub
+// claims.
+func JWTUser(issuer, subject string) string {
+	return "jwt:" + issuer + "|" + subject
+}
+
+// ErrInvalidCredentials reports credentials that do not authenticate
+// anyone: a malformed, unknown, revoked, expired or forged key or token.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// CredentialsError explains why credentials were rejected, in terms safe to
+// show to the client. It matches ErrInvalidCredentials with errors.Is.
+type CredentialsError struct {
+	Reason string
+}
+
+func (e *CredentialsError) Error() string {
+	return ErrInvalidCredentials.Error() + ": " + e.Reason
+}
+
+// Is reports whether target is ErrInvalidCredentials.
+func (e *CredentialsError) Is(target error) bool {
+	return target == ErrInvalidCredentials
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored in ctx by
+// WithPrincipal, and whether there is one.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// Signature algorithms accepted in tokens. Any other alg, "none" included,
+// is rejected.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// Minimum key sizes, as required by RFC 7518.
+const (
+	minHMACKeyBytes = 256 / 8
+	minRSAKeyBits   = 2048
+)
+
+// Key is a verification key of a KeySet.
+type Key struct {
+	// ID matches the kid header of the tokens signed with the key. Tokens
+	// without a kid are checked against every key of their algorithm.
+	ID string
+	// Alg is AlgHS256 or AlgRS256.
+	Alg    string
+	secret []byte
+	public *rsa.PublicKey
+}
+
+// KeySet holds the keys tokens may be signed with.
+type KeySet struct {
+	Keys []Key
+}
+
+// jsonWebKey is a key of a JSON Web Key Set (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// K is the secret of a symmetric ("oct") key.
+	K string `json:"k"`
+	// N and E are the modulus and exponent of an RSA public key.
+	N string `json:"n"`
+	E string `json:"e"`
+}
+
+// ParseKeySet reads a JSON Web Key Set. Symmetric keys ("kty": "oct") verify
+// HS256 tokens and RSA public keys verify RS256 tokens; keys meant for
+// encryption ("use": "enc") are skipped.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("key set: %w", err)
+	}
+	set := &KeySet{}
+	for i, jwk := range jwks.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+		key, err := jwk.key()
+		if err != nil {
+			return nil, fmt.Errorf("key set: key %d: %w", i, err)
+		}
+		set.Keys = append(set.Keys, key)
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("key set: no signature keys")
+	}
+	return set, nil
+}
+
+// LoadKeySet reads the JSON Web Key Set in the file at path.
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeySet(data)
+}
+
+func (jwk jsonWebKey) key() (Key, error) {
+	key := Key{ID: jwk.Kid}
+	switch jwk.Kty {
+	case "oct":
+		key.Alg = AlgHS256
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid k: %w", err)
+		}
+		if len(secret) < minHMACKeyBytes {
+			return Key{}, fmt.Errorf("HS256 keys need at least %d bytes", minHMACKeyBytes)
+		}
+		key.secret = secret
+	case "RSA":
+		key.Alg = AlgRS256
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return Key{}, errors.New("invalid e")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < minRSAKeyBits {
+			return Key{}, fmt.Errorf("RS256 keys need at least %d bits", minRSAKeyBits)
+		}
+		key.public = pub
+	default:
+		return Key{}, fmt.Errorf("unsupported kty %q", jwk.Kty)
+	}
+	if jwk.Alg != "" && jwk.Alg != key.Alg {
+		return Key{}, fmt.Errorf("alg %q does not match kty %q", jwk.Alg, jwk.Kty)
+	}
+	return key, nil
+}
+
+// verify checks the signature of signed with the key.
+func (k Key) verify(signed, signature []byte) bool {
+	switch k.Alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case AlgRS256:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(k.public, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
+
+// Tokens authenticates requests with bearer tokens: JSON Web Tokens in the
+// compact serialization, signed with a key of Keys.
+type Tokens struct {
+	Keys *KeySet
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew with the issuer on exp and nbf.
+	Leeway time.Duration
+	// now returns the current time; nil means time.Now.
+	now func() time.Time
+}
+
+// claims are the registered claims checked by Tokens.
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+}
+
+// audience is the aud claim, either a single string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("aud is neither a string nor an array of strings")
+	}
+	*a = many
+	return nil
+}
+
+// Authenticate verifies token and returns its principal. It fails with a
+// CredentialsError when the token is malformed, is not signed by a key
+// of the set with an accepted algorithm, has expired or is not yet valid,
+// lacks a subject or was issued by or for someone else.
+func (t *Tokens) Authenticate(token string) (Principal, error) {
+	invalid := func(reason string) (Principal, error) {
+		return Principal{}, &CredentialsError{Reason: reason}
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return invalid("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if !decodeSegment(parts[0], &header) {
+		return invalid("malformed token header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return invalid("malformed token signature")
+	}
+	if header.Alg != AlgHS256 && header.Alg != AlgRS256 {
+		return invalid("unsupported signature algorithm")
+	}
+	if !t.verify(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), signature) {
+		return invalid("invalid token signature")
+	}
+
+	var c claims
+	if !decodeSegment(parts[1], &c) {
+		return invalid("malformed token claims")
+	}
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+	switch at := now(); {
+	case c.ExpiresAt == nil:
+		return invalid("token has no expiry")
+	case at.After(numericDate(*c.ExpiresAt).Add(t.Leeway)):
+		return invalid("token expired")
+	case c.NotBefore != nil && at.Add(t.Leeway).Before(numericDate(*c.NotBefore)):
+		return invalid("token is not valid yet")
+	}
+	if c.Subject == "" {
+		return invalid("token has no subject")
+	}
+	if t.Issuer != "" && c.Issuer != t.Issuer {
+		return invalid("token has another issuer")
+	}
+	if t.Audience != "" && !c.Audience.contains(t.Audience) {
+		return invalid("token is meant for another audience")
+	}
+	return Principal{Subject: c.Subject, Method: MethodJWT, Issuer: c.Issuer}, nil
+}
+
+// verify checks the signature with the keys of the set that match the alg
+// and kid of the token.
+func (t *Tokens) verify(alg, kid string, signed, signature []byte) bool {
+	for _, key := range t.Keys.Keys {
+		if key.Alg == alg && (kid == "" || key.ID == kid) && key.verify(signed, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a audience) contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v.
+func decodeSegment(segment string, v any) bool {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	return err == nil && json.Unmarshal(data, v) == nil
+}
+
+// numericDate converts seconds since the epoch, possibly fractional, into a
+// time.
+func numericDate(seconds float64) time.Time {
+	return time.UnixMilli(int64(seconds * 1000))
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hmacSecret is the HS256 key of the tests, 32 bytes long.
+var hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+var testNow = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// sign returns a compact JWT with the given header and claims. The
+// signature is computed by sig over the signing input.
+func sign(t *testing.T, header, claims map[string]any, sig func(input []byte) []byte) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	input := enc(header) + "." + enc(claims)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig([]byte(input)))
+}
+
+func hs256(input []byte) []byte {
+	mac := hmac.New(sha256.New, hmacSecret)
+	mac.Write(input)
+	return mac.Sum(nil)
+}
+
+// validClaims returns claims accepted by testTokens.
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub": "alice",
+		"iss": "https://issuer.example",
+		"aud": []string{"other", "task-manager"},
+		"exp": testNow.Add(time.Hour).Unix(),
+		"nbf": testNow.Add(-time.Hour).Unix(),
+	}
+}
+
+// testTokens accepts HS256 tokens signed with hmacSecret under the kid
+// "hmac" and RS256 tokens signed with rsaKey under the kid "rsa".
+func testTokens(t *testing.T, rsaKey *rsa.PrivateKey) *Tokens {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "oct", "kid": "hmac", "alg": "HS256", "k": %q},
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": %q, "e": %q},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"}
+	]}`, b64(hmacSecret), b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()))
+	keys, err := ParseKeySet([]byte(jwks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.Keys) != 2 {
+		t.Fatalf("expected the encryption key to be skipped, got %d keys", len(keys.Keys))
+	}
+	return &Tokens{
+		Keys:     keys,
+		Issuer:   "https://issuer.example",
+		Audience: "task-manager",
+		Leeway:   time.Minute,
+		now:      func() time.Time { return testNow },
+	}
+}
+
+func TestTokensAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := testTokens(t, rsaKey)
+	rs256 := func(input []byte) []byte {
+		digest := sha256.Sum256(input)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	for name, token := range map[string]string{
+		"HS256":       sign(t, map[string]any{"alg": "HS256", "kid": "hmac"}, validClaims(), hs256),
+		"RS256":       sign(t, map[string]any{"alg": "RS256", "kid": "rsa", "typ": "JWT"}, validClaims(), rs256),
+		"without kid": sign(t, map[string]any{"alg": "RS256"}, validClaims(), rs256),
+	} {
+		p, err := tokens.Authenticate(token)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if p != (Principal{Subject: "alice", Method: MethodJWT, Issuer: "https://issuer.example"}) {
+			t.Errorf("%s: unexpected principal %+v", name, p)
+		}
+	}
+}
+
+func TestTokensRejectInvalidTokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := testTokens(t, rsaKey)
+	hmacHeader := map[string]any{"alg": "HS256", "kid": "hmac"}
+	with := func(key string, value any) map[string]any {
+		c := validClaims()
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+	valid := strings.Split(sign(t, hmacHeader, validClaims(), hs256), ".")
+	mallory, _ := json.Marshal(with("sub", "mallory"))
+	tampered := valid[0] + "." + base64.RawURLEncoding.EncodeToString(mallory) + "." + valid[2]
+	forged := valid[0] + "." + valid[1] + "." + base64.RawURLEncoding.EncodeToString(make([]byte, 32))
+
+	for _, tc := range []struct {
+		name, token, reason string
+	}{
+		{"not a JWT", "opaque-token", "malformed token"},
+		{"alg none", sign(t, map[string]any{"alg": "none"}, validClaims(), func([]byte) []byte { return nil }), "unsupported signature algorithm"},
+		// The RSA public key must not be usable as an HMAC secret.
+		{"alg confusion", sign(t, map[string]any{"alg": "HS256", "kid": "rsa"}, validClaims(), hs256), "invalid token signature"},
+		{"unknown kid", sign(t, map[string]any{"alg": "HS256", "kid": "other"}, validClaims(), hs256), "invalid token signature"},
+		{"tampered claims", tampered, "invalid token signature"},
+		{"forged signature", forged, "invalid token signature"},
+		{"expired", sign(t, hmacHeader, with("exp", testNow.Add(-2*time.Minute).Unix()), hs256), "token expired"},
+		{"no expiry", sign(t, hmacHeader, with("exp", nil), hs256), "token has no expiry"},
+		{"not yet valid", sign(t, hmacHeader, with("nbf", testNow.Add(2*time.Minute).Unix()), hs256), "token is not valid yet"},
+		{"no subject", sign(t, hmacHeader, with("sub", nil), hs256), "token has no subject"},
+		{"other issuer", sign(t, hmacHeader, with("iss", "https://evil.example"), hs256), "token has another issuer"},
+		{"other audience", sign(t, hmacHeader, with("aud", "billing"), hs256), "token is meant for another audience"},
+	} {
+		_, err := tokens.Authenticate(tc.token)
+		var credErr *CredentialsError
+		if !errors.As(err, &credErr) || !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: expected invalid credentials, got %v", tc.name, err)
+			continue
+		}
+		if credErr.Reason != tc.reason {
+			t.Errorf("%s: expected reason %q, got %q", tc.name, tc.reason, credErr.Reason)
+		}
+	}
+
+	// Within the leeway, a token that just expired is still accepted.
+	if _, err := tokens.Authenticate(sign(t, hmacHeader, with("exp", testNow.Add(-30*time.Second).Unix()), hs256)); err != nil {
+		t.Errorf("expected the leeway to apply, got %v", err)
+	}
+}
+
+func TestParseKeySetRejectsWeakKeys(t *testing.T) {
+	for name, jwks := range map[string]string{
+		"short secret":   `{"keys": [{"kty": "oct", "k": "c2hvcnQ"}]}`,
+		"small RSA key":  `{"keys": [{"kty": "RSA", "n": "AQAB", "e": "AQAB"}]}`,
+		"alg mismatch":   fmt.Sprintf(`{"keys": [{"kty": "oct", "alg": "RS256", "k": %q}]}`, base64.RawURLEncoding.EncodeToString(hmacSecret)),
+		"unknown kty":    `{"keys": [{"kty": "EC", "crv": "P-256"}]}`,
+		"no signing key": `{"keys": []}`,
+	} {
+		if _, err := ParseKeySet([]byte(jwks)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
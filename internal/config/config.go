@@ -0,0 +1,368 @@
+// Package config assembles the server configuration from, in increasing
+// order of precedence, built-in defaults, an optional YAML file, environment
+// variables and command-line flags. Load returns the validated result.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration. The yaml tags name the keys
+// of the configuration file.
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Storage  Storage  `yaml:"storage"`
+	Log      Log      `yaml:"log"`
+	Tracing  Tracing  `yaml:"tracing"`
+	Auth     Auth     `yaml:"auth"`
+	Features Features `yaml:"features"`
+	// Workflow is the graph of status changes tasks follow. Each list the
+	// file gives replaces the one of the default workflow.
+	Workflow model.Workflow `yaml:"workflow"`
+}
+
+// Server configures the HTTP listener.
+type Server struct {
+	// Addr is the TCP address to listen on, e.g. ":8080".
+	Addr string `yaml:"addr"`
+	TLS  TLS    `yaml:"tls"`
+
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	// MaxHeaderBytes limits the size of the request headers.
+	MaxHeaderBytes int `yaml:"maxHeaderBytes"`
+	// QueryTimeout bounds the storage work of a single request.
+	QueryTimeout time.Duration `yaml:"queryTimeout"`
+	// ShutdownTimeout bounds the graceful shutdown: draining in-flight
+	// requests, stopping workers and closing the database.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// ShutdownDelay keeps serving, with /readyz failing, for this long after
+	// the shutdown signal and before draining starts, so that load balancers
+	// stop sending traffic before the listener closes.
+	ShutdownDelay time.Duration `yaml:"shutdownDelay"`
+}
+
+// TLS enables HTTPS when both files are set.
+type TLS struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// Enabled reports whether the server should serve HTTPS.
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// Storage selects and configures the task storage.
+type Storage struct {
+	// Driver is postgres, sqlite or memory.
+	Driver   string   `yaml:"driver"`
+	Postgres Postgres `yaml:"postgres"`
+	SQLite   SQLite   `yaml:"sqlite"`
+	Pool     Pool     `yaml:"pool"`
+}
+
+// Postgres locates the PostgreSQL database, either with a complete DSN or
+// with the individual connection parameters.
+type Postgres struct {
+	// DSN, when set, is used as is and the other fields are ignored. Both
+	// the key=value and the postgres:// URL forms are accepted.
+	DSN      string `yaml:"dsn"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Database string `yaml:"database"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	SSLMode  string `yaml:"sslMode"`
+}
+
+// ConnString returns the connection string passed to the driver.
+func (p Postgres) ConnString() string {
+	if p.DSN != "" {
+		return p.DSN
+	}
+	params := []string{
+		"host=" + quoteParam(p.Host),
+		fmt.Sprintf("port=%d", p.Port),
+		"dbname=" + quoteParam(p.Database),
+		"user=" + quoteParam(p.User),
+	}
+	if p.Password != "" {
+		params = append(params, "password="+quoteParam(p.Password))
+	}
+	if p.SSLMode != "" {
+		params = append(params, "sslmode="+quoteParam(p.SSLMode))
+	}
+	return strings.Join(params, " ")
+}
+
+// quoteParam quotes a key=value connection parameter if needed, as
+// described in the libpq documentation.
+func quoteParam(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// SQLite locates the SQLite database.
+type SQLite struct {
+	// DSN is a file name or a file: URI; ":memory:" keeps the database in
+	// memory.
+	DSN string `yaml:"dsn"`
+}
+
+// Pool sizes the database/sql connection pool of PostgreSQL. SQLite always
+// uses a single connection.
+type Pool struct {
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"connMaxIdleTime"`
+}
+
+// Log configures the server logs, written to standard error.
+type Log struct {
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is json, one object per line, or text, as key=value pairs.
+	Format string `yaml:"format"`
+}
+
+// Tracing configures where the spans of traced requests are exported.
+type Tracing struct {
+	// Exporter is none, stdout, file or otlp.
+	Exporter string `yaml:"exporter"`
+	// File receives the spans, one JSON object per line, with the file
+	// exporter.
+	File string `yaml:"file"`
+	// OTLPEndpoint is the base URL of an OTLP/HTTP collector; spans are
+	// posted to its /v1/traces path.
+	OTLPEndpoint string `yaml:"otlpEndpoint"`
+	// ServiceName identifies the server in the traces.
+	ServiceName string `yaml:"serviceName"`
+}
+
+// Tracing exporters.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Auth configures how clients authenticate.
+type Auth struct {
+	// Enabled requires every request but the probes and /metrics to
+	// authenticate. Disable it only on trusted networks.
+	Enabled bool `yaml:"enabled"`
+	// APIKeys accepts the keys managed with the apikey command in the
+	// X-API-Key header. They are stored in the database, so the memory
+	// storage cannot use them.
+	APIKeys bool `yaml:"apiKeys"`
+	JWT     JWT  `yaml:"jwt"`
+}
+
+// JWT configures the bearer tokens accepted in the Authorization header.
+type JWT struct {
+	// KeySetFile is a JSON Web Key Set with the HS256 secrets and RS256
+	// public keys tokens may be signed with; empty disables bearer tokens.
+	KeySetFile string `yaml:"keySetFile"`
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// Leeway tolerates clock skew with the issuer on exp and nbf.
+	Leeway time.Duration `yaml:"leeway"`
+}
+
+// Features switches optional behaviour on or off.
+type Features struct {
+	// AutoMigrate applies pending schema migrations before serving.
+	AutoMigrate bool `yaml:"autoMigrate"`
+}
+
+// Storage drivers.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// Default returns the configuration used when nothing overrides it. It
+// matches the settings the server used to hardcode.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    64 << 10,
+			QueryTimeout:      5 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Storage: Storage{
+			Driver: DriverPostgres,
+			Postgres: Postgres{
+				Host:     "localhost",
+				Port:     5432,
+				Database: "task_manager",
+				SSLMode:  "disable",
+			},
+			SQLite: SQLite{DSN: "tasks.db"},
+			Pool: Pool{
+				MaxOpenConns:    10,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: 30 * time.Minute,
+				ConnMaxIdleTime: 5 * time.Minute,
+			},
+		},
+		Log: Log{Level: "info", Format: "json"},
+		Tracing: Tracing{
+			Exporter:     ExporterNone,
+			OTLPEndpoint: "http://localhost:4318",
+			ServiceName:  "task-manager",
+		},
+		Auth: Auth{
+			Enabled: true,
+			APIKeys: true,
+			JWT:     JWT{Leeway: time.Minute},
+		},
+		Workflow: *model.DefaultWorkflow(),
+	}
+}
+
+// Validate reports every inconsistency of the configuration at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	s := c.Server
+	_, _, err := net.SplitHostPort(s.Addr)
+	check(err == nil, "server.addr: %q is not a host:port address", s.Addr)
+	check((s.TLS.CertFile == "") == (s.TLS.KeyFile == ""), "server.tls: certFile and keyFile must be set together")
+	for name, d := range map[string]time.Duration{
+		"readHeaderTimeout": s.ReadHeaderTimeout,
+		"readTimeout":       s.ReadTimeout,
+		"writeTimeout":      s.WriteTimeout,
+		"idleTimeout":       s.IdleTimeout,
+		"queryTimeout":      s.QueryTimeout,
+		"shutdownDelay":     s.ShutdownDelay,
+	} {
+		check(d >= 0, "server.%s: must not be negative", name)
+	}
+	check(s.MaxHeaderBytes >= 0, "server.maxHeaderBytes: must not be negative")
+	check(s.ShutdownTimeout > 0, "server.shutdownTimeout: must be positive")
+	check(s.WriteTimeout == 0 || s.QueryTimeout == 0 || s.WriteTimeout > s.QueryTimeout,
+		"server.writeTimeout: must be longer than server.queryTimeout, or the response to a slow query is lost")
+
+	st := c.Storage
+	switch st.Driver {
+	case DriverPostgres:
+		p := st.Postgres
+		if p.DSN == "" {
+			check(p.Host != "", "storage.postgres.host: is required unless storage.postgres.dsn is set")
+			check(p.Port > 0 && p.Port < 65536, "storage.postgres.port: %d is not a valid port", p.Port)
+			check(p.Database != "", "storage.postgres.database: is required unless storage.postgres.dsn is set")
+			check(p.User != "", "storage.postgres.user: is required unless storage.postgres.dsn is set")
+		}
+		check(st.Pool.MaxOpenConns >= 0, "storage.pool.maxOpenConns: must not be negative")
+		check(st.Pool.MaxIdleConns >= 0, "storage.pool.maxIdleConns: must not be negative")
+		check(st.Pool.MaxOpenConns == 0 || st.Pool.MaxIdleConns <= st.Pool.MaxOpenConns,
+			"storage.pool.maxIdleConns: must not exceed storage.pool.maxOpenConns")
+		check(st.Pool.ConnMaxLifetime >= 0, "storage.pool.connMaxLifetime: must not be negative")
+		check(st.Pool.ConnMaxIdleTime >= 0, "storage.pool.connMaxIdleTime: must not be negative")
+	case DriverSQLite:
+		check(st.SQLite.DSN != "", "storage.sqlite.dsn: is required")
+	case DriverMemory:
+	default:
+		check(false, "storage.driver: %q is not one of postgres, sqlite or memory", st.Driver)
+	}
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level: %q is not one of debug, info, warn or error", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format: %q is not one of json or text", c.Log.Format)
+
+	tr := c.Tracing
+	switch tr.Exporter {
+	case ExporterNone, ExporterStdout:
+	case ExporterFile:
+		check(tr.File != "", "tracing.file: is required by the file exporter")
+	case ExporterOTLP:
+		u, err := url.Parse(tr.OTLPEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.otlpEndpoint: %q is not an http or https URL", tr.OTLPEndpoint)
+	default:
+		check(false, "tracing.exporter: %q is not one of none, stdout, file or otlp", tr.Exporter)
+	}
+	check(tr.ServiceName != "", "tracing.serviceName: is required")
+
+	a := c.Auth
+	check(a.JWT.Leeway >= 0, "auth.jwt.leeway: must not be negative")
+	check(!a.Enabled || a.JWT.KeySetFile != "" || a.APIKeys && st.Driver != DriverMemory,
+		"auth.enabled: no authentication method is usable; set auth.jwt.keySetFile, enable auth.apiKeys with a database, or disable auth")
+
+	if err := c.Workflow.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// redacted replaces secrets in the printed configuration.
+const redacted = "REDACTED"
+
+// dsnPassword matches the password parameter of a key=value DSN.
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// Redacted returns a copy of the configuration with its secrets replaced,
+// safe to print or log.
+func (c Config) Redacted() Config {
+	p := &c.Storage.Postgres
+	if p.Password != "" {
+		p.Password = redacted
+	}
+	p.DSN = redactDSN(p.DSN)
+	return c
+}
+
+// redactDSN hides the password of a URL or key=value DSN.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", redacted)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// String returns the redacted configuration as YAML, in the format of the
+// configuration file.
+func (c Config) String() string {
+	b, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("config: %s", err)
+	}
+	return string(b)
+}
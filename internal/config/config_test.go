@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a getenv function reading from vars.
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":9000"
+  queryTimeout: 2s
+storage:
+  postgres:
+    host: db.internal
+    user: file-user
+  pool:
+    maxOpenConns: 20
+`)
+	vars := map[string]string{
+		"CONFIG_FILE":   path,
+		"POSTGRES_USER": "env-user",
+		"QUERY_TIMEOUT": "3s",
+		"AUTO_MIGRATE":  "true",
+	}
+	cfg, rest, err := Load([]string{"-query-timeout=4s", "migrate", "up"}, env(vars), io.Discard)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	// Defaults survive where nothing overrides them.
+	if cfg.Storage.Postgres.Port != 5432 || cfg.Storage.Pool.MaxIdleConns != 5 {
+		t.Errorf("expected default port and idle connections, got %+v", cfg.Storage)
+	}
+	// The file overrides the defaults...
+	if cfg.Server.Addr != ":9000" || cfg.Storage.Postgres.Host != "db.internal" || cfg.Storage.Pool.MaxOpenConns != 20 {
+		t.Errorf("expected the file settings, got %+v", cfg)
+	}
+	// ...the environment overrides the file...
+	if cfg.Storage.Postgres.User != "env-user" || !cfg.Features.AutoMigrate {
+		t.Errorf("expected the environment settings, got %+v", cfg)
+	}
+	// ...and flags override everything.
+	if cfg.Server.QueryTimeout != 4*time.Second {
+		t.Errorf("expected the flag query timeout, got %s", cfg.Server.QueryTimeout)
+	}
+	if strings.Join(rest, " ") != "migrate up" {
+		t.Errorf("expected the remaining arguments, got %v", rest)
+	}
+}
+
+func TestLoadConfigFlagOverridesEnvironment(t *testing.T) {
+	envFile := writeFile(t, "storage:\n  driver: sqlite\n")
+	flagFile := writeFile(t, "storage:\n  driver: memory\nauth:\n  enabled: false\n")
+
+	cfg, _, err := Load([]string{"-config", flagFile}, env(map[string]string{"CONFIG_FILE": envFile}), io.Discard)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	if cfg.Storage.Driver != DriverMemory {
+		t.Errorf("expected the file named by -config, got driver %q", cfg.Storage.Driver)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		vars map[string]string
+		want string
+	}{
+		{"unknown file key", nil, map[string]string{"CONFIG_FILE": writeFile(t, "server:\n  adress: ':80'\n")}, "adress"},
+		{"missing file", []string{"-config", "does-not-exist.yaml"}, nil, "does-not-exist.yaml"},
+		{"bad env value", []string{"-storage=memory"}, map[string]string{"QUERY_TIMEOUT": "soon"}, "QUERY_TIMEOUT"},
+		{"bad flag value", []string{"-storage=memory", "-postgres-port=x"}, nil, "-postgres-port"},
+		{"missing user", nil, nil, "storage.postgres.user"},
+		{"unknown driver", []string{"-storage=mysql"}, nil, "storage.driver"},
+		{"half TLS", []string{"-storage=memory", "-tls-cert=cert.pem"}, nil, "server.tls"},
+		{"write timeout", []string{"-storage=memory", "-write-timeout=1s", "-query-timeout=5s"}, nil, "server.writeTimeout"},
+		{"bad workflow document", []string{"-storage=memory"}, map[string]string{"WORKFLOW": "statuses: Pending"}, "WORKFLOW"},
+		{"unknown workflow key", []string{"-storage=memory", `-workflow={"states": ["Pending"]}`}, nil, "states"},
+		{"invalid workflow", []string{"-storage=memory"}, map[string]string{"CONFIG_FILE": writeFile(t, "workflow:\n  statuses: [Pending, Completed]\n")}, "workflow: initial"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Load(tc.args, env(tc.vars), io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected an error mentioning %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadWorkflow(t *testing.T) {
+	path := writeFile(t, `
+storage:
+  driver: memory
+auth:
+  enabled: false
+workflow:
+  transitions:
+    - name: finish
+      from: [Pending, In Progress]
+      to: Completed
+    - name: reopen
+      from: [Completed]
+      to: Pending
+      explicit: true
+`)
+	cfg, _, err := Load(nil, env(map[string]string{"CONFIG_FILE": path}), io.Discard)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	// The file replaces the transitions and keeps the default statuses.
+	w := cfg.Workflow
+	if len(w.Statuses) != 4 || len(w.Transitions) != 2 || !w.Transitions[1].Explicit {
+		t.Errorf("expected the default statuses and the file transitions, got %+v", w)
+	}
+	if _, ok := w.Find("start"); ok {
+		t.Error("expected the default transitions to be replaced")
+	}
+
+	// The environment replaces the whole workflow.
+	vars := map[string]string{
+		"CONFIG_FILE": path,
+		"WORKFLOW":    `{"statuses": ["Pending", "Completed"], "initial": ["Pending"], "transitions": [{"name": "done", "from": ["Pending"], "to": "Completed"}]}`,
+	}
+	cfg, _, err = Load(nil, env(vars), io.Discard)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	if w := cfg.Workflow; len(w.Statuses) != 2 || len(w.Transitions) != 1 || w.Transitions[0].Name != "done" {
+		t.Errorf("expected the workflow of the environment, got %+v", w)
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	var usage strings.Builder
+	if _, _, err := Load([]string{"-h"}, env(nil), &usage); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("expected flag.ErrHelp, got %v", err)
+	}
+	if !strings.Contains(usage.String(), "POSTGRES_USER") {
+		t.Errorf("expected the usage to name the environment variables, got %q", usage.String())
+	}
+	if strings.Contains(usage.String(), "POSTGRES_PASSWORD") {
+		t.Error("the password must not be settable with a flag")
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Addr = "8080"
+	cfg.Storage.Pool.MaxIdleConns = 50
+	cfg.Log.Level = "verbose"
+	cfg.Log.Format = "xml"
+	cfg.Tracing.Exporter = ExporterOTLP
+	cfg.Tracing.OTLPEndpoint = "localhost:4318"
+	cfg.Auth.APIKeys = false
+
+	err := cfg.Validate()
+	for _, want := range []string{"server.addr", "storage.postgres.user", "storage.pool.maxIdleConns", "log.level", "log.format", "tracing.otlpEndpoint", "auth.enabled"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error mentioning %q, got %v", want, err)
+		}
+	}
+}
+
+func TestConnString(t *testing.T) {
+	p := Default().Storage.Postgres
+	p.User = "tasks"
+	p.Password = `it's secret`
+	want := `host=localhost port=5432 dbname=task_manager user=tasks password='it\'s secret' sslmode=disable`
+	if got := p.ConnString(); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	p.DSN = "postgres://tasks@db/task_manager"
+	if got := p.ConnString(); got != p.DSN {
+		t.Errorf("expected the DSN to be used as is, got %s", got)
+	}
+}
+
+func TestStringRedactsSecrets(t *testing.T) {
+	cases := []struct {
+		dsn, password string
+	}{
+		{"postgres://tasks:hunter2@db/task_manager?sslmode=require", ""},
+		{"postgres://tasks@db/task_manager?password=hunter2", ""},
+		{"host=db user=tasks password='hunter2' dbname=task_manager", ""},
+		{"host=db user=tasks password=hunter2", ""},
+		{"", "hunter2"},
+	}
+	for _, tc := range cases {
+		cfg := Default()
+		cfg.Storage.Postgres.DSN = tc.dsn
+		cfg.Storage.Postgres.Password = tc.password
+
+		out := cfg.String()
+		if strings.Contains(out, "hunter2") || !strings.Contains(out, redacted) {
+			t.Errorf("expected the password to be redacted, got:\n%s", out)
+		}
+		if cfg.Storage.Postgres.Password != tc.password {
+			t.Error("String must not modify the configuration")
+		}
+	}
+
+	if out := Default().String(); !strings.Contains(out, "queryTimeout: 5s") {
+		t.Errorf("expected durations in the file format, got:\n%s", out)
+	}
+}
+
+func TestExampleFileMatchesDefaults(t *testing.T) {
+	cfg := Default()
+	if err := readFile(&cfg, "../../config.example.yaml"); err != nil {
+		t.Fatalf("readFile: %s", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("config.example.yaml differs from the defaults:\n%s", cfg)
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a configuration value that can be overridden from the
+// environment and the command line. Every field of Config that is not only
+// set in the file is listed in settings.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	// field returns a pointer to the value within c.
+	field func(c *Config) any
+}
+
+// settings lists the overridable values. The environment variable names of
+// PostgreSQL follow the conventions of the official container image.
+var settings = []setting{
+	{"addr", "LISTEN_ADDR", "address to listen on", func(c *Config) any { return &c.Server.Addr }},
+	{"tls-cert", "TLS_CERT_FILE", "TLS certificate file; enables HTTPS together with -tls-key", func(c *Config) any { return &c.Server.TLS.CertFile }},
+	{"tls-key", "TLS_KEY_FILE", "TLS private key file", func(c *Config) any { return &c.Server.TLS.KeyFile }},
+	{"read-header-timeout", "READ_HEADER_TIMEOUT", "time allowed to read request headers", func(c *Config) any { return &c.Server.ReadHeaderTimeout }},
+	{"read-timeout", "READ_TIMEOUT", "time allowed to read a whole request", func(c *Config) any { return &c.Server.ReadTimeout }},
+	{"write-timeout", "WRITE_TIMEOUT", "time allowed to write a response", func(c *Config) any { return &c.Server.WriteTimeout }},
+	{"idle-timeout", "IDLE_TIMEOUT", "time an idle keep-alive connection is kept open", func(c *Config) any { return &c.Server.IdleTimeout }},
+	{"max-header-bytes", "MAX_HEADER_BYTES", "maximum size of the request headers in bytes; 0 means the net/http default of 1 MiB", func(c *Config) any { return &c.Server.MaxHeaderBytes }},
+	{"query-timeout", "QUERY_TIMEOUT", "time a request may spend on its storage work before it fails with 504; 0 disables the limit", func(c *Config) any { return &c.Server.QueryTimeout }},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "time allowed on SIGINT or SIGTERM to drain requests and close the database", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"shutdown-delay", "SHUTDOWN_DELAY", "time to keep serving with /readyz failing after SIGINT or SIGTERM, before draining", func(c *Config) any { return &c.Server.ShutdownDelay }},
+
+	{"storage", "STORAGE", "task storage: postgres, sqlite, or memory to keep tasks in process memory (lost on exit)", func(c *Config) any { return &c.Storage.Driver }},
+	{"postgres-dsn", "POSTGRES_DSN", "PostgreSQL connection string; overrides the other -postgres-* settings", func(c *Config) any { return &c.Storage.Postgres.DSN }},
+	{"postgres-host", "POSTGRES_HOST", "PostgreSQL host", func(c *Config) any { return &c.Storage.Postgres.Host }},
+	{"postgres-port", "POSTGRES_PORT", "PostgreSQL port", func(c *Config) any { return &c.Storage.Postgres.Port }},
+	{"postgres-db", "POSTGRES_DB", "PostgreSQL database name", func(c *Config) any { return &c.Storage.Postgres.Database }},
+	{"postgres-user", "POSTGRES_USER", "PostgreSQL user", func(c *Config) any { return &c.Storage.Postgres.User }},
+	// No flag: command lines are visible to other users of the machine.
+	{"", "POSTGRES_PASSWORD", "", func(c *Config) any { return &c.Storage.Postgres.Password }},
+	{"postgres-sslmode", "POSTGRES_SSLMODE", "PostgreSQL sslmode", func(c *Config) any { return &c.Storage.Postgres.SSLMode }},
+	{"sqlite-dsn", "SQLITE_DSN", "SQLite database file or DSN used by -storage=sqlite", func(c *Config) any { return &c.Storage.SQLite.DSN }},
+	{"db-max-open-conns", "DB_MAX_OPEN_CONNS", "maximum open database connections; 0 means unlimited", func(c *Config) any { return &c.Storage.Pool.MaxOpenConns }},
+	{"db-max-idle-conns", "DB_MAX_IDLE_CONNS", "maximum idle database connections", func(c *Config) any { return &c.Storage.Pool.MaxIdleConns }},
+	{"db-conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "maximum lifetime of a database connection; 0 means unlimited", func(c *Config) any { return &c.Storage.Pool.ConnMaxLifetime }},
+	{"db-conn-max-idle-time", "DB_CONN_MAX_IDLE_TIME", "maximum idle time of a database connection; 0 means unlimited", func(c *Config) any { return &c.Storage.Pool.ConnMaxIdleTime }},
+
+	{"log-level", "LOG_LEVEL", "minimum log level: debug, info, warn or error", func(c *Config) any { return &c.Log.Level }},
+	{"log-format", "LOG_FORMAT", "log format: json or text", func(c *Config) any { return &c.Log.Format }},
+
+	// The OTEL_* names are those of the OpenTelemetry SDKs.
+	{"tracing-exporter", "TRACING_EXPORTER", "where spans are exported: none, stdout, file or otlp", func(c *Config) any { return &c.Tracing.Exporter }},
+	{"tracing-file", "TRACING_FILE", "file receiving the spans as JSON lines with -tracing-exporter=file", func(c *Config) any { return &c.Tracing.File }},
+	{"otlp-endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", "base URL of the OTLP/HTTP collector used by -tracing-exporter=otlp", func(c *Config) any { return &c.Tracing.OTLPEndpoint }},
+	{"service-name", "OTEL_SERVICE_NAME", "service name recorded in the traces", func(c *Config) any { return &c.Tracing.ServiceName }},
+
+	{"auth", "AUTH_ENABLED", "require clients to authenticate with an API key or a bearer token", func(c *Config) any { return &c.Auth.Enabled }},
+	{"auth-api-keys", "AUTH_API_KEYS", "accept the API keys stored in the database in the X-API-Key header", func(c *Config) any { return &c.Auth.APIKeys }},
+	{"jwt-key-set", "JWT_KEY_SET_FILE", "JSON Web Key Set file with the keys bearer tokens may be signed with; empty disables bearer tokens", func(c *Config) any { return &c.Auth.JWT.KeySetFile }},
+	{"jwt-issuer", "JWT_ISSUER", "required iss claim of bearer tokens", func(c *Config) any { return &c.Auth.JWT.Issuer }},
+	{"jwt-audience", "JWT_AUDIENCE", "required aud claim of bearer tokens", func(c *Config) any { return &c.Auth.JWT.Audience }},
+	{"jwt-leeway", "JWT_LEEWAY", "clock skew tolerated on the exp and nbf claims of bearer tokens", func(c *Config) any { return &c.Auth.JWT.Leeway }},
+
+	{"auto-migrate", "AUTO_MIGRATE", "apply pending schema migrations before serving", func(c *Config) any { return &c.Features.AutoMigrate }},
+
+	{"workflow", "WORKFLOW", "task workflow as a YAML or JSON document in the format of GET /workflow; replaces the configured one", func(c *Config) any { return &c.Workflow }},
+}
+
+// Load builds the configuration from the command-line arguments (without
+// the program name) and the environment, as read by getenv. The file named
+// by -config or CONFIG_FILE is read first, then environment variables and
+// flags override it. Load returns the validated configuration and the
+// arguments left after the flags.
+//
+// The usage of the flags is written to usage when -h is given, in which
+// case Load returns flag.ErrHelp.
+func Load(args []string, getenv func(string) string, usage io.Writer) (Config, []string, error) {
+	fs := flag.NewFlagSet("task-manager", flag.ContinueOnError)
+	fs.SetOutput(usage)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "YAML configuration file (env CONFIG_FILE)")
+
+	// Flags are only recorded while parsing: they must win over the file
+	// and the environment, which are read afterwards.
+	flags := make(map[string]string)
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		name, help := s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		record := func(v string) error { flags[name] = v; return nil }
+		if _, ok := s.field(&Config{}).(*bool); ok {
+			fs.BoolFunc(name, help, record)
+		} else {
+			fs.Func(name, help, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := readFile(&cfg, *configFile); err != nil {
+			return Config{}, nil, err
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := set(s.field(&cfg), v); err != nil {
+				return Config{}, nil, fmt.Errorf("config: %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flags[s.flag]; ok && s.flag != "" {
+			if err := set(s.field(&cfg), v); err != nil {
+				return Config{}, nil, fmt.Errorf("config: -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, fmt.Errorf("config: invalid configuration:\n%w", err)
+	}
+	return cfg, fs.Args(), nil
+}
+
+// readFile overlays the YAML file at path onto cfg. Keys the file omits keep
+// their current value; unknown keys are rejected, as they are most likely
+// typos.
+func readFile(cfg *Config, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// set parses v into the field pointed to by ptr.
+func set(ptr any, v string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = v
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", v)
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 5s or 1m30s", v)
+		}
+		*p = d
+	case *model.Workflow:
+		var w model.Workflow
+		dec := yaml.NewDecoder(strings.NewReader(v))
+		dec.KnownFields(true)
+		if err := dec.Decode(&w); err != nil {
+			return fmt.Errorf("not a workflow document: %w", err)
+		}
+		*p = w
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %T", ptr))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package health serves the liveness and readiness probes of the server.
+// Liveness only tells that the process is running and able to answer HTTP;
+// readiness runs a set of checks, such as database connectivity, and reports
+// each of them so an orchestrator knows whether to route traffic here.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultCheckTimeout bounds every readiness check, so that a hanging
+// database makes the probe fail rather than time out.
+const DefaultCheckTimeout = 2 * time.Second
+
+// Check is one readiness condition. Run returns nil when the condition holds
+// and must give up when ctx is done.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Report is the JSON body of a probe response.
+type Report struct {
+	// Status is "ok" when every check passed and "unavailable" otherwise.
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// LatencyMs is the time the check took, in milliseconds.
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report and check statuses.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Liveness answers 200 as long as the process serves HTTP. It deliberately
+// checks nothing else: restarting the process would not fix a database
+// outage.
+func Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+// Readiness runs the checks concurrently, each bounded by timeout, and
+// answers 200 when all pass and 503 otherwise, with the result of every
+// check in the body.
+func Readiness(timeout time.Duration, checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := make([]CheckResult, len(checks))
+		var wg sync.WaitGroup
+		for i, check := range checks {
+			wg.Add(1)
+			go func(i int, check Check) {
+				defer wg.Done()
+				results[i] = run(r.Context(), timeout, check)
+			}(i, check)
+		}
+		wg.Wait()
+
+		report, status := Report{Status: StatusOK, Checks: results}, http.StatusOK
+		for _, result := range results {
+			if result.Status != StatusOK {
+				report.Status, status = StatusUnavailable, http.StatusServiceUnavailable
+			}
+		}
+		writeReport(w, status, report)
+	})
+}
+
+// run runs a single check and times it.
+func run(ctx context.Context, timeout time.Duration, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result := CheckResult{
+		Name:      check.Name,
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status, result.Error = StatusUnavailable, err.Error()
+	}
+	return result
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	// Probes must always reach the server.
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Database checks that db accepts connections.
+func Database(db *sql.DB) Check {
+	return Check{Name: "database", Run: db.PingContext}
+}
+
+// Migrator is the part of migrations.Migrator the migrations check needs.
+type Migrator interface {
+	Version(ctx context.Context) (int, error)
+	Latest() int
+}
+
+// Migrations checks that the database schema is at the version the binary
+// expects. A server whose schema is behind would fail on the first query
+// touching the missing parts; one whose schema is ahead may be a rolled back
+// release that must not receive traffic either.
+func Migrations(m Migrator) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		version, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if want := m.Latest(); version != want {
+			return fmt.Errorf("schema is at version %d, expected %d", version, want)
+		}
+		return nil
+	}}
+}
+
+// errShuttingDown is reported by the shutdown check.
+var errShuttingDown = errors.New("server is shutting down")
+
+// Shutdown fails once shuttingDown reports true, so that load balancers stop
+// routing new requests to a server that is draining.
+func Shutdown(shuttingDown func() bool) Check {
+	return Check{Name: "shutdown", Run: func(context.Context) error {
+		if shuttingDown() {
+			return errShuttingDown
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func probe(t *testing.T, h http.Handler) (int, Report) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	var report Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("could not decode %q: %v", rr.Body.String(), err)
+	}
+	return rr.Code, report
+}
+
+func TestLiveness(t *testing.T) {
+	if status, report := probe(t, Liveness()); status != http.StatusOK || report.Status != StatusOK {
+		t.Errorf("expected 200 ok, got %d %+v", status, report)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	ok := Check{Name: "ok", Run: func(context.Context) error { return nil }}
+	failing := Check{Name: "failing", Run: func(context.Context) error { return errors.New("boom") }}
+	hanging := Check{Name: "hanging", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	status, report := probe(t, Readiness(time.Second, ok))
+	if status != http.StatusOK || report.Status != StatusOK || len(report.Checks) != 1 || report.Checks[0].Name != "ok" {
+		t.Errorf("expected 200 with one passing check, got %d %+v", status, report)
+	}
+
+	status, report = probe(t, Readiness(10*time.Millisecond, ok, failing, hanging))
+	if status != http.StatusServiceUnavailable || report.Status != StatusUnavailable {
+		t.Fatalf("expected 503 unavailable, got %d %+v", status, report)
+	}
+	want := []struct{ name, status, err string }{
+		{"ok", StatusOK, ""},
+		{"failing", StatusUnavailable, "boom"},
+		{"hanging", StatusUnavailable, context.DeadlineExceeded.Error()},
+	}
+	for i, w := range want {
+		got := report.Checks[i]
+		if got.Name != w.name || got.Status != w.status || got.Error != w.err {
+			t.Errorf("check %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+	if report.Checks[2].LatencyMs < 10 {
+		t.Errorf("expected the hanging check to take the timeout, got %vms", report.Checks[2].LatencyMs)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	check := Database(db)
+	if err := check.Run(context.Background()); err != nil {
+		t.Errorf("expected the first ping to pass, got %v", err)
+	}
+	if err := check.Run(context.Background()); err == nil {
+		t.Error("expected the second ping to fail")
+	}
+}
+
+type fakeMigrator struct {
+	version, latest int
+	err             error
+}
+
+func (m fakeMigrator) Version(context.Context) (int, error) { return m.version, m.err }
+func (m fakeMigrator) Latest() int                          { return m.latest }
+
+func TestMigrations(t *testing.T) {
+	cases := []struct {
+		m    fakeMigrator
+		fail bool
+	}{
+		{fakeMigrator{version: 3, latest: 3}, false},
+		{fakeMigrator{version: 2, latest: 3}, true},
+		{fakeMigrator{version: 4, latest: 3}, true},
+		{fakeMigrator{err: errors.New("no table")}, true},
+	}
+	for _, tc := range cases {
+		if err := Migrations(tc.m).Run(context.Background()); (err != nil) != tc.fail {
+			t.Errorf("%+v: expected failure %v, got %v", tc.m, tc.fail, err)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	shuttingDown := false
+	check := Shutdown(func() bool { return shuttingDown })
+	if err := check.Run(context.Background()); err != nil {
+		t.Errorf("expected ready before the shutdown, got %v", err)
+	}
+	shuttingDown = true
+	if err := check.Run(context.Background()); !errors.Is(err, errShuttingDown) {
+		t.Errorf("expected errShuttingDown, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("dropped")
+	logger.Warn("kept", "task", 7)
+	if got := buf.String(); strings.Contains(got, "dropped") || !strings.Contains(got, `"msg":"kept","task":7`) {
+		t.Errorf("expected only the warning as JSON, got %q", got)
+	}
+
+	buf.Reset()
+	logger, err = New(&buf, "DEBUG", FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("kept")
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG msg=kept") {
+		t.Errorf("expected a text debug line, got %q", got)
+	}
+
+	if _, err := New(&buf, "verbose", FormatJSON); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+	if _, err := New(&buf, "info", "xml"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if FromContext(ctx) != slog.Default() || RequestID(ctx) != "" {
+		t.Error("expected the default logger and no request ID in an empty context")
+	}
+
+	logger := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	ctx = WithRequestID(WithLogger(ctx, logger), "req-1")
+	if FromContext(ctx) != logger || RequestID(ctx) != "req-1" {
+		t.Error("expected the logger and request ID carried by the context")
+	}
+}
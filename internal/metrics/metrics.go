@@ -0,0 +1,311 @@
+// Package metrics keeps counters, histograms and gauges in memory and serves
+// them at /metrics in the Prometheus text exposition format (version 0.0.4),
+// so that any Prometheus-compatible scraper can collect them. It implements
+// only what the server needs: labeled counters and histograms, and gauges
+// and counters read from a function at scrape time.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are histogram buckets, in seconds, suited to request and query
+// latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// family is a named metric with all its label combinations.
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metric families served by its Handler.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+var nameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// register adds f to the registry. It panics on an invalid or duplicate
+// name, both programming errors.
+func (r *Registry) register(f family, labels []string) {
+	if !nameRE.MatchString(f.name()) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", f.name()))
+	}
+	for _, l := range labels {
+		if !nameRE.MatchString(l) || strings.Contains(l, ":") || strings.HasPrefix(l, "__") || l == "le" {
+			panic(fmt.Sprintf("metrics: invalid label name %q for %s", l, f.name()))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[f.name()]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name()))
+	}
+	r.families[f.name()] = f
+}
+
+// WriteTo writes every family, sorted by name, in the text exposition
+// format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler serves the registry to scrapers.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		r.WriteTo(w)
+	})
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// CounterVec is a counter per combination of label values.
+type CounterVec struct {
+	meta
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+// NewCounterVec registers a counter with the given label names. Counter
+// names end in _total by convention.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{meta: meta{n: name, help: help, typ: "counter", labels: labels}, values: make(map[string]*counterValue)}
+	r.register(c, labels)
+	return c
+}
+
+// Add adds v, which must not be negative, to the counter of the label
+// values, given in the order of the label names.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s decreased", c.n))
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cv, ok := c.values[key]
+	if !ok {
+		cv = &counterValue{labels: append([]string(nil), labelValues...)}
+		c.values[key] = cv
+	}
+	cv.value += v
+}
+
+// Inc adds one to the counter of the label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		cv := c.values[key]
+		writeSample(w, c.n, c.labels, cv.labels, "", "", cv.value)
+	}
+}
+
+// HistogramVec is a histogram per combination of label values.
+type HistogramVec struct {
+	meta
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	// counts holds the observations per bucket, not cumulated; the last
+	// element counts those above every bound.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket bounds,
+// in increasing order, and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s are not sorted", name))
+	}
+	h := &HistogramVec{
+		meta:    meta{n: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+	r.register(h, labels)
+	return h
+}
+
+// Observe records v in the histogram of the label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets)+1)}
+		h.values[key] = hv
+	}
+	hv.counts[sort.SearchFloat64s(h.buckets, v)]++
+	hv.sum += v
+	hv.count++
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += hv.counts[i]
+			writeSample(w, h.n+"_bucket", h.labels, hv.labels, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, h.n+"_bucket", h.labels, hv.labels, "le", "+Inf", float64(hv.count))
+		writeSample(w, h.n+"_sum", h.labels, hv.labels, "", "", hv.sum)
+		writeSample(w, h.n+"_count", h.labels, hv.labels, "", "", float64(hv.count))
+	}
+}
+
+// funcMetric is an unlabeled gauge or counter read at scrape time.
+type funcMetric struct {
+	meta
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is fn's result at scrape time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{meta: meta{n: name, help: help, typ: "gauge"}, fn: fn}, nil)
+}
+
+// NewCounterFunc registers a counter whose value is fn's result at scrape
+// time; fn must never return less than it did before.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{meta: meta{n: name, help: help, typ: "counter"}, fn: fn}, nil)
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.writeHeader(w)
+	writeSample(w, f.n, nil, nil, "", "", f.fn())
+}
+
+// meta holds what every family has in common.
+type meta struct {
+	n, help, typ string
+	labels       []string
+}
+
+func (m *meta) name() string { return m.n }
+
+// key identifies a combination of label values. It panics when their number
+// does not match the label names.
+func (m *meta) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", m.n, len(m.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m *meta) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.n, helpEscaper.Replace(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.n, m.typ)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// writeSample writes one sample line; extraName and extraValue add a label
+// such as the le of a histogram bucket.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		sep := ""
+		for i, l := range labels {
+			fmt.Fprintf(w, `%s%s="%s"`, sep, l, labelEscaper.Replace(values[i]))
+			sep = ","
+		}
+		if extraName != "" {
+			fmt.Fprintf(w, `%s%s="%s"`, sep, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
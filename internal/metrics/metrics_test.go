@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("expected %q, got %q", ContentType, ct)
+	}
+	return rr.Body.String()
+}
+
+func TestExpositionFormat(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("jobs_total", "Jobs run.\nBy queue.", "queue")
+	c.Inc("mail")
+	c.Add(2.5, `say "hi"\now`)
+	h := r.NewHistogramVec("job_seconds", "Job duration.", []float64{0.1, 1}, "queue")
+	h.Observe(0.05, "mail")
+	h.Observe(0.1, "mail")
+	h.Observe(3, "mail")
+	r.NewGaugeFunc("queue_length", "Queued jobs.", func() float64 { return 7 })
+
+	want := `# HELP job_seconds Job duration.
+# TYPE job_seconds histogram
+job_seconds_bucket{queue="mail",le="0.1"} 2
+job_seconds_bucket{queue="mail",le="1"} 2
+job_seconds_bucket{queue="mail",le="+Inf"} 3
+job_seconds_sum{queue="mail"} 3.15
+job_seconds_count{queue="mail"} 3
+# HELP jobs_total Jobs run.\nBy queue.
+# TYPE jobs_total counter
+jobs_total{queue="mail"} 1
+jobs_total{queue="say \"hi\"\\now"} 2.5
+# HELP queue_length Queued jobs.
+# TYPE queue_length gauge
+queue_length 7
+`
+	if got := scrape(t, r); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestRegistryRejectsMistakes(t *testing.T) {
+	for name, fn := range map[string]func(r *Registry){
+		"duplicate": func(r *Registry) {
+			r.NewCounterVec("a_total", "")
+			r.NewCounterVec("a_total", "")
+		},
+		"invalid name": func(r *Registry) { r.NewCounterVec("a-total", "") },
+		"reserved le":  func(r *Registry) { r.NewHistogramVec("a_seconds", "", DefBuckets, "le") },
+		"label count":  func(r *Registry) { r.NewCounterVec("a_total", "", "x").Inc() },
+		"negative add": func(r *Registry) { r.NewCounterVec("a_total", "").Add(-1) },
+		"bucket order": func(r *Registry) { r.NewHistogramVec("a_seconds", "", []float64{1, 0.5}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			fn(NewRegistry())
+		}()
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	r := NewRegistry()
+	m := NewHTTP(r)
+	m.ObserveRequest("/tasks/{id}", "GET", 404, 30*time.Millisecond)
+
+	out := scrape(t, r)
+	for _, line := range []string{
+		`http_requests_total{route="/tasks/{id}",method="GET",status="404"} 1`,
+		`http_request_duration_seconds_bucket{route="/tasks/{id}",method="GET",status="404",le="0.025"} 0`,
+		`http_request_duration_seconds_bucket{route="/tasks/{id}",method="GET",status="404",le="0.05"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected %s in\n%s", line, out)
+		}
+	}
+}
+
+func TestRepo(t *testing.T) {
+	r := NewRegistry()
+	m := NewRepo(r)
+	observe := func(op string, err error) {
+		_, done := m.Observe(context.Background(), op)
+		done(err)
+	}
+	observe(repo.OpGetByID, nil)
+	observe(repo.OpGetByID, fmt.Errorf("task 3: %w", repo.ErrNotFound))
+	observe(repo.OpUpdate, repo.ErrVersionMismatch)
+	observe(repo.OpUpdate, errors.New("boom"))
+
+	out := scrape(t, r)
+	for _, line := range []string{
+		`task_repository_operation_duration_seconds_count{operation="GetByID"} 2`,
+		`task_repository_operation_duration_seconds_count{operation="Update"} 2`,
+		`task_repository_errors_total{operation="GetByID",kind="not_found"} 1`,
+		`task_repository_errors_total{operation="Update",kind="internal"} 1`,
+		`task_repository_errors_total{operation="Update",kind="version_mismatch"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected %s in\n%s", line, out)
+		}
+	}
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	r := NewRegistry()
+	RegisterDBStats(r, db)
+	out := scrape(t, r)
+	for _, line := range []string{
+		"# TYPE db_max_open_connections gauge\ndb_max_open_connections 7\n",
+		"# TYPE db_wait_count_total counter\ndb_wait_count_total 0\n",
+		"db_in_use_connections 0\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in\n%s", line, out)
+		}
+	}
+}
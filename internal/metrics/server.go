@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// HTTP holds the request metrics of the server.
+type HTTP struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+// NewHTTP registers the request metrics in r.
+func NewHTTP(r *Registry) *HTTP {
+	return &HTTP{
+		requests: r.NewCounterVec("http_requests_total",
+			"HTTP requests served, by route template, method and status code.",
+			"route", "method", "status"),
+		duration: r.NewHistogramVec("http_request_duration_seconds",
+			"Time taken to serve HTTP requests, by route template, method and status code.",
+			DefBuckets, "route", "method", "status"),
+	}
+}
+
+// ObserveRequest records a served request. route must be a route template
+// such as /tasks/{id}, never the raw path, to keep the number of series
+// bounded.
+func (h *HTTP) ObserveRequest(route, method string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	h.requests.Inc(route, method, code)
+	h.duration.Observe(d.Seconds(), route, method, code)
+}
+
+// Repo holds the TaskRepository metrics; it is a repo.Observer.
+type Repo struct {
+	duration *HistogramVec
+	errors   *CounterVec
+}
+
+var _ repo.Observer = (*Repo)(nil)
+
+// NewRepo registers the TaskRepository metrics in r.
+func NewRepo(r *Registry) *Repo {
+	return &Repo{
+		duration: r.NewHistogramVec("task_repository_operation_duration_seconds",
+			"Time taken by TaskRepository operations, by operation.",
+			DefBuckets, "operation"),
+		errors: r.NewCounterVec("task_repository_errors_total",
+			"TaskRepository operations that failed, by operation and error kind.",
+			"operation", "kind"),
+	}
+}
+
+// Observe times the operation and counts its error, if any.
+func (m *Repo) Observe(ctx context.Context, op string) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		m.duration.Observe(time.Since(start).Seconds(), op)
+		if err != nil {
+			m.errors.Inc(op, errorKind(err))
+		}
+	}
+}
+
+// errorKind names the sentinel error err wraps, so that e.g. a burst of
+// not-found answers can be told apart from a database outage.
+func errorKind(err error) string {
+	for _, kind := range []struct {
+		err  error
+		name string
+	}{
+		{repo.ErrNotFound, "not_found"},
+		{repo.ErrVersionMismatch, "version_mismatch"},
+		{repo.ErrConflict, "conflict"},
+		{repo.ErrValidation, "validation"},
+		{repo.ErrUnavailable, "unavailable"},
+		{repo.ErrTimeout, "timeout"},
+		{repo.ErrCanceled, "canceled"},
+	} {
+		if errors.Is(err, kind.err) {
+			return kind.name
+		}
+	}
+	return "internal"
+}
+
+// RegisterDBStats registers gauges and counters of the connection pool of
+// db in r, read from db.Stats at scrape time.
+func RegisterDBStats(r *Registry, db *sql.DB) {
+	gauge := func(name, help string, fn func(s sql.DBStats) float64) {
+		r.NewGaugeFunc(name, help, func() float64 { return fn(db.Stats()) })
+	}
+	counter := func(name, help string, fn func(s sql.DBStats) float64) {
+		r.NewCounterFunc(name, help, func() float64 { return fn(db.Stats()) })
+	}
+
+	gauge("db_max_open_connections", "Maximum number of open connections to the database.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("db_open_connections", "Established connections, both in use and idle.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("db_in_use_connections", "Connections currently in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("db_idle_connections", "Idle connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("db_wait_count_total", "Connections waited for because the pool was exhausted.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("db_wait_duration_seconds_total", "Time spent waiting for a connection.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("db_max_idle_closed_total", "Connections closed because of the idle connection limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("db_max_idle_time_closed_total", "Connections closed because they were idle too long.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) })
+	counter("db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+}
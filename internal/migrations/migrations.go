@@ -0,0 +1,100 @@
+// Package migrations evolves the database schema with versioned SQL scripts.
+// Each migration is a pair of files named NNNN_name.up.sql and
+// NNNN_name.down.sql; the scripts of the supported databases are embedded in
+// the binary, so a deployment needs nothing but the executable.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Migration is one step of the schema history.
+type Migration struct {
+	Version int
+	Name    string
+	// Up applies the step and Down reverts it.
+	Up   string
+	Down string
+}
+
+// String returns the file name stem of the migration, e.g. 0002_add_version.
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+//go:embed postgres/*.sql
+var postgresFiles embed.FS
+
+// Postgres returns the migrations of the PostgreSQL schema.
+func Postgres() ([]Migration, error) {
+	return Load(postgresFiles, "postgres")
+}
+
+// fileName matches migration file names and captures the version, name and
+// direction.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// SQLite returns the migrations of the SQLite schema. SQLite has a history
+// of its own: it starts from the current PostgreSQL schema, and dates and
+// priorities are stored the SQLite way.
+func SQLite() ([]Migration, error) {
+	return Load(sqliteFiles, "sqlite")
+}
+
+// Load reads the migrations stored in dir of fsys, ordered by version. Every
+// version needs both an up and a down script; other files are ignored.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrations: %s: invalid version", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("migrations: %w", err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d is used by both %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: %s needs both an up and a down script", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_add_column.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN c INT;")},
+		"sql/0002_add_column.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		"sql/0001_create.up.sql":       {Data: []byte("CREATE TABLE t (id INT);")},
+		"sql/0001_create.down.sql":     {Data: []byte("DROP TABLE t;")},
+		"sql/README.md":                {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys, "sql")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if m := migrations[0]; m.Version != 1 || m.Name != "create" || m.Down != "DROP TABLE t;" {
+		t.Errorf("unexpected first migration %+v", m)
+	}
+	if s := migrations[1].String(); s != "0002_add_column" {
+		t.Errorf("expected 0002_add_column, got %s", s)
+	}
+}
+
+func TestLoadRejectsInconsistentFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing down": {
+			"sql/0001_create.up.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		},
+		"duplicate version": {
+			"sql/0001_create.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"sql/0001_create.down.sql": {Data: []byte("DROP TABLE t;")},
+			"sql/0001_other.up.sql":    {Data: []byte("CREATE TABLE u (id INT);")},
+			"sql/0001_other.down.sql":  {Data: []byte("DROP TABLE u;")},
+		},
+	}
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(fsys, "sql"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	for name, load := range map[string]func() ([]Migration, error){
+		"postgres": Postgres,
+		"sqlite":   SQLite,
+	} {
+		migrations, err := load()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s: expected contiguous versions, got %s at position %d", name, m, i)
+			}
+		}
+	}
+}
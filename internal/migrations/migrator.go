@@ -0,0 +1,284 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// lockKey identifies the PostgreSQL advisory lock held while migrating, so
+// that replicas starting at the same time apply each migration only once.
+// The value is arbitrary but must be the same for every replica.
+const lockKey int64 = 0x7461736b6d6967 // "taskmig"
+
+// dialect holds the database specific statements of a Migrator.
+type dialect struct {
+	// createTable creates the table recording the applied migrations.
+	createTable string
+	// lock and unlock serialise concurrent migrators; they are empty when
+	// the database needs no lock.
+	lock, unlock string
+}
+
+var postgres = dialect{
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`,
+	lock:   "SELECT pg_advisory_lock($1)",
+	unlock: "SELECT pg_advisory_unlock($1)",
+}
+
+// sqlite needs no lock: a database file belongs to a single server, and
+// SQLite serialises write transactions anyway.
+var sqlite = dialect{
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+}
+
+// ErrUnknownVersion is returned when the database has a migration applied
+// that the binary does not know, typically because a newer release migrated
+// it. Such a database is left untouched.
+var ErrUnknownVersion = errors.New("migrations: database has unknown migrations applied")
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to a PostgreSQL or SQLite database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+// New creates a Migrator for a PostgreSQL database and the given migrations,
+// as returned by Load.
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: postgres, migrations: migrations}
+}
+
+// NewSQLite creates a Migrator for a SQLite database and the given
+// migrations, as returned by SQLite.
+func NewSQLite(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: sqlite, migrations: migrations}
+}
+
+// Latest returns the version of the newest known migration, or 0 when there
+// are none.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies all pending migrations and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the most recently applied migration and returns it. It does
+// nothing when no migration is applied.
+func (m *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok {
+				if err := revert(ctx, conn, mig); err != nil {
+					return err
+				}
+				done = append(done, mig)
+				return nil
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// To migrates the database up or down to the given version and returns the
+// migrations applied or reverted, in order. Version 0 reverts everything.
+func (m *Migrator) To(ctx context.Context, version int) ([]Migration, error) {
+	if version != 0 && !m.has(version) {
+		return nil, fmt.Errorf("migrations: unknown version %d", version)
+	}
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(applied); err != nil {
+			return err
+		}
+		// Revert newer migrations first, newest first, then apply the
+		// missing older ones, oldest first.
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > version {
+				if err := revert(ctx, conn, mig); err != nil {
+					return err
+				}
+				done = append(done, mig)
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+				if err := apply(ctx, conn, mig); err != nil {
+					return err
+				}
+				done = append(done, mig)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists the known migrations and whether they are applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			at, ok := applied[mig.Version]
+			statuses = append(statuses, MigrationStatus{Migration: mig, Applied: ok, AppliedAt: at})
+		}
+		return m.checkKnown(applied)
+	})
+	return statuses, err
+}
+
+// Version returns the newest applied migration version, 0 when none is
+// applied.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	err := m.db.QueryRowContext(ctx, "SELECT max(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("migrations: reading schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// has reports whether version is one of the known migrations.
+func (m *Migrator) has(version int) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKnown fails with ErrUnknownVersion if an applied version is missing
+// from the known migrations.
+func (m *Migrator) checkKnown(applied map[int]time.Time) error {
+	for version := range applied {
+		if !m.has(version) {
+			return fmt.Errorf("%w (version %d)", ErrUnknownVersion, version)
+		}
+	}
+	return nil
+}
+
+// withLock runs fn on a connection holding the migration lock, after making
+// sure the schema_migrations table exists. PostgreSQL advisory locks belong
+// to the session, so everything runs on that one connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+	defer conn.Close()
+
+	if m.dialect.lock != "" {
+		if _, err := conn.ExecContext(ctx, m.dialect.lock, lockKey); err != nil {
+			return fmt.Errorf("migrations: acquiring lock: %w", err)
+		}
+		// Unlock even when ctx is done; closing the connection would
+		// release the lock too, but the pool keeps it open.
+		defer conn.ExecContext(context.Background(), m.dialect.unlock, lockKey)
+	}
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return fmt.Errorf("migrations: creating schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// appliedVersions returns the applied migration versions with the time they
+// were applied.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("migrations: reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("migrations: reading schema_migrations: %w", err)
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("migrations: reading schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// apply runs the up script of mig and records it, in one transaction.
+func apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	return inTx(ctx, conn, mig, mig.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", mig.Version, mig.Name)
+}
+
+// revert runs the down script of mig and forgets it, in one transaction.
+func revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	return inTx(ctx, conn, mig, mig.Down, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+}
+
+// inTx runs a migration script followed by the bookkeeping statement. DDL is
+// transactional in both PostgreSQL and SQLite, so a failing script leaves no
+// trace.
+func inTx(ctx context.Context, conn *sql.Conn, mig Migration, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrations: %s: %w", mig, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migrations: %s: %w", mig, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("migrations: %s: recording: %w", mig, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrations: %s: %w", mig, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create", Up: "CREATE TABLE t (id INT)", Down: "DROP TABLE t"},
+	{Version: 2, Name: "add_column", Up: "ALTER TABLE t ADD COLUMN c INT", Down: "ALTER TABLE t DROP COLUMN c"},
+	{Version: 3, Name: "add_index", Up: "CREATE INDEX t_c ON t (c)", Down: "DROP INDEX t_c"},
+}
+
+// expectLocked sets up the statements run before and after every locked
+// operation, reporting the given versions as applied.
+func expectLocked(mock sqlmock.Sqlmock, applied ...int) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, v := range applied {
+		rows.AddRow(v, time.Now())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, applied_at FROM schema_migrations")).WillReturnRows(rows)
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectApply(mock sqlmock.Sqlmock, m Migration) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(m.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)")).
+		WithArgs(m.Version, m.Name).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expectRevert(mock sqlmock.Sqlmock, m Migration) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(m.Down)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE version = $1")).
+		WithArgs(m.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func newMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db, testMigrations), mock
+}
+
+func TestUpAppliesPendingMigrations(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectLocked(mock, 1)
+	expectApply(mock, testMigrations[1])
+	expectApply(mock, testMigrations[2])
+	expectUnlock(mock)
+
+	done, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(done) != 2 || done[0].Version != 2 || done[1].Version != 3 {
+		t.Errorf("expected migrations 2 and 3 to be applied, got %v", done)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpRollsBackAFailingMigration(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectLocked(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(testMigrations[2].Up)).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	if _, err := m.Up(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDownRevertsLatestMigration(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectLocked(mock, 1, 2)
+	expectRevert(mock, testMigrations[1])
+	expectUnlock(mock)
+
+	done, err := m.Down(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(done) != 1 || done[0].Version != 2 {
+		t.Errorf("expected migration 2 to be reverted, got %v", done)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestToMigratesDown(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectLocked(mock, 1, 2, 3)
+	expectRevert(mock, testMigrations[2])
+	expectRevert(mock, testMigrations[1])
+	expectUnlock(mock)
+
+	done, err := m.To(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(done) != 2 {
+		t.Errorf("expected 2 migrations to be reverted, got %v", done)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	if _, err := m.To(context.Background(), 7); err == nil {
+		t.Error("expected an error for an unknown target version")
+	}
+}
+
+func TestUnknownAppliedVersion(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectLocked(mock, 1, 2, 3, 4)
+	expectUnlock(mock)
+
+	if _, err := m.Up(context.Background()); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectLocked(mock, 1)
+	expectUnlock(mock)
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(statuses) != 3 || !statuses[0].Applied || statuses[1].Applied || statuses[2].Applied {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+}
@@ -0,0 +1,10 @@
+-- The tasks table as first documented in the README. IF NOT EXISTS lets
+-- databases created by hand from that statement adopt the migrations.
+CREATE TABLE IF NOT EXISTS tasks (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    dueDate DATE,
+    priority VARCHAR(50),
+    status VARCHAR(50)
+);
@@ -0,0 +1,2 @@
+-- Optimistic concurrency: every write increments the version.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_priority_check;
+ALTER TABLE tasks ALTER COLUMN priority TYPE VARCHAR(50) USING
+    CASE priority
+        WHEN 1 THEN 'Low'
+        WHEN 2 THEN 'Medium'
+        WHEN 3 THEN 'High'
+        WHEN 4 THEN 'Critical'
+    END;
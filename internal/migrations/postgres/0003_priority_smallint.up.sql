@@ -0,0 +1,18 @@
+-- Store priorities as their rank so that they sort and compare correctly.
+-- Tables created from the later README statement already use SMALLINT.
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'tasks' AND column_name = 'priority') <> 'smallint' THEN
+        ALTER TABLE tasks ALTER COLUMN priority TYPE SMALLINT USING
+            CASE lower(trim(priority))
+                WHEN 'low' THEN 1 WHEN 'minor' THEN 1 WHEN '1' THEN 1
+                WHEN 'medium' THEN 2 WHEN 'normal' THEN 2 WHEN '2' THEN 2
+                WHEN 'high' THEN 3 WHEN 'major' THEN 3 WHEN '3' THEN 3
+                WHEN 'critical' THEN 4 WHEN 'urgent' THEN 4 WHEN '4' THEN 4
+            END;
+    END IF;
+END $$;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_priority_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_priority_check CHECK (priority BETWEEN 1 AND 4);
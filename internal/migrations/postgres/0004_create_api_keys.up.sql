@@ -0,0 +1,11 @@
+-- API keys authenticate clients in the X-API-Key header. The key is shown
+-- once when it is created; only the SHA-256 hash of its secret part is kept.
+-- id is the public part of the key, so a key is found without scanning.
+CREATE TABLE api_keys (
+    id VARCHAR(32) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
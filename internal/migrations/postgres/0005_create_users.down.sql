@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS task_watchers;
+ALTER TABLE tasks DROP COLUMN IF EXISTS created_by, DROP COLUMN IF EXISTS assignee_id;
+DROP TABLE IF EXISTS users;
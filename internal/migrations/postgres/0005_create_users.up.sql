@@ -0,0 +1,23 @@
+-- Users are recorded the first time a principal makes a request, under the
+-- subject it authenticated as. Tasks remember who created them and who they
+-- are assigned to; removing a user keeps the tasks but forgets the user.
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    subject VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE tasks
+    ADD COLUMN created_by INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    ADD COLUMN assignee_id INTEGER REFERENCES users (id) ON DELETE SET NULL;
+CREATE INDEX tasks_created_by_idx ON tasks (created_by);
+CREATE INDEX tasks_assignee_id_idx ON tasks (assignee_id);
+
+-- The primary key serves the lookup of a task's watchers, the index the
+-- listing of the tasks a user watches.
+CREATE TABLE task_watchers (
+    task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (task_id, user_id)
+);
+CREATE INDEX task_watchers_user_id_idx ON task_watchers (user_id);
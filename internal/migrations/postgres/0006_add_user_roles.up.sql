@@ -0,0 +1,5 @@
+-- Every user gets a role deciding what it may do with tasks. Existing users
+-- become members, the role new users get too.
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'member'
+        CHECK (role IN ('viewer', 'member', 'admin'));
@@ -0,0 +1,23 @@
+-- Tasks belong to a workspace, which only its members may use. The default
+-- workspace takes the existing tasks and is open to every user.
+CREATE TABLE workspaces (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO workspaces (slug, name) VALUES ('default', 'Default');
+
+CREATE TABLE workspace_members (
+    workspace_id INTEGER NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (workspace_id, user_id)
+);
+CREATE INDEX workspace_members_user_id_idx ON workspace_members (user_id);
+
+-- The column is left without a default, so no task can be stored without
+-- naming its workspace.
+ALTER TABLE tasks ADD COLUMN workspace_id INTEGER REFERENCES workspaces (id);
+UPDATE tasks SET workspace_id = (SELECT id FROM workspaces WHERE slug = 'default');
+ALTER TABLE tasks ALTER COLUMN workspace_id SET NOT NULL;
+CREATE INDEX tasks_workspace_id_idx ON tasks (workspace_id, id);
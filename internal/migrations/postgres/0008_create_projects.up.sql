@@ -0,0 +1,26 @@
+-- Projects group the tasks of a workspace. A project that still has tasks
+-- cannot be deleted: the repository first deletes or moves them, as the
+-- client chose.
+CREATE TABLE projects (
+    id SERIAL PRIMARY KEY,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    color VARCHAR(7) NOT NULL DEFAULT '' CHECK (color = '' OR color ~ '^#[0-9a-fA-F]{6}$'),
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX projects_workspace_id_idx ON projects (workspace_id, id);
+
+ALTER TABLE tasks ADD COLUMN project_id INTEGER REFERENCES projects (id);
+CREATE INDEX tasks_project_id_idx ON tasks (project_id);
+
+-- Users can be granted a role in a project, which replaces their own role
+-- for the project and its tasks. Grants go with the project or the user.
+CREATE TABLE project_roles (
+    project_id INTEGER NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('viewer', 'member', 'admin')),
+    PRIMARY KEY (user_id, project_id)
+);
+CREATE INDEX project_roles_project_id_idx ON project_roles (project_id);
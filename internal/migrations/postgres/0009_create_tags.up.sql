@@ -0,0 +1,18 @@
+-- Tags label the tasks of a workspace. Tasks name their tags, so a name is
+-- unique in its workspace; tags a task names are created on first use.
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '' CHECK (color = '' OR color ~ '^#[0-9a-fA-F]{6}$'),
+    UNIQUE (workspace_id, name)
+);
+
+-- The primary key serves the lookup of a task's tags, the index the listing
+-- of the tasks with a tag.
+CREATE TABLE task_tags (
+    task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (task_id, tag_id)
+);
+CREATE INDEX task_tags_tag_id_idx ON task_tags (tag_id);
@@ -0,0 +1,5 @@
+-- A task can be a subtask of another task of its workspace. A task that
+-- still has subtasks cannot be deleted: the repository first deletes or
+-- re-parents them, as the client chose.
+ALTER TABLE tasks ADD COLUMN parent_id INTEGER REFERENCES tasks (id);
+CREATE INDEX tasks_parent_id_idx ON tasks (parent_id);
@@ -0,0 +1,13 @@
+-- The tasks table as it stands after the PostgreSQL migrations. Due dates
+-- are calendar dates stored as YYYY-MM-DD text, which sorts and compares
+-- like the dates themselves; the CHECK rejects anything else. AUTOINCREMENT
+-- keeps IDs of deleted tasks from being reused, like a SERIAL column.
+CREATE TABLE tasks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL CHECK (length(title) <= 255),
+    description TEXT,
+    duedate TEXT CHECK (duedate IS NULL OR duedate = date(duedate)),
+    priority INTEGER CHECK (priority BETWEEN 1 AND 4),
+    status TEXT,
+    version INTEGER NOT NULL DEFAULT 1
+);
@@ -0,0 +1,10 @@
+-- See the PostgreSQL migration 0004_create_api_keys. The driver reads
+-- TIMESTAMP columns back as times.
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL CHECK (length(name) <= 255),
+    subject TEXT NOT NULL CHECK (length(subject) <= 255),
+    hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS tasks_delete_watchers;
+DROP TABLE IF EXISTS task_watchers;
+DROP INDEX IF EXISTS tasks_assignee_id_idx;
+DROP INDEX IF EXISTS tasks_created_by_idx;
+ALTER TABLE tasks DROP COLUMN assignee_id;
+ALTER TABLE tasks DROP COLUMN created_by;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,25 @@
+-- See the PostgreSQL migration 0005_create_users. SQLite only enforces
+-- foreign keys on connections that ask for it, so the user columns carry
+-- no REFERENCES clause and a trigger removes the watchers of deleted tasks.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subject TEXT NOT NULL UNIQUE CHECK (length(subject) <= 255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE tasks ADD COLUMN created_by INTEGER;
+ALTER TABLE tasks ADD COLUMN assignee_id INTEGER;
+CREATE INDEX tasks_created_by_idx ON tasks (created_by);
+CREATE INDEX tasks_assignee_id_idx ON tasks (assignee_id);
+
+CREATE TABLE task_watchers (
+    task_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (task_id, user_id)
+);
+CREATE INDEX task_watchers_user_id_idx ON task_watchers (user_id);
+
+CREATE TRIGGER tasks_delete_watchers AFTER DELETE ON tasks
+BEGIN
+    DELETE FROM task_watchers WHERE task_id = old.id;
+END;
@@ -0,0 +1,4 @@
+-- See the PostgreSQL migration 0006_add_user_roles.
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+        CHECK (role IN ('viewer', 'member', 'admin'));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS tasks_workspace_id_idx;
+ALTER TABLE tasks DROP COLUMN workspace_id;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,20 @@
+-- See the PostgreSQL migration 0007_create_workspaces. SQLite cannot add a
+-- NOT NULL column without a default, so existing and new rows default to
+-- the default workspace, which is the first one created here.
+CREATE TABLE workspaces (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL UNIQUE CHECK (length(slug) <= 63),
+    name TEXT NOT NULL CHECK (length(name) <= 255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO workspaces (slug, name) VALUES ('default', 'Default');
+
+CREATE TABLE workspace_members (
+    workspace_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (workspace_id, user_id)
+);
+CREATE INDEX workspace_members_user_id_idx ON workspace_members (user_id);
+
+ALTER TABLE tasks ADD COLUMN workspace_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX tasks_workspace_id_idx ON tasks (workspace_id, id);
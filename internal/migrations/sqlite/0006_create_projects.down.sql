@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS projects_delete_roles;
+DROP TABLE IF EXISTS project_roles;
@@ -0,0 +1,29 @@
+-- See the PostgreSQL migration 0008_create_projects. Like the other
+-- references, tasks.project_id and project_roles carry no REFERENCES
+-- clause: a trigger removes the grants of deleted projects.
+CREATE TABLE projects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace_id INTEGER NOT NULL,
+    name TEXT NOT NULL CHECK (length(name) <= 255),
+    description TEXT NOT NULL DEFAULT '',
+    color TEXT NOT NULL DEFAULT '' CHECK (color = '' OR (length(color) = 7 AND color GLOB '#[0-9a-fA-F][0-9a-fA-F][0-9a-fA-F][0-9a-fA-F][0-9a-fA-F][0-9a-fA-F]')),
+    archived BOOLEAN NOT NULL DEFAULT FALSE CHECK (archived IN (FALSE, TRUE)),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX projects_workspace_id_idx ON projects (workspace_id, id);
+
+ALTER TABLE tasks ADD COLUMN project_id INTEGER;
+CREATE INDEX tasks_project_id_idx ON tasks (project_id);
+
+CREATE TABLE project_roles (
+    project_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'member', 'admin')),
+    PRIMARY KEY (user_id, project_id)
+);
+CREATE INDEX project_roles_project_id_idx ON project_roles (project_id);
+
+CREATE TRIGGER projects_delete_roles AFTER DELETE ON projects
+BEGIN
+    DELETE FROM project_roles WHERE project_id = old.id;
+END;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS tasks_delete_tags;
+DROP TABLE IF EXISTS task_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,22 @@
+-- See the PostgreSQL migration 0009_create_tags. Like task_watchers,
+-- task_tags carries no REFERENCES clause: a trigger removes the tags of
+-- deleted tasks.
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace_id INTEGER NOT NULL,
+    name TEXT NOT NULL CHECK (length(name) <= 50),
+    color TEXT NOT NULL DEFAULT '' CHECK (color = '' OR (length(color) = 7 AND color GLOB '#[0-9a-fA-F][0-9a-fA-F][0-9a-fA-F][0-9a-fA-F][0-9a-fA-F][0-9a-fA-F]')),
+    UNIQUE (workspace_id, name)
+);
+
+CREATE TABLE task_tags (
+    task_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (task_id, tag_id)
+);
+CREATE INDEX task_tags_tag_id_idx ON task_tags (tag_id);
+
+CREATE TRIGGER tasks_delete_tags AFTER DELETE ON tasks
+BEGIN
+    DELETE FROM task_tags WHERE task_id = old.id;
+END;
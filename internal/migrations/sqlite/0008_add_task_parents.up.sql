@@ -0,0 +1,4 @@
+-- See the PostgreSQL migration 0010_add_task_parents. Like the other
+-- references, tasks.parent_id carries no REFERENCES clause.
+ALTER TABLE tasks ADD COLUMN parent_id INTEGER;
+CREATE INDEX tasks_parent_id_idx ON tasks (parent_id);
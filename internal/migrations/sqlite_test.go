@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestSQLiteMigrator runs the embedded SQLite migrations up and down against
+// a real database file.
+func TestSQLiteMigrator(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations, err := SQLite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewSQLite(db, migrations)
+	ctx := context.Background()
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %s", err)
+	}
+	if len(done) != len(migrations) {
+		t.Errorf("expected %d migrations applied, got %d", len(migrations), len(done))
+	}
+	if version, err := m.Version(ctx); err != nil || version != m.Latest() {
+		t.Errorf("expected version %d, got %d (%v)", m.Latest(), version, err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %s", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || s.AppliedAt.IsZero() {
+			t.Errorf("expected %s to be applied with a time, got %+v", s.Migration, s)
+		}
+	}
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Errorf("expected a second Up to do nothing, got %v (%v)", done, err)
+	}
+
+	if _, err := m.To(ctx, 0); err != nil {
+		t.Fatalf("To(0): %s", err)
+	}
+	var tables int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'tasks'").Scan(&tables); err != nil || tables != 0 {
+		t.Errorf("expected the tasks table to be dropped, got %d (%v)", tables, err)
+	}
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// APIKey is a credential clients send in the X-API-Key header. Only a hash
+// of its secret part is stored: the key itself is shown once, when it is
+// created, and cannot be recovered.
+type APIKey struct {
+	// ID is the public part of the key, which it starts with.
+	ID string
+	// Name tells the keys apart, e.g. "ci-pipeline".
+	Name string
+	// Subject is the principal a request authenticated with the key acts as.
+	Subject string
+	// Hash is the hex SHA-256 hash of the secret part of the key.
+	Hash      string
+	CreatedAt time.Time
+	// RevokedAt is set once the key no longer authenticates requests.
+	RevokedAt *time.Time
+}
+
+// Revoked reports whether the key was revoked.
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Priority is the urgency of a task. Priorities are ordered, so they can be
+// compared and sorted: Low < Medium < High < Critical. The zero value means
+// that no priority is set.
+type Priority int
+
+// The priorities a task can have.
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityCritical
+)
+
+// priorityUnknown holds values that could not be parsed, so that
+// validation can reject them with a proper field error.
+const priorityUnknown Priority = -1
+
+// Priorities lists every priority from lowest to highest.
+var Priorities = []Priority{PriorityLow, PriorityMedium, PriorityHigh, PriorityCritical}
+
+var priorityNames = map[Priority]string{
+	PriorityLow:      "Low",
+	PriorityMedium:   "Medium",
+	PriorityHigh:     "High",
+	PriorityCritical: "Critical",
+}
+
+// priorityAliases maps the lower-cased spellings found in older clients and
+// rows onto the priorities.
+var priorityAliases = map[string]Priority{
+	"low":      PriorityLow,
+	"minor":    PriorityLow,
+	"medium":   PriorityMedium,
+	"normal":   PriorityMedium,
+	"high":     PriorityHigh,
+	"major":    PriorityHigh,
+	"critical": PriorityCritical,
+	"urgent":   PriorityCritical,
+}
+
+// ParsePriority returns the priority named by s. It accepts the names in
+// any letter case, legacy spellings such as "urgent", and the numbers 1 to
+// 4.
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if p, ok := priorityAliases[s]; ok {
+		return p, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && Priority(n).Valid() {
+		return Priority(n), nil
+	}
+	return PriorityNone, fmt.Errorf("unknown priority %q", s)
+}
+
+// Valid reports whether p is one of the defined priorities. PriorityNone is
+// not valid, but may be stored to leave the priority unset.
+func (p Priority) Valid() bool {
+	return p >= PriorityLow && p <= PriorityCritical
+}
+
+// String returns the name of the priority, or "" when none is set.
+func (p Priority) String() string {
+	if p == PriorityNone {
+		return ""
+	}
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// MarshalJSON encodes the priority by name.
+func (p Priority) MarshalJSON() ([]byte, error) {
+	if p == PriorityNone {
+		return []byte("null"), nil
+	}
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON accepts a priority name, a legacy spelling, a number from 1
+// to 4, or null. Unknown values are rejected later by validation.
+func (p *Priority) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		*p = PriorityNone
+	case string:
+		*p = parseOrUnknown(v)
+	case float64:
+		*p = parseOrUnknown(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		*p = priorityUnknown
+	}
+	return nil
+}
+
+// parseOrUnknown parses s, mapping an empty string to PriorityNone and
+// anything unrecognized to priorityUnknown.
+func parseOrUnknown(s string) Priority {
+	if strings.TrimSpace(s) == "" {
+		return PriorityNone
+	}
+	p, err := ParsePriority(s)
+	if err != nil {
+		return priorityUnknown
+	}
+	return p
+}
+
+// Scan implements sql.Scanner. Priorities are stored as SMALLINT, but rows
+// written before that change may still hold the priority names.
+func (p *Priority) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*p = PriorityNone
+	case int64:
+		*p = Priority(v)
+	case string:
+		*p = parseOrUnknown(v)
+	case []byte:
+		*p = parseOrUnknown(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into Priority", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer, storing an unset priority as NULL.
+func (p Priority) Value() (driver.Value, error) {
+	if p == PriorityNone {
+		return nil, nil
+	}
+	return int64(p), nil
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+)
+
+func TestPriorityJSON(t *testing.T) {
+	tests := map[string]Priority{
+		`"High"`:     PriorityHigh,
+		`"critical"`: PriorityCritical,
+		`"urgent"`:   PriorityCritical,
+		`"normal"`:   PriorityMedium,
+		`1`:          PriorityLow,
+		`null`:       PriorityNone,
+		`""`:         PriorityNone,
+		`"Blocker"`:  priorityUnknown,
+		`9`:          priorityUnknown,
+	}
+	for in, want := range tests {
+		var p Priority
+		if err := json.Unmarshal([]byte(in), &p); err != nil || p != want {
+			t.Errorf("Unmarshal(%s) = %v, %v; want %v", in, p, err, want)
+		}
+	}
+
+	b, err := json.Marshal(Task{Title: "Task", Priority: PriorityHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"title":"Task","description":"","priority":"High"}`; string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+}
+
+func TestPriorityOrdering(t *testing.T) {
+	ps := []Priority{PriorityMedium, PriorityCritical, PriorityLow, PriorityHigh}
+	sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+	for i, p := range Priorities {
+		if ps[i] != p {
+			t.Fatalf("got order %v, want %v", ps, Priorities)
+		}
+	}
+}
+
+func TestPriorityScan(t *testing.T) {
+	tests := []struct {
+		src  any
+		want Priority
+	}{
+		{int64(4), PriorityCritical},
+		{nil, PriorityNone},
+		// Rows written before priorities became SMALLINT hold the names.
+		{"Medium", PriorityMedium},
+		{[]byte("low"), PriorityLow},
+	}
+	for _, tt := range tests {
+		var p Priority
+		if err := p.Scan(tt.src); err != nil || p != tt.want {
+			t.Errorf("Scan(%v) = %v, %v; want %v", tt.src, p, err, tt.want)
+		}
+	}
+}
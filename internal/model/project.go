@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Project groups related tasks of a workspace, e.g. the tasks of a release.
+// A task belongs to at most one project. Archived projects are kept with
+// their tasks, but their tasks are left out of listings unless asked for.
+type Project struct {
+	ID          int    `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Color is the color UIs show the project in, as #rrggbb; empty for
+	// none.
+	Color     string    `json:"color,omitempty"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Status is the workflow state of a task.
+type Status string
+
+// The statuses a task can be in.
+const (
+	StatusPending    Status = "Pending"
+	StatusInProgress Status = "In Progress"
+	StatusCompleted  Status = "Completed"
+	StatusCancelled  Status = "Cancelled"
+)
+
+// Statuses lists every known status in workflow order.
+var Statuses = []Status{StatusPending, StatusInProgress, StatusCompleted, StatusCancelled}
+
+// statusAliases maps the lower-cased spellings found in older clients and
+// rows onto the canonical statuses.
+var statusAliases = map[string]Status{
+	"pending":     StatusPending,
+	"open":        StatusPending,
+	"new":         StatusPending,
+	"todo":        StatusPending,
+	"in progress": StatusInProgress,
+	"in_progress": StatusInProgress,
+	"in-progress": StatusInProgress,
+	"inprogress":  StatusInProgress,
+	"completed":   StatusCompleted,
+	"complete":    StatusCompleted,
+	"done":        StatusCompleted,
+	"cancelled":   StatusCancelled,
+	"canceled":    StatusCancelled,
+}
+
+// ParseStatus returns the canonical status for s, accepting legacy
+// spellings such as "open" or "in_progress" in any letter case.
+func ParseStatus(s string) (Status, error) {
+	if status, ok := statusAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown status %q", s)
+}
+
+// Valid reports whether s is one of the canonical statuses.
+func (s Status) Valid() bool {
+	for _, status := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeStatus canonicalizes known spellings and keeps anything else
+// verbatim, so that validation can report the original value.
+func normalizeStatus(s string) Status {
+	if status, err := ParseStatus(s); err == nil {
+		return status
+	}
+	return Status(s)
+}
+
+// UnmarshalJSON accepts the legacy spellings of a status. Unknown values are
+// kept as sent and rejected later by validation.
+func (s *Status) UnmarshalJSON(b []byte) error {
+	var raw string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*s = normalizeStatus(raw)
+	return nil
+}
+
+// Scan implements sql.Scanner, canonicalizing legacy values stored in the
+// tasks table.
+func (s *Status) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = normalizeStatus(v)
+	case []byte:
+		*s = normalizeStatus(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into Status", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (s Status) Value() (driver.Value, error) {
+	return string(s), nil
+}
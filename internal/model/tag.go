@@ -0,0 +1,10 @@
+package model
+
+// Tag labels tasks of a workspace, e.g. by the area they touch. Tasks name
+// their tags by Name, which is unique in the workspace.
+type Tag struct {
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Color is the color UIs show the tag in, as #rrggbb; empty for none.
+	Color string `json:"color,omitempty"`
+}
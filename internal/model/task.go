@@ -2,11 +2,43 @@ package model
 
 import "time"
 
+// Task is a unit of work tracked by the task manager. Version is incremented
+// on every write and backs the ETag of the task's HTTP representation.
+//
+// CreatedBy, AssigneeID and Watchers hold user IDs. CreatedBy is set when
+// the task is created and never changes; Watchers are kept in ascending
+// order. ProjectID names the project the task belongs to, if any. Tags
+// holds the names of the tags of the task, in ascending order.
+//
+// ParentID names the task this one is a subtask of, if any. Progress counts
+// the direct subtasks of the task; it is derived when the task is read, is
+// nil for a task without subtasks, and is ignored on writes.
 type Task struct {
-    ID          int       `json:"id,omitempty"`
-    Title       string    `json:"title"`
-    Description string    `json:"description"`
-    DueDate     *time.Time `json:"dueDate,omitempty"`
-    Priority    string    `json:"priority,omitempty"`
-    Status      string    `json:"status,omitempty"`
-}
\ No newline at end of file
+	ID          int        `json:"id,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	DueDate     *time.Time `json:"dueDate,omitempty"`
+	Priority    Priority   `json:"priority,omitempty"`
+	Status      Status     `json:"status,omitempty"`
+	Version     int        `json:"version,omitempty"`
+	CreatedBy   *int       `json:"createdBy,omitempty"`
+	AssigneeID  *int       `json:"assigneeId,omitempty"`
+	ProjectID   *int       `json:"projectId,omitempty"`
+	Watchers    []int      `json:"watchers,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	ParentID    *int       `json:"parentId,omitempty"`
+	Progress    *Progress  `json:"progress,omitempty"`
+}
+
+// Progress tells how many of the subtasks of a task are completed.
+type Progress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
+
+// TaskTree is a task with its subtasks, and theirs, as deep as was asked
+// for.
+type TaskTree struct {
+	Task
+	Subtasks []TaskTree `json:"subtasks,omitempty"`
+}
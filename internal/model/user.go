@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// User is a person or client tasks can be created by, assigned to and
+// watched by. Users are not registered explicitly: one is recorded the
+// first time a principal makes a request, under the subject it
+// authenticated as.
+type User struct {
+	ID int `json:"id"`
+	// Subject is the subject of the principal, namespaced by how it
+	// authenticated: "apikey:" and the subject of an API key, or "jwt:"
+	// and the iss and sub claims of a token separated by "|".
+	Subject string `json:"subject"`
+	// Role decides what the user may do with tasks, see internal/policy.
+	Role Role `json:"role"`
+	// ProjectRoles maps the IDs of projects onto the role the user was
+	// granted in them. Within such a project, and for its tasks, that role
+	// replaces Role.
+	ProjectRoles map[int]Role `json:"projectRoles,omitempty"`
+	CreatedAt    time.Time    `json:"createdAt"`
+}
+
+// RoleIn returns the role of the user in the project with the given ID:
+// the role granted in that project, or else Role. A nil project, for tasks
+// outside any project, is Role too.
+func (u User) RoleIn(project *int) Role {
+	if project != nil {
+		if role, ok := u.ProjectRoles[*project]; ok {
+			return role
+		}
+	}
+	return u.Role
+}
+
+// Role is the access level of a user.
+type Role string
+
+// The roles a user can have, in increasing order of privilege.
+const (
+	// RoleViewer may read tasks and watch them.
+	RoleViewer Role = "viewer"
+	// RoleMember may also create tasks and change the ones it created or
+	// is assigned to.
+	RoleMember Role = "member"
+	// RoleAdmin may do anything.
+	RoleAdmin Role = "admin"
+)
+
+// DefaultRole is the role of a newly recorded user.
+const DefaultRole = RoleMember
+
+// Roles lists every known role in increasing order of privilege.
+var Roles = []Role{RoleViewer, RoleMember, RoleAdmin}
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleViewer, RoleMember, RoleAdmin:
+		return true
+	}
+	return false
+}
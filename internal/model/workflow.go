@@ -0,0 +1,163 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Transition is a named move between statuses, e.g. "reopen" takes a
+// completed task back to pending. An explicit transition can only be
+// performed by name, not by simply editing the task's status.
+type Transition struct {
+	Name     string   `json:"name" yaml:"name"`
+	From     []Status `json:"from" yaml:"from"`
+	To       Status   `json:"to" yaml:"to"`
+	Explicit bool     `json:"explicit,omitempty" yaml:"explicit,omitempty"`
+}
+
+// Workflow is the graph of allowed status changes. Staying in the same
+// status is always allowed, and tasks whose stored status is not part of
+// the workflow may move to any status, so legacy rows can be repaired.
+// Status edits may use any transition that is not explicit. The yaml tags
+// name the keys of the workflow section of the configuration file.
+type Workflow struct {
+	// Statuses lists the statuses in use, in display order.
+	Statuses []Status `json:"statuses" yaml:"statuses"`
+	// Initial lists the statuses a new task may start in; the first one is
+	// the default.
+	Initial     []Status     `json:"initial" yaml:"initial"`
+	Transitions []Transition `json:"transitions" yaml:"transitions"`
+}
+
+// DefaultWorkflow returns the workflow used unless one is configured:
+//
+//	Pending ──start──▶ In Progress ──complete──▶ Completed
+//	   ▲  ◀──pause───      │                        │
+//	   │                   └──cancel──▶ Cancelled   │
+//	   └────────────reopen─────────────────┴────────┘
+//
+// Pending tasks may also be completed or cancelled directly. Reopening is
+// explicit, so a finished task cannot drift back to Pending by accident.
+func DefaultWorkflow() *Workflow {
+	return &Workflow{
+		Statuses: []Status{StatusPending, StatusInProgress, StatusCompleted, StatusCancelled},
+		Initial:  []Status{StatusPending, StatusInProgress},
+		Transitions: []Transition{
+			{Name: "start", From: []Status{StatusPending}, To: StatusInProgress},
+			{Name: "pause", From: []Status{StatusInProgress}, To: StatusPending},
+			{Name: "complete", From: []Status{StatusPending, StatusInProgress}, To: StatusCompleted},
+			{Name: "cancel", From: []Status{StatusPending, StatusInProgress}, To: StatusCancelled},
+			{Name: "reopen", From: []Status{StatusCompleted, StatusCancelled}, To: StatusPending, Explicit: true},
+		},
+	}
+}
+
+// Validate checks that the workflow only refers to known statuses and has
+// at least one initial status.
+func (w *Workflow) Validate() error {
+	if len(w.Initial) == 0 {
+		return errors.New("workflow: at least one initial status is required")
+	}
+	inUse := make(map[Status]bool, len(w.Statuses))
+	for _, s := range w.Statuses {
+		if !s.Valid() {
+			return fmt.Errorf("workflow: unknown status %q", s)
+		}
+		inUse[s] = true
+	}
+	check := func(s Status, where string) error {
+		if !inUse[s] {
+			return fmt.Errorf("workflow: %s refers to status %q, which is not listed in statuses", where, s)
+		}
+		return nil
+	}
+	for _, s := range w.Initial {
+		if err := check(s, "initial"); err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool, len(w.Transitions))
+	for _, t := range w.Transitions {
+		if t.Name == "" || names[t.Name] {
+			return fmt.Errorf("workflow: transition names must be unique and non-empty, got %q", t.Name)
+		}
+		names[t.Name] = true
+		where := fmt.Sprintf("transition %q", t.Name)
+		if err := check(t.To, where); err != nil {
+			return err
+		}
+		for _, from := range t.From {
+			if err := check(from, where); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Has reports whether s is one of the workflow's statuses.
+func (w *Workflow) Has(s Status) bool {
+	for _, status := range w.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInitial reports whether a new task may start in status s.
+func (w *Workflow) IsInitial(s Status) bool {
+	for _, status := range w.Initial {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether editing a task's status may move it from one
+// status to another, without naming an explicit transition.
+func (w *Workflow) Allows(from, to Status) bool {
+	if from == to || !w.Has(from) {
+		return true
+	}
+	for _, t := range w.Available(from, to) {
+		if !t.Explicit {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the transition with the given name.
+func (w *Workflow) Find(name string) (Transition, bool) {
+	for _, t := range w.Transitions {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// CanApply reports whether transition t may be performed on a task in
+// status from.
+func (t Transition) CanApply(from Status) bool {
+	for _, f := range t.From {
+		if f == from {
+			return true
+		}
+	}
+	return false
+}
+
+// Available returns the transitions leaving status from. When to is not
+// empty, only transitions leading to it are returned.
+func (w *Workflow) Available(from, to Status) []Transition {
+	var available []Transition
+	for _, t := range w.Transitions {
+		if (to == "" || t.To == to) && t.CanApply(from) {
+			available = append(available, t)
+		}
+	}
+	return available
+}
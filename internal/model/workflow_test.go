@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := map[string]Status{
+		"Pending":     StatusPending,
+		"open":        StatusPending,
+		"In Progress": StatusInProgress,
+		"in_progress": StatusInProgress,
+		"DONE":        StatusCompleted,
+		"canceled":    StatusCancelled,
+	}
+	for in, want := range tests {
+		got, err := ParseStatus(in)
+		if err != nil || got != want {
+			t.Errorf("ParseStatus(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseStatus("Blocked"); err == nil {
+		t.Error("ParseStatus should reject unknown statuses")
+	}
+}
+
+func TestStatusUnmarshalJSON(t *testing.T) {
+	var task Task
+	if err := json.Unmarshal([]byte(`{"status":"in-progress"}`), &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != StatusInProgress {
+		t.Errorf("got %q, want %q", task.Status, StatusInProgress)
+	}
+	// Unknown values are kept, so validation can report them.
+	if err := json.Unmarshal([]byte(`{"status":"Blocked"}`), &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "Blocked" || task.Status.Valid() {
+		t.Errorf("got %q, want the unknown status kept verbatim", task.Status)
+	}
+}
+
+func TestDefaultWorkflowAllows(t *testing.T) {
+	w := DefaultWorkflow()
+	if err := w.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{StatusPending, StatusInProgress, true},
+		{StatusInProgress, StatusCompleted, true},
+		{StatusCompleted, StatusCompleted, true},
+		{StatusCompleted, StatusInProgress, false},
+		// Reopening is explicit and cannot happen through a status edit.
+		{StatusCompleted, StatusPending, false},
+		{StatusCancelled, StatusPending, false},
+		// Legacy rows outside the workflow may be repaired.
+		{"Blocked", StatusCompleted, true},
+	}
+	for _, tt := range tests {
+		if got := w.Allows(tt.from, tt.to); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	reopen, ok := w.Find("reopen")
+	if !ok || !reopen.CanApply(StatusCompleted) || reopen.CanApply(StatusPending) {
+		t.Errorf("unexpected reopen transition %+v", reopen)
+	}
+}
+
+func TestWorkflowValidate(t *testing.T) {
+	w := DefaultWorkflow()
+	w.Transitions = append(w.Transitions, Transition{Name: "block", From: []Status{StatusPending}, To: "Blocked"})
+	if err := w.Validate(); err == nil {
+		t.Error("Validate should reject transitions to unknown statuses")
+	}
+
+	w = DefaultWorkflow()
+	w.Initial = nil
+	if err := w.Validate(); err == nil {
+		t.Error("Validate should require an initial status")
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Workspace separates the tasks of one team from those of the others. Every
+// task belongs to exactly one workspace, and only the members of a
+// workspace may use it.
+type Workspace struct {
+	ID int `json:"id"`
+	// Slug names the workspace in URLs, e.g. /workspaces/platform/tasks.
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DefaultWorkspace is the slug of the workspace holding the tasks created
+// before workspaces existed. It serves the routes without a workspace, such
+// as /tasks, and is open to every user.
+const DefaultWorkspace = "default"
+
+// DefaultWorkspaceID is the ID of the default workspace, the first one every
+// store holds.
+const DefaultWorkspaceID = 1
@@ -0,0 +1,387 @@
+package policy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// Enforce returns a TaskRepository that authorizes every call to next for
+// the user the principal of the call's context is recorded as in users.
+// Denied calls fail with a Denial and never reach next. Calls without a
+// principal, made while authentication is disabled, are let through.
+//
+// Changes to an existing task are authorized against the task as stored.
+// When the caller expects no particular version, the change is made
+// conditional on the version that was authorized, so that a concurrent
+// reassignment cannot widen what the caller may do; the caller then sees
+// repo.ErrVersionMismatch. Deleting a task with its subtasks also needs the
+// right to delete each of them, and re-parenting them the right to change
+// each of them. The tasks and projects a call moves a task into count too:
+// making a task a subtask, or moving subtasks, needs the right to change
+// the new parent, and moving a task to a project the right to change it
+// there.
+func Enforce(next repo.TaskRepository, users repo.UserRepository) repo.TaskRepository {
+	return &enforcedRepo{next: next, users: users}
+}
+
+type enforcedRepo struct {
+	next  repo.TaskRepository
+	users repo.UserRepository
+}
+
+// user returns the user the call acts for, or nil when it has no principal.
+func (e *enforcedRepo) user(ctx context.Context) (*model.User, error) {
+	return principalUser(ctx, e.users)
+}
+
+// principalUser returns the user the principal of ctx is recorded as in
+// users, or nil when ctx has no principal.
+func principalUser(ctx context.Context, users repo.UserRepository) (*model.User, error) {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	user, err := users.EnsureUser(ctx, p.UserSubject())
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// authorize checks action on task, which is not stored yet or is nil.
+func (e *enforcedRepo) authorize(ctx context.Context, action Action, task *model.Task) error {
+	user, err := e.user(ctx)
+	if err != nil || user == nil {
+		return err
+	}
+	return Authorize(*user, action, task)
+}
+
+// authorizeStored checks action on the stored task with the given ID for
+// user, and returns the version the change must expect: version itself,
+// or the authorized version when version is zero.
+func (e *enforcedRepo) authorizeStored(ctx context.Context, user *model.User, action Action, id, version int) (int, error) {
+	_, version, err := e.authorizeTask(ctx, user, action, id, version)
+	return version, err
+}
+
+// authorizeTask is authorizeStored, also returning the stored task; the
+// task is the zero value when user is nil.
+func (e *enforcedRepo) authorizeTask(ctx context.Context, user *model.User, action Action, id, version int) (model.Task, int, error) {
+	if user == nil {
+		return model.Task{}, version, nil
+	}
+	task, err := e.next.GetByID(ctx, id)
+	if err != nil {
+		return model.Task{}, 0, err
+	}
+	if err := Authorize(*user, action, &task); err != nil {
+		return model.Task{}, 0, err
+	}
+	if version == 0 {
+		version = task.Version
+	}
+	return task, version, nil
+}
+
+// authorizeParent checks that user may change the task with the given ID,
+// which a call makes the parent of other tasks. A parent that does not
+// exist is left for the repository to reject.
+func (e *enforcedRepo) authorizeParent(ctx context.Context, user *model.User, id *int) error {
+	if user == nil || id == nil {
+		return nil
+	}
+	parent, err := e.next.GetByID(ctx, *id)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return Authorize(*user, ActionUpdate, &parent)
+}
+
+func (e *enforcedRepo) Create(ctx context.Context, task model.Task) (model.Task, error) {
+	user, err := e.user(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if user != nil {
+		if err := Authorize(*user, ActionCreate, &task); err != nil {
+			return model.Task{}, err
+		}
+	}
+	if err := e.authorizeParent(ctx, user, task.ParentID); err != nil {
+		return model.Task{}, err
+	}
+	return e.next.Create(ctx, task)
+}
+
+func (e *enforcedRepo) GetByID(ctx context.Context, id int) (model.Task, error) {
+	task, err := e.next.GetByID(ctx, id)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := e.authorize(ctx, ActionRead, &task); err != nil {
+		return model.Task{}, err
+	}
+	return task, nil
+}
+
+func (e *enforcedRepo) GetAll(ctx context.Context) ([]model.Task, error) {
+	if err := e.authorize(ctx, ActionRead, nil); err != nil {
+		return nil, err
+	}
+	return e.next.GetAll(ctx)
+}
+
+func (e *enforcedRepo) List(ctx context.Context, q repo.TaskQuery) (repo.TaskPage, error) {
+	if err := e.authorize(ctx, ActionRead, nil); err != nil {
+		return repo.TaskPage{}, err
+	}
+	return e.next.List(ctx, q)
+}
+
+func (e *enforcedRepo) Update(ctx context.Context, task model.Task) (model.Task, error) {
+	user, err := e.user(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	task.Version, err = e.authorizeStored(ctx, user, ActionUpdate, task.ID, task.Version)
+	if err != nil {
+		return model.Task{}, err
+	}
+	return e.next.Update(ctx, task)
+}
+
+func (e *enforcedRepo) Patch(ctx context.Context, id int, patch repo.TaskPatch) (model.Task, error) {
+	user, err := e.user(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	var task model.Task
+	task, patch.Version, err = e.authorizeTask(ctx, user, ActionUpdate, id, patch.Version)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if user != nil && patch.SetProject {
+		// The task must also be one the user may change in its new project.
+		task.ProjectID = patch.ProjectID
+		if err := Authorize(*user, ActionUpdate, &task); err != nil {
+			return model.Task{}, err
+		}
+	}
+	if patch.SetParent {
+		if err := e.authorizeParent(ctx, user, patch.ParentID); err != nil {
+			return model.Task{}, err
+		}
+	}
+	return e.next.Patch(ctx, id, patch)
+}
+
+func (e *enforcedRepo) Delete(ctx context.Context, id int, version int, subtasks repo.TaskSubtasks) error {
+	user, err := e.user(ctx)
+	if err != nil {
+		return err
+	}
+	task, version, err := e.authorizeTask(ctx, user, ActionDelete, id, version)
+	if err != nil {
+		return err
+	}
+	if err := e.authorizeSubtasks(ctx, user, task, subtasks); err != nil {
+		return err
+	}
+	return e.next.Delete(ctx, id, version, subtasks)
+}
+
+// authorizeSubtasks checks what deleting task does to its subtasks:
+// cascading deletes every one of them, moving changes the direct ones and
+// the task they are moved below.
+func (e *enforcedRepo) authorizeSubtasks(ctx context.Context, user *model.User, task model.Task, subtasks repo.TaskSubtasks) error {
+	if user == nil || (!subtasks.Cascade && !subtasks.Move) {
+		return nil
+	}
+	action, depth := ActionDelete, 0
+	if !subtasks.Cascade {
+		action, depth = ActionUpdate, 1
+		moveTo := subtasks.MoveTo
+		if moveTo == nil {
+			moveTo = task.ParentID
+		}
+		if err := e.authorizeParent(ctx, user, moveTo); err != nil {
+			return err
+		}
+	}
+	tasks, err := e.next.Subtasks(ctx, task.ID, depth)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		if err := Authorize(*user, action, &tasks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *enforcedRepo) Subtasks(ctx context.Context, id, depth int) ([]model.Task, error) {
+	if err := e.authorize(ctx, ActionRead, nil); err != nil {
+		return nil, err
+	}
+	return e.next.Subtasks(ctx, id, depth)
+}
+
+func (e *enforcedRepo) AddWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	version, err := e.authorizeWatch(ctx, id, userID, version)
+	if err != nil {
+		return model.Task{}, err
+	}
+	return e.next.AddWatcher(ctx, id, userID, version)
+}
+
+func (e *enforcedRepo) RemoveWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	version, err := e.authorizeWatch(ctx, id, userID, version)
+	if err != nil {
+		return model.Task{}, err
+	}
+	return e.next.RemoveWatcher(ctx, id, userID, version)
+}
+
+// authorizeWatch checks a change of whether userID watches a task: users
+// may change their own watch, changing someone else's is an update.
+func (e *enforcedRepo) authorizeWatch(ctx context.Context, id, userID, version int) (int, error) {
+	user, err := e.user(ctx)
+	if err != nil {
+		return 0, err
+	}
+	action := ActionUpdate
+	if user != nil && user.ID == userID {
+		action = ActionWatch
+	}
+	return e.authorizeStored(ctx, user, action, id, version)
+}
+
+// EnforceProjects returns a ProjectRepository that authorizes every call to
+// next with AuthorizeProject, for the user the principal of the call's
+// context is recorded as in users. Like Enforce, it lets calls without a
+// principal through.
+func EnforceProjects(next repo.ProjectRepository, users repo.UserRepository) repo.ProjectRepository {
+	return &enforcedProjects{next: next, users: users}
+}
+
+type enforcedProjects struct {
+	next  repo.ProjectRepository
+	users repo.UserRepository
+}
+
+// authorize checks action on the project with the given ID, or on projects
+// at large when it is nil, for the user the call acts for.
+func (e *enforcedProjects) authorize(ctx context.Context, action Action, project *int) error {
+	user, err := principalUser(ctx, e.users)
+	if err != nil || user == nil {
+		return err
+	}
+	return AuthorizeProject(*user, action, project)
+}
+
+func (e *enforcedProjects) CreateProject(ctx context.Context, p model.Project) (model.Project, error) {
+	if err := e.authorize(ctx, ActionCreate, nil); err != nil {
+		return model.Project{}, err
+	}
+	return e.next.CreateProject(ctx, p)
+}
+
+func (e *enforcedProjects) GetProject(ctx context.Context, id int) (model.Project, error) {
+	if err := e.authorize(ctx, ActionRead, &id); err != nil {
+		return model.Project{}, err
+	}
+	return e.next.GetProject(ctx, id)
+}
+
+func (e *enforcedProjects) ListProjects(ctx context.Context, includeArchived bool) ([]model.Project, error) {
+	if err := e.authorize(ctx, ActionRead, nil); err != nil {
+		return nil, err
+	}
+	return e.next.ListProjects(ctx, includeArchived)
+}
+
+func (e *enforcedProjects) PatchProject(ctx context.Context, id int, patch repo.ProjectPatch) (model.Project, error) {
+	if err := e.authorize(ctx, ActionUpdate, &id); err != nil {
+		return model.Project{}, err
+	}
+	return e.next.PatchProject(ctx, id, patch)
+}
+
+func (e *enforcedProjects) DeleteProject(ctx context.Context, id int, tasks repo.ProjectTasks) error {
+	if err := e.authorize(ctx, ActionDelete, &id); err != nil {
+		return err
+	}
+	if tasks.Move && tasks.MoveTo != nil {
+		// Moving the tasks changes the project they are moved to.
+		if err := e.authorize(ctx, ActionUpdate, tasks.MoveTo); err != nil {
+			return err
+		}
+	}
+	return e.next.DeleteProject(ctx, id, tasks)
+}
+
+// EnforceTags returns a TagRepository that authorizes every call to next
+// with AuthorizeTag, for the user the principal of the call's context is
+// recorded as in users. Like Enforce, it lets calls without a principal
+// through.
+func EnforceTags(next repo.TagRepository, users repo.UserRepository) repo.TagRepository {
+	return &enforcedTags{next: next, users: users}
+}
+
+type enforcedTags struct {
+	next  repo.TagRepository
+	users repo.UserRepository
+}
+
+// authorize checks action for the user the call acts for.
+func (e *enforcedTags) authorize(ctx context.Context, action Action) error {
+	user, err := principalUser(ctx, e.users)
+	if err != nil || user == nil {
+		return err
+	}
+	return AuthorizeTag(*user, action)
+}
+
+func (e *enforcedTags) CreateTag(ctx context.Context, tag model.Tag) (model.Tag, error) {
+	if err := e.authorize(ctx, ActionCreate); err != nil {
+		return model.Tag{}, err
+	}
+	return e.next.CreateTag(ctx, tag)
+}
+
+func (e *enforcedTags) GetTag(ctx context.Context, id int) (model.Tag, error) {
+	if err := e.authorize(ctx, ActionRead); err != nil {
+		return model.Tag{}, err
+	}
+	return e.next.GetTag(ctx, id)
+}
+
+func (e *enforcedTags) ListTags(ctx context.Context) ([]model.Tag, error) {
+	if err := e.authorize(ctx, ActionRead); err != nil {
+		return nil, err
+	}
+	return e.next.ListTags(ctx)
+}
+
+func (e *enforcedTags) PatchTag(ctx context.Context, id int, patch repo.TagPatch) (model.Tag, error) {
+	if err := e.authorize(ctx, ActionUpdate); err != nil {
+		return model.Tag{}, err
+	}
+	return e.next.PatchTag(ctx, id, patch)
+}
+
+func (e *enforcedTags) DeleteTag(ctx context.Context, id int) error {
+	if err := e.authorize(ctx, ActionDelete); err != nil {
+		return err
+	}
+	return e.next.DeleteTag(ctx, id)
+}
@@ -0,0 +1,287 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/auth"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// inDefault confines the calls of the tests to the default workspace.
+var inDefault = repo.WithWorkspace(context.Background(), model.DefaultWorkspaceID)
+
+// as returns a context authenticated as subject.
+func as(subject string) context.Context {
+	return auth.WithPrincipal(inDefault, auth.Principal{Subject: subject, Method: auth.MethodAPIKey})
+}
+
+func TestEnforce(t *testing.T) {
+	users := repo.NewMemoryUserRepo()
+	tasks := repo.NewMemoryTaskRepo()
+	r := Enforce(tasks, users)
+	ctx := inDefault
+
+	viewer, _ := users.EnsureUser(ctx, auth.APIKeyUser("viewer"))
+	users.SetRole(ctx, viewer.ID, model.RoleViewer)
+	member, _ := users.EnsureUser(ctx, auth.APIKeyUser("member"))
+	other, _ := users.EnsureUser(ctx, auth.APIKeyUser("other"))
+	admin, _ := users.EnsureUser(ctx, auth.APIKeyUser("admin"))
+	users.SetRole(ctx, admin.ID, model.RoleAdmin)
+
+	own, err := r.Create(as("member"), model.Task{Title: "Own", CreatedBy: &member.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	others, err := r.Create(as("other"), model.Task{Title: "Other's", CreatedBy: &other.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forbidden := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s: expected ErrForbidden, got %v", name, err)
+		}
+	}
+	allowed := func(name string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("%s: expected it to be allowed, got %v", name, err)
+		}
+	}
+
+	_, err = r.Create(as("viewer"), model.Task{Title: "Viewer's"})
+	forbidden("viewer creates", err)
+	_, err = r.List(as("viewer"), repo.TaskQuery{})
+	allowed("viewer lists", err)
+	_, err = r.GetByID(as("viewer"), own.ID)
+	allowed("viewer reads", err)
+	_, err = r.Patch(as("viewer"), own.ID, repo.TaskPatch{Title: ptr("Changed")})
+	forbidden("viewer patches", err)
+	_, err = r.AddWatcher(as("viewer"), own.ID, viewer.ID, 0)
+	allowed("viewer watches", err)
+	_, err = r.AddWatcher(as("viewer"), own.ID, member.ID, 0)
+	forbidden("viewer adds a watcher", err)
+
+	_, err = r.Patch(as("member"), others.ID, repo.TaskPatch{Title: ptr("Changed")})
+	forbidden("member patches another's task", err)
+	forbidden("member deletes another's task", r.Delete(as("member"), others.ID, 0, repo.TaskSubtasks{}))
+
+	// Once assigned, the member may change the task but not delete it.
+	_, err = r.Patch(as("other"), others.ID, repo.TaskPatch{SetAssignee: true, AssigneeID: &member.ID})
+	allowed("owner assigns", err)
+	_, err = r.Update(as("member"), model.Task{ID: others.ID, Title: "Changed"})
+	allowed("assignee updates", err)
+	forbidden("assignee deletes", r.Delete(as("member"), others.ID, 0, repo.TaskSubtasks{}))
+
+	allowed("admin deletes", r.Delete(as("admin"), others.ID, 0, repo.TaskSubtasks{}))
+	allowed("member deletes own task", r.Delete(as("member"), own.ID, 0, repo.TaskSubtasks{}))
+
+	// Denied writes leave the task untouched.
+	task, _ := r.Create(ctx, model.Task{Title: "Unowned"})
+	_, err = r.Patch(as("member"), task.ID, repo.TaskPatch{Title: ptr("Changed")})
+	forbidden("member patches an unowned task", err)
+	if stored, _ := tasks.GetByID(ctx, task.ID); stored.Title != "Unowned" || stored.Version != task.Version {
+		t.Errorf("expected the task to be unchanged, got %+v", stored)
+	}
+
+	// Without a principal, authentication is disabled and every call passes.
+	_, err = r.Patch(ctx, task.ID, repo.TaskPatch{Title: ptr("Changed")})
+	allowed("unauthenticated patch", err)
+
+	// A missing task is reported as such, not as a denial.
+	if _, err := r.Patch(as("member"), 12345, repo.TaskPatch{Title: ptr("Changed")}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEnforceSubtasks(t *testing.T) {
+	users := repo.NewMemoryUserRepo()
+	tasks := repo.NewMemoryTaskRepo()
+	r := Enforce(tasks, users)
+
+	member, _ := users.EnsureUser(inDefault, auth.APIKeyUser("member"))
+	other, _ := users.EnsureUser(inDefault, auth.APIKeyUser("other"))
+
+	parent, _ := tasks.Create(inDefault, model.Task{Title: "Parent", CreatedBy: &member.ID})
+	own, _ := tasks.Create(inDefault, model.Task{Title: "Own", CreatedBy: &member.ID, ParentID: &parent.ID})
+	others, _ := tasks.Create(inDefault, model.Task{Title: "Other's", CreatedBy: &other.ID, ParentID: &own.ID})
+
+	if _, err := r.Subtasks(as("member"), parent.ID, 0); err != nil {
+		t.Errorf("member lists subtasks: expected it to be allowed, got %v", err)
+	}
+	// The member may delete the parent, but not the task of another user
+	// below it, nor move the own subtask without the right to change it.
+	if err := r.Delete(as("member"), parent.ID, 0, repo.TaskSubtasks{Cascade: true}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("member cascades over another's task: expected ErrForbidden, got %v", err)
+	}
+	if err := r.Delete(as("member"), own.ID, 0, repo.TaskSubtasks{Move: true}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("member moves another's task: expected ErrForbidden, got %v", err)
+	}
+	if err := r.Delete(as("member"), parent.ID, 0, repo.TaskSubtasks{Move: true}); err != nil {
+		t.Errorf("member moves own subtask: expected it to be allowed, got %v", err)
+	}
+	if stored, err := tasks.GetByID(inDefault, others.ID); err != nil || stored.ParentID == nil || *stored.ParentID != own.ID {
+		t.Errorf("expected the other user's task untouched, got %+v (%v)", stored, err)
+	}
+}
+
+func TestEnforceReferences(t *testing.T) {
+	users := repo.NewMemoryUserRepo()
+	tasks := repo.NewMemoryTaskRepo()
+	projects := repo.NewMemoryProjectRepo(tasks)
+	r := Enforce(tasks, users)
+
+	member, _ := users.EnsureUser(inDefault, auth.APIKeyUser("member"))
+	other, _ := users.EnsureUser(inDefault, auth.APIKeyUser("other"))
+	readOnly, _ := projects.CreateProject(inDefault, model.Project{Name: "Read-only"})
+	users.SetProjectRole(inDefault, member.ID, readOnly.ID, model.RoleViewer)
+
+	own, _ := tasks.Create(inDefault, model.Task{Title: "Own", CreatedBy: &member.ID})
+	child, _ := tasks.Create(inDefault, model.Task{Title: "Child", CreatedBy: &member.ID, ParentID: &own.ID})
+	others, _ := tasks.Create(inDefault, model.Task{Title: "Other's", CreatedBy: &other.ID})
+
+	forbidden := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s: expected ErrForbidden, got %v", name, err)
+		}
+	}
+	_, err := r.Create(as("member"), model.Task{Title: "Sub", CreatedBy: &member.ID, ParentID: &others.ID})
+	forbidden("member creates a subtask of another's task", err)
+	_, err = r.Patch(as("member"), child.ID, repo.TaskPatch{SetParent: true, ParentID: &others.ID})
+	forbidden("member moves a task below another's task", err)
+	forbidden("member moves subtasks below another's task",
+		r.Delete(as("member"), own.ID, 0, repo.TaskSubtasks{Move: true, MoveTo: &others.ID}))
+	_, err = r.Create(as("member"), model.Task{Title: "Read-only", CreatedBy: &member.ID, ProjectID: &readOnly.ID})
+	forbidden("member creates in a project granting viewer", err)
+	_, err = r.Patch(as("member"), own.ID, repo.TaskPatch{SetProject: true, ProjectID: &readOnly.ID})
+	forbidden("member moves a task to a project granting viewer", err)
+
+	if stored, err := tasks.GetByID(inDefault, child.ID); err != nil || *stored.ParentID != own.ID || stored.Version != child.Version {
+		t.Errorf("expected the child untouched, got %+v (%v)", stored, err)
+	}
+	// A parent that does not exist is left to the repository to reject.
+	missing := others.ID + 100
+	if _, err := r.Create(as("member"), model.Task{Title: "Sub", ParentID: &missing}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("expected ErrValidation for a missing parent, got %v", err)
+	}
+}
+
+func TestEnforceProjects(t *testing.T) {
+	users := repo.NewMemoryUserRepo()
+	r := EnforceProjects(repo.NewMemoryProjectRepo(repo.NewMemoryTaskRepo()), users)
+
+	viewer, _ := users.EnsureUser(inDefault, auth.APIKeyUser("viewer"))
+	users.SetRole(inDefault, viewer.ID, model.RoleViewer)
+	users.EnsureUser(inDefault, auth.APIKeyUser("member"))
+	admin, _ := users.EnsureUser(inDefault, auth.APIKeyUser("admin"))
+	users.SetRole(inDefault, admin.ID, model.RoleAdmin)
+
+	if _, err := r.CreateProject(as("viewer"), model.Project{Name: "Viewer's"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer creates: expected ErrForbidden, got %v", err)
+	}
+	project, err := r.CreateProject(as("member"), model.Project{Name: "Member's"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ListProjects(as("viewer"), false); err != nil {
+		t.Errorf("viewer lists: expected it to be allowed, got %v", err)
+	}
+	if err := r.DeleteProject(as("member"), project.ID, repo.ProjectTasks{}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("member deletes: expected ErrForbidden, got %v", err)
+	}
+	if err := r.DeleteProject(as("admin"), project.ID, repo.ProjectTasks{}); err != nil {
+		t.Errorf("admin deletes: expected it to be allowed, got %v", err)
+	}
+
+	// A role granted in a project replaces the user's own role there.
+	granted, _ := r.CreateProject(as("admin"), model.Project{Name: "Granted"})
+	users.SetProjectRole(inDefault, viewer.ID, granted.ID, model.RoleAdmin)
+	users.SetProjectRole(inDefault, admin.ID, granted.ID, model.RoleViewer)
+	name := "Renamed"
+	if _, err := r.PatchProject(as("admin"), granted.ID, repo.ProjectPatch{Name: &name}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("admin granted viewer renames: expected ErrForbidden, got %v", err)
+	}
+	if err := r.DeleteProject(as("viewer"), granted.ID, repo.ProjectTasks{}); err != nil {
+		t.Errorf("viewer granted admin deletes: expected it to be allowed, got %v", err)
+	}
+}
+
+func TestEnforceTags(t *testing.T) {
+	users := repo.NewMemoryUserRepo()
+	tasks := repo.NewMemoryTaskRepo()
+	r := EnforceTags(repo.NewMemoryTagRepo(tasks), users)
+
+	viewer, _ := users.EnsureUser(inDefault, auth.APIKeyUser("viewer"))
+	users.SetRole(inDefault, viewer.ID, model.RoleViewer)
+	users.EnsureUser(inDefault, auth.APIKeyUser("member"))
+	admin, _ := users.EnsureUser(inDefault, auth.APIKeyUser("admin"))
+	users.SetRole(inDefault, admin.ID, model.RoleAdmin)
+
+	if _, err := r.CreateTag(as("viewer"), model.Tag{Name: "viewer"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer creates: expected ErrForbidden, got %v", err)
+	}
+	tag, err := r.CreateTag(as("member"), model.Tag{Name: "member"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "renamed"
+	if _, err := r.PatchTag(as("viewer"), tag.ID, repo.TagPatch{Name: &name}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer renames: expected ErrForbidden, got %v", err)
+	}
+	if _, err := r.PatchTag(as("member"), tag.ID, repo.TagPatch{Name: &name}); err != nil {
+		t.Errorf("member renames: expected it to be allowed, got %v", err)
+	}
+	if _, err := r.ListTags(as("viewer")); err != nil {
+		t.Errorf("viewer lists: expected it to be allowed, got %v", err)
+	}
+	if err := r.DeleteTag(as("member"), tag.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("member deletes: expected ErrForbidden, got %v", err)
+	}
+	if err := r.DeleteTag(as("admin"), tag.ID); err != nil {
+		t.Errorf("admin deletes: expected it to be allowed, got %v", err)
+	}
+}
+
+func TestEnforcePinsTheAuthorizedVersion(t *testing.T) {
+	users := repo.NewMemoryUserRepo()
+	tasks := &racingRepo{TaskRepository: repo.NewMemoryTaskRepo()}
+	r := Enforce(tasks, users)
+	ctx := inDefault
+
+	member, _ := users.EnsureUser(ctx, auth.APIKeyUser("member"))
+	task, _ := tasks.Create(ctx, model.Task{Title: "Task", AssigneeID: &member.ID})
+
+	// The task is reassigned between the check and the write, which must
+	// then fail rather than apply to a task the member may no longer change.
+	tasks.race = func() {
+		tasks.TaskRepository.Patch(ctx, task.ID, repo.TaskPatch{SetAssignee: true})
+	}
+	if _, err := r.Patch(as("member"), task.ID, repo.TaskPatch{Title: ptr("Changed")}); !errors.Is(err, repo.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+}
+
+// racingRepo runs race after the first GetByID, as if another request
+// changed the task right after it was read.
+type racingRepo struct {
+	repo.TaskRepository
+	race func()
+}
+
+func (r *racingRepo) GetByID(ctx context.Context, id int) (model.Task, error) {
+	task, err := r.TaskRepository.GetByID(ctx, id)
+	if r.race != nil {
+		r.race()
+		r.race = nil
+	}
+	return task, err
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
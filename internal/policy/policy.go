@@ -0,0 +1,182 @@
+// Package policy decides what a user may do with tasks, projects and tags,
+// based on the role of the user and on how the user relates to the task. A
+// user granted a role in a project has that role, instead of their own, for
+// the project and its tasks. Authorize, AuthorizeProject and AuthorizeTag
+// hold the rules and know nothing about HTTP or storage; Enforce,
+// EnforceProjects and EnforceTags apply them to every call of a
+// TaskRepository, a ProjectRepository and a TagRepository.
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// Action is something a user can do with tasks.
+type Action string
+
+// The actions Authorize decides on.
+const (
+	// ActionRead covers getting and listing tasks.
+	ActionRead Action = "read"
+	// ActionCreate covers creating a task.
+	ActionCreate Action = "create"
+	// ActionUpdate covers every change of an existing task: PUT, PATCH,
+	// transitions, assignment and changing someone else's watch.
+	ActionUpdate Action = "update"
+	// ActionDelete covers deleting a task.
+	ActionDelete Action = "delete"
+	// ActionWatch covers a user starting or stopping to watch a task
+	// themselves.
+	ActionWatch Action = "watch"
+)
+
+// ErrForbidden reports that the user may not perform the action. It is
+// always wrapped by a Denial, so compare it with errors.Is.
+var ErrForbidden = errors.New("forbidden")
+
+// Denial explains why Authorize refused an action, in terms safe to show to
+// the client. It matches ErrForbidden with errors.Is.
+type Denial struct {
+	Action Action
+	Reason string
+}
+
+func (d *Denial) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrForbidden, d.Action, d.Reason)
+}
+
+// Is reports whether target is ErrForbidden.
+func (d *Denial) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Authorize returns nil when user may perform action on task, and a Denial
+// otherwise. task is the task as stored, or the one being created; it is
+// nil for listings, which are not about a single task. The role that
+// counts is the one of the user in the project of the task.
+//
+//   - Viewers may read and watch tasks.
+//   - Members may also create tasks, change the tasks they created or are
+//     assigned to, and delete the tasks they created.
+//   - Admins may do anything.
+//
+// A user without a known role may do nothing.
+func Authorize(user model.User, action Action, task *model.Task) error {
+	var project *int
+	if task != nil {
+		project = task.ProjectID
+	}
+	role := user.RoleIn(project)
+	if !role.Valid() {
+		return deny(action, "Your account has no valid role")
+	}
+	if role == model.RoleAdmin {
+		return nil
+	}
+
+	switch action {
+	case ActionRead, ActionWatch:
+		return nil
+	case ActionCreate:
+		if role == model.RoleViewer {
+			return deny(action, "Viewers cannot create tasks")
+		}
+		return nil
+	case ActionUpdate:
+		if role == model.RoleViewer {
+			return deny(action, "Viewers cannot change tasks")
+		}
+		if task != nil && (isUser(task.CreatedBy, user) || isUser(task.AssigneeID, user)) {
+			return nil
+		}
+		return deny(action, "Members can only change tasks they created or are assigned to")
+	case ActionDelete:
+		if role == model.RoleViewer {
+			return deny(action, "Viewers cannot delete tasks")
+		}
+		if task != nil && isUser(task.CreatedBy, user) {
+			return nil
+		}
+		return deny(action, "Members can only delete tasks they created")
+	}
+	return deny(action, "Unknown action")
+}
+
+// AuthorizeProject returns nil when user may perform action on the project
+// with the given ID, and a Denial otherwise. project is nil for listings
+// and for creating a project. Projects have no owner, so only the role of
+// the user in the project counts:
+//
+//   - Viewers may read projects.
+//   - Members may also create and change projects, including archiving
+//     them.
+//   - Only admins may delete projects, since that deletes or moves tasks
+//     of other users.
+//
+// A user without a known role may do nothing.
+func AuthorizeProject(user model.User, action Action, project *int) error {
+	role := user.RoleIn(project)
+	if !role.Valid() {
+		return deny(action, "Your account has no valid role")
+	}
+	if role == model.RoleAdmin {
+		return nil
+	}
+
+	switch action {
+	case ActionRead:
+		return nil
+	case ActionCreate, ActionUpdate:
+		if role == model.RoleViewer {
+			return deny(action, "Viewers cannot change projects")
+		}
+		return nil
+	case ActionDelete:
+		return deny(action, "Only admins can delete projects")
+	}
+	return deny(action, "Unknown action")
+}
+
+// AuthorizeTag returns nil when user may perform action on tags, and a
+// Denial otherwise. Like projects, tags have no owner:
+//
+//   - Viewers may read tags.
+//   - Members may also create tags and rename or recolor them.
+//   - Only admins may delete tags, since that changes tasks of other
+//     users.
+//
+// Tagging a task is a change of the task, authorized by Authorize; it
+// creates the tags the task names without asking AuthorizeTag.
+func AuthorizeTag(user model.User, action Action) error {
+	if !user.Role.Valid() {
+		return deny(action, "Your account has no valid role")
+	}
+	if user.Role == model.RoleAdmin {
+		return nil
+	}
+
+	switch action {
+	case ActionRead:
+		return nil
+	case ActionCreate, ActionUpdate:
+		if user.Role == model.RoleViewer {
+			return deny(action, "Viewers cannot change tags")
+		}
+		return nil
+	case ActionDelete:
+		return deny(action, "Only admins can delete tags")
+	}
+	return deny(action, "Unknown action")
+}
+
+// isUser reports whether the user reference id names user.
+func isUser(id *int, user model.User) bool {
+	return id != nil && *id == user.ID
+}
+
+func deny(action Action, reason string) error {
+	return &Denial{Action: action, Reason: reason}
+}
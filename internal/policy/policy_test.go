@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+func TestAuthorize(t *testing.T) {
+	alice, bob := 1, 2
+	created := &model.Task{ID: 1, CreatedBy: &alice}
+	assigned := &model.Task{ID: 2, CreatedBy: &bob, AssigneeID: &alice}
+	others := &model.Task{ID: 3, CreatedBy: &bob}
+
+	user := func(role model.Role) model.User { return model.User{ID: alice, Subject: "alice", Role: role} }
+	for _, tc := range []struct {
+		role    model.Role
+		action  Action
+		task    *model.Task
+		allowed bool
+	}{
+		{model.RoleViewer, ActionRead, others, true},
+		{model.RoleViewer, ActionRead, nil, true},
+		{model.RoleViewer, ActionWatch, others, true},
+		{model.RoleViewer, ActionCreate, &model.Task{}, false},
+		{model.RoleViewer, ActionUpdate, created, false},
+		{model.RoleViewer, ActionDelete, created, false},
+
+		{model.RoleMember, ActionRead, others, true},
+		{model.RoleMember, ActionCreate, &model.Task{}, true},
+		{model.RoleMember, ActionUpdate, created, true},
+		{model.RoleMember, ActionUpdate, assigned, true},
+		{model.RoleMember, ActionUpdate, others, false},
+		{model.RoleMember, ActionDelete, created, true},
+		{model.RoleMember, ActionDelete, assigned, false},
+		{model.RoleMember, ActionDelete, others, false},
+
+		{model.RoleAdmin, ActionUpdate, others, true},
+		{model.RoleAdmin, ActionDelete, others, true},
+
+		// Unknown roles and actions fail closed.
+		{"", ActionRead, others, false},
+		{"owner", ActionRead, others, false},
+		{model.RoleMember, "archive", created, false},
+	} {
+		err := Authorize(user(tc.role), tc.action, tc.task)
+		if tc.allowed && err != nil {
+			t.Errorf("%s %s: expected it to be allowed, got %v", tc.role, tc.action, err)
+		}
+		if !tc.allowed {
+			var denial *Denial
+			if !errors.As(err, &denial) || !errors.Is(err, ErrForbidden) || denial.Action != tc.action || denial.Reason == "" {
+				t.Errorf("%s %s: expected a Denial, got %v", tc.role, tc.action, err)
+			}
+		}
+	}
+}
+
+func TestAuthorizeProject(t *testing.T) {
+	for _, tc := range []struct {
+		role    model.Role
+		action  Action
+		allowed bool
+	}{
+		{model.RoleViewer, ActionRead, true},
+		{model.RoleViewer, ActionCreate, false},
+		{model.RoleViewer, ActionUpdate, false},
+		{model.RoleMember, ActionCreate, true},
+		{model.RoleMember, ActionUpdate, true},
+		{model.RoleMember, ActionDelete, false},
+		{model.RoleAdmin, ActionDelete, true},
+		{"", ActionRead, false},
+		{model.RoleMember, ActionWatch, false},
+	} {
+		err := AuthorizeProject(model.User{ID: 1, Role: tc.role}, tc.action, nil)
+		if tc.allowed != (err == nil) || (err != nil && !errors.Is(err, ErrForbidden)) {
+			t.Errorf("%s %s: expected allowed=%v, got %v", tc.role, tc.action, tc.allowed, err)
+		}
+	}
+}
+
+func TestAuthorizeProjectRoles(t *testing.T) {
+	alice, granted, elsewhere := 1, 7, 8
+	user := model.User{ID: alice, Role: model.RoleViewer, ProjectRoles: map[int]model.Role{granted: model.RoleMember}}
+	admin := model.User{ID: 2, Role: model.RoleAdmin, ProjectRoles: map[int]model.Role{granted: model.RoleViewer}}
+	for _, tc := range []struct {
+		name    string
+		user    model.User
+		action  Action
+		task    *model.Task
+		allowed bool
+	}{
+		{"viewer creates in a project granting member", user, ActionCreate, &model.Task{ProjectID: &granted}, true},
+		{"viewer changes own task there", user, ActionUpdate, &model.Task{ProjectID: &granted, CreatedBy: &alice}, true},
+		{"viewer creates in another project", user, ActionCreate, &model.Task{ProjectID: &elsewhere}, false},
+		{"viewer creates outside projects", user, ActionCreate, &model.Task{}, false},
+		{"admin changes a task where granted viewer", admin, ActionUpdate, &model.Task{ProjectID: &granted}, false},
+		{"admin reads a task where granted viewer", admin, ActionRead, &model.Task{ProjectID: &granted}, true},
+		{"admin changes a task elsewhere", admin, ActionUpdate, &model.Task{ProjectID: &elsewhere}, true},
+	} {
+		err := Authorize(tc.user, tc.action, tc.task)
+		if tc.allowed != (err == nil) || (err != nil && !errors.Is(err, ErrForbidden)) {
+			t.Errorf("%s: expected allowed=%v, got %v", tc.name, tc.allowed, err)
+		}
+	}
+
+	if err := AuthorizeProject(user, ActionUpdate, &granted); err != nil {
+		t.Errorf("member of the project changes it: expected it to be allowed, got %v", err)
+	}
+	if err := AuthorizeProject(user, ActionUpdate, &elsewhere); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer changes another project: expected ErrForbidden, got %v", err)
+	}
+	if err := AuthorizeProject(admin, ActionDelete, &granted); !errors.Is(err, ErrForbidden) {
+		t.Errorf("admin granted viewer deletes the project: expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestAuthorizeTag(t *testing.T) {
+	for _, tc := range []struct {
+		role    model.Role
+		action  Action
+		allowed bool
+	}{
+		{model.RoleViewer, ActionRead, true},
+		{model.RoleViewer, ActionCreate, false},
+		{model.RoleViewer, ActionUpdate, false},
+		{model.RoleMember, ActionCreate, true},
+		{model.RoleMember, ActionUpdate, true},
+		{model.RoleMember, ActionDelete, false},
+		{model.RoleAdmin, ActionDelete, true},
+		{"", ActionRead, false},
+		{model.RoleMember, ActionWatch, false},
+	} {
+		err := AuthorizeTag(model.User{ID: 1, Role: tc.role}, tc.action)
+		if tc.allowed != (err == nil) || (err != nil && !errors.Is(err, ErrForbidden)) {
+			t.Errorf("%s %s: expected allowed=%v, got %v", tc.role, tc.action, tc.allowed, err)
+		}
+	}
+}
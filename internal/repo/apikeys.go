@@ -0,0 +1,143 @@
+// internal/repo/apikeys.go
+// The apikeys.go stores the API keys of the auth package in the same
+// database as the tasks.
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// APIKeyRepository stores API keys. It reports failures with the same
+// sentinel errors as TaskRepository.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key model.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (model.APIKey, error)
+	// ListAPIKeys returns every key, revoked ones included, oldest first.
+	ListAPIKeys(ctx context.Context) ([]model.APIKey, error)
+	// RevokeAPIKey marks a key as revoked at the given time. Revoking a
+	// revoked key keeps its first revocation time.
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+// Ensure APIKeyRepo implements APIKeyRepository.
+var _ APIKeyRepository = &APIKeyRepo{}
+
+// APIKeyRepo provides access to the api_keys table of a SQL database.
+type APIKeyRepo struct {
+	db      tracedDB
+	dialect dialect
+}
+
+// NewAPIKeyRepo creates an APIKeyRepo for a PostgreSQL database.
+func NewAPIKeyRepo(db *sql.DB) *APIKeyRepo {
+	return &APIKeyRepo{db: tracedDB{DB: db, system: "postgresql"}, dialect: postgresDialect}
+}
+
+// NewSQLiteAPIKeyRepo creates an APIKeyRepo for a SQLite database migrated
+// with migrations.SQLite.
+func NewSQLiteAPIKeyRepo(db *sql.DB) *APIKeyRepo {
+	return &APIKeyRepo{db: tracedDB{DB: db, system: "sqlite"}, dialect: sqliteDialect}
+}
+
+// apiKeyColumns lists the api_keys columns in the order expected by
+// scanAPIKey.
+const apiKeyColumns = "id, name, subject, hash, created_at, revoked_at"
+
+// scanAPIKey reads a single key row selected with apiKeyColumns.
+func scanAPIKey(s rowScanner) (model.APIKey, error) {
+	var key model.APIKey
+	var revokedAt sql.NullTime
+	if err := s.Scan(&key.ID, &key.Name, &key.Subject, &key.Hash, &key.CreatedAt, &revokedAt); err != nil {
+		return model.APIKey{}, err
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+// apiKeyNotFound builds the error returned when no key has the given ID.
+func apiKeyNotFound(id string) error {
+	return fmt.Errorf("%w: API key %s", ErrNotFound, id)
+}
+
+// CreateAPIKey stores a new key. A zero CreatedAt is set to the current
+// time.
+func (kr *APIKeyRepo) CreateAPIKey(ctx context.Context, key model.APIKey) error {
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	_, err := kr.db.ExecContext(ctx,
+		"INSERT INTO api_keys ("+apiKeyColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		key.ID, key.Name, key.Subject, key.Hash, key.CreatedAt.UTC(), nullTime(key.RevokedAt),
+	)
+	return kr.translate(ctx, err)
+}
+
+// GetAPIKey retrieves a key by its ID.
+func (kr *APIKeyRepo) GetAPIKey(ctx context.Context, id string) (model.APIKey, error) {
+	key, err := scanAPIKey(kr.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.APIKey{}, apiKeyNotFound(id)
+	}
+	if err != nil {
+		return model.APIKey{}, kr.translate(ctx, err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys retrieves every key, oldest first.
+func (kr *APIKeyRepo) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	rows, err := kr.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at, id")
+	if err != nil {
+		return nil, kr.translate(ctx, err)
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, kr.translate(ctx, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, kr.translate(ctx, err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey sets the revocation time of a key unless it is already set.
+func (kr *APIKeyRepo) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	res, err := kr.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2",
+		at.UTC(), id,
+	)
+	if err != nil {
+		return kr.translate(ctx, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return kr.translate(ctx, err)
+	}
+	if n == 0 {
+		return apiKeyNotFound(id)
+	}
+	return nil
+}
+
+// translate maps a failed statement onto the repository sentinels; nil
+// stays nil.
+func (kr *APIKeyRepo) translate(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	return kr.dialect.translateStatement(ctx, err)
+}
@@ -0,0 +1,228 @@
+// internal/repo/errors.go
+// The errors.go defines the errors every TaskRepository implementation reports,
+// so callers can react to the kind of failure without knowing which database
+// sits behind the repository.
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/lib/pq"
+)
+
+// Sentinel errors returned by TaskRepository implementations. They are always
+// wrapped with additional context, so compare them with errors.Is.
+var (
+	// ErrNotFound reports that the requested task does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict reports that the operation clashes with the current state of
+	// the stored data, e.g. a uniqueness violation or a concurrent update.
+	ErrConflict = errors.New("conflict")
+	// ErrValidation reports that the task was rejected by the storage rules.
+	ErrValidation = errors.New("validation failed")
+	// ErrUnavailable reports that the storage backend could not be reached.
+	ErrUnavailable = errors.New("storage unavailable")
+	// ErrCanceled reports that the caller gave up on the operation, e.g.
+	// because the client disconnected. Whether a write took effect is
+	// unknown.
+	ErrCanceled = errors.New("operation canceled")
+	// ErrTimeout reports that the operation did not finish before the
+	// deadline of its context. Whether a write took effect is unknown.
+	ErrTimeout = errors.New("operation timed out")
+	// ErrVersionMismatch reports that a conditional write expected another
+	// version of the task. It is a kind of ErrConflict.
+	ErrVersionMismatch = fmt.Errorf("%w: version mismatch", ErrConflict)
+	// ErrNoWorkspace reports a TaskRepository call whose context names no
+	// workspace, see WithWorkspace. It is a programming error: the call is
+	// refused rather than allowed to see the tasks of every workspace.
+	ErrNoWorkspace = errors.New("no workspace in context")
+)
+
+// ValidationError describes why a single field was rejected. It matches
+// ErrValidation with errors.Is, so callers that do not care about the field
+// can treat it like any other validation failure.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s %s", ErrValidation, e.Field, e.Message)
+}
+
+// Is reports whether target is ErrValidation.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// maxTitleLength mirrors the VARCHAR(255) limit of the tasks.title column.
+const maxTitleLength = 255
+
+// validateTask checks the constraints the tasks table enforces, so every
+// implementation rejects the same input with ErrValidation.
+func validateTask(task model.Task) error {
+	if strings.TrimSpace(task.Title) == "" {
+		return &ValidationError{Field: "title", Message: "is required"}
+	}
+	if len(task.Title) > maxTitleLength {
+		return &ValidationError{Field: "title", Message: fmt.Sprintf("must be at most %d characters", maxTitleLength)}
+	}
+	if err := validatePriority(task.Priority); err != nil {
+		return err
+	}
+	if err := validateAssignee(task.AssigneeID); err != nil {
+		return err
+	}
+	if err := validateProjectID(task.ProjectID); err != nil {
+		return err
+	}
+	if err := validateTags(task.Tags); err != nil {
+		return err
+	}
+	if err := validateParentID(task.ParentID); err != nil {
+		return err
+	}
+	return validateStatus(task.Status)
+}
+
+// validateAssignee rejects assignee IDs no user can have. A nil assignee is
+// allowed and means "unassigned".
+func validateAssignee(id *int) error {
+	if id != nil && *id < 1 {
+		return &ValidationError{Field: "assigneeId", Message: "must be a positive user ID"}
+	}
+	return nil
+}
+
+// validateProjectID rejects project IDs no project can have. A nil project
+// is allowed and means the task belongs to no project.
+func validateProjectID(id *int) error {
+	if id != nil && *id < 1 {
+		return &ValidationError{Field: "projectId", Message: "must be a positive project ID"}
+	}
+	return nil
+}
+
+// validateParentID rejects parent IDs no task can have. A nil parent is
+// allowed and means the task is not a subtask. Whether the parent exists is
+// checked against the stored tasks, see checkParent.
+func validateParentID(id *int) error {
+	if id != nil && *id < 1 {
+		return &ValidationError{Field: "parentId", Message: "must be a positive task ID"}
+	}
+	return nil
+}
+
+// validateTags rejects tag names the tags table does not accept.
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if err := validateTagName("tags", tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePriority rejects priorities outside the model.Priority range.
+// model.PriorityNone is allowed and means "not set".
+func validatePriority(p model.Priority) error {
+	if p != model.PriorityNone && !p.Valid() {
+		return &ValidationError{Field: "priority", Message: "is not a known priority"}
+	}
+	return nil
+}
+
+// validateStatus rejects statuses outside the model.Status enum. An empty
+// status is allowed and means "not set".
+func validateStatus(status model.Status) error {
+	if status != "" && !status.Valid() {
+		return &ValidationError{Field: "status", Message: fmt.Sprintf("unknown status %q", status)}
+	}
+	return nil
+}
+
+// notFound builds the error returned when no task has the given ID.
+func notFound(id int) error {
+	return fmt.Errorf("%w: task %d", ErrNotFound, id)
+}
+
+// staleVersion builds the error returned when a task is no longer at the
+// version a conditional write expected.
+func staleVersion(id, version int) error {
+	return fmt.Errorf("%w: task %d is no longer at version %d", ErrVersionMismatch, id, version)
+}
+
+// contextError reports err as ErrCanceled or ErrTimeout when ctx is done,
+// and returns nil otherwise.
+func contextError(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+}
+
+// checkContext fails with ErrCanceled or ErrTimeout once ctx is done. It
+// lets implementations that never block honour cancellation too.
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(ctx, err)
+	}
+	return nil
+}
+
+// translateError maps database driver errors onto the repository sentinels.
+// Errors that do not fall into one of the known categories are returned as is.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505", // unique_violation
+			pqErr.Code == "23503", // foreign_key_violation
+			pqErr.Code == "40001", // serialization_failure
+			pqErr.Code == "40P01": // deadlock_detected
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case pqErr.Code.Class() == "22", // data_exception
+			pqErr.Code.Class() == "23": // integrity_constraint_violation
+			return fmt.Errorf("%w: %w", ErrValidation, err)
+		case pqErr.Code.Class() == "08", // connection_exception
+			pqErr.Code.Class() == "53", // insufficient_resources
+			pqErr.Code == "57P01",      // admin_shutdown
+			pqErr.Code == "57P02",      // crash_shutdown
+			pqErr.Code == "57P03":      // cannot_connect_now
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		case pqErr.Code == "57014": // query_canceled, e.g. by statement_timeout
+			return fmt.Errorf("%w: %w", ErrTimeout, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
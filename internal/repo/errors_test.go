@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestTranslateError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no rows", sql.ErrNoRows, ErrNotFound},
+		{"unique violation", &pq.Error{Code: "23505"}, ErrConflict},
+		{"serialization failure", &pq.Error{Code: "40001"}, ErrConflict},
+		{"not null violation", &pq.Error{Code: "23502"}, ErrValidation},
+		{"invalid datetime", &pq.Error{Code: "22007"}, ErrValidation},
+		{"connection failure", &pq.Error{Code: "08006"}, ErrUnavailable},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, ErrUnavailable},
+		{"bad connection", driver.ErrBadConn, ErrUnavailable},
+		{"connection done", sql.ErrConnDone, ErrUnavailable},
+		{"statement timeout", &pq.Error{Code: "57014"}, ErrTimeout},
+		{"context canceled", context.Canceled, ErrCanceled},
+		{"context deadline", context.DeadlineExceeded, ErrTimeout},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := translateError(tc.err)
+			if !errors.Is(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+			// The original error must stay reachable for logging
+			if !errors.Is(got, tc.err) {
+				t.Errorf("translated error %v does not wrap %v", got, tc.err)
+			}
+		})
+	}
+}
+
+func TestTranslateErrorPassesThroughUnknownErrors(t *testing.T) {
+	unknown := errors.New("boom")
+	if got := translateError(unknown); got != unknown {
+		t.Errorf("expected unknown errors to be returned as is, got %v", got)
+	}
+	if got := translateError(nil); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
+
+func TestContextError(t *testing.T) {
+	driverErr := errors.New("pq: canceling statement due to user request")
+
+	if err := contextError(context.Background(), driverErr); err != nil {
+		t.Errorf("expected nil for a live context, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := contextError(canceled, driverErr); !errors.Is(err, ErrCanceled) || !errors.Is(err, driverErr) {
+		t.Errorf("expected ErrCanceled wrapping the driver error, got %v", err)
+	}
+
+	expired, cancel := context.WithTimeout(context.Background(), -1)
+	defer cancel()
+	if err := contextError(expired, driverErr); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
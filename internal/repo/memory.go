@@ -0,0 +1,505 @@
+// internal/repo/memory.go
+// The memory.go implements TaskRepository in process memory. It needs no
+// database, which makes it handy for tests and demos; all data is lost when
+// the process exits.
+package repo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// Ensure MemoryTaskRepo implements TaskRepository.
+var _ TaskRepository = &MemoryTaskRepo{}
+
+// MemoryTaskRepo is a TaskRepository keeping tasks in a map. It is safe for
+// concurrent use and behaves like TaskRepo: IDs are assigned in increasing
+// order, versions start at 1, and due dates are stored as calendar dates.
+// Tasks are copied on the way in and out, so callers never share a DueDate
+// with the store. Operations never block on I/O, so a context is only
+// checked before the work starts.
+type MemoryTaskRepo struct {
+	mu    sync.RWMutex
+	tasks map[int]model.Task
+	// workspaces maps the ID of every task onto the ID of its workspace.
+	workspaces map[int]int
+	// children maps the ID of every task with subtasks onto the IDs of its
+	// subtasks. Tasks are stored with put and deleted with remove, which
+	// keep it up to date.
+	children map[int]map[int]bool
+	lastID   int
+
+	// projects holds the projects of the MemoryProjectRepo sharing this
+	// store, so that task listings can leave out archived projects.
+	projects      map[int]memoryProject
+	lastProjectID int
+
+	// tags holds the tags of the MemoryTagRepo sharing this store. Tasks
+	// name their tags, and the tags they name are created here.
+	tags      map[int]memoryTag
+	lastTagID int
+}
+
+// memoryProject is a project stored with the ID of its workspace.
+type memoryProject struct {
+	model.Project
+	workspace int
+}
+
+// memoryTag is a tag stored with the ID of its workspace.
+type memoryTag struct {
+	model.Tag
+	workspace int
+}
+
+// NewMemoryTaskRepo creates an empty MemoryTaskRepo.
+func NewMemoryTaskRepo() *MemoryTaskRepo {
+	return &MemoryTaskRepo{
+		tasks:      make(map[int]model.Task),
+		workspaces: make(map[int]int),
+		children:   make(map[int]map[int]bool),
+		projects:   make(map[int]memoryProject),
+		tags:       make(map[int]memoryTag),
+	}
+}
+
+// tagID returns the ID of the tag of the workspace ws with the given name.
+// The caller must hold mr.mu.
+func (mr *MemoryTaskRepo) tagID(ws int, name string) (int, bool) {
+	for id, tag := range mr.tags {
+		if tag.workspace == ws && tag.Name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// ensureTags creates the tags of the workspace ws a task names that do not
+// exist yet. The caller must hold mr.mu for writing.
+func (mr *MemoryTaskRepo) ensureTags(ws int, names []string) {
+	for _, name := range names {
+		if _, ok := mr.tagID(ws, name); !ok {
+			mr.lastTagID++
+			mr.tags[mr.lastTagID] = memoryTag{Tag: model.Tag{ID: mr.lastTagID, Name: name}, workspace: ws}
+		}
+	}
+}
+
+// begin checks ctx before an operation starts and returns the workspace the
+// operation is confined to.
+func begin(ctx context.Context) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+	return scope(ctx)
+}
+
+// copyTask returns task with its own copy of the due date, user, project and
+// parent IDs, watchers, tags and progress. Like the DATE column of the tasks
+// table, it keeps only the calendar date of the due date.
+func copyTask(task model.Task) model.Task {
+	if task.DueDate != nil {
+		d := task.DueDate
+		due := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+		task.DueDate = &due
+	}
+	task.CreatedBy, task.AssigneeID, task.ProjectID = copyID(task.CreatedBy), copyID(task.AssigneeID), copyID(task.ProjectID)
+	task.ParentID = copyID(task.ParentID)
+	if task.Progress != nil {
+		progress := *task.Progress
+		task.Progress = &progress
+	}
+	if task.Watchers != nil {
+		task.Watchers = append([]int(nil), task.Watchers...)
+	}
+	if task.Tags != nil {
+		task.Tags = append([]string(nil), task.Tags...)
+	}
+	return task
+}
+
+func copyID(id *int) *int {
+	if id == nil {
+		return nil
+	}
+	v := *id
+	return &v
+}
+
+// Create stores a new task and returns it with its assigned ID.
+func (mr *MemoryTaskRepo) Create(ctx context.Context, task model.Task) (model.Task, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := validateTask(task); err != nil {
+		return model.Task{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if err := mr.checkRefs(ws, 0, task.ProjectID, task.ParentID); err != nil {
+		return model.Task{}, err
+	}
+	mr.lastID++
+	task.ID, task.Version, task.Watchers, task.Progress = mr.lastID, 1, nil, nil
+	task.Tags = normalizeTags(task.Tags)
+	mr.ensureTags(ws, task.Tags)
+	task = copyTask(task)
+	mr.put(task)
+	mr.workspaces[task.ID] = ws
+	return mr.output(task), nil
+}
+
+// GetByID retrieves a task by its ID.
+func (mr *MemoryTaskRepo) GetByID(ctx context.Context, id int) (model.Task, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	task, err := mr.current(ws, id, 0)
+	if err != nil {
+		return model.Task{}, err
+	}
+	return mr.output(task), nil
+}
+
+// GetAll retrieves all tasks of the workspace, ordered by ID.
+func (mr *MemoryTaskRepo) GetAll(ctx context.Context) ([]model.Task, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	tasks := make([]model.Task, 0, len(mr.tasks))
+	for _, task := range mr.tasks {
+		if mr.workspaces[task.ID] == ws {
+			tasks = append(tasks, mr.output(task))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks, nil
+}
+
+// List retrieves one page of tasks matching the query, with the same
+// ordering and cursors as TaskRepo.List.
+func (mr *MemoryTaskRepo) List(ctx context.Context, q TaskQuery) (TaskPage, error) {
+	q, err := q.normalize()
+	if err != nil {
+		return TaskPage{}, err
+	}
+	all, err := mr.GetAll(ctx)
+	if err != nil {
+		return TaskPage{}, err
+	}
+	archived := mr.archivedProjects()
+
+	var tasks []model.Task
+	for _, task := range all {
+		if matches(task, q) && (q.IncludeArchived || q.ProjectID != 0 || task.ProjectID == nil || !archived[*task.ProjectID]) && (q.After == nil || compareTasks(task, q.After.Value, q.After.ID, q) > 0) {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return compareTasks(tasks[i], sortValue(tasks[j], q.SortBy), tasks[j].ID, q) < 0
+	})
+	if len(tasks) > q.Limit+1 {
+		tasks = tasks[:q.Limit+1]
+	}
+	return newPage(tasks, q), nil
+}
+
+// archivedProjects returns the set of IDs of the archived projects.
+func (mr *MemoryTaskRepo) archivedProjects() map[int]bool {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	archived := make(map[int]bool)
+	for id, p := range mr.projects {
+		if p.Archived {
+			archived[id] = true
+		}
+	}
+	return archived
+}
+
+// matches reports whether task passes the filters of q.
+func matches(task model.Task, q TaskQuery) bool {
+	if len(q.Statuses) > 0 && !contains(q.Statuses, task.Status) {
+		return false
+	}
+	if len(q.Priorities) > 0 && !contains(q.Priorities, task.Priority) {
+		return false
+	}
+	if q.MinPriority != model.PriorityNone && (task.Priority == model.PriorityNone || task.Priority < q.MinPriority) {
+		return false
+	}
+	if q.MaxPriority != model.PriorityNone && (task.Priority == model.PriorityNone || task.Priority > q.MaxPriority) {
+		return false
+	}
+	if q.DueAfter != nil && (task.DueDate == nil || task.DueDate.Before(*q.DueAfter)) {
+		return false
+	}
+	if q.DueBefore != nil && (task.DueDate == nil || task.DueDate.After(*q.DueBefore)) {
+		return false
+	}
+	if q.AssigneeID != 0 && (task.AssigneeID == nil || *task.AssigneeID != q.AssigneeID) {
+		return false
+	}
+	if q.CreatedBy != 0 && (task.CreatedBy == nil || *task.CreatedBy != q.CreatedBy) {
+		return false
+	}
+	if q.Watcher != 0 && !contains(task.Watchers, q.Watcher) {
+		return false
+	}
+	if q.ProjectID != 0 && (task.ProjectID == nil || *task.ProjectID != q.ProjectID) {
+		return false
+	}
+	if len(q.Tags) > 0 {
+		found := 0
+		for _, tag := range q.Tags {
+			if contains(task.Tags, tag) {
+				found++
+			}
+		}
+		if found == 0 || (q.AllTags && found < len(q.Tags)) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// compareTasks orders task against the sort key (value, id) the way listSQL
+// does: by the sort column with NULLs last, then by ID, and reversed for a
+// descending query. The cursor representation of every sort column orders
+// like the column itself, so the keys are compared as strings.
+func compareTasks(task model.Task, value *string, id int, q TaskQuery) int {
+	c := 0
+	if q.SortBy != SortByID {
+		c = compareNullable(sortValue(task, q.SortBy), value)
+	}
+	if c == 0 {
+		c = compareInts(task.ID, id)
+	}
+	if q.Descending {
+		return -c
+	}
+	return c
+}
+
+// compareNullable compares two keys, treating nil (NULL) as the largest.
+func compareNullable(a, b *string) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	case *a < *b:
+		return -1
+	case *a > *b:
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Update replaces an existing task and returns it with its new version,
+// honouring task.Version like TaskRepo.Update. Like there, who created the
+// task, its assignee, its project, its parent and its watchers are kept, and
+// so are its tags unless task.Tags is non-nil.
+func (mr *MemoryTaskRepo) Update(ctx context.Context, task model.Task) (model.Task, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := validateTask(task); err != nil {
+		return model.Task{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	current, err := mr.current(ws, task.ID, task.Version)
+	if err != nil {
+		return model.Task{}, err
+	}
+	task.Version = current.Version + 1
+	task.CreatedBy, task.AssigneeID, task.Watchers = current.CreatedBy, current.AssigneeID, current.Watchers
+	task.ProjectID, task.ParentID, task.Progress = current.ProjectID, current.ParentID, nil
+	if task.Tags == nil {
+		task.Tags = current.Tags
+	}
+	task.Tags = normalizeTags(task.Tags)
+	mr.ensureTags(ws, task.Tags)
+	task = copyTask(task)
+	mr.put(task)
+	return mr.output(task), nil
+}
+
+// Patch changes only the fields set in the patch, honouring patch.Version
+// like TaskRepo.Patch.
+func (mr *MemoryTaskRepo) Patch(ctx context.Context, id int, patch TaskPatch) (model.Task, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := validatePatch(patch); err != nil {
+		return model.Task{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	task, err := mr.current(ws, id, patch.Version)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if patch.IsEmpty() {
+		return mr.output(task), nil
+	}
+	project, parent := patch.refs()
+	if err := mr.checkRefs(ws, id, project, parent); err != nil {
+		return model.Task{}, err
+	}
+	task = copyTask(patch.Apply(task))
+	mr.ensureTags(ws, task.Tags)
+	task.Version++
+	mr.put(task)
+	return mr.output(task), nil
+}
+
+// Delete removes a task and deletes or moves its subtasks, honouring a
+// non-zero version like TaskRepo.Delete.
+func (mr *MemoryTaskRepo) Delete(ctx context.Context, id int, version int, subtasks TaskSubtasks) error {
+	ws, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateTaskSubtasks(id, subtasks); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	task, err := mr.current(ws, id, version)
+	if err != nil {
+		return err
+	}
+	children := mr.subtasks(ws, id, 1)
+	switch {
+	case subtasks.Cascade:
+		for _, childID := range mr.subtasks(ws, id, 0) {
+			mr.remove(childID)
+		}
+	case subtasks.Move:
+		moveTo := task.ParentID
+		if subtasks.MoveTo != nil {
+			if err := mr.checkParent(ws, id, *subtasks.MoveTo, "moveTo"); err != nil {
+				return err
+			}
+			moveTo = subtasks.MoveTo
+		}
+		for _, childID := range children {
+			child := mr.tasks[childID]
+			child.ParentID = copyID(moveTo)
+			child.Version++
+			mr.put(child)
+		}
+	case len(children) > 0:
+		return hasSubtasks(id)
+	}
+	mr.remove(id)
+	return nil
+}
+
+// AddWatcher makes a user watch a task, like TaskRepo.AddWatcher.
+func (mr *MemoryTaskRepo) AddWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	return mr.setWatcher(ctx, id, version, func(watchers []int) []int {
+		if contains(watchers, userID) {
+			return watchers
+		}
+		watchers = append(watchers, userID)
+		sort.Ints(watchers)
+		return watchers
+	})
+}
+
+// RemoveWatcher stops a user from watching a task, like
+// TaskRepo.RemoveWatcher.
+func (mr *MemoryTaskRepo) RemoveWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	return mr.setWatcher(ctx, id, version, func(watchers []int) []int {
+		var kept []int
+		for _, w := range watchers {
+			if w != userID {
+				kept = append(kept, w)
+			}
+		}
+		return kept
+	})
+}
+
+// setWatcher replaces the watchers of a task with the result of change,
+// incrementing the version when they changed.
+func (mr *MemoryTaskRepo) setWatcher(ctx context.Context, id, version int, change func(watchers []int) []int) (model.Task, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	task, err := mr.current(ws, id, version)
+	if err != nil {
+		return model.Task{}, err
+	}
+	task = copyTask(task)
+	watchers := change(task.Watchers)
+	if len(watchers) == len(task.Watchers) {
+		return mr.output(task), nil
+	}
+	task.Watchers = watchers
+	task.Version++
+	mr.put(task)
+	return mr.output(task), nil
+}
+
+// current returns the stored task with the given ID in the workspace ws,
+// checking it is at the expected version when one is given. The caller
+// must hold mr.mu.
+func (mr *MemoryTaskRepo) current(ws, id, version int) (model.Task, error) {
+	task, ok := mr.tasks[id]
+	if !ok || mr.workspaces[id] != ws {
+		return model.Task{}, notFound(id)
+	}
+	if version > 0 && task.Version != version {
+		return model.Task{}, staleVersion(id, version)
+	}
+	return task, nil
+}
@@ -0,0 +1,15 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/DimWebDev/task-manager-tool/internal/repo/repotest"
+)
+
+func TestMemoryTaskRepo(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		tasks := repo.NewMemoryTaskRepo()
+		return repotest.Repos{Tasks: tasks, Users: repo.NewMemoryUserRepo(), Workspaces: repo.NewMemoryWorkspaceRepo(), Projects: repo.NewMemoryProjectRepo(tasks), Tags: repo.NewMemoryTagRepo(tasks)}
+	})
+}
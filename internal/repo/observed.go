@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// Observer is told about every operation of an observed TaskRepository, e.g.
+// to time it or count its errors. Observe is called when the operation
+// starts, with the method name as op; the returned context is passed to the
+// repository, and done is called with the operation's error when it ends.
+type Observer interface {
+	Observe(ctx context.Context, op string) (_ context.Context, done func(err error))
+}
+
+// Observed returns a TaskRepository that reports every call to next to obs.
+// It changes nothing else, so observers can be stacked by observing an
+// observed repository.
+func Observed(next TaskRepository, obs Observer) TaskRepository {
+	return &observedRepo{next: next, obs: obs}
+}
+
+// Operation names passed to Observer.Observe.
+const (
+	OpCreate  = "Create"
+	OpGetByID = "GetByID"
+	OpGetAll  = "GetAll"
+	OpList    = "List"
+	OpUpdate  = "Update"
+	OpPatch   = "Patch"
+	OpDelete  = "Delete"
+
+	OpAddWatcher    = "AddWatcher"
+	OpRemoveWatcher = "RemoveWatcher"
+
+	OpSubtasks = "Subtasks"
+)
+
+type observedRepo struct {
+	next TaskRepository
+	obs  Observer
+}
+
+func (o *observedRepo) Create(ctx context.Context, task model.Task) (model.Task, error) {
+	ctx, done := o.obs.Observe(ctx, OpCreate)
+	created, err := o.next.Create(ctx, task)
+	done(err)
+	return created, err
+}
+
+func (o *observedRepo) GetByID(ctx context.Context, id int) (model.Task, error) {
+	ctx, done := o.obs.Observe(ctx, OpGetByID)
+	task, err := o.next.GetByID(ctx, id)
+	done(err)
+	return task, err
+}
+
+func (o *observedRepo) GetAll(ctx context.Context) ([]model.Task, error) {
+	ctx, done := o.obs.Observe(ctx, OpGetAll)
+	tasks, err := o.next.GetAll(ctx)
+	done(err)
+	return tasks, err
+}
+
+func (o *observedRepo) List(ctx context.Context, q TaskQuery) (TaskPage, error) {
+	ctx, done := o.obs.Observe(ctx, OpList)
+	page, err := o.next.List(ctx, q)
+	done(err)
+	return page, err
+}
+
+func (o *observedRepo) Update(ctx context.Context, task model.Task) (model.Task, error) {
+	ctx, done := o.obs.Observe(ctx, OpUpdate)
+	updated, err := o.next.Update(ctx, task)
+	done(err)
+	return updated, err
+}
+
+func (o *observedRepo) Patch(ctx context.Context, id int, patch TaskPatch) (model.Task, error) {
+	ctx, done := o.obs.Observe(ctx, OpPatch)
+	patched, err := o.next.Patch(ctx, id, patch)
+	done(err)
+	return patched, err
+}
+
+func (o *observedRepo) Delete(ctx context.Context, id int, version int, subtasks TaskSubtasks) error {
+	ctx, done := o.obs.Observe(ctx, OpDelete)
+	err := o.next.Delete(ctx, id, version, subtasks)
+	done(err)
+	return err
+}
+
+func (o *observedRepo) Subtasks(ctx context.Context, id, depth int) ([]model.Task, error) {
+	ctx, done := o.obs.Observe(ctx, OpSubtasks)
+	tasks, err := o.next.Subtasks(ctx, id, depth)
+	done(err)
+	return tasks, err
+}
+
+func (o *observedRepo) AddWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	ctx, done := o.obs.Observe(ctx, OpAddWatcher)
+	task, err := o.next.AddWatcher(ctx, id, userID, version)
+	done(err)
+	return task, err
+}
+
+func (o *observedRepo) RemoveWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	ctx, done := o.obs.Observe(ctx, OpRemoveWatcher)
+	task, err := o.next.RemoveWatcher(ctx, id, userID, version)
+	done(err)
+	return task, err
+}
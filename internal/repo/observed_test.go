@@ -0,0 +1,72 @@
+package repo_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/DimWebDev/task-manager-tool/internal/repo/repotest"
+)
+
+// recorder is an Observer remembering every operation and its error. It is
+// safe for concurrent use, as repositories are.
+type recorder struct {
+	mu   sync.Mutex
+	ops  []string
+	errs []error
+}
+
+func (r *recorder) Observe(ctx context.Context, op string) (context.Context, func(error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = append(r.ops, op)
+	return ctx, func(err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.errs = append(r.errs, err)
+	}
+}
+
+func TestObservedTaskRepo(t *testing.T) {
+	// The decorator must not change the behaviour of the repository.
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		tasks := repo.NewMemoryTaskRepo()
+		return repotest.Repos{
+			Tasks:      repo.Observed(tasks, &recorder{}),
+			Users:      repo.NewMemoryUserRepo(),
+			Workspaces: repo.NewMemoryWorkspaceRepo(),
+			Projects:   repo.NewMemoryProjectRepo(tasks),
+			Tags:       repo.NewMemoryTagRepo(tasks),
+		}
+	})
+}
+
+func TestObservedReportsOperations(t *testing.T) {
+	rec := &recorder{}
+	r := repo.Observed(repo.NewMemoryTaskRepo(), rec)
+	ctx := repo.WithWorkspace(context.Background(), 1)
+
+	task, err := r.Create(ctx, model.Task{Title: "Write report", Priority: model.PriorityHigh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.GetByID(ctx, task.ID)
+	r.Delete(ctx, task.ID, task.Version, repo.TaskSubtasks{})
+	r.GetByID(ctx, task.ID)
+
+	want := []string{repo.OpCreate, repo.OpGetByID, repo.OpDelete, repo.OpGetByID}
+	if len(rec.ops) != len(want) {
+		t.Fatalf("expected operations %v, got %v", want, rec.ops)
+	}
+	for i := range want {
+		if rec.ops[i] != want[i] {
+			t.Errorf("operation %d: expected %s, got %s", i, want[i], rec.ops[i])
+		}
+	}
+	if rec.errs[2] != nil || !errors.Is(rec.errs[3], repo.ErrNotFound) {
+		t.Errorf("expected the errors of the calls to be reported, got %v", rec.errs)
+	}
+}
@@ -0,0 +1,178 @@
+// internal/repo/patch.go
+// The patch.go describes partial task updates and builds the UPDATE
+// statement that only touches the columns being changed.
+package repo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// TaskPatch lists the fields changed by a partial update. Nil pointers leave
+// the corresponding field untouched.
+type TaskPatch struct {
+	Title       *string
+	Description *string
+	// DueDate is only applied when SetDueDate is true, so that a nil DueDate
+	// can clear the stored date.
+	SetDueDate bool
+	DueDate    *time.Time
+	Priority   *model.Priority
+	Status     *model.Status
+	// AssigneeID is only applied when SetAssignee is true, so that a nil
+	// AssigneeID can unassign the task.
+	SetAssignee bool
+	AssigneeID  *int
+	// ProjectID is only applied when SetProject is true, so that a nil
+	// ProjectID can take the task out of its project.
+	SetProject bool
+	ProjectID  *int
+	// Tags replaces the tags of the task when SetTags is true, so that a
+	// nil Tags can remove them all.
+	SetTags bool
+	Tags    []string
+	// ParentID is only applied when SetParent is true, so that a nil
+	// ParentID can make the task a top-level task again.
+	SetParent bool
+	ParentID  *int
+
+	// Version, when set, is the version the task is expected to be at; the
+	// patch fails with ErrVersionMismatch otherwise.
+	Version int
+}
+
+// refs returns the project and the parent the patch moves the task to, nil
+// when it does not move it to one.
+func (p TaskPatch) refs() (project, parent *int) {
+	if p.SetProject {
+		project = p.ProjectID
+	}
+	if p.SetParent {
+		parent = p.ParentID
+	}
+	return project, parent
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p TaskPatch) IsEmpty() bool {
+	return p.Title == nil && p.Description == nil && !p.SetDueDate && p.Priority == nil && p.Status == nil &&
+		!p.SetAssignee && !p.SetProject && !p.SetTags && !p.SetParent
+}
+
+// Apply returns a copy of task with the patch applied.
+func (p TaskPatch) Apply(task model.Task) model.Task {
+	if p.Title != nil {
+		task.Title = *p.Title
+	}
+	if p.Description != nil {
+		task.Description = *p.Description
+	}
+	if p.SetDueDate {
+		task.DueDate = nil
+		if p.DueDate != nil {
+			due := *p.DueDate
+			task.DueDate = &due
+		}
+	}
+	if p.Priority != nil {
+		task.Priority = *p.Priority
+	}
+	if p.Status != nil {
+		task.Status = *p.Status
+	}
+	if p.SetAssignee {
+		task.AssigneeID = nil
+		if p.AssigneeID != nil {
+			assignee := *p.AssigneeID
+			task.AssigneeID = &assignee
+		}
+	}
+	if p.SetProject {
+		task.ProjectID = copyID(p.ProjectID)
+	}
+	if p.SetTags {
+		task.Tags = normalizeTags(p.Tags)
+	}
+	if p.SetParent {
+		task.ParentID = copyID(p.ParentID)
+	}
+	return task
+}
+
+// validatePatch applies the validateTask rules to the fields being changed.
+func validatePatch(p TaskPatch) error {
+	if p.Title != nil {
+		if err := validateTask(model.Task{Title: *p.Title}); err != nil {
+			return err
+		}
+	}
+	if p.Priority != nil {
+		if err := validatePriority(*p.Priority); err != nil {
+			return err
+		}
+	}
+	if p.SetAssignee {
+		if err := validateAssignee(p.AssigneeID); err != nil {
+			return err
+		}
+	}
+	if p.SetProject {
+		if err := validateProjectID(p.ProjectID); err != nil {
+			return err
+		}
+	}
+	if p.SetTags {
+		if err := validateTags(p.Tags); err != nil {
+			return err
+		}
+	}
+	if p.SetParent {
+		if err := validateParentID(p.ParentID); err != nil {
+			return err
+		}
+	}
+	if p.Status != nil {
+		return validateStatus(*p.Status)
+	}
+	return nil
+}
+
+// patchSQL builds the UPDATE statement of a non-empty patch of a task in
+// the workspace ws. Tags are not a column of the tasks table: TaskRepo.Patch
+// sets them before running the statement.
+func patchSQL(ws, id int, p TaskPatch, d dialect) (string, []any) {
+	var b sqlBuilder
+	var set []string
+	if p.Title != nil {
+		set = append(set, "title = "+b.arg(*p.Title))
+	}
+	if p.Description != nil {
+		set = append(set, "description = "+b.arg(*p.Description))
+	}
+	if p.SetDueDate {
+		set = append(set, "duedate = "+b.arg(d.date(p.DueDate)))
+	}
+	if p.Priority != nil {
+		set = append(set, "priority = "+b.arg(*p.Priority))
+	}
+	if p.Status != nil {
+		set = append(set, "status = "+b.arg(*p.Status))
+	}
+	if p.SetAssignee {
+		set = append(set, "assignee_id = "+b.arg(nullInt(p.AssigneeID)))
+	}
+	if p.SetProject {
+		set = append(set, "project_id = "+b.arg(nullInt(p.ProjectID)))
+	}
+	if p.SetParent {
+		set = append(set, "parent_id = "+b.arg(nullInt(p.ParentID)))
+	}
+	set = append(set, "version = version + 1")
+	query := "UPDATE tasks SET " + strings.Join(set, ", ") + " WHERE id = " + b.arg(id) + " AND workspace_id = " + b.arg(ws)
+	if p.Version > 0 {
+		query += " AND version = " + b.arg(p.Version)
+	}
+	return query + " RETURNING " + taskColumns, b.args
+}
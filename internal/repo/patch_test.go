@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+func TestPatchOnlyUpdatesGivenColumns(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	// Completing the task also gives its parent a new version.
+	status := model.StatusCompleted
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status, parent_id FROM tasks WHERE id = $1 AND workspace_id = $2 FOR UPDATE")).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "parent_id"}).AddRow("Pending", 7))
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE tasks SET duedate = $1, status = $2, version = version + 1 WHERE id = $3 AND workspace_id = $4 AND version = $5 RETURNING "+taskColumns)).
+		WithArgs(nil, "Completed", 1, 1, 5).
+		WillReturnRows(sqlmock.NewRows(listColumns).AddRow(1, "Title", "Description", nil, 3, "Completed", 6, nil, nil, nil, 7, nil, nil, 0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE tasks SET version = version + 1 WHERE id = $1 AND workspace_id = $2")).
+		WithArgs(7, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	task, err := repo.Patch(testCtx, 1, TaskPatch{Status: &status, SetDueDate: true, Version: 5})
+	if err != nil {
+		t.Fatalf("error was not expected while patching task: %s", err)
+	}
+
+	parent := 7
+	expected := model.Task{ID: 1, Title: "Title", Description: "Description", Priority: model.PriorityHigh, Status: "Completed", Version: 6, ParentID: &parent}
+	if !reflect.DeepEqual(task, expected) {
+		t.Errorf("expected task %v, got %v", expected, task)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPatchNotFound(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	title := "New title"
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE tasks SET title = $1, version = version + 1 WHERE id = $2 AND workspace_id = $3")).
+		WithArgs("New title", 99, 1).
+		WillReturnRows(sqlmock.NewRows(listColumns))
+
+	if _, err := repo.Patch(testCtx, 99, TaskPatch{Title: &title}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPatchRejectsEmptyTitle(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	empty := ""
+	if _, err := repo.Patch(testCtx, 1, TaskPatch{Title: &empty}); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected ErrValidation, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPatchProjectOnlyUpdatesGivenColumns(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewProjectRepo(db)
+	defer db.Close()
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	archived := true
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE projects SET archived = $1 WHERE id = $2 AND workspace_id = $3 RETURNING "+projectColumns)).
+		WithArgs(true, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "color", "archived", "created_at"}).AddRow(1, "Name", "", "#1e90ff", true, created))
+
+	project, err := repo.PatchProject(testCtx, 1, ProjectPatch{Archived: &archived})
+	if err != nil {
+		t.Fatalf("error was not expected while patching project: %s", err)
+	}
+
+	expected := model.Project{ID: 1, Name: "Name", Color: "#1e90ff", Archived: true, CreatedAt: created}
+	if !reflect.DeepEqual(project, expected) {
+		t.Errorf("expected project %v, got %v", expected, project)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPatchApply(t *testing.T) {
+	due := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newDue := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	task := model.Task{ID: 1, Title: "Title", DueDate: &due, Priority: model.PriorityLow}
+
+	priority := model.PriorityHigh
+	patched := TaskPatch{Priority: &priority, SetDueDate: true, DueDate: &newDue}.Apply(task)
+
+	if patched.Priority != model.PriorityHigh || !patched.DueDate.Equal(newDue) || patched.Title != "Title" {
+		t.Errorf("unexpected patched task %v", patched)
+	}
+	if !task.DueDate.Equal(due) || task.Priority != model.PriorityLow {
+		t.Errorf("Apply must not modify the original task, got %v", task)
+	}
+	if cleared := (TaskPatch{SetDueDate: true}).Apply(task); cleared.DueDate != nil {
+		t.Errorf("expected the due date to be cleared, got %v", cleared.DueDate)
+	}
+}
@@ -0,0 +1,49 @@
+package repo_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/DimWebDev/task-manager-tool/internal/migrations"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/DimWebDev/task-manager-tool/internal/repo/repotest"
+	_ "github.com/lib/pq"
+)
+
+// TestTaskRepoConformance runs the conformance tests against a real
+// PostgreSQL database. It is skipped unless TEST_POSTGRES_DSN names a
+// database the tests may migrate and empty, e.g.
+//
+//	TEST_POSTGRES_DSN="host=localhost dbname=task_manager_test sslmode=disable" go test ./internal/repo/
+func TestTaskRepoConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := migrations.Postgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.New(db, schema).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		if _, err := db.Exec("TRUNCATE tasks, task_watchers, task_tags, tags, project_roles, projects, workspace_members, users RESTART IDENTITY"); err != nil {
+			t.Fatal(err)
+		}
+		// The default workspace is created by the migrations and stays.
+		if _, err := db.Exec("DELETE FROM workspaces WHERE slug <> 'default'"); err != nil {
+			t.Fatal(err)
+		}
+		return repotest.Repos{Tasks: repo.NewTaskRepo(db), Users: repo.NewUserRepo(db), Workspaces: repo.NewWorkspaceRepo(db), Projects: repo.NewProjectRepo(db), Tags: repo.NewTagRepo(db)}
+	})
+}
@@ -0,0 +1,532 @@
+// internal/repo/projects.go
+// The projects.go stores the projects tasks are grouped in, in the same
+// database and workspace as the tasks.
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// ProjectRepository stores projects. Like TaskRepository, every method is
+// confined to the workspace named by its context, and failures are reported
+// with the same sentinel errors.
+type ProjectRepository interface {
+	CreateProject(ctx context.Context, p model.Project) (model.Project, error)
+	GetProject(ctx context.Context, id int) (model.Project, error)
+	// ListProjects returns the projects of the workspace, ordered by ID.
+	// Archived projects are only included when includeArchived is set.
+	ListProjects(ctx context.Context, includeArchived bool) ([]model.Project, error)
+	// PatchProject changes the fields set in the patch and returns the
+	// updated project.
+	PatchProject(ctx context.Context, id int, patch ProjectPatch) (model.Project, error)
+	// DeleteProject deletes a project, doing with its tasks what tasks
+	// says. A project that still has tasks is only deleted when tasks
+	// says what to do with them; otherwise ErrProjectNotEmpty is returned.
+	DeleteProject(ctx context.Context, id int, tasks ProjectTasks) error
+}
+
+// Ensure ProjectRepo and MemoryProjectRepo implement ProjectRepository.
+var (
+	_ ProjectRepository = &ProjectRepo{}
+	_ ProjectRepository = &MemoryProjectRepo{}
+)
+
+// ErrProjectNotEmpty reports that a project was not deleted because it
+// still has tasks. It is a kind of ErrConflict.
+var ErrProjectNotEmpty = fmt.Errorf("%w: project has tasks", ErrConflict)
+
+// ProjectPatch lists the fields changed by PatchProject. Nil pointers leave
+// the corresponding field untouched.
+type ProjectPatch struct {
+	Name        *string
+	Description *string
+	Color       *string
+	Archived    *bool
+}
+
+// Apply returns a copy of p with the patch applied.
+func (pp ProjectPatch) Apply(p model.Project) model.Project {
+	if pp.Name != nil {
+		p.Name = *pp.Name
+	}
+	if pp.Description != nil {
+		p.Description = *pp.Description
+	}
+	if pp.Color != nil {
+		p.Color = *pp.Color
+	}
+	if pp.Archived != nil {
+		p.Archived = *pp.Archived
+	}
+	return p
+}
+
+// validate checks the fields set in the patch against the constraints the
+// projects table enforces.
+func (pp ProjectPatch) validate() error {
+	p := model.Project{Name: "unchanged"}
+	return validateProject(pp.Apply(p))
+}
+
+// sql builds the UPDATE statement of a non-empty patch of the project id in
+// the workspace ws.
+func (pp ProjectPatch) sql(ws, id int) (string, []any) {
+	var b sqlBuilder
+	var set []string
+	if pp.Name != nil {
+		set = append(set, "name = "+b.arg(*pp.Name))
+	}
+	if pp.Description != nil {
+		set = append(set, "description = "+b.arg(*pp.Description))
+	}
+	if pp.Color != nil {
+		set = append(set, "color = "+b.arg(*pp.Color))
+	}
+	if pp.Archived != nil {
+		set = append(set, "archived = "+b.arg(*pp.Archived))
+	}
+	query := "UPDATE projects SET " + strings.Join(set, ", ") + " WHERE id = " + b.arg(id) + " AND workspace_id = " + b.arg(ws)
+	return query + " RETURNING " + projectColumns, b.args
+}
+
+// ProjectTasks says what DeleteProject does with the tasks of the project.
+// The zero value deletes only projects without tasks.
+type ProjectTasks struct {
+	// Cascade deletes the tasks together with the project. Subtasks of
+	// those tasks outside the project become top-level tasks, with a new
+	// version.
+	Cascade bool
+	// Move moves the tasks to the project MoveTo, which must be another
+	// project of the workspace, or out of any project when MoveTo is nil.
+	// Moved tasks get a new version.
+	Move   bool
+	MoveTo *int
+}
+
+// colorPattern matches the colors the projects.color column accepts.
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// validateProject checks the constraints the projects table enforces.
+func validateProject(p model.Project) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+	if len(p.Name) > maxTitleLength {
+		return &ValidationError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxTitleLength)}
+	}
+	if p.Color != "" && !colorPattern.MatchString(p.Color) {
+		return &ValidationError{Field: "color", Message: "must be a color like #1e90ff"}
+	}
+	return nil
+}
+
+// validateProjectTasks checks the choice of what to do with the tasks of
+// the project id.
+func validateProjectTasks(id int, tasks ProjectTasks) error {
+	if tasks.Cascade && tasks.Move {
+		return &ValidationError{Field: "tasks", Message: "cannot be both deleted and moved"}
+	}
+	if tasks.MoveTo != nil && (!tasks.Move || *tasks.MoveTo == id) {
+		return &ValidationError{Field: "moveTo", Message: "must be another project the tasks are moved to"}
+	}
+	return nil
+}
+
+// projectNotFound builds the error returned when no project has the given
+// ID.
+func projectNotFound(id int) error {
+	return fmt.Errorf("%w: project %d", ErrNotFound, id)
+}
+
+// unknownMoveTarget is returned when the project tasks are to be moved to
+// does not exist.
+var unknownMoveTarget = &ValidationError{Field: "moveTo", Message: "is not a project of the workspace"}
+
+// unknownProject is returned when a task names a project that is not one
+// of its workspace.
+var unknownProject = &ValidationError{Field: "projectId", Message: "is not a project of the workspace"}
+
+// checkProject checks, within the transaction of q, that project, when set,
+// is a project of the workspace ws.
+func (tr *TaskRepo) checkProject(ctx context.Context, q rowQuerier, ws int, project *int) error {
+	if project == nil {
+		return nil
+	}
+	var found bool
+	err := q.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1 AND workspace_id = $2)", *project, ws,
+	).Scan(&found)
+	if err != nil {
+		return tr.translate(ctx, err)
+	}
+	if !found {
+		return unknownProject
+	}
+	return nil
+}
+
+// checkProject is MemoryTaskRepo's version of TaskRepo.checkProject. The
+// caller must hold mr.mu.
+func (mr *MemoryTaskRepo) checkProject(ws int, project *int) error {
+	if project == nil {
+		return nil
+	}
+	if p, ok := mr.projects[*project]; !ok || p.workspace != ws {
+		return unknownProject
+	}
+	return nil
+}
+
+// ProjectRepo provides access to the projects of a SQL database.
+type ProjectRepo struct {
+	db      tracedDB
+	dialect dialect
+}
+
+// NewProjectRepo creates a ProjectRepo for a PostgreSQL database.
+func NewProjectRepo(db *sql.DB) *ProjectRepo {
+	return &ProjectRepo{db: tracedDB{DB: db, system: "postgresql"}, dialect: postgresDialect}
+}
+
+// NewSQLiteProjectRepo creates a ProjectRepo for a SQLite database
+// migrated with migrations.SQLite.
+func NewSQLiteProjectRepo(db *sql.DB) *ProjectRepo {
+	return &ProjectRepo{db: tracedDB{DB: db, system: "sqlite"}, dialect: sqliteDialect}
+}
+
+// projectColumns lists the projects columns in the order expected by
+// scanProject.
+const projectColumns = "id, name, description, color, archived, created_at"
+
+// scanProject reads a single project row selected with projectColumns.
+func scanProject(s rowScanner) (model.Project, error) {
+	var p model.Project
+	if err := s.Scan(&p.ID, &p.Name, &p.Description, &p.Color, &p.Archived, &p.CreatedAt); err != nil {
+		return model.Project{}, err
+	}
+	return p, nil
+}
+
+// CreateProject inserts a new project into the workspace.
+func (pr *ProjectRepo) CreateProject(ctx context.Context, p model.Project) (model.Project, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Project{}, err
+	}
+	if err := validateProject(p); err != nil {
+		return model.Project{}, err
+	}
+	created, err := scanProject(pr.db.QueryRowContext(ctx,
+		"INSERT INTO projects (workspace_id, name, description, color, archived, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING "+projectColumns,
+		ws, p.Name, p.Description, p.Color, p.Archived, time.Now().UTC(),
+	))
+	if err != nil {
+		return model.Project{}, pr.translate(ctx, err)
+	}
+	return created, nil
+}
+
+// GetProject retrieves a project of the workspace by its ID.
+func (pr *ProjectRepo) GetProject(ctx context.Context, id int) (model.Project, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Project{}, err
+	}
+	p, err := scanProject(pr.db.QueryRowContext(ctx, "SELECT "+projectColumns+" FROM projects WHERE id = $1 AND workspace_id = $2", id, ws))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Project{}, projectNotFound(id)
+	}
+	if err != nil {
+		return model.Project{}, pr.translate(ctx, err)
+	}
+	return p, nil
+}
+
+// ListProjects retrieves the projects of the workspace, ordered by ID.
+func (pr *ProjectRepo) ListProjects(ctx context.Context, includeArchived bool) ([]model.Project, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + projectColumns + " FROM projects WHERE workspace_id = $1"
+	if !includeArchived {
+		query += " AND archived = FALSE"
+	}
+	rows, err := pr.db.QueryContext(ctx, query+" ORDER BY id", ws)
+	if err != nil {
+		return nil, pr.translate(ctx, err)
+	}
+	defer rows.Close()
+
+	projects := []model.Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, pr.translate(ctx, err)
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pr.translate(ctx, err)
+	}
+	return projects, nil
+}
+
+// PatchProject changes the fields set in the patch. Only the patched columns
+// are written, so that concurrent patches of other fields are not lost.
+func (pr *ProjectRepo) PatchProject(ctx context.Context, id int, patch ProjectPatch) (model.Project, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Project{}, err
+	}
+	if err := patch.validate(); err != nil {
+		return model.Project{}, err
+	}
+	if patch == (ProjectPatch{}) {
+		return pr.GetProject(ctx, id)
+	}
+	query, args := patch.sql(ws, id)
+	updated, err := scanProject(pr.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Project{}, projectNotFound(id)
+	}
+	if err != nil {
+		return model.Project{}, pr.translate(ctx, err)
+	}
+	return updated, nil
+}
+
+// DeleteProject deletes a project and deletes or moves its tasks in one
+// transaction.
+func (pr *ProjectRepo) DeleteProject(ctx context.Context, id int, tasks ProjectTasks) error {
+	ws, err := scope(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateProjectTasks(id, tasks); err != nil {
+		return err
+	}
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return pr.translate(ctx, err)
+	}
+	defer tx.Rollback()
+
+	exists := func(project int) (bool, error) {
+		var found bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1 AND workspace_id = $2)", project, ws,
+		).Scan(&found)
+		return found, err
+	}
+	if found, err := exists(id); err != nil || !found {
+		if err != nil {
+			return pr.translate(ctx, err)
+		}
+		return projectNotFound(id)
+	}
+
+	switch {
+	case tasks.Cascade:
+		// Subtasks in other projects become top-level tasks, and parents
+		// in other projects lose the subtasks deleted with this one.
+		_, err = tx.ExecContext(ctx,
+			"UPDATE tasks SET parent_id = NULL, version = version + 1 WHERE parent_id IN (SELECT id FROM tasks WHERE project_id = $1 AND workspace_id = $2) AND (project_id IS NULL OR project_id <> $1)",
+			id, ws,
+		)
+		if err == nil {
+			_, err = tx.ExecContext(ctx,
+				"UPDATE tasks SET version = version + 1 WHERE id IN (SELECT parent_id FROM tasks WHERE project_id = $1 AND workspace_id = $2) AND (project_id IS NULL OR project_id <> $1)",
+				id, ws,
+			)
+		}
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "DELETE FROM tasks WHERE project_id = $1 AND workspace_id = $2", id, ws)
+		}
+	case tasks.Move:
+		if tasks.MoveTo != nil {
+			found, err := exists(*tasks.MoveTo)
+			if err != nil {
+				return pr.translate(ctx, err)
+			}
+			if !found {
+				return unknownMoveTarget
+			}
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE tasks SET project_id = $1, version = version + 1 WHERE project_id = $2 AND workspace_id = $3",
+			nullInt(tasks.MoveTo), id, ws,
+		)
+	default:
+		var hasTasks bool
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM tasks WHERE project_id = $1 AND workspace_id = $2)", id, ws).Scan(&hasTasks)
+		if err == nil && hasTasks {
+			return fmt.Errorf("%w: project %d", ErrProjectNotEmpty, id)
+		}
+	}
+	if err != nil {
+		return pr.translate(ctx, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM projects WHERE id = $1 AND workspace_id = $2", id, ws); err != nil {
+		return pr.translate(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return pr.translate(ctx, err)
+	}
+	return nil
+}
+
+// translate maps a failed statement onto the repository sentinels.
+func (pr *ProjectRepo) translate(ctx context.Context, err error) error {
+	return pr.dialect.translateStatement(ctx, err)
+}
+
+// MemoryProjectRepo is a ProjectRepository keeping projects in memory
+// together with the tasks of a MemoryTaskRepo, so that archiving and
+// deleting projects acts on those tasks. It is safe for concurrent use.
+type MemoryProjectRepo struct {
+	tasks *MemoryTaskRepo
+}
+
+// NewMemoryProjectRepo creates a MemoryProjectRepo for the tasks of tasks.
+func NewMemoryProjectRepo(tasks *MemoryTaskRepo) *MemoryProjectRepo {
+	return &MemoryProjectRepo{tasks: tasks}
+}
+
+// project returns the project with the given ID in the workspace ws. The
+// caller must hold the lock of the task repository.
+func (mr *MemoryProjectRepo) project(ws, id int) (model.Project, error) {
+	p, ok := mr.tasks.projects[id]
+	if !ok || p.workspace != ws {
+		return model.Project{}, projectNotFound(id)
+	}
+	return p.Project, nil
+}
+
+// CreateProject stores a new project. IDs are assigned in increasing
+// order.
+func (mr *MemoryProjectRepo) CreateProject(ctx context.Context, p model.Project) (model.Project, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Project{}, err
+	}
+	if err := validateProject(p); err != nil {
+		return model.Project{}, err
+	}
+	mr.tasks.mu.Lock()
+	defer mr.tasks.mu.Unlock()
+
+	mr.tasks.lastProjectID++
+	p.ID, p.CreatedAt = mr.tasks.lastProjectID, time.Now().UTC()
+	mr.tasks.projects[p.ID] = memoryProject{Project: p, workspace: ws}
+	return p, nil
+}
+
+// GetProject retrieves a project by its ID.
+func (mr *MemoryProjectRepo) GetProject(ctx context.Context, id int) (model.Project, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Project{}, err
+	}
+	mr.tasks.mu.RLock()
+	defer mr.tasks.mu.RUnlock()
+
+	return mr.project(ws, id)
+}
+
+// ListProjects retrieves the projects of the workspace, ordered by ID.
+func (mr *MemoryProjectRepo) ListProjects(ctx context.Context, includeArchived bool) ([]model.Project, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mr.tasks.mu.RLock()
+	defer mr.tasks.mu.RUnlock()
+
+	projects := []model.Project{}
+	for id := 1; id <= mr.tasks.lastProjectID; id++ {
+		p, err := mr.project(ws, id)
+		if err == nil && (includeArchived || !p.Archived) {
+			projects = append(projects, p)
+		}
+	}
+	return projects, nil
+}
+
+// PatchProject changes the fields set in the patch.
+func (mr *MemoryProjectRepo) PatchProject(ctx context.Context, id int, patch ProjectPatch) (model.Project, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Project{}, err
+	}
+	mr.tasks.mu.Lock()
+	defer mr.tasks.mu.Unlock()
+
+	current, err := mr.project(ws, id)
+	if err != nil {
+		return model.Project{}, err
+	}
+	p := patch.Apply(current)
+	if err := validateProject(p); err != nil {
+		return model.Project{}, err
+	}
+	mr.tasks.projects[id] = memoryProject{Project: p, workspace: ws}
+	return p, nil
+}
+
+// DeleteProject deletes a project and deletes or moves its tasks, like
+// ProjectRepo.DeleteProject.
+func (mr *MemoryProjectRepo) DeleteProject(ctx context.Context, id int, tasks ProjectTasks) error {
+	ws, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateProjectTasks(id, tasks); err != nil {
+		return err
+	}
+	mr.tasks.mu.Lock()
+	defer mr.tasks.mu.Unlock()
+
+	if _, err := mr.project(ws, id); err != nil {
+		return err
+	}
+	if tasks.Move && tasks.MoveTo != nil {
+		if _, err := mr.project(ws, *tasks.MoveTo); err != nil {
+			return unknownMoveTarget
+		}
+	}
+	inProject := func(task model.Task) bool { return task.ProjectID != nil && *task.ProjectID == id }
+	for taskID, task := range mr.tasks.tasks {
+		if !inProject(task) {
+			continue
+		}
+		switch {
+		case tasks.Cascade:
+			for childID := range mr.tasks.children[taskID] {
+				if child := mr.tasks.tasks[childID]; !inProject(child) {
+					child.ParentID = nil
+					child.Version++
+					mr.tasks.put(child)
+				}
+			}
+			mr.tasks.remove(taskID)
+		case tasks.Move:
+			task.ProjectID = copyID(tasks.MoveTo)
+			task.Version++
+			mr.tasks.put(task)
+		default:
+			return fmt.Errorf("%w: project %d", ErrProjectNotEmpty, id)
+		}
+	}
+	delete(mr.tasks.projects, id)
+	return nil
+}
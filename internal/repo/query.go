@@ -0,0 +1,334 @@
+// internal/repo/query.go
+// The query.go describes how tasks can be filtered, sorted and paginated, and
+// builds the parameterised SQL used by TaskRepo.List.
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// SortField names a task attribute tasks can be ordered by. The values match
+// the JSON field names of model.Task.
+type SortField string
+
+// Supported sort fields.
+const (
+	SortByID          SortField = "id"
+	SortByTitle       SortField = "title"
+	SortByDescription SortField = "description"
+	SortByDueDate     SortField = "dueDate"
+	SortByPriority    SortField = "priority"
+	SortByStatus      SortField = "status"
+)
+
+// sortColumns maps every SortField onto its column in the tasks table.
+var sortColumns = map[SortField]string{
+	SortByID:          "id",
+	SortByTitle:       "title",
+	SortByDescription: "description",
+	SortByDueDate:     "duedate",
+	SortByPriority:    "priority",
+	SortByStatus:      "status",
+}
+
+// ParseSortField validates a client supplied sort field.
+func ParseSortField(s string) (SortField, error) {
+	field := SortField(s)
+	if _, ok := sortColumns[field]; !ok {
+		return "", &ValidationError{Field: "sort", Message: fmt.Sprintf("cannot sort by %q", s)}
+	}
+	return field, nil
+}
+
+// Page size limits applied by List.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// TaskQuery selects a page of tasks. The zero value lists the first page of
+// all tasks ordered by ID.
+type TaskQuery struct {
+	// Statuses and Priorities restrict the result to tasks having one of the
+	// given values. An empty slice does not filter.
+	Statuses   []model.Status
+	Priorities []model.Priority
+	// MinPriority and MaxPriority bound the priority, both inclusive; zero
+	// does not filter. Tasks without a priority are excluded as soon as
+	// either bound is set.
+	MinPriority model.Priority
+	MaxPriority model.Priority
+	// DueAfter and DueBefore bound the due date, both inclusive. Tasks
+	// without a due date are excluded as soon as either bound is set.
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	// AssigneeID, CreatedBy and Watcher restrict the result to the tasks
+	// assigned to, created by or watched by the user with the given ID;
+	// zero does not filter.
+	AssigneeID int
+	CreatedBy  int
+	Watcher    int
+	// ProjectID restricts the result to the tasks of the project with the
+	// given ID; zero does not filter. Unless a project is given or
+	// IncludeArchived is set, tasks of archived projects are left out.
+	ProjectID       int
+	IncludeArchived bool
+	// Tags restricts the result to the tasks labelled with any of the
+	// given tags, or with all of them when AllTags is set. An empty slice
+	// does not filter.
+	Tags    []string
+	AllTags bool
+
+	SortBy     SortField
+	Descending bool
+
+	// Limit is the page size; zero means DefaultPageSize.
+	Limit int
+	// After continues a previous listing from the cursor it returned.
+	After *Cursor
+}
+
+// TaskPage is one page of a task listing.
+type TaskPage struct {
+	Tasks []model.Task
+	// Next is the cursor of the following page, or nil on the last page.
+	Next *Cursor
+}
+
+// Cursor marks the position after the last task of a page. It records the
+// sort key of that task, so the next page is found with a keyset condition
+// instead of an OFFSET.
+type Cursor struct {
+	SortBy     SortField `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	// Value is the sort column value of the last task, nil for NULL.
+	Value *string `json:"v,omitempty"`
+	ID    int     `json:"id"`
+}
+
+// Encode returns the opaque string representation of the cursor.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor produced by Cursor.Encode.
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, &ValidationError{Field: "cursor", Message: "is malformed"}
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, &ValidationError{Field: "cursor", Message: "is malformed"}
+	}
+	if _, ok := sortColumns[c.SortBy]; !ok {
+		return nil, &ValidationError{Field: "cursor", Message: "is malformed"}
+	}
+	return &c, nil
+}
+
+// normalize applies defaults and checks the query for consistency.
+func (q TaskQuery) normalize() (TaskQuery, error) {
+	if q.SortBy == "" {
+		q.SortBy = SortByID
+	}
+	if _, ok := sortColumns[q.SortBy]; !ok {
+		return q, &ValidationError{Field: "sort", Message: fmt.Sprintf("cannot sort by %q", q.SortBy)}
+	}
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultPageSize
+	case q.Limit < 0 || q.Limit > MaxPageSize:
+		return q, &ValidationError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", MaxPageSize)}
+	}
+	if q.MinPriority != model.PriorityNone && !q.MinPriority.Valid() {
+		return q, &ValidationError{Field: "minPriority", Message: "is not a known priority"}
+	}
+	if q.MaxPriority != model.PriorityNone && !q.MaxPriority.Valid() {
+		return q, &ValidationError{Field: "maxPriority", Message: "is not a known priority"}
+	}
+	if q.MinPriority != model.PriorityNone && q.MaxPriority != model.PriorityNone && q.MinPriority > q.MaxPriority {
+		return q, &ValidationError{Field: "minPriority", Message: "must not be higher than maxPriority"}
+	}
+	for _, f := range []struct {
+		name string
+		id   int
+	}{{"assignee", q.AssigneeID}, {"createdBy", q.CreatedBy}, {"watcher", q.Watcher}} {
+		if f.id < 0 {
+			return q, &ValidationError{Field: f.name, Message: "must be a positive user ID"}
+		}
+	}
+	if q.ProjectID < 0 {
+		return q, &ValidationError{Field: "project", Message: "must be a positive project ID"}
+	}
+	for _, tag := range q.Tags {
+		if err := validateTagName("tag", tag); err != nil {
+			return q, err
+		}
+	}
+	q.Tags = normalizeTags(q.Tags)
+	if q.DueAfter != nil && q.DueBefore != nil && q.DueAfter.After(*q.DueBefore) {
+		return q, &ValidationError{Field: "dueAfter", Message: "must not be later than dueBefore"}
+	}
+	if q.After != nil && (q.After.SortBy != q.SortBy || q.After.Descending != q.Descending) {
+		return q, &ValidationError{Field: "cursor", Message: "does not match the requested sort order"}
+	}
+	return q, nil
+}
+
+// sortValue returns the cursor representation of the task's sort key.
+func sortValue(task model.Task, field SortField) *string {
+	var v string
+	switch field {
+	case SortByID:
+		v = strconv.Itoa(task.ID)
+	case SortByTitle:
+		v = task.Title
+	case SortByDescription:
+		v = task.Description
+	case SortByDueDate:
+		if task.DueDate == nil {
+			return nil
+		}
+		v = task.DueDate.Format(time.DateOnly)
+	case SortByPriority:
+		if task.Priority == model.PriorityNone {
+			return nil
+		}
+		v = strconv.Itoa(int(task.Priority))
+	case SortByStatus:
+		v = string(task.Status)
+	}
+	return &v
+}
+
+// sqlBuilder accumulates the WHERE conditions and positional arguments of
+// a statement.
+type sqlBuilder struct {
+	where []string
+	args  []any
+}
+
+// arg records a statement argument and returns its placeholder.
+func (b *sqlBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// in adds "column IN (...)" for a non-empty set of values.
+func (b *sqlBuilder) in(column string, values []any) {
+	if len(values) == 0 {
+		return
+	}
+	b.where = append(b.where, column+" IN ("+b.list(values)+")")
+}
+
+// list records values as statement arguments and returns their
+// comma-separated placeholders.
+func (b *sqlBuilder) list(values []any) string {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = b.arg(v)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// anySlice converts values into statement arguments.
+func anySlice[T any](values []T) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// listSQL builds the SELECT statement of a normalized query over the tasks
+// of the workspace ws. It fetches one row more than the page size to detect
+// whether a next page exists.
+func listSQL(ws int, q TaskQuery, d dialect) (string, []any) {
+	var b sqlBuilder
+	b.where = append(b.where, "workspace_id = "+b.arg(ws))
+	b.in("status", anySlice(q.Statuses))
+	b.in("priority", anySlice(q.Priorities))
+	if q.MinPriority != model.PriorityNone {
+		b.where = append(b.where, "priority >= "+b.arg(q.MinPriority))
+	}
+	if q.MaxPriority != model.PriorityNone {
+		b.where = append(b.where, "priority <= "+b.arg(q.MaxPriority))
+	}
+	if q.DueAfter != nil {
+		b.where = append(b.where, "duedate >= "+b.arg(d.date(q.DueAfter)))
+	}
+	if q.DueBefore != nil {
+		b.where = append(b.where, "duedate <= "+b.arg(d.date(q.DueBefore)))
+	}
+	if q.AssigneeID != 0 {
+		b.where = append(b.where, "assignee_id = "+b.arg(q.AssigneeID))
+	}
+	if q.CreatedBy != 0 {
+		b.where = append(b.where, "created_by = "+b.arg(q.CreatedBy))
+	}
+	if q.Watcher != 0 {
+		b.where = append(b.where, "id IN (SELECT task_id FROM task_watchers WHERE user_id = "+b.arg(q.Watcher)+")")
+	}
+	switch {
+	case q.ProjectID != 0:
+		b.where = append(b.where, "project_id = "+b.arg(q.ProjectID))
+	case !q.IncludeArchived:
+		b.where = append(b.where, "(project_id IS NULL OR project_id NOT IN (SELECT id FROM projects WHERE archived = TRUE))")
+	}
+	if len(q.Tags) > 0 {
+		cond := "id IN (SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE tags.name IN (" +
+			b.list(anySlice(q.Tags)) + ")"
+		if q.AllTags {
+			// The tags are distinct, so a task has them all when it
+			// matches as many of them.
+			cond += " GROUP BY task_tags.task_id HAVING COUNT(*) = " + b.arg(len(q.Tags))
+		}
+		b.where = append(b.where, cond+")")
+	}
+
+	column := sortColumns[q.SortBy]
+	// NULLs sort after all values in ascending order and before them in
+	// descending order, so a descending listing is the exact reverse.
+	cmp, order := ">", "ASC NULLS LAST"
+	if q.Descending {
+		cmp, order = "<", "DESC NULLS FIRST"
+	}
+	if c := q.After; c != nil {
+		switch {
+		case q.SortBy == SortByID:
+			b.where = append(b.where, "id "+cmp+" "+b.arg(c.ID))
+		case c.Value == nil && !q.Descending:
+			// Only NULL keys are left after a NULL key.
+			b.where = append(b.where, fmt.Sprintf("(%s IS NULL AND id > %s)", column, b.arg(c.ID)))
+		case c.Value == nil:
+			id := b.arg(c.ID)
+			b.where = append(b.where, fmt.Sprintf("((%s IS NULL AND id < %s) OR %s IS NOT NULL)", column, id, column))
+		default:
+			v, id := b.arg(*c.Value), b.arg(c.ID)
+			cond := fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s)", column, cmp, v, column, v, cmp, id)
+			if !q.Descending {
+				cond += " OR " + column + " IS NULL"
+			}
+			b.where = append(b.where, cond+")")
+		}
+	}
+
+	query := "SELECT " + taskColumns + " FROM tasks WHERE " + strings.Join(b.where, " AND ")
+	if q.SortBy == SortByID {
+		query += " ORDER BY id " + strings.Fields(order)[0]
+	} else {
+		query += fmt.Sprintf(" ORDER BY %s %s, id %s", column, order, strings.Fields(order)[0])
+	}
+	query += " LIMIT " + b.arg(q.Limit+1)
+	return query, b.args
+}
@@ -0,0 +1,283 @@
+package repo
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// hideArchived is the condition leaving out the tasks of archived projects.
+const hideArchived = "(project_id IS NULL OR project_id NOT IN (SELECT id FROM projects WHERE archived = TRUE))"
+
+var listColumns = []string{"id", "title", "description", "duedate", "priority", "status", "version", "created_by", "assignee_id", "project_id", "parent_id", "watchers", "tags", "subtasks", "completed_subtasks"}
+
+func TestListFiltersAndPaginates(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+taskColumns+" FROM tasks "+
+		"WHERE workspace_id = $1 AND status IN ($2, $3) AND priority IN ($4) AND duedate >= $5 AND "+hideArchived+" "+
+		"ORDER BY duedate ASC NULLS LAST, id ASC LIMIT $6")).
+		WithArgs(1, "Pending", "In Progress", int64(3), after, 3).
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow(3, "Task 3", "", due, 3, "Pending", 1, nil, nil, nil, nil, nil, nil, 0, 0).
+			AddRow(1, "Task 1", "", due, 3, "In Progress", 1, nil, nil, nil, nil, nil, nil, 0, 0).
+			AddRow(2, "Task 2", "", due, 3, "Pending", 1, nil, nil, nil, nil, nil, nil, 0, 0))
+
+	page, err := repo.List(testCtx, TaskQuery{
+		Statuses:   []model.Status{model.StatusPending, model.StatusInProgress},
+		Priorities: []model.Priority{model.PriorityHigh},
+		DueAfter:   &after,
+		SortBy:     SortByDueDate,
+		Limit:      2,
+	})
+	if err != nil {
+		t.Fatalf("error was not expected while listing tasks: %s", err)
+	}
+
+	if len(page.Tasks) != 2 || page.Tasks[0].ID != 3 || page.Tasks[1].ID != 1 {
+		t.Errorf("expected tasks 3 and 1, got %v", page.Tasks)
+	}
+	value := "2024-02-01"
+	expectedNext := &Cursor{SortBy: SortByDueDate, Value: &value, ID: 1}
+	if !reflect.DeepEqual(page.Next, expectedNext) {
+		t.Errorf("expected next cursor %v, got %v", expectedNext, page.Next)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestListContinuesAfterCursor(t *testing.T) {
+	value := "2024-02-01"
+	cases := []struct {
+		name   string
+		cursor Cursor
+		sql    string
+		args   []any
+	}{
+		{
+			name:   "ascending",
+			cursor: Cursor{SortBy: SortByDueDate, Value: &value, ID: 1},
+			sql: "WHERE workspace_id = $1 AND (duedate > $2 OR (duedate = $2 AND id > $3) OR duedate IS NULL) " +
+				"ORDER BY duedate ASC NULLS LAST, id ASC LIMIT $4",
+			args: []any{1, value, 1, DefaultPageSize + 1},
+		},
+		{
+			name:   "descending",
+			cursor: Cursor{SortBy: SortByDueDate, Descending: true, Value: &value, ID: 1},
+			sql: "WHERE workspace_id = $1 AND (duedate < $2 OR (duedate = $2 AND id < $3)) " +
+				"ORDER BY duedate DESC NULLS FIRST, id DESC LIMIT $4",
+			args: []any{1, value, 1, DefaultPageSize + 1},
+		},
+		{
+			name:   "ascending after NULL",
+			cursor: Cursor{SortBy: SortByDueDate, ID: 5},
+			sql:    "WHERE workspace_id = $1 AND (duedate IS NULL AND id > $2) ORDER BY duedate ASC NULLS LAST, id ASC LIMIT $3",
+			args:   []any{1, 5, DefaultPageSize + 1},
+		},
+		{
+			name:   "descending after NULL",
+			cursor: Cursor{SortBy: SortByDueDate, Descending: true, ID: 5},
+			sql: "WHERE workspace_id = $1 AND ((duedate IS NULL AND id < $2) OR duedate IS NOT NULL) " +
+				"ORDER BY duedate DESC NULLS FIRST, id DESC LIMIT $3",
+			args: []any{1, 5, DefaultPageSize + 1},
+		},
+		{
+			name:   "by id",
+			cursor: Cursor{SortBy: SortByID, ID: 5},
+			sql:    "WHERE workspace_id = $1 AND id > $2 ORDER BY id ASC LIMIT $3",
+			args:   []any{1, 5, DefaultPageSize + 1},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := TaskQuery{SortBy: tc.cursor.SortBy, Descending: tc.cursor.Descending, After: &tc.cursor, IncludeArchived: true}
+			q, err := q.normalize()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			query, args := listSQL(1, q, postgresDialect)
+			expected := "SELECT " + taskColumns + " FROM tasks " + tc.sql
+			if query != expected {
+				t.Errorf("expected query\n%s\ngot\n%s", expected, query)
+			}
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("expected args %v, got %v", tc.args, args)
+			}
+		})
+	}
+}
+
+func TestListLastPageHasNoCursor(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+taskColumns+" FROM tasks WHERE workspace_id = $1 AND "+hideArchived+" ORDER BY id ASC LIMIT $2")).
+		WithArgs(1, DefaultPageSize+1).
+		WillReturnRows(sqlmock.NewRows(listColumns).AddRow(1, "Task 1", "", nil, 1, "Pending", 1, nil, nil, nil, nil, nil, nil, 0, 0))
+
+	page, err := repo.List(testCtx, TaskQuery{})
+	if err != nil {
+		t.Fatalf("error was not expected while listing tasks: %s", err)
+	}
+	expected := []model.Task{{ID: 1, Title: "Task 1", Priority: model.PriorityLow, Status: "Pending", Version: 1}}
+	if !reflect.DeepEqual(page.Tasks, expected) || page.Next != nil {
+		t.Errorf("expected a single last page with %v, got %v (next %v)", expected, page.Tasks, page.Next)
+	}
+}
+
+func TestListRejectsInvalidQueries(t *testing.T) {
+	other := Cursor{SortBy: SortByTitle, ID: 1}
+	cases := map[string]TaskQuery{
+		"unknown sort field": {SortBy: "color"},
+		"limit too large":    {Limit: MaxPageSize + 1},
+		"negative limit":     {Limit: -1},
+		"cursor mismatch":    {SortBy: SortByID, After: &other},
+	}
+
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	for name, q := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := repo.List(testCtx, q); !errors.Is(err, ErrValidation) {
+				t.Errorf("expected ErrValidation, got %v", err)
+			}
+		})
+	}
+	// The database must not be touched for invalid queries
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	value := "Write docs"
+	cursor := Cursor{SortBy: SortByTitle, Descending: true, Value: &value, ID: 12}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("unexpected error decoding cursor: %s", err)
+	}
+	if !reflect.DeepEqual(*decoded, cursor) {
+		t.Errorf("expected %v, got %v", cursor, *decoded)
+	}
+
+	for _, raw := range []string{"!!", "bm90IGpzb24", "eyJzIjoiY29sb3IiLCJpZCI6MX0"} {
+		if _, err := DecodeCursor(raw); !errors.Is(err, ErrValidation) {
+			t.Errorf("expected ErrValidation for %q, got %v", raw, err)
+		}
+	}
+}
+
+func TestListSQLPriorityRange(t *testing.T) {
+	q, err := TaskQuery{MinPriority: model.PriorityHigh, SortBy: SortByPriority, Descending: true}.normalize()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	query, args := listSQL(1, q, postgresDialect)
+	expected := "SELECT " + taskColumns + " FROM tasks WHERE workspace_id = $1 AND priority >= $2 AND " + hideArchived + " " +
+		"ORDER BY priority DESC NULLS FIRST, id DESC LIMIT $3"
+	if query != expected {
+		t.Errorf("expected query\n%s\ngot\n%s", expected, query)
+	}
+	if !reflect.DeepEqual(args, []any{1, model.PriorityHigh, DefaultPageSize + 1}) {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	if _, err := (TaskQuery{MinPriority: model.PriorityCritical, MaxPriority: model.PriorityLow}).normalize(); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a validation error for an empty priority range, got %v", err)
+	}
+}
+
+func TestListSQLProjects(t *testing.T) {
+	cases := []struct {
+		name  string
+		query TaskQuery
+		where string
+		args  []any
+	}{
+		{"default", TaskQuery{}, "workspace_id = $1 AND " + hideArchived, []any{1}},
+		{"including archived", TaskQuery{IncludeArchived: true}, "workspace_id = $1", []any{1}},
+		{"one project", TaskQuery{ProjectID: 4}, "workspace_id = $1 AND project_id = $2", []any{1, 4}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.query.normalize()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			query, args := listSQL(1, q, postgresDialect)
+			expected := "SELECT " + taskColumns + " FROM tasks WHERE " + tc.where + " ORDER BY id ASC LIMIT $" + strconv.Itoa(len(tc.args)+1)
+			if query != expected {
+				t.Errorf("expected query\n%s\ngot\n%s", expected, query)
+			}
+			if !reflect.DeepEqual(args, append(tc.args, DefaultPageSize+1)) {
+				t.Errorf("unexpected args %v", args)
+			}
+		})
+	}
+
+	if _, err := (TaskQuery{ProjectID: -1}).normalize(); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a validation error for a negative project, got %v", err)
+	}
+}
+
+func TestListSQLTags(t *testing.T) {
+	const tagged = "id IN (SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE tags.name IN ($2, $3)"
+	cases := []struct {
+		name  string
+		query TaskQuery
+		where string
+		args  []any
+	}{
+		{"any", TaskQuery{Tags: []string{"infra", "bug"}}, tagged + ")", []any{1, "bug", "infra"}},
+		{"all", TaskQuery{Tags: []string{"infra", "bug", "bug"}, AllTags: true},
+			tagged + " GROUP BY task_tags.task_id HAVING COUNT(*) = $4)", []any{1, "bug", "infra", 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.query.IncludeArchived = true
+			q, err := tc.query.normalize()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			query, args := listSQL(1, q, postgresDialect)
+			expected := "SELECT " + taskColumns + " FROM tasks WHERE workspace_id = $1 AND " + tc.where + " ORDER BY id ASC LIMIT $" + strconv.Itoa(len(tc.args)+1)
+			if query != expected {
+				t.Errorf("expected query\n%s\ngot\n%s", expected, query)
+			}
+			if !reflect.DeepEqual(args, append(tc.args, DefaultPageSize+1)) {
+				t.Errorf("unexpected args %v", args)
+			}
+		})
+	}
+
+	if _, err := (TaskQuery{Tags: []string{"a,b"}}).normalize(); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a validation error for an invalid tag, got %v", err)
+	}
+}
+
+func TestPrioritySortValue(t *testing.T) {
+	if v := sortValue(model.Task{Priority: model.PriorityHigh}, SortByPriority); v == nil || *v != "3" {
+		t.Errorf("expected the numeric priority as cursor value, got %v", v)
+	}
+	if v := sortValue(model.Task{}, SortByPriority); v != nil {
+		t.Errorf("expected a NULL cursor value for an unset priority, got %q", *v)
+	}
+}
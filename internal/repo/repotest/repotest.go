@@ -0,0 +1,1633 @@
+// Package repotest holds the conformance tests every repo.TaskRepository,
+// repo.UserRepository, repo.WorkspaceRepository, repo.ProjectRepository and
+// repo.TagRepository implementation must pass, so that the storage backends stay interchangeable.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+)
+
+// Repos are the repositories of one store, tasks referring to the users of
+// Users, the projects of Projects and the tags of Tags.
+type Repos struct {
+	Tasks      repo.TaskRepository
+	Users      repo.UserRepository
+	Workspaces repo.WorkspaceRepository
+	Projects   repo.ProjectRepository
+	Tags       repo.TagRepository
+}
+
+// ctx confines the calls of the tests to the default workspace, which every
+// store starts with.
+var ctx = repo.WithWorkspace(context.Background(), model.DefaultWorkspaceID)
+
+// NewRepo returns empty repositories for one test. It may register cleanup
+// with t.Cleanup.
+type NewRepo func(t *testing.T) Repos
+
+// Run runs the conformance tests against the repositories made by newRepo.
+func Run(t *testing.T, newRepo NewRepo) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r repo.TaskRepository)
+	}{
+		{"CreateAssignsIDAndVersion", testCreateAssignsIDAndVersion},
+		{"CreateRejectsInvalidTasks", testCreateRejectsInvalidTasks},
+		{"GetByIDNotFound", testGetByIDNotFound},
+		{"DueDateIsCopied", testDueDateIsCopied},
+		{"GetAll", testGetAll},
+		{"Update", testUpdate},
+		{"UpdateWithStaleVersion", testUpdateWithStaleVersion},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"Patch", testPatch},
+		{"Delete", testDelete},
+		{"ListFilters", testListFilters},
+		{"ListPaginates", testListPaginates},
+		{"ListSortsWithNullsLast", testListSortsWithNullsLast},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ContextDone", testContextDone},
+		{"Subtasks", testSubtasks},
+		{"SubtaskCycles", testSubtaskCycles},
+		{"DeleteSubtasks", testDeleteSubtasks},
+		{"SubtasksChangeParentVersion", testSubtasksChangeParentVersion},
+		{"ConcurrentReparents", testConcurrentReparents},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t).Tasks)
+		})
+	}
+
+	userTests := []struct {
+		name string
+		fn   func(t *testing.T, r Repos)
+	}{
+		{"EnsureUser", testEnsureUser},
+		{"SetRole", testSetRole},
+		{"SetProjectRole", testSetProjectRole},
+		{"OwnershipAndAssignment", testOwnershipAndAssignment},
+		{"Watchers", testWatchers},
+		{"ListFiltersByUser", testListFiltersByUser},
+		{"Workspaces", testWorkspaces},
+		{"WorkspaceMembers", testWorkspaceMembers},
+		{"WorkspacesAreIsolated", testWorkspacesAreIsolated},
+		{"CallsRequireWorkspace", testCallsRequireWorkspace},
+		{"Projects", testProjects},
+		{"ProjectTasks", testProjectTasks},
+		{"ArchivedProjectsHideTasks", testArchivedProjectsHideTasks},
+		{"DeleteProject", testDeleteProject},
+		{"ProjectsAreIsolated", testProjectsAreIsolated},
+		{"TaskTags", testTaskTags},
+		{"Tags", testTags},
+		{"ListFiltersByTags", testListFiltersByTags},
+		{"TagsAreIsolated", testTagsAreIsolated},
+		{"SubtasksAreIsolated", testSubtasksAreIsolated},
+		{"DeleteProjectKeepsSubtasks", testDeleteProjectKeepsSubtasks},
+	}
+	for _, tt := range userTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+func mustCreate(t *testing.T, r repo.TaskRepository, task model.Task) model.Task {
+	t.Helper()
+	created, err := r.Create(ctx, task)
+	if err != nil {
+		t.Fatalf("Create(%+v): %s", task, err)
+	}
+	return created
+}
+
+// sameTask compares tasks, treating due dates as equal when they denote the
+// same instant.
+func sameTask(a, b model.Task) bool {
+	if (a.DueDate == nil) != (b.DueDate == nil) {
+		return false
+	}
+	if a.DueDate != nil && !a.DueDate.Equal(*b.DueDate) {
+		return false
+	}
+	a.DueDate, b.DueDate = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func mustEnsureUser(t *testing.T, r repo.UserRepository, subject string) model.User {
+	t.Helper()
+	user, err := r.EnsureUser(ctx, subject)
+	if err != nil {
+		t.Fatalf("EnsureUser(%q): %s", subject, err)
+	}
+	return user
+}
+
+func mustGet(t *testing.T, r repo.TaskRepository, id int) model.Task {
+	t.Helper()
+	task, err := r.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID(%d): %s", id, err)
+	}
+	return task
+}
+
+func ids(tasks []model.Task) []int {
+	ids := make([]int, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func testCreateAssignsIDAndVersion(t *testing.T, r repo.TaskRepository) {
+	task := model.Task{
+		Title:       "Write report",
+		Description: "Quarterly numbers",
+		DueDate:     date(2024, 5, 1),
+		Priority:    model.PriorityHigh,
+		Status:      model.StatusPending,
+	}
+	first := mustCreate(t, r, task)
+	second := mustCreate(t, r, model.Task{Title: "Second"})
+
+	if first.ID == 0 || second.ID <= first.ID {
+		t.Errorf("expected increasing IDs, got %d and %d", first.ID, second.ID)
+	}
+	if first.Version != 1 {
+		t.Errorf("expected version 1, got %d", first.Version)
+	}
+	task.ID, task.Version = first.ID, 1
+	if !sameTask(first, task) {
+		t.Errorf("expected %+v, got %+v", task, first)
+	}
+
+	stored, err := r.GetByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %s", err)
+	}
+	if !sameTask(stored, first) {
+		t.Errorf("expected %+v, got %+v", first, stored)
+	}
+}
+
+func testCreateRejectsInvalidTasks(t *testing.T, r repo.TaskRepository) {
+	for _, task := range []model.Task{
+		{Title: ""},
+		{Title: "Task", Status: "Blocked"},
+		{Title: "Task", Priority: model.Priority(9)},
+	} {
+		var verr *repo.ValidationError
+		if _, err := r.Create(ctx, task); !errors.As(err, &verr) {
+			t.Errorf("Create(%+v): expected a ValidationError, got %v", task, err)
+		}
+	}
+}
+
+func testGetByIDNotFound(t *testing.T, r repo.TaskRepository) {
+	if _, err := r.GetByID(ctx, 12345); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testDueDateIsCopied(t *testing.T, r repo.TaskRepository) {
+	due := date(2024, 5, 1)
+	created := mustCreate(t, r, model.Task{Title: "Task", DueDate: due})
+
+	// Neither the caller's pointer nor the returned one may alias the store.
+	*due = due.AddDate(1, 0, 0)
+	*created.DueDate = created.DueDate.AddDate(2, 0, 0)
+
+	stored, err := r.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %s", err)
+	}
+	if !stored.DueDate.Equal(*date(2024, 5, 1)) {
+		t.Errorf("stored due date changed to %s", stored.DueDate)
+	}
+}
+
+func testGetAll(t *testing.T, r repo.TaskRepository) {
+	a := mustCreate(t, r, model.Task{Title: "A"})
+	b := mustCreate(t, r, model.Task{Title: "B"})
+
+	tasks, err := r.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll: %s", err)
+	}
+	got := map[int]bool{}
+	for _, task := range tasks {
+		got[task.ID] = true
+	}
+	if len(tasks) != 2 || !got[a.ID] || !got[b.ID] {
+		t.Errorf("expected tasks %d and %d, got %v", a.ID, b.ID, ids(tasks))
+	}
+}
+
+func testUpdate(t *testing.T, r repo.TaskRepository) {
+	created := mustCreate(t, r, model.Task{Title: "Task", Status: model.StatusPending})
+
+	change := model.Task{ID: created.ID, Title: "Renamed", Status: model.StatusInProgress, DueDate: date(2024, 6, 1), Version: created.Version}
+	updated, err := r.Update(ctx, change)
+	if err != nil {
+		t.Fatalf("Update: %s", err)
+	}
+	change.Version = 2
+	if !sameTask(updated, change) {
+		t.Errorf("expected %+v, got %+v", change, updated)
+	}
+
+	// Without a version the update is unconditional.
+	change.Version = 0
+	if updated, err = r.Update(ctx, change); err != nil || updated.Version != 3 {
+		t.Errorf("expected version 3, got %d (%v)", updated.Version, err)
+	}
+}
+
+func testUpdateWithStaleVersion(t *testing.T, r repo.TaskRepository) {
+	created := mustCreate(t, r, model.Task{Title: "Task"})
+	if _, err := r.Update(ctx, model.Task{ID: created.ID, Title: "First", Version: 1}); err != nil {
+		t.Fatalf("Update: %s", err)
+	}
+
+	_, err := r.Update(ctx, model.Task{ID: created.ID, Title: "Second", Version: 1})
+	if !errors.Is(err, repo.ErrVersionMismatch) || !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+	if _, err := r.Patch(ctx, created.ID, repo.TaskPatch{Version: 1}); !errors.Is(err, repo.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch from an empty patch, got %v", err)
+	}
+	if err := r.Delete(ctx, created.ID, 1, repo.TaskSubtasks{}); !errors.Is(err, repo.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch from Delete, got %v", err)
+	}
+}
+
+func testUpdateNotFound(t *testing.T, r repo.TaskRepository) {
+	if _, err := r.Update(ctx, model.Task{ID: 12345, Title: "Task"}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from Update, got %v", err)
+	}
+	if _, err := r.Update(ctx, model.Task{ID: 12345, Title: "Task", Version: 3}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from a conditional Update, got %v", err)
+	}
+	title := "Task"
+	if _, err := r.Patch(ctx, 12345, repo.TaskPatch{Title: &title}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from Patch, got %v", err)
+	}
+}
+
+func testPatch(t *testing.T, r repo.TaskRepository) {
+	created := mustCreate(t, r, model.Task{Title: "Task", Description: "Keep", DueDate: date(2024, 5, 1), Priority: model.PriorityLow})
+
+	priority := model.PriorityCritical
+	patched, err := r.Patch(ctx, created.ID, repo.TaskPatch{Priority: &priority, SetDueDate: true, Version: 1})
+	if err != nil {
+		t.Fatalf("Patch: %s", err)
+	}
+	if patched.Priority != priority || patched.DueDate != nil || patched.Description != "Keep" || patched.Version != 2 {
+		t.Errorf("unexpected patched task %+v", patched)
+	}
+
+	empty, err := r.Patch(ctx, created.ID, repo.TaskPatch{})
+	if err != nil || empty.Version != 2 {
+		t.Errorf("expected an empty patch to return the task unchanged, got %+v (%v)", empty, err)
+	}
+
+	title := ""
+	var verr *repo.ValidationError
+	if _, err := r.Patch(ctx, created.ID, repo.TaskPatch{Title: &title}); !errors.As(err, &verr) || verr.Field != "title" {
+		t.Errorf("expected a title ValidationError, got %v", err)
+	}
+}
+
+func testDelete(t *testing.T, r repo.TaskRepository) {
+	created := mustCreate(t, r, model.Task{Title: "Task"})
+	if err := r.Delete(ctx, created.ID, 1, repo.TaskSubtasks{}); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	if _, err := r.GetByID(ctx, created.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := r.Delete(ctx, created.ID, 0, repo.TaskSubtasks{}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when deleting twice, got %v", err)
+	}
+}
+
+func testListFilters(t *testing.T, r repo.TaskRepository) {
+	low := mustCreate(t, r, model.Task{Title: "Low", Priority: model.PriorityLow, Status: model.StatusPending, DueDate: date(2024, 1, 10)})
+	high := mustCreate(t, r, model.Task{Title: "High", Priority: model.PriorityHigh, Status: model.StatusInProgress, DueDate: date(2024, 2, 10)})
+	critical := mustCreate(t, r, model.Task{Title: "Critical", Priority: model.PriorityCritical, Status: model.StatusPending})
+	none := mustCreate(t, r, model.Task{Title: "None", Status: model.StatusCompleted, DueDate: date(2024, 3, 10)})
+
+	cases := []struct {
+		name string
+		q    repo.TaskQuery
+		want []int
+	}{
+		{"all", repo.TaskQuery{}, []int{low.ID, high.ID, critical.ID, none.ID}},
+		{"status", repo.TaskQuery{Statuses: []model.Status{model.StatusPending}}, []int{low.ID, critical.ID}},
+		{"priority", repo.TaskQuery{Priorities: []model.Priority{model.PriorityLow, model.PriorityCritical}}, []int{low.ID, critical.ID}},
+		{"min priority", repo.TaskQuery{MinPriority: model.PriorityHigh}, []int{high.ID, critical.ID}},
+		{"max priority", repo.TaskQuery{MaxPriority: model.PriorityHigh}, []int{low.ID, high.ID}},
+		{"due range", repo.TaskQuery{DueAfter: date(2024, 1, 10), DueBefore: date(2024, 2, 10)}, []int{low.ID, high.ID}},
+	}
+	for _, tc := range cases {
+		page, err := r.List(ctx, tc.q)
+		if err != nil {
+			t.Errorf("%s: List: %s", tc.name, err)
+			continue
+		}
+		if got := ids(page.Tasks); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	if _, err := r.List(ctx, repo.TaskQuery{Limit: repo.MaxPageSize + 1}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("expected ErrValidation for an oversized page, got %v", err)
+	}
+}
+
+func testListPaginates(t *testing.T, r repo.TaskRepository) {
+	var want []int
+	for i := 0; i < 5; i++ {
+		// Two tasks share each priority, so pages split ties.
+		task := mustCreate(t, r, model.Task{Title: fmt.Sprintf("Task %d", i), Priority: model.Priority(i/2 + 1)})
+		want = append([]int{task.ID}, want...)
+	}
+	// Descending by priority, ties broken by descending ID.
+	q := repo.TaskQuery{SortBy: repo.SortByPriority, Descending: true, Limit: 2}
+
+	var got []int
+	for pages := 0; pages < 5; pages++ {
+		page, err := r.List(ctx, q)
+		if err != nil {
+			t.Fatalf("List: %s", err)
+		}
+		got = append(got, ids(page.Tasks)...)
+		if page.Next == nil {
+			break
+		}
+		// Cursors survive being sent to a client and back.
+		if q.After, err = repo.DecodeCursor(page.Next.Encode()); err != nil {
+			t.Fatalf("DecodeCursor: %s", err)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func testListSortsWithNullsLast(t *testing.T, r repo.TaskRepository) {
+	none := mustCreate(t, r, model.Task{Title: "None"})
+	late := mustCreate(t, r, model.Task{Title: "Late", DueDate: date(2024, 9, 1)})
+	early := mustCreate(t, r, model.Task{Title: "Early", DueDate: date(2024, 1, 1)})
+
+	for _, tc := range []struct {
+		descending bool
+		want       []int
+	}{
+		{false, []int{early.ID, late.ID, none.ID}},
+		{true, []int{none.ID, late.ID, early.ID}},
+	} {
+		var got []int
+		q := repo.TaskQuery{SortBy: repo.SortByDueDate, Descending: tc.descending, Limit: 1}
+		for {
+			page, err := r.List(ctx, q)
+			if err != nil {
+				t.Fatalf("List: %s", err)
+			}
+			got = append(got, ids(page.Tasks)...)
+			if page.Next == nil || len(got) > 3 {
+				break
+			}
+			q.After = page.Next
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("descending=%v: expected %v, got %v", tc.descending, tc.want, got)
+		}
+	}
+}
+
+func testConcurrentCreates(t *testing.T, r repo.TaskRepository) {
+	const n = 20
+	var wg sync.WaitGroup
+	created := make(chan int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			task, err := r.Create(ctx, model.Task{Title: fmt.Sprintf("Task %d", i)})
+			if err != nil {
+				t.Errorf("Create: %s", err)
+				return
+			}
+			created <- task.ID
+		}(i)
+	}
+	wg.Wait()
+	close(created)
+
+	seen := map[int]bool{}
+	for id := range created {
+		if seen[id] {
+			t.Errorf("ID %d assigned twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func testContextDone(t *testing.T, r repo.TaskRepository) {
+	created := mustCreate(t, r, model.Task{Title: "Task"})
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := r.GetByID(canceled, created.ID); !errors.Is(err, repo.ErrCanceled) {
+		t.Errorf("expected ErrCanceled from GetByID, got %v", err)
+	}
+	if _, err := r.List(canceled, repo.TaskQuery{}); !errors.Is(err, repo.ErrCanceled) {
+		t.Errorf("expected ErrCanceled from List, got %v", err)
+	}
+
+	expired, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+	if _, err := r.Create(expired, model.Task{Title: "Late"}); !errors.Is(err, repo.ErrTimeout) {
+		t.Errorf("expected ErrTimeout from Create, got %v", err)
+	}
+	if err := r.Delete(expired, created.ID, 0, repo.TaskSubtasks{}); !errors.Is(err, repo.ErrTimeout) {
+		t.Errorf("expected ErrTimeout from Delete, got %v", err)
+	}
+
+	// Work abandoned by a done context leaves the task untouched.
+	if stored, err := r.GetByID(ctx, created.ID); err != nil || stored.Version != 1 {
+		t.Errorf("expected the task unchanged, got %+v (%v)", stored, err)
+	}
+}
+
+func testEnsureUser(t *testing.T, r Repos) {
+	alice := mustEnsureUser(t, r.Users, "alice")
+	bob := mustEnsureUser(t, r.Users, "bob")
+	if alice.ID < 1 || bob.ID <= alice.ID || alice.Subject != "alice" || alice.CreatedAt.IsZero() {
+		t.Errorf("expected increasing IDs and a creation time, got %+v and %+v", alice, bob)
+	}
+	if again := mustEnsureUser(t, r.Users, "alice"); again.ID != alice.ID {
+		t.Errorf("expected the known user %d, got %+v", alice.ID, again)
+	}
+
+	got, err := r.Users.GetUser(ctx, bob.ID)
+	if err != nil || got.Subject != "bob" {
+		t.Errorf("expected bob, got %+v (%v)", got, err)
+	}
+	if _, err := r.Users.GetUser(ctx, 12345); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	users, err := r.Users.ListUsers(ctx)
+	if err != nil || len(users) != 2 || users[0].ID != alice.ID || users[1].ID != bob.ID {
+		t.Errorf("expected alice and bob, got %+v (%v)", users, err)
+	}
+
+	var verr *repo.ValidationError
+	if _, err := r.Users.EnsureUser(ctx, " "); !errors.As(err, &verr) || verr.Field != "subject" {
+		t.Errorf("expected a subject ValidationError, got %v", err)
+	}
+}
+
+func testSetRole(t *testing.T, r Repos) {
+	alice := mustEnsureUser(t, r.Users, "alice")
+	if alice.Role != model.DefaultRole {
+		t.Errorf("expected new users to get the role %q, got %q", model.DefaultRole, alice.Role)
+	}
+
+	updated, err := r.Users.SetRole(ctx, alice.ID, model.RoleAdmin)
+	if err != nil || updated.Role != model.RoleAdmin {
+		t.Fatalf("expected alice to become an admin, got %+v (%v)", updated, err)
+	}
+	if got := mustEnsureUser(t, r.Users, "alice"); got.Role != model.RoleAdmin {
+		t.Errorf("expected the role to be stored, got %q", got.Role)
+	}
+
+	var verr *repo.ValidationError
+	if _, err := r.Users.SetRole(ctx, alice.ID, "owner"); !errors.As(err, &verr) || verr.Field != "role" {
+		t.Errorf("expected a role ValidationError, got %v", err)
+	}
+	if _, err := r.Users.SetRole(ctx, 12345, model.RoleViewer); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// testSetProjectRole checks that roles granted in projects are stored with
+// the user, replaced and withdrawn.
+func testSetProjectRole(t *testing.T, r Repos) {
+	alice := mustEnsureUser(t, r.Users, "alice")
+	bob := mustEnsureUser(t, r.Users, "bob")
+	launch := mustCreateProject(t, r.Projects, "Launch")
+	docs := mustCreateProject(t, r.Projects, "Docs")
+
+	if _, err := r.Users.SetProjectRole(ctx, alice.ID, launch.ID, model.RoleAdmin); err != nil {
+		t.Fatalf("SetProjectRole: %s", err)
+	}
+	updated, err := r.Users.SetProjectRole(ctx, alice.ID, docs.ID, model.RoleViewer)
+	want := map[int]model.Role{launch.ID: model.RoleAdmin, docs.ID: model.RoleViewer}
+	if err != nil || !reflect.DeepEqual(updated.ProjectRoles, want) || updated.Role != model.DefaultRole {
+		t.Fatalf("expected the roles %v, got %+v (%v)", want, updated, err)
+	}
+	if got := mustEnsureUser(t, r.Users, "alice"); !reflect.DeepEqual(got.ProjectRoles, want) {
+		t.Errorf("EnsureUser: expected the roles %v, got %v", want, got.ProjectRoles)
+	}
+	if users, err := r.Users.ListUsers(ctx); err != nil || len(users) != 2 || !reflect.DeepEqual(users[0].ProjectRoles, want) || users[1].ProjectRoles != nil {
+		t.Errorf("ListUsers: expected only alice's roles, got %+v (%v)", users, err)
+	}
+
+	updated, err = r.Users.SetProjectRole(ctx, alice.ID, launch.ID, model.RoleMember)
+	if err != nil || updated.ProjectRoles[launch.ID] != model.RoleMember {
+		t.Errorf("expected the role replaced, got %+v (%v)", updated, err)
+	}
+	updated, err = r.Users.SetProjectRole(ctx, alice.ID, docs.ID, "")
+	if err != nil || !reflect.DeepEqual(updated.ProjectRoles, map[int]model.Role{launch.ID: model.RoleMember}) {
+		t.Errorf("expected the grant withdrawn, got %+v (%v)", updated, err)
+	}
+	if got, err := r.Users.GetUser(ctx, bob.ID); err != nil || got.ProjectRoles != nil {
+		t.Errorf("expected bob without grants, got %+v (%v)", got, err)
+	}
+
+	var verr *repo.ValidationError
+	if _, err := r.Users.SetProjectRole(ctx, alice.ID, launch.ID, "owner"); !errors.As(err, &verr) || verr.Field != "role" {
+		t.Errorf("expected a role ValidationError, got %v", err)
+	}
+	if _, err := r.Users.SetProjectRole(ctx, 12345, launch.ID, model.RoleViewer); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing user, got %v", err)
+	}
+}
+
+func testOwnershipAndAssignment(t *testing.T, r Repos) {
+	alice := mustEnsureUser(t, r.Users, "alice")
+	bob := mustEnsureUser(t, r.Users, "bob")
+	created := mustCreate(t, r.Tasks, model.Task{Title: "Task", CreatedBy: &alice.ID, AssigneeID: &bob.ID})
+	if created.CreatedBy == nil || *created.CreatedBy != alice.ID || created.AssigneeID == nil || *created.AssigneeID != bob.ID {
+		t.Fatalf("expected the creator and assignee to be stored, got %+v", created)
+	}
+
+	// Update replaces the fields of the task but keeps its users.
+	updated, err := r.Tasks.Update(ctx, model.Task{ID: created.ID, Title: "Renamed", Version: 1})
+	if err != nil {
+		t.Fatalf("Update: %s", err)
+	}
+	if !sameTask(updated, model.Task{ID: created.ID, Title: "Renamed", Version: 2, CreatedBy: &alice.ID, AssigneeID: &bob.ID}) {
+		t.Errorf("expected Update to keep the creator and assignee, got %+v", updated)
+	}
+
+	patched, err := r.Tasks.Patch(ctx, created.ID, repo.TaskPatch{SetAssignee: true, AssigneeID: &alice.ID, Version: 2})
+	if err != nil || patched.AssigneeID == nil || *patched.AssigneeID != alice.ID || patched.Version != 3 {
+		t.Errorf("expected the task to be reassigned, got %+v (%v)", patched, err)
+	}
+	patched, err = r.Tasks.Patch(ctx, created.ID, repo.TaskPatch{SetAssignee: true})
+	if err != nil || patched.AssigneeID != nil || *patched.CreatedBy != alice.ID {
+		t.Errorf("expected the task to be unassigned, got %+v (%v)", patched, err)
+	}
+
+	invalid := 0
+	var verr *repo.ValidationError
+	if _, err := r.Tasks.Patch(ctx, created.ID, repo.TaskPatch{SetAssignee: true, AssigneeID: &invalid}); !errors.As(err, &verr) || verr.Field != "assigneeId" {
+		t.Errorf("expected an assigneeId ValidationError, got %v", err)
+	}
+}
+
+func testWatchers(t *testing.T, r Repos) {
+	alice := mustEnsureUser(t, r.Users, "alice")
+	bob := mustEnsureUser(t, r.Users, "bob")
+	created := mustCreate(t, r.Tasks, model.Task{Title: "Task", Watchers: []int{alice.ID}})
+	if created.Watchers != nil {
+		t.Errorf("expected Create to ignore the watchers, got %v", created.Watchers)
+	}
+
+	task, err := r.Tasks.AddWatcher(ctx, created.ID, bob.ID, 1)
+	if err != nil || !reflect.DeepEqual(task.Watchers, []int{bob.ID}) || task.Version != 2 {
+		t.Fatalf("expected bob to watch the task at version 2, got %+v (%v)", task, err)
+	}
+	task, err = r.Tasks.AddWatcher(ctx, created.ID, alice.ID, 0)
+	if err != nil || !reflect.DeepEqual(task.Watchers, []int{alice.ID, bob.ID}) || task.Version != 3 {
+		t.Fatalf("expected both users to watch the task at version 3, got %+v (%v)", task, err)
+	}
+	got, err := r.Tasks.GetByID(ctx, created.ID)
+	if err != nil || !sameTask(got, task) {
+		t.Errorf("expected GetByID to return %+v, got %+v (%v)", task, got, err)
+	}
+
+	// Watching again changes nothing, not even the version.
+	if task, err = r.Tasks.AddWatcher(ctx, created.ID, alice.ID, 3); err != nil || task.Version != 3 {
+		t.Errorf("expected the task to stay at version 3, got %+v (%v)", task, err)
+	}
+	if _, err := r.Tasks.AddWatcher(ctx, created.ID, alice.ID, 1); !errors.Is(err, repo.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+	if _, err := r.Tasks.RemoveWatcher(ctx, created.ID, alice.ID, 1); !errors.Is(err, repo.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+
+	task, err = r.Tasks.RemoveWatcher(ctx, created.ID, alice.ID, 3)
+	if err != nil || !reflect.DeepEqual(task.Watchers, []int{bob.ID}) || task.Version != 4 {
+		t.Errorf("expected only bob to watch the task at version 4, got %+v (%v)", task, err)
+	}
+	if task, err = r.Tasks.RemoveWatcher(ctx, created.ID, alice.ID, 0); err != nil || task.Version != 4 {
+		t.Errorf("expected removing a missing watcher to change nothing, got %+v (%v)", task, err)
+	}
+
+	if _, err := r.Tasks.AddWatcher(ctx, 12345, alice.ID, 0); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from AddWatcher, got %v", err)
+	}
+	if _, err := r.Tasks.RemoveWatcher(ctx, 12345, alice.ID, 0); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from RemoveWatcher, got %v", err)
+	}
+
+	// The watchers go with the task.
+	if err := r.Tasks.Delete(ctx, created.ID, 0, repo.TaskSubtasks{}); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	page, err := r.Tasks.List(ctx, repo.TaskQuery{Watcher: bob.ID})
+	if err != nil || len(page.Tasks) != 0 {
+		t.Errorf("expected no watched tasks, got %+v (%v)", page.Tasks, err)
+	}
+}
+
+func testListFiltersByUser(t *testing.T, r Repos) {
+	alice := mustEnsureUser(t, r.Users, "alice")
+	bob := mustEnsureUser(t, r.Users, "bob")
+	byAlice := mustCreate(t, r.Tasks, model.Task{Title: "By alice", CreatedBy: &alice.ID})
+	forBob := mustCreate(t, r.Tasks, model.Task{Title: "For bob", CreatedBy: &alice.ID, AssigneeID: &bob.ID})
+	byBob := mustCreate(t, r.Tasks, model.Task{Title: "By bob", CreatedBy: &bob.ID, AssigneeID: &alice.ID})
+	if _, err := r.Tasks.AddWatcher(ctx, byBob.ID, bob.ID, 0); err != nil {
+		t.Fatalf("AddWatcher: %s", err)
+	}
+	if _, err := r.Tasks.AddWatcher(ctx, byAlice.ID, bob.ID, 0); err != nil {
+		t.Fatalf("AddWatcher: %s", err)
+	}
+
+	cases := []struct {
+		name string
+		q    repo.TaskQuery
+		want []int
+	}{
+		{"assignee", repo.TaskQuery{AssigneeID: bob.ID}, []int{forBob.ID}},
+		{"created by", repo.TaskQuery{CreatedBy: alice.ID}, []int{byAlice.ID, forBob.ID}},
+		{"watcher", repo.TaskQuery{Watcher: bob.ID}, []int{byAlice.ID, byBob.ID}},
+		{"combined", repo.TaskQuery{CreatedBy: bob.ID, Watcher: bob.ID, AssigneeID: alice.ID}, []int{byBob.ID}},
+		{"no match", repo.TaskQuery{Watcher: alice.ID}, []int{}},
+	}
+	for _, tc := range cases {
+		page, err := r.Tasks.List(ctx, tc.q)
+		if err != nil {
+			t.Errorf("%s: List: %s", tc.name, err)
+			continue
+		}
+		if got := ids(page.Tasks); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func mustCreateWorkspace(t *testing.T, r repo.WorkspaceRepository, slug string) model.Workspace {
+	t.Helper()
+	ws, err := r.CreateWorkspace(ctx, model.Workspace{Slug: slug, Name: "Workspace " + slug})
+	if err != nil {
+		t.Fatalf("CreateWorkspace(%q): %s", slug, err)
+	}
+	return ws
+}
+
+func testWorkspaces(t *testing.T, r Repos) {
+	def, err := r.Workspaces.GetWorkspace(ctx, model.DefaultWorkspace)
+	if err != nil || def.ID != model.DefaultWorkspaceID {
+		t.Fatalf("expected the default workspace with ID %d, got %+v (%v)", model.DefaultWorkspaceID, def, err)
+	}
+
+	team := mustCreateWorkspace(t, r.Workspaces, "team")
+	if team.ID <= def.ID || team.Slug != "team" || team.CreatedAt.IsZero() {
+		t.Errorf("expected a new ID and a creation time, got %+v", team)
+	}
+	if got, err := r.Workspaces.GetWorkspace(ctx, "team"); err != nil || got.ID != team.ID || got.Name != team.Name {
+		t.Errorf("expected %+v, got %+v (%v)", team, got, err)
+	}
+	if _, err := r.Workspaces.GetWorkspace(ctx, "missing"); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := r.Workspaces.CreateWorkspace(ctx, model.Workspace{Slug: "team", Name: "Again"}); !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("expected ErrConflict for a taken slug, got %v", err)
+	}
+
+	var verr *repo.ValidationError
+	for _, ws := range []model.Workspace{
+		{Slug: "Team", Name: "Upper case"},
+		{Slug: "-team", Name: "Leading dash"},
+		{Slug: "", Name: "Empty"},
+		{Slug: "named", Name: " "},
+	} {
+		if _, err := r.Workspaces.CreateWorkspace(ctx, ws); !errors.As(err, &verr) {
+			t.Errorf("expected a ValidationError for %+v, got %v", ws, err)
+		}
+	}
+}
+
+func testWorkspaceMembers(t *testing.T, r Repos) {
+	alice := mustEnsureUser(t, r.Users, "alice")
+	team := mustCreateWorkspace(t, r.Workspaces, "team")
+	other := mustCreateWorkspace(t, r.Workspaces, "other")
+
+	slugs := func(userID int) []string {
+		t.Helper()
+		list, err := r.Workspaces.ListWorkspaces(ctx, userID)
+		if err != nil {
+			t.Fatalf("ListWorkspaces: %s", err)
+		}
+		slugs := []string{}
+		for _, ws := range list {
+			slugs = append(slugs, ws.Slug)
+		}
+		return slugs
+	}
+	if got := slugs(alice.ID); !reflect.DeepEqual(got, []string{model.DefaultWorkspace}) {
+		t.Errorf("expected only the default workspace, got %v", got)
+	}
+
+	// Adding a member twice is not an error.
+	for i := 0; i < 2; i++ {
+		if err := r.Workspaces.AddMember(ctx, team.ID, alice.ID); err != nil {
+			t.Fatalf("AddMember: %s", err)
+		}
+	}
+	if member, err := r.Workspaces.IsMember(ctx, team.ID, alice.ID); err != nil || !member {
+		t.Errorf("expected alice to be a member of team, got %v (%v)", member, err)
+	}
+	if member, err := r.Workspaces.IsMember(ctx, other.ID, alice.ID); err != nil || member {
+		t.Errorf("expected alice not to be a member of other, got %v (%v)", member, err)
+	}
+	if got := slugs(alice.ID); !reflect.DeepEqual(got, []string{model.DefaultWorkspace, "team"}) {
+		t.Errorf("expected default and team, got %v", got)
+	}
+	if got := slugs(0); !reflect.DeepEqual(got, []string{model.DefaultWorkspace, "team", "other"}) {
+		t.Errorf("expected every workspace, got %v", got)
+	}
+
+	if err := r.Workspaces.RemoveMember(ctx, team.ID, alice.ID); err != nil {
+		t.Fatalf("RemoveMember: %s", err)
+	}
+	if err := r.Workspaces.RemoveMember(ctx, team.ID, alice.ID); err != nil {
+		t.Errorf("expected removing a non-member to succeed, got %v", err)
+	}
+	if member, err := r.Workspaces.IsMember(ctx, team.ID, alice.ID); err != nil || member {
+		t.Errorf("expected alice to have left team, got %v (%v)", member, err)
+	}
+}
+
+// testWorkspacesAreIsolated checks that no operation reaches a task of
+// another workspace, whose tasks look as if they did not exist.
+func testWorkspacesAreIsolated(t *testing.T, r Repos) {
+	alice := mustEnsureUser(t, r.Users, "alice")
+	team := mustCreateWorkspace(t, r.Workspaces, "team")
+	inTeam := repo.WithWorkspace(context.Background(), team.ID)
+
+	mine := mustCreate(t, r.Tasks, model.Task{Title: "Default task", CreatedBy: &alice.ID})
+	theirs, err := r.Tasks.Create(inTeam, model.Task{Title: "Team task", CreatedBy: &alice.ID})
+	if err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+
+	all, err := r.Tasks.GetAll(ctx)
+	if err != nil || !reflect.DeepEqual(ids(all), []int{mine.ID}) {
+		t.Errorf("expected only %d in the default workspace, got %v (%v)", mine.ID, ids(all), err)
+	}
+	page, err := r.Tasks.List(inTeam, repo.TaskQuery{CreatedBy: alice.ID})
+	if err != nil || !reflect.DeepEqual(ids(page.Tasks), []int{theirs.ID}) {
+		t.Errorf("expected only %d in team, got %v (%v)", theirs.ID, ids(page.Tasks), err)
+	}
+
+	title := "Changed"
+	notFound := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, repo.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", name, err)
+		}
+	}
+	_, err = r.Tasks.GetByID(ctx, theirs.ID)
+	notFound("GetByID", err)
+	_, err = r.Tasks.Update(ctx, model.Task{ID: theirs.ID, Title: title})
+	notFound("Update", err)
+	_, err = r.Tasks.Update(ctx, model.Task{ID: theirs.ID, Title: title, Version: 1})
+	notFound("Update with version", err)
+	_, err = r.Tasks.Patch(ctx, theirs.ID, repo.TaskPatch{Title: &title})
+	notFound("Patch", err)
+	_, err = r.Tasks.AddWatcher(ctx, theirs.ID, alice.ID, 0)
+	notFound("AddWatcher", err)
+	_, err = r.Tasks.RemoveWatcher(ctx, theirs.ID, alice.ID, 0)
+	notFound("RemoveWatcher", err)
+	notFound("Delete", r.Tasks.Delete(ctx, theirs.ID, 0, repo.TaskSubtasks{}))
+	notFound("Delete with version", r.Tasks.Delete(ctx, theirs.ID, 1, repo.TaskSubtasks{}))
+	_, err = r.Tasks.Subtasks(ctx, theirs.ID, 0)
+	notFound("Subtasks", err)
+
+	if stored, err := r.Tasks.GetByID(inTeam, theirs.ID); err != nil || !sameTask(stored, theirs) {
+		t.Errorf("expected the team task unchanged, got %+v (%v)", stored, err)
+	}
+}
+
+// testCallsRequireWorkspace checks that the repository fails closed when a
+// call does not say which workspace it is confined to.
+func testCallsRequireWorkspace(t *testing.T, r Repos) {
+	created := mustCreate(t, r.Tasks, model.Task{Title: "Task"})
+	title := "Changed"
+
+	for _, unscoped := range []context.Context{context.Background(), repo.WithWorkspace(context.Background(), 0)} {
+		calls := map[string]error{}
+		_, calls["Create"] = r.Tasks.Create(unscoped, model.Task{Title: "Task"})
+		_, calls["GetByID"] = r.Tasks.GetByID(unscoped, created.ID)
+		_, calls["GetAll"] = r.Tasks.GetAll(unscoped)
+		_, calls["List"] = r.Tasks.List(unscoped, repo.TaskQuery{})
+		_, calls["Update"] = r.Tasks.Update(unscoped, model.Task{ID: created.ID, Title: title})
+		_, calls["Patch"] = r.Tasks.Patch(unscoped, created.ID, repo.TaskPatch{Title: &title})
+		_, calls["AddWatcher"] = r.Tasks.AddWatcher(unscoped, created.ID, 1, 0)
+		_, calls["RemoveWatcher"] = r.Tasks.RemoveWatcher(unscoped, created.ID, 1, 0)
+		calls["Delete"] = r.Tasks.Delete(unscoped, created.ID, 0, repo.TaskSubtasks{})
+		_, calls["Subtasks"] = r.Tasks.Subtasks(unscoped, created.ID, 0)
+		for name, err := range calls {
+			if !errors.Is(err, repo.ErrNoWorkspace) {
+				t.Errorf("%s: expected ErrNoWorkspace, got %v", name, err)
+			}
+		}
+	}
+
+	if stored, err := r.Tasks.GetByID(ctx, created.ID); err != nil || stored.Version != 1 {
+		t.Errorf("expected the task unchanged, got %+v (%v)", stored, err)
+	}
+}
+
+func mustCreateProject(t *testing.T, r repo.ProjectRepository, name string) model.Project {
+	t.Helper()
+	p, err := r.CreateProject(ctx, model.Project{Name: name})
+	if err != nil {
+		t.Fatalf("CreateProject(%q): %s", name, err)
+	}
+	return p
+}
+
+func projectIDs(projects []model.Project) []int {
+	ids := make([]int, len(projects))
+	for i, p := range projects {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func testProjects(t *testing.T, r Repos) {
+	created, err := r.Projects.CreateProject(ctx, model.Project{Name: "Launch", Description: "Ship it", Color: "#1e90ff"})
+	if err != nil {
+		t.Fatalf("CreateProject: %s", err)
+	}
+	if created.ID == 0 || created.Name != "Launch" || created.Color != "#1e90ff" || created.Archived || created.CreatedAt.IsZero() {
+		t.Errorf("unexpected project %+v", created)
+	}
+	if got, err := r.Projects.GetProject(ctx, created.ID); err != nil || got.Name != created.Name || got.Description != created.Description {
+		t.Errorf("GetProject: expected %+v, got %+v (%v)", created, got, err)
+	}
+	if _, err := r.Projects.GetProject(ctx, created.ID+100); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing project, got %v", err)
+	}
+
+	for _, invalid := range []model.Project{{Name: " "}, {Name: "Colored", Color: "blue"}, {Name: string(make([]byte, 256))}} {
+		if _, err := r.Projects.CreateProject(ctx, invalid); !errors.Is(err, repo.ErrValidation) {
+			t.Errorf("CreateProject(%+v): expected ErrValidation, got %v", invalid, err)
+		}
+	}
+
+	name, archived := "Launched", true
+	patched, err := r.Projects.PatchProject(ctx, created.ID, repo.ProjectPatch{Name: &name, Archived: &archived})
+	if err != nil || patched.Name != name || !patched.Archived || patched.Color != created.Color {
+		t.Errorf("PatchProject: got %+v (%v)", patched, err)
+	}
+	empty := ""
+	if _, err := r.Projects.PatchProject(ctx, created.ID, repo.ProjectPatch{Name: &empty}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("expected ErrValidation for an empty name, got %v", err)
+	}
+	if _, err := r.Projects.PatchProject(ctx, created.ID+100, repo.ProjectPatch{Name: &name}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound patching a missing project, got %v", err)
+	}
+
+	other := mustCreateProject(t, r.Projects, "Other")
+	if list, err := r.Projects.ListProjects(ctx, false); err != nil || !reflect.DeepEqual(projectIDs(list), []int{other.ID}) {
+		t.Errorf("expected only the active project %d, got %v (%v)", other.ID, projectIDs(list), err)
+	}
+	if list, err := r.Projects.ListProjects(ctx, true); err != nil || !reflect.DeepEqual(projectIDs(list), []int{created.ID, other.ID}) {
+		t.Errorf("expected both projects, got %v (%v)", projectIDs(list), err)
+	}
+}
+
+// testProjectTasks checks that tasks are created in, moved between and
+// listed by projects, and that Update leaves the project alone.
+func testProjectTasks(t *testing.T, r Repos) {
+	launch := mustCreateProject(t, r.Projects, "Launch")
+	task := mustCreate(t, r.Tasks, model.Task{Title: "Task", ProjectID: &launch.ID})
+	if task.ProjectID == nil || *task.ProjectID != launch.ID {
+		t.Fatalf("expected the task in project %d, got %v", launch.ID, task.ProjectID)
+	}
+	loose := mustCreate(t, r.Tasks, model.Task{Title: "Loose"})
+
+	updated, err := r.Tasks.Update(ctx, model.Task{ID: task.ID, Title: "Changed"})
+	if err != nil || updated.ProjectID == nil || *updated.ProjectID != launch.ID {
+		t.Errorf("expected Update to keep the project, got %v (%v)", updated.ProjectID, err)
+	}
+	page, err := r.Tasks.List(ctx, repo.TaskQuery{ProjectID: launch.ID})
+	if err != nil || !reflect.DeepEqual(ids(page.Tasks), []int{task.ID}) {
+		t.Errorf("expected only %d in the project, got %v (%v)", task.ID, ids(page.Tasks), err)
+	}
+
+	patched, err := r.Tasks.Patch(ctx, task.ID, repo.TaskPatch{SetProject: true})
+	if err != nil || patched.ProjectID != nil || patched.Version != updated.Version+1 {
+		t.Errorf("expected the task out of its project, got %+v (%v)", patched, err)
+	}
+	patched, err = r.Tasks.Patch(ctx, loose.ID, repo.TaskPatch{SetProject: true, ProjectID: &launch.ID})
+	if err != nil || patched.ProjectID == nil || *patched.ProjectID != launch.ID {
+		t.Errorf("expected the task in project %d, got %+v (%v)", launch.ID, patched, err)
+	}
+	invalid := 0
+	if _, err := r.Tasks.Patch(ctx, loose.ID, repo.TaskPatch{SetProject: true, ProjectID: &invalid}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("expected ErrValidation for project 0, got %v", err)
+	}
+}
+
+func testArchivedProjectsHideTasks(t *testing.T, r Repos) {
+	launch := mustCreateProject(t, r.Projects, "Launch")
+	old := mustCreateProject(t, r.Projects, "Old")
+	active := mustCreate(t, r.Tasks, model.Task{Title: "Active", ProjectID: &launch.ID})
+	hidden := mustCreate(t, r.Tasks, model.Task{Title: "Hidden", ProjectID: &old.ID})
+	loose := mustCreate(t, r.Tasks, model.Task{Title: "Loose"})
+
+	archived := true
+	if _, err := r.Projects.PatchProject(ctx, old.ID, repo.ProjectPatch{Archived: &archived}); err != nil {
+		t.Fatalf("PatchProject: %s", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query repo.TaskQuery
+		want  []int
+	}{
+		{"default", repo.TaskQuery{}, []int{active.ID, loose.ID}},
+		{"including archived", repo.TaskQuery{IncludeArchived: true}, []int{active.ID, hidden.ID, loose.ID}},
+		{"archived project", repo.TaskQuery{ProjectID: old.ID}, []int{hidden.ID}},
+	} {
+		page, err := r.Tasks.List(ctx, tc.query)
+		if err != nil || !reflect.DeepEqual(ids(page.Tasks), tc.want) {
+			t.Errorf("%s: expected %v, got %v (%v)", tc.name, tc.want, ids(page.Tasks), err)
+		}
+	}
+	if _, err := r.Tasks.GetByID(ctx, hidden.ID); err != nil {
+		t.Errorf("expected tasks of archived projects to stay readable, got %v", err)
+	}
+}
+
+func testDeleteProject(t *testing.T, r Repos) {
+	empty := mustCreateProject(t, r.Projects, "Empty")
+	if err := r.Projects.DeleteProject(ctx, empty.ID, repo.ProjectTasks{}); err != nil {
+		t.Errorf("expected an empty project to be deleted, got %v", err)
+	}
+	if _, err := r.Projects.GetProject(ctx, empty.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected the project to be gone, got %v", err)
+	}
+	if err := r.Projects.DeleteProject(ctx, empty.ID, repo.ProjectTasks{}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting it again, got %v", err)
+	}
+
+	from := mustCreateProject(t, r.Projects, "From")
+	to := mustCreateProject(t, r.Projects, "To")
+	task := mustCreate(t, r.Tasks, model.Task{Title: "Task", ProjectID: &from.ID})
+
+	if err := r.Projects.DeleteProject(ctx, from.ID, repo.ProjectTasks{}); !errors.Is(err, repo.ErrProjectNotEmpty) || !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("expected ErrProjectNotEmpty, got %v", err)
+	}
+	missing := to.ID + 100
+	for _, invalid := range []repo.ProjectTasks{
+		{Cascade: true, Move: true},
+		{Move: true, MoveTo: &from.ID},
+		{Move: true, MoveTo: &missing},
+	} {
+		if err := r.Projects.DeleteProject(ctx, from.ID, invalid); !errors.Is(err, repo.ErrValidation) {
+			t.Errorf("DeleteProject(%+v): expected ErrValidation, got %v", invalid, err)
+		}
+	}
+	if _, err := r.Projects.GetProject(ctx, from.ID); err != nil {
+		t.Fatalf("expected the project to be kept after failed deletes, got %v", err)
+	}
+
+	if err := r.Projects.DeleteProject(ctx, from.ID, repo.ProjectTasks{Move: true, MoveTo: &to.ID}); err != nil {
+		t.Fatalf("DeleteProject moving tasks: %s", err)
+	}
+	moved, err := r.Tasks.GetByID(ctx, task.ID)
+	if err != nil || moved.ProjectID == nil || *moved.ProjectID != to.ID || moved.Version != task.Version+1 {
+		t.Errorf("expected the task moved to %d with a new version, got %+v (%v)", to.ID, moved, err)
+	}
+
+	if err := r.Projects.DeleteProject(ctx, to.ID, repo.ProjectTasks{Move: true}); err != nil {
+		t.Fatalf("DeleteProject moving tasks out: %s", err)
+	}
+	if moved, err := r.Tasks.GetByID(ctx, task.ID); err != nil || moved.ProjectID != nil {
+		t.Errorf("expected the task out of any project, got %+v (%v)", moved, err)
+	}
+
+	doomed := mustCreateProject(t, r.Projects, "Doomed")
+	inDoomed := mustCreate(t, r.Tasks, model.Task{Title: "Doomed task", ProjectID: &doomed.ID})
+	if err := r.Projects.DeleteProject(ctx, doomed.ID, repo.ProjectTasks{Cascade: true}); err != nil {
+		t.Fatalf("DeleteProject cascading: %s", err)
+	}
+	if _, err := r.Tasks.GetByID(ctx, inDoomed.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected the task deleted with its project, got %v", err)
+	}
+	if _, err := r.Tasks.GetByID(ctx, task.ID); err != nil {
+		t.Errorf("expected other tasks to be kept, got %v", err)
+	}
+}
+
+// testProjectsAreIsolated checks that projects, like tasks, are confined to
+// their workspace.
+func testProjectsAreIsolated(t *testing.T, r Repos) {
+	team := mustCreateWorkspace(t, r.Workspaces, "team")
+	inTeam := repo.WithWorkspace(context.Background(), team.ID)
+	theirs, err := r.Projects.CreateProject(inTeam, model.Project{Name: "Team project"})
+	if err != nil {
+		t.Fatalf("CreateProject: %s", err)
+	}
+	mine := mustCreateProject(t, r.Projects, "Default project")
+
+	if list, err := r.Projects.ListProjects(ctx, true); err != nil || !reflect.DeepEqual(projectIDs(list), []int{mine.ID}) {
+		t.Errorf("expected only %d in the default workspace, got %v (%v)", mine.ID, projectIDs(list), err)
+	}
+	name := "Changed"
+	notFound := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, repo.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", name, err)
+		}
+	}
+	_, err = r.Projects.GetProject(ctx, theirs.ID)
+	notFound("GetProject", err)
+	_, err = r.Projects.PatchProject(ctx, theirs.ID, repo.ProjectPatch{Name: &name})
+	notFound("PatchProject", err)
+	notFound("DeleteProject", r.Projects.DeleteProject(ctx, theirs.ID, repo.ProjectTasks{Cascade: true}))
+	if err := r.Projects.DeleteProject(ctx, mine.ID, repo.ProjectTasks{Move: true, MoveTo: &theirs.ID}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("expected tasks not to move into another workspace, got %v", err)
+	}
+	var validationErr *repo.ValidationError
+	if _, err := r.Tasks.Create(ctx, model.Task{Title: "Task", ProjectID: &theirs.ID}); !errors.As(err, &validationErr) || validationErr.Field != "projectId" {
+		t.Errorf("Create: expected a validation error of projectId, got %v", err)
+	}
+	task := mustCreate(t, r.Tasks, model.Task{Title: "Task", ProjectID: &mine.ID})
+	if _, err := r.Tasks.Patch(ctx, task.ID, repo.TaskPatch{SetProject: true, ProjectID: &theirs.ID}); !errors.As(err, &validationErr) || validationErr.Field != "projectId" {
+		t.Errorf("Patch: expected a validation error of projectId, got %v", err)
+	}
+	if stored, err := r.Tasks.GetByID(ctx, task.ID); err != nil || *stored.ProjectID != mine.ID || stored.Version != task.Version {
+		t.Errorf("expected the task kept in %d, got %+v (%v)", mine.ID, stored, err)
+	}
+
+	if _, err := r.Projects.ListProjects(context.Background(), true); !errors.Is(err, repo.ErrNoWorkspace) {
+		t.Errorf("expected ErrNoWorkspace without a workspace, got %v", err)
+	}
+	if stored, err := r.Projects.GetProject(inTeam, theirs.ID); err != nil || stored.Name != theirs.Name {
+		t.Errorf("expected the team project unchanged, got %+v (%v)", stored, err)
+	}
+}
+
+func tagNames(tags []model.Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+// testTaskTags checks that tasks are created, updated and patched with
+// tags, and that the tags they name are created.
+func testTaskTags(t *testing.T, r Repos) {
+	task := mustCreate(t, r.Tasks, model.Task{Title: "Task", Tags: []string{"infra", "bug", "infra"}})
+	if want := []string{"bug", "infra"}; !reflect.DeepEqual(task.Tags, want) {
+		t.Fatalf("expected tags %v, got %v", want, task.Tags)
+	}
+	if stored, err := r.Tasks.GetByID(ctx, task.ID); err != nil || !sameTask(stored, task) {
+		t.Errorf("GetByID: expected %+v, got %+v (%v)", task, stored, err)
+	}
+	if tags, err := r.Tags.ListTags(ctx); err != nil || !reflect.DeepEqual(tagNames(tags), []string{"bug", "infra"}) {
+		t.Errorf("expected the tags of the task to be created, got %v (%v)", tagNames(tags), err)
+	}
+	if all, err := r.Tasks.GetAll(ctx); err != nil || len(all) != 1 || !reflect.DeepEqual(all[0].Tags, task.Tags) {
+		t.Errorf("GetAll: expected the tags %v, got %+v (%v)", task.Tags, all, err)
+	}
+
+	updated, err := r.Tasks.Update(ctx, model.Task{ID: task.ID, Title: "Changed"})
+	if err != nil || !reflect.DeepEqual(updated.Tags, task.Tags) {
+		t.Errorf("expected Update without tags to keep them, got %v (%v)", updated.Tags, err)
+	}
+	updated, err = r.Tasks.Update(ctx, model.Task{ID: task.ID, Title: "Changed", Tags: []string{"backend"}})
+	if err != nil || !reflect.DeepEqual(updated.Tags, []string{"backend"}) {
+		t.Errorf("expected Update to replace the tags, got %v (%v)", updated.Tags, err)
+	}
+
+	patched, err := r.Tasks.Patch(ctx, task.ID, repo.TaskPatch{SetTags: true, Tags: []string{"bug", "backend"}})
+	if err != nil || !reflect.DeepEqual(patched.Tags, []string{"backend", "bug"}) || patched.Version != updated.Version+1 {
+		t.Errorf("expected the patched tags with a new version, got %+v (%v)", patched, err)
+	}
+	patched, err = r.Tasks.Patch(ctx, task.ID, repo.TaskPatch{SetTags: true})
+	if err != nil || patched.Tags != nil {
+		t.Errorf("expected the tags removed, got %v (%v)", patched.Tags, err)
+	}
+	if _, err := r.Tasks.Patch(ctx, task.ID+100, repo.TaskPatch{SetTags: true, Tags: []string{"bug"}}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound tagging a missing task, got %v", err)
+	}
+	if _, err := r.Tasks.Patch(ctx, task.ID, repo.TaskPatch{SetTags: true, Tags: []string{"bug"}, Version: 1}); !errors.Is(err, repo.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
+	if stored, err := r.Tasks.GetByID(ctx, task.ID); err != nil || stored.Tags != nil {
+		t.Errorf("expected failed patches to leave the tags alone, got %v (%v)", stored.Tags, err)
+	}
+
+	for _, invalid := range [][]string{{""}, {"Bug"}, {"a,b"}, {string(make([]byte, 51))}} {
+		if _, err := r.Tasks.Create(ctx, model.Task{Title: "Invalid", Tags: invalid}); !errors.Is(err, repo.ErrValidation) {
+			t.Errorf("Create with tags %q: expected ErrValidation, got %v", invalid, err)
+		}
+		if _, err := r.Tasks.Patch(ctx, task.ID, repo.TaskPatch{SetTags: true, Tags: invalid}); !errors.Is(err, repo.ErrValidation) {
+			t.Errorf("Patch with tags %q: expected ErrValidation, got %v", invalid, err)
+		}
+	}
+}
+
+func testTags(t *testing.T, r Repos) {
+	created, err := r.Tags.CreateTag(ctx, model.Tag{Name: "bug", Color: "#ff0000"})
+	if err != nil || created.ID == 0 || created.Name != "bug" || created.Color != "#ff0000" {
+		t.Fatalf("CreateTag: got %+v (%v)", created, err)
+	}
+	if got, err := r.Tags.GetTag(ctx, created.ID); err != nil || got != created {
+		t.Errorf("GetTag: expected %+v, got %+v (%v)", created, got, err)
+	}
+	if _, err := r.Tags.GetTag(ctx, created.ID+100); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing tag, got %v", err)
+	}
+	if _, err := r.Tags.CreateTag(ctx, model.Tag{Name: "bug"}); !errors.Is(err, repo.ErrTagExists) || !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("expected ErrTagExists for a duplicate name, got %v", err)
+	}
+	for _, invalid := range []model.Tag{{Name: ""}, {Name: "Infra"}, {Name: "infra", Color: "red"}} {
+		if _, err := r.Tags.CreateTag(ctx, invalid); !errors.Is(err, repo.ErrValidation) {
+			t.Errorf("CreateTag(%+v): expected ErrValidation, got %v", invalid, err)
+		}
+	}
+
+	tagged := mustCreate(t, r.Tasks, model.Task{Title: "Tagged", Tags: []string{"bug", "infra"}})
+	other := mustCreate(t, r.Tasks, model.Task{Title: "Other", Tags: []string{"infra"}})
+
+	name := "defect"
+	renamed, err := r.Tags.PatchTag(ctx, created.ID, repo.TagPatch{Name: &name})
+	if err != nil || renamed.Name != name || renamed.Color != created.Color {
+		t.Errorf("PatchTag: got %+v (%v)", renamed, err)
+	}
+	relabelled, err := r.Tasks.GetByID(ctx, tagged.ID)
+	if err != nil || !reflect.DeepEqual(relabelled.Tags, []string{"defect", "infra"}) || relabelled.Version != tagged.Version+1 {
+		t.Errorf("expected the task relabelled with a new version, got %+v (%v)", relabelled, err)
+	}
+	if stored, err := r.Tasks.GetByID(ctx, other.ID); err != nil || stored.Version != other.Version {
+		t.Errorf("expected other tasks to keep their version, got %+v (%v)", stored, err)
+	}
+	taken := "infra"
+	if _, err := r.Tags.PatchTag(ctx, created.ID, repo.TagPatch{Name: &taken}); !errors.Is(err, repo.ErrTagExists) {
+		t.Errorf("expected ErrTagExists renaming onto another tag, got %v", err)
+	}
+	color := "#00ff00"
+	if recolored, err := r.Tags.PatchTag(ctx, created.ID, repo.TagPatch{Color: &color}); err != nil || recolored.Color != color {
+		t.Errorf("PatchTag color: got %+v (%v)", recolored, err)
+	}
+	if stored, err := r.Tasks.GetByID(ctx, tagged.ID); err != nil || stored.Version != relabelled.Version {
+		t.Errorf("expected a color change to leave the tasks alone, got %+v (%v)", stored, err)
+	}
+	if _, err := r.Tags.PatchTag(ctx, created.ID+100, repo.TagPatch{Name: &name}); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound patching a missing tag, got %v", err)
+	}
+
+	if err := r.Tags.DeleteTag(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteTag: %s", err)
+	}
+	if stored, err := r.Tasks.GetByID(ctx, tagged.ID); err != nil || !reflect.DeepEqual(stored.Tags, []string{"infra"}) || stored.Version != relabelled.Version+1 {
+		t.Errorf("expected the tag removed from the task with a new version, got %+v (%v)", stored, err)
+	}
+	if err := r.Tags.DeleteTag(ctx, created.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting it again, got %v", err)
+	}
+	if tags, err := r.Tags.ListTags(ctx); err != nil || !reflect.DeepEqual(tagNames(tags), []string{"infra"}) {
+		t.Errorf("expected only infra to be left, got %v (%v)", tagNames(tags), err)
+	}
+}
+
+func testListFiltersByTags(t *testing.T, r Repos) {
+	bug := mustCreate(t, r.Tasks, model.Task{Title: "Bug", Tags: []string{"bug"}})
+	infraBug := mustCreate(t, r.Tasks, model.Task{Title: "Infra bug", Tags: []string{"bug", "infra"}})
+	infra := mustCreate(t, r.Tasks, model.Task{Title: "Infra", Tags: []string{"infra", "backend"}})
+	mustCreate(t, r.Tasks, model.Task{Title: "Untagged"})
+
+	for _, tc := range []struct {
+		name  string
+		query repo.TaskQuery
+		want  []int
+	}{
+		{"any", repo.TaskQuery{Tags: []string{"bug", "infra"}}, []int{bug.ID, infraBug.ID, infra.ID}},
+		{"all", repo.TaskQuery{Tags: []string{"infra", "bug"}, AllTags: true}, []int{infraBug.ID}},
+		{"all with duplicates", repo.TaskQuery{Tags: []string{"infra", "infra"}, AllTags: true}, []int{infraBug.ID, infra.ID}},
+		{"unknown tag", repo.TaskQuery{Tags: []string{"frontend"}}, []int{}},
+		{"combined", repo.TaskQuery{Tags: []string{"bug"}, SortBy: repo.SortByTitle, Descending: true}, []int{infraBug.ID, bug.ID}},
+	} {
+		page, err := r.Tasks.List(ctx, tc.query)
+		if err != nil || !reflect.DeepEqual(ids(page.Tasks), tc.want) {
+			t.Errorf("%s: expected %v, got %v (%v)", tc.name, tc.want, ids(page.Tasks), err)
+		}
+	}
+	if _, err := r.Tasks.List(ctx, repo.TaskQuery{Tags: []string{"Bug"}}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("expected ErrValidation for an invalid tag, got %v", err)
+	}
+}
+
+// testTagsAreIsolated checks that tags, like tasks, are confined to their
+// workspace, so that workspaces can use the same tag names.
+func testTagsAreIsolated(t *testing.T, r Repos) {
+	team := mustCreateWorkspace(t, r.Workspaces, "team")
+	inTeam := repo.WithWorkspace(context.Background(), team.ID)
+	theirTask, err := r.Tasks.Create(inTeam, model.Task{Title: "Team task", Tags: []string{"bug"}})
+	if err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+	mine := mustCreate(t, r.Tasks, model.Task{Title: "Default task", Tags: []string{"bug"}})
+
+	theirTags, err := r.Tags.ListTags(inTeam)
+	if err != nil || len(theirTags) != 1 {
+		t.Fatalf("expected one tag in the team workspace, got %v (%v)", theirTags, err)
+	}
+	myTags, err := r.Tags.ListTags(ctx)
+	if err != nil || len(myTags) != 1 || myTags[0].ID == theirTags[0].ID {
+		t.Fatalf("expected a tag of its own in the default workspace, got %v (%v)", myTags, err)
+	}
+
+	name := "defect"
+	notFound := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, repo.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", name, err)
+		}
+	}
+	_, err = r.Tags.GetTag(ctx, theirTags[0].ID)
+	notFound("GetTag", err)
+	_, err = r.Tags.PatchTag(ctx, theirTags[0].ID, repo.TagPatch{Name: &name})
+	notFound("PatchTag", err)
+	notFound("DeleteTag", r.Tags.DeleteTag(ctx, theirTags[0].ID))
+
+	if _, err := r.Tags.PatchTag(ctx, myTags[0].ID, repo.TagPatch{Name: &name}); err != nil {
+		t.Fatalf("PatchTag: %s", err)
+	}
+	if page, err := r.Tasks.List(ctx, repo.TaskQuery{Tags: []string{"defect"}}); err != nil || !reflect.DeepEqual(ids(page.Tasks), []int{mine.ID}) {
+		t.Errorf("expected only %d relabelled, got %v (%v)", mine.ID, ids(page.Tasks), err)
+	}
+	if stored, err := r.Tasks.GetByID(inTeam, theirTask.ID); err != nil || !reflect.DeepEqual(stored.Tags, []string{"bug"}) || stored.Version != theirTask.Version {
+		t.Errorf("expected the team task unchanged, got %+v (%v)", stored, err)
+	}
+	if _, err := r.Tags.ListTags(context.Background()); !errors.Is(err, repo.ErrNoWorkspace) {
+		t.Errorf("expected ErrNoWorkspace without a workspace, got %v", err)
+	}
+}
+
+func progress(task model.Task) string {
+	if task.Progress == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%d/%d", task.Progress.Completed, task.Progress.Total)
+}
+
+// testSubtasks checks that tasks are created and patched as subtasks, that
+// their parents count them, and that Subtasks reads them as deep as asked.
+func testSubtasks(t *testing.T, r repo.TaskRepository) {
+	root := mustCreate(t, r, model.Task{Title: "Root"})
+	child := mustCreate(t, r, model.Task{Title: "Child", ParentID: &root.ID, Status: model.StatusCompleted})
+	other := mustCreate(t, r, model.Task{Title: "Other child", ParentID: &root.ID})
+	grandchild := mustCreate(t, r, model.Task{Title: "Grandchild", ParentID: &child.ID})
+	loose := mustCreate(t, r, model.Task{Title: "Loose"})
+
+	if child.ParentID == nil || *child.ParentID != root.ID || child.Progress != nil {
+		t.Errorf("expected a subtask of %d without progress, got %+v", root.ID, child)
+	}
+	stored, err := r.GetByID(ctx, root.ID)
+	if err != nil || progress(stored) != "1/2" || stored.Version != root.Version+2 {
+		t.Errorf("expected the root at 1/2 completed with a new version per subtask, got %s (%+v, %v)", progress(stored), stored, err)
+	}
+	if all, err := r.GetAll(ctx); err != nil || len(all) != 5 || progress(all[0]) != "1/2" || progress(all[1]) != "0/1" {
+		t.Errorf("GetAll: expected the progress of every task, got %+v (%v)", all, err)
+	}
+	if page, err := r.List(ctx, repo.TaskQuery{}); err != nil || progress(page.Tasks[0]) != "1/2" {
+		t.Errorf("List: expected the progress of every task, got %+v (%v)", page.Tasks, err)
+	}
+
+	for depth, want := range map[int][]int{
+		1: {child.ID, other.ID},
+		2: {child.ID, other.ID, grandchild.ID},
+		0: {child.ID, other.ID, grandchild.ID},
+	} {
+		subtasks, err := r.Subtasks(ctx, root.ID, depth)
+		if err != nil || !reflect.DeepEqual(ids(subtasks), want) {
+			t.Errorf("Subtasks at depth %d: expected %v, got %v (%v)", depth, want, ids(subtasks), err)
+		}
+	}
+	if subtasks, err := r.Subtasks(ctx, loose.ID, 0); err != nil || subtasks == nil || len(subtasks) != 0 {
+		t.Errorf("expected no subtasks, got %v (%v)", subtasks, err)
+	}
+	if _, err := r.Subtasks(ctx, loose.ID+100, 1); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing task, got %v", err)
+	}
+	if _, err := r.Subtasks(ctx, root.ID, -1); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("expected ErrValidation for a negative depth, got %v", err)
+	}
+
+	// PUT keeps the parent; a patch moves the task or makes it top-level.
+	updated, err := r.Update(ctx, model.Task{ID: grandchild.ID, Title: "Changed", Status: model.StatusCompleted})
+	if err != nil || updated.ParentID == nil || *updated.ParentID != child.ID {
+		t.Errorf("expected Update to keep the parent, got %+v (%v)", updated, err)
+	}
+	if stored, err := r.GetByID(ctx, child.ID); err != nil || progress(stored) != "1/1" {
+		t.Errorf("expected the child at 1/1 completed, got %s (%v)", progress(stored), err)
+	}
+	patched, err := r.Patch(ctx, other.ID, repo.TaskPatch{SetParent: true, ParentID: &loose.ID})
+	if err != nil || patched.ParentID == nil || *patched.ParentID != loose.ID || patched.Version != other.Version+1 {
+		t.Errorf("expected the task moved to %d with a new version, got %+v (%v)", loose.ID, patched, err)
+	}
+	patched, err = r.Patch(ctx, other.ID, repo.TaskPatch{SetParent: true})
+	if err != nil || patched.ParentID != nil {
+		t.Errorf("expected a top-level task, got %+v (%v)", patched, err)
+	}
+	if stored, err := r.GetByID(ctx, root.ID); err != nil || progress(stored) != "1/1" {
+		t.Errorf("expected the root at 1/1 completed, got %s (%v)", progress(stored), err)
+	}
+}
+
+// testSubtasksChangeParentVersion checks that a parent gets a new version,
+// and so a new ETag, whenever the progress of its subtasks may change: a
+// subtask is created, deleted, moved away or below it, or changes status.
+func testSubtasksChangeParentVersion(t *testing.T, r repo.TaskRepository) {
+	parent := mustCreate(t, r, model.Task{Title: "Parent"})
+	other := mustCreate(t, r, model.Task{Title: "Other"})
+
+	changed := func(name string, task model.Task) model.Task {
+		t.Helper()
+		stored, err := r.GetByID(ctx, task.ID)
+		if err != nil || stored.Version == task.Version {
+			t.Errorf("%s: expected task %d at a new version, got %+v (%v)", name, task.ID, stored, err)
+		}
+		return stored
+	}
+	unchanged := func(name string, task model.Task) {
+		t.Helper()
+		if stored, err := r.GetByID(ctx, task.ID); err != nil || stored.Version != task.Version {
+			t.Errorf("%s: expected task %d at version %d, got %+v (%v)", name, task.ID, task.Version, stored, err)
+		}
+	}
+
+	child := mustCreate(t, r, model.Task{Title: "Child", ParentID: &parent.ID})
+	parent = changed("Create", parent)
+
+	completed := model.StatusCompleted
+	child, err := r.Patch(ctx, child.ID, repo.TaskPatch{Status: &completed})
+	if err != nil {
+		t.Fatalf("Patch: %s", err)
+	}
+	parent = changed("Patch completing the subtask", parent)
+
+	title := "Renamed"
+	if child, err = r.Patch(ctx, child.ID, repo.TaskPatch{Title: &title}); err != nil {
+		t.Fatalf("Patch: %s", err)
+	}
+	unchanged("Patch renaming the subtask", parent)
+
+	if child, err = r.Update(ctx, model.Task{ID: child.ID, Title: title, Status: model.StatusPending}); err != nil {
+		t.Fatalf("Update: %s", err)
+	}
+	parent = changed("Update reopening the subtask", parent)
+
+	if child, err = r.Patch(ctx, child.ID, repo.TaskPatch{SetParent: true, ParentID: &other.ID}); err != nil {
+		t.Fatalf("Patch: %s", err)
+	}
+	parent = changed("Patch moving the subtask away", parent)
+	other = changed("Patch moving the subtask below", other)
+
+	if err := r.Delete(ctx, child.ID, 0, repo.TaskSubtasks{}); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	other = changed("Delete", other)
+	unchanged("Delete below another task", parent)
+
+	// Moving the subtasks of a deleted task changes the task they move to.
+	child = mustCreate(t, r, model.Task{Title: "Child", ParentID: &other.ID})
+	mustCreate(t, r, model.Task{Title: "Grandchild", ParentID: &child.ID})
+	parent, other = mustGet(t, r, parent.ID), mustGet(t, r, other.ID)
+	if err := r.Delete(ctx, child.ID, 0, repo.TaskSubtasks{Move: true, MoveTo: &parent.ID}); err != nil {
+		t.Fatalf("Delete moving subtasks: %s", err)
+	}
+	changed("Delete moving subtasks to the task", parent)
+	changed("Delete moving subtasks from below the task", other)
+}
+
+// testConcurrentReparents checks that two tasks made subtasks of each other
+// at the same time do not end up in a cycle: one of the patches fails.
+func testConcurrentReparents(t *testing.T, r repo.TaskRepository) {
+	for i := 0; i < 10; i++ {
+		a := mustCreate(t, r, model.Task{Title: fmt.Sprintf("A%d", i)})
+		b := mustCreate(t, r, model.Task{Title: fmt.Sprintf("B%d", i)})
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for j, move := range [][2]int{{a.ID, b.ID}, {b.ID, a.ID}} {
+			wg.Add(1)
+			go func(j, id, parent int) {
+				defer wg.Done()
+				_, errs[j] = r.Patch(ctx, id, repo.TaskPatch{SetParent: true, ParentID: &parent})
+			}(j, move[0], move[1])
+		}
+		wg.Wait()
+
+		if errs[0] == nil && errs[1] == nil {
+			t.Fatalf("expected one of the tasks not made a subtask of the other, both were")
+		}
+		for _, err := range errs {
+			if err != nil && !errors.Is(err, repo.ErrValidation) && !errors.Is(err, repo.ErrConflict) {
+				t.Errorf("expected ErrValidation or ErrConflict, got %v", err)
+			}
+		}
+		if a, b := mustGet(t, r, a.ID), mustGet(t, r, b.ID); a.ParentID != nil && b.ParentID != nil {
+			t.Fatalf("expected no cycle, got %d below %d and %d below %d", a.ID, *a.ParentID, b.ID, *b.ParentID)
+		}
+	}
+}
+
+// testSubtaskCycles checks that a task cannot become a subtask of a task
+// that does not exist, of itself or of one of its own subtasks.
+func testSubtaskCycles(t *testing.T, r repo.TaskRepository) {
+	root := mustCreate(t, r, model.Task{Title: "Root"})
+	child := mustCreate(t, r, model.Task{Title: "Child", ParentID: &root.ID})
+	grandchild := mustCreate(t, r, model.Task{Title: "Grandchild", ParentID: &child.ID})
+	missing, zero := grandchild.ID+100, 0
+	root = mustGet(t, r, root.ID)
+
+	validation := func(name, field string, err error) {
+		t.Helper()
+		var validationErr *repo.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != field {
+			t.Errorf("%s: expected a validation error of %s, got %v", name, field, err)
+		}
+	}
+	_, err := r.Create(ctx, model.Task{Title: "Orphan", ParentID: &missing})
+	validation("Create under a missing task", "parentId", err)
+	_, err = r.Create(ctx, model.Task{Title: "Orphan", ParentID: &zero})
+	validation("Create under task 0", "parentId", err)
+	_, err = r.Patch(ctx, root.ID, repo.TaskPatch{SetParent: true, ParentID: &root.ID})
+	validation("Patch under itself", "parentId", err)
+	_, err = r.Patch(ctx, root.ID, repo.TaskPatch{SetParent: true, ParentID: &grandchild.ID})
+	validation("Patch under its grandchild", "parentId", err)
+	_, err = r.Patch(ctx, child.ID, repo.TaskPatch{SetParent: true, ParentID: &missing})
+	validation("Patch under a missing task", "parentId", err)
+
+	if stored, err := r.GetByID(ctx, root.ID); err != nil || stored.ParentID != nil || stored.Version != root.Version {
+		t.Errorf("expected the root unchanged, got %+v (%v)", stored, err)
+	}
+	if _, err := r.Patch(ctx, grandchild.ID, repo.TaskPatch{SetParent: true, ParentID: &root.ID}); err != nil {
+		t.Errorf("expected a task to move up its own tree, got %v", err)
+	}
+}
+
+func testDeleteSubtasks(t *testing.T, r repo.TaskRepository) {
+	root := mustCreate(t, r, model.Task{Title: "Root"})
+	parent := mustCreate(t, r, model.Task{Title: "Parent", ParentID: &root.ID})
+	child := mustCreate(t, r, model.Task{Title: "Child", ParentID: &parent.ID})
+	grandchild := mustCreate(t, r, model.Task{Title: "Grandchild", ParentID: &child.ID})
+	other := mustCreate(t, r, model.Task{Title: "Other"})
+	parent, child = mustGet(t, r, parent.ID), mustGet(t, r, child.ID)
+
+	err := r.Delete(ctx, parent.ID, 0, repo.TaskSubtasks{})
+	if !errors.Is(err, repo.ErrHasSubtasks) || !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("expected ErrHasSubtasks, got %v", err)
+	}
+	if err := r.Delete(ctx, parent.ID, parent.Version+1, repo.TaskSubtasks{}); !errors.Is(err, repo.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch before ErrHasSubtasks, got %v", err)
+	}
+	for _, invalid := range []repo.TaskSubtasks{
+		{Cascade: true, Move: true},
+		{Cascade: true, MoveTo: &other.ID},
+		{Move: true, MoveTo: &parent.ID},
+		{Move: true, MoveTo: &grandchild.ID},
+	} {
+		if err := r.Delete(ctx, parent.ID, 0, invalid); !errors.Is(err, repo.ErrValidation) {
+			t.Errorf("Delete(%+v): expected ErrValidation, got %v", invalid, err)
+		}
+	}
+	if err := r.Delete(ctx, parent.ID, parent.Version+1, repo.TaskSubtasks{Cascade: true}); !errors.Is(err, repo.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch cascading, got %v", err)
+	}
+	if subtasks, err := r.Subtasks(ctx, root.ID, 0); err != nil || len(subtasks) != 3 {
+		t.Fatalf("expected the tree kept after failed deletes, got %v (%v)", ids(subtasks), err)
+	}
+
+	// Without MoveTo, the subtasks move up to the parent of the deleted task.
+	if err := r.Delete(ctx, parent.ID, 0, repo.TaskSubtasks{Move: true}); err != nil {
+		t.Fatalf("Delete moving subtasks: %s", err)
+	}
+	moved, err := r.GetByID(ctx, child.ID)
+	if err != nil || moved.ParentID == nil || *moved.ParentID != root.ID || moved.Version != child.Version+1 {
+		t.Errorf("expected the child moved to %d with a new version, got %+v (%v)", root.ID, moved, err)
+	}
+	if stored, err := r.GetByID(ctx, grandchild.ID); err != nil || *stored.ParentID != child.ID || stored.Version != grandchild.Version {
+		t.Errorf("expected the grandchild unchanged, got %+v (%v)", stored, err)
+	}
+
+	if err := r.Delete(ctx, child.ID, 0, repo.TaskSubtasks{Move: true, MoveTo: &other.ID}); err != nil {
+		t.Fatalf("Delete moving subtasks to another task: %s", err)
+	}
+	if subtasks, err := r.Subtasks(ctx, other.ID, 1); err != nil || !reflect.DeepEqual(ids(subtasks), []int{grandchild.ID}) {
+		t.Errorf("expected the grandchild below %d, got %v (%v)", other.ID, ids(subtasks), err)
+	}
+	if stored, err := r.GetByID(ctx, other.ID); err != nil || progress(stored) != "0/1" {
+		t.Errorf("expected %d at 0/1 completed, got %s (%v)", other.ID, progress(stored), err)
+	}
+
+	leaf := mustCreate(t, r, model.Task{Title: "Leaf", ParentID: &grandchild.ID})
+	if err := r.Delete(ctx, other.ID, 0, repo.TaskSubtasks{Cascade: true}); err != nil {
+		t.Fatalf("Delete cascading: %s", err)
+	}
+	for _, id := range []int{other.ID, grandchild.ID, leaf.ID} {
+		if _, err := r.GetByID(ctx, id); !errors.Is(err, repo.ErrNotFound) {
+			t.Errorf("expected task %d deleted with its tree, got %v", id, err)
+		}
+	}
+	if stored, err := r.GetByID(ctx, root.ID); err != nil || stored.Progress != nil {
+		t.Errorf("expected the root kept without subtasks, got %+v (%v)", stored, err)
+	}
+	if err := r.Delete(ctx, root.ID, 0, repo.TaskSubtasks{Cascade: true}); err != nil {
+		t.Errorf("expected a task without subtasks to be deleted cascading, got %v", err)
+	}
+}
+
+// testSubtasksAreIsolated checks that a task cannot be a subtask of a task
+// of another workspace.
+func testSubtasksAreIsolated(t *testing.T, r Repos) {
+	team := mustCreateWorkspace(t, r.Workspaces, "team")
+	inTeam := repo.WithWorkspace(context.Background(), team.ID)
+	theirs, err := r.Tasks.Create(inTeam, model.Task{Title: "Team task"})
+	if err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+	mine := mustCreate(t, r.Tasks, model.Task{Title: "Default task"})
+
+	if _, err := r.Tasks.Create(ctx, model.Task{Title: "Subtask", ParentID: &theirs.ID}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("Create: expected ErrValidation, got %v", err)
+	}
+	if _, err := r.Tasks.Patch(ctx, mine.ID, repo.TaskPatch{SetParent: true, ParentID: &theirs.ID}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("Patch: expected ErrValidation, got %v", err)
+	}
+	if err := r.Tasks.Delete(ctx, mine.ID, 0, repo.TaskSubtasks{Move: true, MoveTo: &theirs.ID}); !errors.Is(err, repo.ErrValidation) {
+		t.Errorf("Delete: expected ErrValidation, got %v", err)
+	}
+	if stored, err := r.Tasks.GetByID(inTeam, theirs.ID); err != nil || stored.Progress != nil {
+		t.Errorf("expected the team task without subtasks, got %+v (%v)", stored, err)
+	}
+}
+
+// testDeleteProjectKeepsSubtasks checks that deleting the tasks of a project
+// makes their subtasks outside the project top-level tasks.
+func testDeleteProjectKeepsSubtasks(t *testing.T, r Repos) {
+	project := mustCreateProject(t, r.Projects, "Doomed")
+	parent := mustCreate(t, r.Tasks, model.Task{Title: "Parent", ProjectID: &project.ID})
+	inside := mustCreate(t, r.Tasks, model.Task{Title: "Inside", ProjectID: &project.ID, ParentID: &parent.ID})
+	outside := mustCreate(t, r.Tasks, model.Task{Title: "Outside", ParentID: &parent.ID})
+	holder := mustCreate(t, r.Tasks, model.Task{Title: "Holder"})
+	mustCreate(t, r.Tasks, model.Task{Title: "Held", ProjectID: &project.ID, ParentID: &holder.ID})
+	holder = mustGet(t, r.Tasks, holder.ID)
+
+	if err := r.Projects.DeleteProject(ctx, project.ID, repo.ProjectTasks{Cascade: true}); err != nil {
+		t.Fatalf("DeleteProject cascading: %s", err)
+	}
+	if _, err := r.Tasks.GetByID(ctx, inside.ID); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected the subtask in the project deleted, got %v", err)
+	}
+	stored, err := r.Tasks.GetByID(ctx, outside.ID)
+	if err != nil || stored.ParentID != nil || stored.Version != outside.Version+1 {
+		t.Errorf("expected a top-level task with a new version, got %+v (%v)", stored, err)
+	}
+	// The task outside the project loses its subtask in it.
+	stored, err = r.Tasks.GetByID(ctx, holder.ID)
+	if err != nil || stored.Progress != nil || stored.Version == holder.Version {
+		t.Errorf("expected a task without subtasks at a new version, got %+v (%v)", stored, err)
+	}
+}
@@ -0,0 +1,68 @@
+// internal/repo/sqlite.go
+// The sqlite.go adapts TaskRepo to SQLite, for deployments that want a
+// durable store without running a database server.
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// NewSQLiteTaskRepo creates a TaskRepo for a SQLite database migrated with
+// migrations.SQLite. Use the "sqlite" driver registered by this package to
+// open it.
+func NewSQLiteTaskRepo(db *sql.DB) *TaskRepo {
+	return &TaskRepo{db: tracedDB{DB: db, system: "sqlite"}, dialect: sqliteDialect}
+}
+
+// sqliteDialect stores due dates as YYYY-MM-DD text. The driver would write
+// a time.Time with its clock time and zone, which neither compares with the
+// stored dates nor satisfies the CHECK on the duedate column. A transaction
+// holds the single connection, and so the whole database: it needs neither
+// row locks nor the tree lock.
+var sqliteDialect = dialect{
+	date:      sqliteDate,
+	translate: translateSQLiteError,
+}
+
+// sqliteDate converts an optional due date into its YYYY-MM-DD text, or nil.
+func sqliteDate(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.DateOnly)
+}
+
+// translateSQLiteError maps SQLite result codes onto the repository
+// sentinels like translateError does for PostgreSQL. Other errors are passed
+// on to translateError.
+func translateSQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return translateError(err)
+	}
+	// Code returns the extended result code; its low byte is the primary one.
+	switch code := sqliteErr.Code(); {
+	case code == sqlite3.SQLITE_CONSTRAINT_UNIQUE,
+		code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY,
+		code == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY,
+		code&0xff == sqlite3.SQLITE_BUSY,
+		code&0xff == sqlite3.SQLITE_LOCKED:
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case code&0xff == sqlite3.SQLITE_CONSTRAINT,
+		code&0xff == sqlite3.SQLITE_MISMATCH,
+		code&0xff == sqlite3.SQLITE_TOOBIG:
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	case code&0xff == sqlite3.SQLITE_CANTOPEN,
+		code&0xff == sqlite3.SQLITE_IOERR,
+		code&0xff == sqlite3.SQLITE_FULL,
+		code&0xff == sqlite3.SQLITE_READONLY:
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package repo_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/migrations"
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/DimWebDev/task-manager-tool/internal/repo"
+	"github.com/DimWebDev/task-manager-tool/internal/repo/repotest"
+	"github.com/DimWebDev/task-manager-tool/internal/tracing"
+)
+
+// TestSQLiteTaskRepoConformance runs the conformance tests against a fresh
+// SQLite database file per test.
+func TestSQLiteTaskRepoConformance(t *testing.T) {
+	schema, err := migrations.SQLite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		db := openSQLite(t, schema)
+		return repotest.Repos{Tasks: repo.NewSQLiteTaskRepo(db), Users: repo.NewSQLiteUserRepo(db), Workspaces: repo.NewSQLiteWorkspaceRepo(db), Projects: repo.NewSQLiteProjectRepo(db), Tags: repo.NewSQLiteTagRepo(db)}
+	})
+}
+
+// openSQLite returns a migrated SQLite database in a temporary file.
+func openSQLite(t *testing.T, schema []migrations.Migration) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := migrations.NewSQLite(db, schema).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteTaskRepo_StoresDueDateAsText(t *testing.T) {
+	schema, err := migrations.SQLite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openSQLite(t, schema)
+	r := repo.NewSQLiteTaskRepo(db)
+
+	// The clock time and zone of a due date are dropped.
+	due := time.Date(2024, 5, 1, 15, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	created, err := r.Create(repo.WithWorkspace(context.Background(), 1), model.Task{Title: "Task", DueDate: &due})
+	if err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+	var stored string
+	if err := db.QueryRow("SELECT duedate FROM tasks WHERE id = $1", created.ID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != "2024-05-01" {
+		t.Errorf("expected duedate 2024-05-01, got %q", stored)
+	}
+	if want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC); !created.DueDate.Equal(want) {
+		t.Errorf("expected due date %s, got %s", want, created.DueDate)
+	}
+
+	// The schema rejects anything but a date.
+	_, err = db.Exec("UPDATE tasks SET duedate = '2024-05-01 15:30:00' WHERE id = $1", created.ID)
+	if err == nil {
+		t.Error("expected the CHECK constraint to reject a timestamp")
+	}
+}
+
+// spanRecorder is a tracing.Exporter keeping the spans in memory.
+type spanRecorder struct{ spans []tracing.SpanData }
+
+func (r *spanRecorder) Export(ctx context.Context, spans []tracing.SpanData) error {
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *spanRecorder) Shutdown(ctx context.Context) error { return nil }
+
+func TestSQLiteTaskRepo_TracesStatements(t *testing.T) {
+	schema, err := migrations.SQLite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &spanRecorder{}
+	tracer := tracing.NewTracer(rec)
+	r := repo.Observed(repo.NewSQLiteTaskRepo(openSQLite(t, schema)), tracing.Repo{})
+
+	inDefault := repo.WithWorkspace(context.Background(), 1)
+	ctx, request := tracer.Start(inDefault, "GET /tasks/{id}", tracing.SpanKindServer)
+	_, err = r.GetByID(ctx, 42)
+	request.End()
+	if !errors.Is(err, repo.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	tracer.Flush(context.Background())
+
+	if len(rec.spans) != 3 {
+		t.Fatalf("expected the statement, call and request spans, got %+v", rec.spans)
+	}
+	stmt, call := rec.spans[0], rec.spans[1]
+	if stmt.Name != "db.query" || stmt.ParentID != call.SpanID || call.ParentID != request.SpanContext().SpanID {
+		t.Errorf("expected the statement span below the call span below the request, got %+v", rec.spans)
+	}
+	want := []tracing.Attribute{
+		tracing.String("db.system", "sqlite"),
+		tracing.String("db.statement", "SELECT id, title, description, duedate, priority, status, version, created_by, assignee_id, project_id, parent_id, (SELECT string_agg(CAST(user_id AS TEXT), ',') FROM task_watchers WHERE task_id = tasks.id) AS watchers, "+
+			"(SELECT string_agg(tags.name, ',') FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE task_tags.task_id = tasks.id) AS tags, "+
+			"(SELECT COUNT(*) FROM tasks AS subtasks WHERE subtasks.parent_id = tasks.id) AS subtasks, "+
+			"(SELECT COUNT(*) FROM tasks AS subtasks WHERE subtasks.parent_id = tasks.id AND subtasks.status = 'Completed') AS completed_subtasks FROM tasks WHERE id = $1 AND workspace_id = $2"),
+	}
+	if len(stmt.Attributes) != 2 || stmt.Attributes[0] != want[0] || stmt.Attributes[1] != want[1] {
+		t.Errorf("expected attributes %v, got %v", want, stmt.Attributes)
+	}
+	if call.Name != "TaskRepository.GetByID" || !call.Failed {
+		t.Errorf("expected a failed GetByID span, got %+v", call)
+	}
+
+	// Without a traced request nothing is recorded.
+	rec.spans = nil
+	r.GetByID(inDefault, 42)
+	tracer.Flush(context.Background())
+	if len(rec.spans) != 0 {
+		t.Errorf("expected no spans outside a trace, got %+v", rec.spans)
+	}
+}
+
+func TestSQLiteAPIKeyRepo(t *testing.T) {
+	schema, err := migrations.SQLite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := repo.NewSQLiteAPIKeyRepo(openSQLite(t, schema))
+	ctx := context.Background()
+
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, key := range []model.APIKey{
+		{ID: "00000000000000b2", Name: "deploy", Subject: "deployer", Hash: "h2", CreatedAt: created.Add(time.Hour)},
+		{ID: "00000000000000a1", Name: "ci", Subject: "build-bot", Hash: "h1", CreatedAt: created},
+	} {
+		if err := r.CreateAPIKey(ctx, key); err != nil {
+			t.Fatalf("CreateAPIKey: %s", err)
+		}
+	}
+	if err := r.CreateAPIKey(ctx, model.APIKey{ID: "00000000000000a1", Name: "dup", Subject: "x", Hash: "h"}); !errors.Is(err, repo.ErrConflict) {
+		t.Errorf("expected a duplicate ID to conflict, got %v", err)
+	}
+
+	key, err := r.GetAPIKey(ctx, "00000000000000a1")
+	if err != nil {
+		t.Fatalf("GetAPIKey: %s", err)
+	}
+	if key.Subject != "build-bot" || key.Hash != "h1" || !key.CreatedAt.Equal(created) || key.Revoked() {
+		t.Errorf("unexpected key %+v", key)
+	}
+	if _, err := r.GetAPIKey(ctx, "ffffffffffffffff"); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	revoked := created.Add(2 * time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := r.RevokeAPIKey(ctx, "00000000000000a1", revoked.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("RevokeAPIKey: %s", err)
+		}
+	}
+	if err := r.RevokeAPIKey(ctx, "ffffffffffffffff", revoked); !errors.Is(err, repo.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	keys, err := r.ListAPIKeys(ctx)
+	if err != nil {
+		t.Fatalf("ListAPIKeys: %s", err)
+	}
+	if len(keys) != 2 || keys[0].Name != "ci" || keys[1].Name != "deploy" {
+		t.Fatalf("expected the keys oldest first, got %+v", keys)
+	}
+	if !keys[0].Revoked() || !keys[0].RevokedAt.Equal(revoked) || keys[1].Revoked() {
+		t.Errorf("expected only the first key revoked, at its first revocation, got %+v", keys)
+	}
+}
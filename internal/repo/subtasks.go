@@ -0,0 +1,395 @@
+// internal/repo/subtasks.go
+// The subtasks.go arranges tasks in trees: a task names its parent task, and
+// the subtasks of a task are read with recursive queries.
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// ErrHasSubtasks reports that a task was not deleted because it still has
+// subtasks. It is a kind of ErrConflict.
+var ErrHasSubtasks = fmt.Errorf("%w: task has subtasks", ErrConflict)
+
+// TaskSubtasks says what TaskRepository.Delete does with the subtasks of
+// the task. The zero value deletes only tasks without subtasks.
+type TaskSubtasks struct {
+	// Cascade deletes the subtasks, and theirs, together with the task.
+	Cascade bool
+	// Move re-parents the subtasks to the task MoveTo, which must be a task
+	// of the workspace outside the tree of the deleted task, or to the
+	// parent of the deleted task when MoveTo is nil. Moved subtasks get a
+	// new version.
+	Move   bool
+	MoveTo *int
+}
+
+// validateTaskSubtasks checks the choice of what to do with the subtasks of
+// the task id.
+func validateTaskSubtasks(id int, subtasks TaskSubtasks) error {
+	if subtasks.Cascade && subtasks.Move {
+		return &ValidationError{Field: "subtasks", Message: "cannot be both deleted and moved"}
+	}
+	if subtasks.MoveTo != nil && (!subtasks.Move || *subtasks.MoveTo == id) {
+		return &ValidationError{Field: "moveTo", Message: "must be another task the subtasks are moved to"}
+	}
+	return nil
+}
+
+// hasSubtasks builds the error returned when the task id is not deleted
+// because of its subtasks.
+func hasSubtasks(id int) error {
+	return fmt.Errorf("%w: task %d", ErrHasSubtasks, id)
+}
+
+// unknownParent and cyclicParent are returned when a task is made a
+// subtask of a task that does not exist, or of itself or one of its own
+// subtasks, reported on the given field.
+func unknownParent(field string) error {
+	return &ValidationError{Field: field, Message: "is not a task of the workspace"}
+}
+
+func cyclicParent(field string) error {
+	return &ValidationError{Field: field, Message: "is the task itself or one of its subtasks"}
+}
+
+// ancestorsSQL counts the task $1 of the workspace $2 and its ancestors, and
+// how many of them are the task $3. UNION stops at rows already found,
+// should the tasks ever form a cycle.
+const ancestorsSQL = "WITH RECURSIVE ancestors (id, parent_id) AS (" +
+	"SELECT id, parent_id FROM tasks WHERE id = $1 AND workspace_id = $2 " +
+	"UNION SELECT tasks.id, tasks.parent_id FROM tasks JOIN ancestors ON tasks.id = ancestors.parent_id" +
+	") SELECT COUNT(*), COUNT(CASE WHEN id = $3 THEN 1 END) FROM ancestors"
+
+// subtreeSQL starts a statement with the IDs of the subtasks of the task $1
+// of the workspace $2, of theirs, and so on, as the subtree table.
+const subtreeSQL = "WITH RECURSIVE subtree (id) AS (" +
+	"SELECT id FROM tasks WHERE parent_id = $1 AND workspace_id = $2 " +
+	"UNION SELECT tasks.id FROM tasks JOIN subtree ON tasks.parent_id = subtree.id) "
+
+// subtasksSQL selects the subtasks of the task id of the workspace ws, down
+// to depth levels below it, or all of them when depth is zero.
+func subtasksSQL(ws, id, depth int) (string, []any) {
+	query, args := subtreeSQL, []any{id, ws}
+	if depth > 0 {
+		query = "WITH RECURSIVE subtree (id, depth) AS (" +
+			"SELECT id, 1 FROM tasks WHERE parent_id = $1 AND workspace_id = $2 " +
+			"UNION ALL SELECT tasks.id, subtree.depth + 1 FROM tasks JOIN subtree ON tasks.parent_id = subtree.id WHERE subtree.depth < $3) "
+		args = append(args, depth)
+	}
+	return query + "SELECT " + taskColumns + " FROM tasks WHERE id IN (SELECT id FROM subtree) ORDER BY id", args
+}
+
+// validateDepth rejects depths of a subtask listing that make no sense.
+func validateDepth(depth int) error {
+	if depth < 0 {
+		return &ValidationError{Field: "depth", Message: "must not be negative"}
+	}
+	return nil
+}
+
+// checkParent checks that the task id of the workspace ws may become a
+// subtask of parent: parent must be a task of the workspace, and neither id
+// itself nor one of its subtasks. id is zero for a task being created.
+func (tr *TaskRepo) checkParent(ctx context.Context, q rowQuerier, ws, id, parent int, field string) error {
+	var found, cycle int
+	if err := q.QueryRowContext(ctx, ancestorsSQL, parent, ws, id).Scan(&found, &cycle); err != nil {
+		return tr.translate(ctx, err)
+	}
+	switch {
+	case found == 0:
+		return unknownParent(field)
+	case cycle > 0:
+		return cyclicParent(field)
+	}
+	return nil
+}
+
+// checkRefs checks, within the transaction of q, the project and the parent
+// a write names for the task id of the workspace ws, when they are set. id
+// is zero for a task being created.
+func (tr *TaskRepo) checkRefs(ctx context.Context, q rowQuerier, ws, id int, project, parent *int) error {
+	if err := tr.checkProject(ctx, q, ws, project); err != nil {
+		return err
+	}
+	if parent != nil {
+		return tr.checkParent(ctx, q, ws, id, *parent, "parentId")
+	}
+	return nil
+}
+
+// changedParents returns the tasks whose progress changes when a task goes
+// from before to after: the parent it leaves and the parent it joins, or
+// its parent when its status changes. before is nil for a created task and
+// after for a deleted one.
+func changedParents(before, after *model.Task) []int {
+	var from, to *int
+	if before != nil {
+		from = before.ParentID
+	}
+	if after != nil {
+		to = after.ParentID
+	}
+	var parents []int
+	switch {
+	case before != nil && after != nil && sameID(from, to):
+		if from != nil && before.Status != after.Status {
+			parents = append(parents, *from)
+		}
+	default:
+		if from != nil {
+			parents = append(parents, *from)
+		}
+		if to != nil && !sameID(from, to) {
+			parents = append(parents, *to)
+		}
+	}
+	return parents
+}
+
+// sameID reports whether two optional IDs are equal.
+func sameID(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// lockTrees keeps other transactions from moving tasks within the trees of
+// the workspace ws until tx ends. A transaction that re-parents a task takes
+// it before reading the tree, so that the cycle check sees every move
+// committed before its own.
+func (tr *TaskRepo) lockTrees(ctx context.Context, tx tracedTx, ws int) error {
+	if tr.dialect.lockTrees == "" {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, tr.dialect.lockTrees, ws)
+	return err
+}
+
+// bumpParents gives the tasks parents of the workspace ws a new version
+// within tx, since the progress of their subtasks changed.
+func bumpParents(ctx context.Context, tx tracedTx, ws int, parents []int) error {
+	for _, parent := range parents {
+		if _, err := tx.ExecContext(ctx, "UPDATE tasks SET version = version + 1 WHERE id = $1 AND workspace_id = $2", parent, ws); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subtasks retrieves the subtasks of a task down to depth levels below it,
+// or all of them when depth is zero, ordered by ID. It returns ErrNotFound
+// when no task has the given ID.
+func (tr *TaskRepo) Subtasks(ctx context.Context, id, depth int) ([]model.Task, error) {
+	if err := validateDepth(depth); err != nil {
+		return nil, err
+	}
+	if _, err := tr.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	ws, _ := scope(ctx)
+
+	query, args := subtasksSQL(ws, id, depth)
+	rows, err := tr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, tr.translate(ctx, err)
+	}
+	defer rows.Close()
+
+	tasks := []model.Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, tr.translate(ctx, err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, tr.translate(ctx, err)
+	}
+	return tasks, nil
+}
+
+// checkParent is MemoryTaskRepo's version of TaskRepo.checkParent. The
+// caller must hold mr.mu.
+func (mr *MemoryTaskRepo) checkParent(ws, id, parent int, field string) error {
+	seen := make(map[int]bool)
+	for next := &parent; next != nil && !seen[*next]; {
+		task, err := mr.current(ws, *next, 0)
+		if err != nil {
+			return unknownParent(field)
+		}
+		if task.ID == id {
+			return cyclicParent(field)
+		}
+		seen[task.ID] = true
+		next = task.ParentID
+	}
+	return nil
+}
+
+// checkRefs is MemoryTaskRepo's version of TaskRepo.checkRefs. The caller
+// must hold mr.mu.
+func (mr *MemoryTaskRepo) checkRefs(ws, id int, project, parent *int) error {
+	if err := mr.checkProject(ws, project); err != nil {
+		return err
+	}
+	if parent != nil {
+		return mr.checkParent(ws, id, *parent, "parentId")
+	}
+	return nil
+}
+
+// subtasks returns the IDs of the subtasks of the task id of the workspace
+// ws down to depth levels below it, or all of them when depth is zero,
+// ordered by ID. The caller must hold mr.mu.
+func (mr *MemoryTaskRepo) subtasks(ws, id, depth int) []int {
+	var ids []int
+	seen := map[int]bool{id: true}
+	level := []int{id}
+	for d := 1; len(level) > 0 && (depth == 0 || d <= depth); d++ {
+		var next []int
+		for _, parent := range level {
+			for child := range mr.children[parent] {
+				if !seen[child] && mr.workspaces[child] == ws {
+					seen[child] = true
+					next = append(next, child)
+				}
+			}
+		}
+		ids = append(ids, next...)
+		level = next
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// put stores task under its ID and records it among the subtasks of its
+// parent. The parents whose progress this changes get a new version. The
+// caller must hold mr.mu for writing.
+func (mr *MemoryTaskRepo) put(task model.Task) {
+	old, ok := mr.tasks[task.ID]
+	if ok {
+		mr.unlink(old)
+		mr.bump(changedParents(&old, &task))
+	} else {
+		mr.bump(changedParents(nil, &task))
+	}
+	mr.tasks[task.ID] = task
+	if task.ParentID != nil {
+		siblings := mr.children[*task.ParentID]
+		if siblings == nil {
+			siblings = make(map[int]bool)
+			mr.children[*task.ParentID] = siblings
+		}
+		siblings[task.ID] = true
+	}
+}
+
+// remove deletes the task id, giving its parent a new version. The caller
+// must hold mr.mu for writing.
+func (mr *MemoryTaskRepo) remove(id int) {
+	if old, ok := mr.tasks[id]; ok {
+		mr.unlink(old)
+		mr.bump(changedParents(&old, nil))
+	}
+	delete(mr.tasks, id)
+	delete(mr.workspaces, id)
+}
+
+// bump gives the stored tasks among parents a new version. The caller must
+// hold mr.mu for writing.
+func (mr *MemoryTaskRepo) bump(parents []int) {
+	for _, id := range parents {
+		if parent, ok := mr.tasks[id]; ok {
+			parent.Version++
+			mr.tasks[id] = parent
+		}
+	}
+}
+
+// unlink removes task from the subtasks of its parent. The caller must hold
+// mr.mu for writing.
+func (mr *MemoryTaskRepo) unlink(task model.Task) {
+	if task.ParentID == nil {
+		return
+	}
+	siblings := mr.children[*task.ParentID]
+	delete(siblings, task.ID)
+	if len(siblings) == 0 {
+		delete(mr.children, *task.ParentID)
+	}
+}
+
+// progress counts the direct subtasks of the task id, or returns nil when it
+// has none. The caller must hold mr.mu.
+func (mr *MemoryTaskRepo) progress(id int) *model.Progress {
+	children := mr.children[id]
+	if len(children) == 0 {
+		return nil
+	}
+	progress := &model.Progress{Total: len(children)}
+	for childID := range children {
+		if mr.tasks[childID].Status == model.StatusCompleted {
+			progress.Completed++
+		}
+	}
+	return progress
+}
+
+// output returns the copy of a stored task handed to callers, with the
+// progress of its subtasks. The caller must hold mr.mu.
+func (mr *MemoryTaskRepo) output(task model.Task) model.Task {
+	task = copyTask(task)
+	task.Progress = mr.progress(task.ID)
+	return task
+}
+
+// Subtasks retrieves the subtasks of a task like TaskRepo.Subtasks.
+func (mr *MemoryTaskRepo) Subtasks(ctx context.Context, id, depth int) ([]model.Task, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDepth(depth); err != nil {
+		return nil, err
+	}
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	if _, err := mr.current(ws, id, 0); err != nil {
+		return nil, err
+	}
+	tasks := []model.Task{}
+	for _, childID := range mr.subtasks(ws, id, depth) {
+		tasks = append(tasks, mr.output(mr.tasks[childID]))
+	}
+	return tasks, nil
+}
+
+// undeleted explains why the delete of a task without a choice about its
+// subtasks matched no row: the task does not exist, is no longer at the
+// expected version, or has subtasks.
+func (tr *TaskRepo) undeleted(ctx context.Context, ws, id, version int) error {
+	var current int
+	var subtasks bool
+	err := tr.db.QueryRowContext(ctx,
+		"SELECT version, EXISTS (SELECT 1 FROM tasks AS subtasks WHERE subtasks.parent_id = tasks.id) FROM tasks WHERE id = $1 AND workspace_id = $2",
+		id, ws,
+	).Scan(&current, &subtasks)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return notFound(id)
+	case err != nil:
+		return tr.translate(ctx, err)
+	case version > 0 && current != version:
+		return staleVersion(id, version)
+	case subtasks:
+		return hasSubtasks(id)
+	}
+	return fmt.Errorf("%w: task %d changed while being deleted", ErrConflict, id)
+}
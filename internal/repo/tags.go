@@ -0,0 +1,475 @@
+// internal/repo/tags.go
+// The tags.go stores the tags tasks are labelled with, in the same database
+// and workspace as the tasks.
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// TagRepository stores tags. Like TaskRepository, every method is confined
+// to the workspace named by its context, and failures are reported with the
+// same sentinel errors.
+//
+// Tasks name their tags in model.Task.Tags; a TaskRepository creates the
+// tags a task names that do not exist yet. Renaming or deleting a tag
+// changes the tasks it labels, which get a new version.
+type TagRepository interface {
+	CreateTag(ctx context.Context, tag model.Tag) (model.Tag, error)
+	GetTag(ctx context.Context, id int) (model.Tag, error)
+	// ListTags returns the tags of the workspace, ordered by name.
+	ListTags(ctx context.Context) ([]model.Tag, error)
+	// PatchTag changes the fields set in the patch and returns the updated
+	// tag. A new name is one no other tag of the workspace may have;
+	// otherwise ErrTagExists is returned.
+	PatchTag(ctx context.Context, id int, patch TagPatch) (model.Tag, error)
+	// DeleteTag deletes a tag and removes it from the tasks it labels.
+	DeleteTag(ctx context.Context, id int) error
+}
+
+// Ensure TagRepo and MemoryTagRepo implement TagRepository.
+var (
+	_ TagRepository = &TagRepo{}
+	_ TagRepository = &MemoryTagRepo{}
+)
+
+// ErrTagExists reports that a tag was not created or renamed because
+// another tag of the workspace has the name. It is a kind of ErrConflict.
+var ErrTagExists = fmt.Errorf("%w: tag name is taken", ErrConflict)
+
+// TagPatch lists the fields changed by PatchTag. Nil pointers leave the
+// corresponding field untouched.
+type TagPatch struct {
+	Name  *string
+	Color *string
+}
+
+// Apply returns a copy of tag with the patch applied.
+func (tp TagPatch) Apply(tag model.Tag) model.Tag {
+	if tp.Name != nil {
+		tag.Name = *tp.Name
+	}
+	if tp.Color != nil {
+		tag.Color = *tp.Color
+	}
+	return tag
+}
+
+// maxTagLength mirrors the VARCHAR(50) limit of the tags.name column.
+const maxTagLength = 50
+
+// tagPattern matches the tag names accepted. Tag names cannot contain
+// commas, which separate them when the tags of a task are aggregated.
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._:/-]*$`)
+
+// validateTagName checks a tag name, reported as field.
+func validateTagName(field, name string) error {
+	if name == "" {
+		return &ValidationError{Field: field, Message: "must not contain empty tag names"}
+	}
+	if len(name) > maxTagLength {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("must be at most %d characters per tag", maxTagLength)}
+	}
+	if !tagPattern.MatchString(name) {
+		return &ValidationError{Field: field,
+			Message: fmt.Sprintf("%q must consist of lowercase letters, digits and . _ : / -, starting with a letter or digit", name)}
+	}
+	return nil
+}
+
+// validateTag checks the constraints the tags table enforces.
+func validateTag(tag model.Tag) error {
+	if err := validateTagName("name", tag.Name); err != nil {
+		return err
+	}
+	if tag.Color != "" && !colorPattern.MatchString(tag.Color) {
+		return &ValidationError{Field: "color", Message: "must be a color like #1e90ff"}
+	}
+	return nil
+}
+
+// normalizeTags returns the tag names of a task sorted and without
+// duplicates, or nil for none.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	unique := sorted[:1]
+	for _, tag := range sorted[1:] {
+		if tag != unique[len(unique)-1] {
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+// tagNotFound builds the error returned when no tag has the given ID.
+func tagNotFound(id int) error {
+	return fmt.Errorf("%w: tag %d", ErrNotFound, id)
+}
+
+// tagExists builds the error returned when another tag has the given name.
+func tagExists(name string) error {
+	return fmt.Errorf("%w: %q", ErrTagExists, name)
+}
+
+// TagRepo provides access to the tags of a SQL database.
+type TagRepo struct {
+	db      tracedDB
+	dialect dialect
+}
+
+// NewTagRepo creates a TagRepo for a PostgreSQL database.
+func NewTagRepo(db *sql.DB) *TagRepo {
+	return &TagRepo{db: tracedDB{DB: db, system: "postgresql"}, dialect: postgresDialect}
+}
+
+// NewSQLiteTagRepo creates a TagRepo for a SQLite database migrated with
+// migrations.SQLite.
+func NewSQLiteTagRepo(db *sql.DB) *TagRepo {
+	return &TagRepo{db: tracedDB{DB: db, system: "sqlite"}, dialect: sqliteDialect}
+}
+
+// tagColumns lists the tags columns in the order expected by scanTag.
+const tagColumns = "id, name, color"
+
+// scanTag reads a single tag row selected with tagColumns.
+func scanTag(s rowScanner) (model.Tag, error) {
+	var tag model.Tag
+	if err := s.Scan(&tag.ID, &tag.Name, &tag.Color); err != nil {
+		return model.Tag{}, err
+	}
+	return tag, nil
+}
+
+// replaceTags replaces the tags of the task id in the workspace ws with the
+// normalized tags, creating the tags the workspace does not have yet.
+func replaceTags(ctx context.Context, tx tracedTx, ws, id int, tags []string) error {
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM task_tags WHERE task_id = $1 AND task_id IN (SELECT id FROM tasks WHERE workspace_id = $2)", id, ws,
+	); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	var b sqlBuilder
+	workspace := b.arg(ws)
+	rows := make([]string, len(tags))
+	for i, tag := range tags {
+		rows[i] = "(" + workspace + ", " + b.arg(tag) + ")"
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO tags (workspace_id, name) VALUES "+strings.Join(rows, ", ")+" ON CONFLICT (workspace_id, name) DO NOTHING", b.args...,
+	); err != nil {
+		return err
+	}
+
+	b = sqlBuilder{}
+	query := "INSERT INTO task_tags (task_id, tag_id) SELECT tasks.id, tags.id FROM tasks JOIN tags ON tags.workspace_id = tasks.workspace_id " +
+		"WHERE tasks.id = " + b.arg(id) + " AND tasks.workspace_id = " + b.arg(ws) + " AND tags.name IN (" + b.list(anySlice(tags)) + ")"
+	_, err := tx.ExecContext(ctx, query, b.args...)
+	return err
+}
+
+// CreateTag inserts a new tag into the workspace.
+func (tr *TagRepo) CreateTag(ctx context.Context, tag model.Tag) (model.Tag, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	if err := validateTag(tag); err != nil {
+		return model.Tag{}, err
+	}
+	created, err := scanTag(tr.db.QueryRowContext(ctx,
+		"INSERT INTO tags (workspace_id, name, color) VALUES ($1, $2, $3) RETURNING "+tagColumns, ws, tag.Name, tag.Color,
+	))
+	if err != nil {
+		return model.Tag{}, tr.translate(ctx, err, tag.Name)
+	}
+	return created, nil
+}
+
+// GetTag retrieves a tag of the workspace by its ID.
+func (tr *TagRepo) GetTag(ctx context.Context, id int) (model.Tag, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	tag, err := scanTag(tr.db.QueryRowContext(ctx, "SELECT "+tagColumns+" FROM tags WHERE id = $1 AND workspace_id = $2", id, ws))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Tag{}, tagNotFound(id)
+	}
+	if err != nil {
+		return model.Tag{}, tr.translate(ctx, err, "")
+	}
+	return tag, nil
+}
+
+// ListTags retrieves the tags of the workspace, ordered by name.
+func (tr *TagRepo) ListTags(ctx context.Context) ([]model.Tag, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tr.db.QueryContext(ctx, "SELECT "+tagColumns+" FROM tags WHERE workspace_id = $1 ORDER BY name", ws)
+	if err != nil {
+		return nil, tr.translate(ctx, err, "")
+	}
+	defer rows.Close()
+
+	tags := []model.Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, tr.translate(ctx, err, "")
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, tr.translate(ctx, err, "")
+	}
+	return tags, nil
+}
+
+// PatchTag changes the fields set in the patch. Renaming the tag increments
+// the version of the tasks it labels, in the same transaction.
+func (tr *TagRepo) PatchTag(ctx context.Context, id int, patch TagPatch) (model.Tag, error) {
+	current, err := tr.GetTag(ctx, id)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	tag := patch.Apply(current)
+	if err := validateTag(tag); err != nil {
+		return model.Tag{}, err
+	}
+	ws, _ := scope(ctx)
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Tag{}, tr.translate(ctx, err, "")
+	}
+	defer tx.Rollback()
+
+	updated, err := scanTag(tx.QueryRowContext(ctx,
+		"UPDATE tags SET name = $1, color = $2 WHERE id = $3 AND workspace_id = $4 RETURNING "+tagColumns,
+		tag.Name, tag.Color, id, ws,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Tag{}, tagNotFound(id)
+	}
+	if err != nil {
+		return model.Tag{}, tr.translate(ctx, err, tag.Name)
+	}
+	if updated.Name != current.Name {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE tasks SET version = version + 1 WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id = $1)", id,
+		); err != nil {
+			return model.Tag{}, tr.translate(ctx, err, "")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Tag{}, tr.translate(ctx, err, "")
+	}
+	return updated, nil
+}
+
+// DeleteTag deletes a tag and removes it from its tasks in one transaction.
+// The tasks get a new version.
+func (tr *TagRepo) DeleteTag(ctx context.Context, id int) error {
+	ws, err := scope(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return tr.translate(ctx, err, "")
+	}
+	defer tx.Rollback()
+
+	var found bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM tags WHERE id = $1 AND workspace_id = $2)", id, ws,
+	).Scan(&found); err != nil {
+		return tr.translate(ctx, err, "")
+	}
+	if !found {
+		return tagNotFound(id)
+	}
+	for _, statement := range []string{
+		"UPDATE tasks SET version = version + 1 WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id = $1)",
+		"DELETE FROM task_tags WHERE tag_id = $1",
+		"DELETE FROM tags WHERE id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, statement, id); err != nil {
+			return tr.translate(ctx, err, "")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return tr.translate(ctx, err, "")
+	}
+	return nil
+}
+
+// translate maps a failed statement onto the repository sentinels. A
+// conflict while writing the tag name means another tag has that name.
+func (tr *TagRepo) translate(ctx context.Context, err error, name string) error {
+	err = tr.dialect.translateStatement(ctx, err)
+	if name != "" && errors.Is(err, ErrConflict) {
+		return tagExists(name)
+	}
+	return err
+}
+
+// MemoryTagRepo is a TagRepository keeping tags in memory together with
+// the tasks of a MemoryTaskRepo, so that renaming and deleting tags acts on
+// those tasks. It is safe for concurrent use.
+type MemoryTagRepo struct {
+	tasks *MemoryTaskRepo
+}
+
+// NewMemoryTagRepo creates a MemoryTagRepo for the tasks of tasks.
+func NewMemoryTagRepo(tasks *MemoryTaskRepo) *MemoryTagRepo {
+	return &MemoryTagRepo{tasks: tasks}
+}
+
+// tag returns the tag with the given ID in the workspace ws. The caller
+// must hold the lock of the task repository.
+func (mr *MemoryTagRepo) tag(ws, id int) (model.Tag, error) {
+	tag, ok := mr.tasks.tags[id]
+	if !ok || tag.workspace != ws {
+		return model.Tag{}, tagNotFound(id)
+	}
+	return tag.Tag, nil
+}
+
+// CreateTag stores a new tag. IDs are assigned in increasing order.
+func (mr *MemoryTagRepo) CreateTag(ctx context.Context, tag model.Tag) (model.Tag, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	if err := validateTag(tag); err != nil {
+		return model.Tag{}, err
+	}
+	mr.tasks.mu.Lock()
+	defer mr.tasks.mu.Unlock()
+
+	if _, ok := mr.tasks.tagID(ws, tag.Name); ok {
+		return model.Tag{}, tagExists(tag.Name)
+	}
+	mr.tasks.lastTagID++
+	tag.ID = mr.tasks.lastTagID
+	mr.tasks.tags[tag.ID] = memoryTag{Tag: tag, workspace: ws}
+	return tag, nil
+}
+
+// GetTag retrieves a tag by its ID.
+func (mr *MemoryTagRepo) GetTag(ctx context.Context, id int) (model.Tag, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	mr.tasks.mu.RLock()
+	defer mr.tasks.mu.RUnlock()
+
+	return mr.tag(ws, id)
+}
+
+// ListTags retrieves the tags of the workspace, ordered by name.
+func (mr *MemoryTagRepo) ListTags(ctx context.Context) ([]model.Tag, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mr.tasks.mu.RLock()
+	defer mr.tasks.mu.RUnlock()
+
+	tags := []model.Tag{}
+	for _, tag := range mr.tasks.tags {
+		if tag.workspace == ws {
+			tags = append(tags, tag.Tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// PatchTag changes the fields set in the patch, relabelling the tasks of
+// the tag when it is renamed.
+func (mr *MemoryTagRepo) PatchTag(ctx context.Context, id int, patch TagPatch) (model.Tag, error) {
+	ws, err := begin(ctx)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	mr.tasks.mu.Lock()
+	defer mr.tasks.mu.Unlock()
+
+	current, err := mr.tag(ws, id)
+	if err != nil {
+		return model.Tag{}, err
+	}
+	tag := patch.Apply(current)
+	if err := validateTag(tag); err != nil {
+		return model.Tag{}, err
+	}
+	if tag.Name != current.Name {
+		if _, ok := mr.tasks.tagID(ws, tag.Name); ok {
+			return model.Tag{}, tagExists(tag.Name)
+		}
+		mr.relabel(ws, current.Name, func(tags []string) []string {
+			return normalizeTags(append(tags, tag.Name))
+		})
+	}
+	mr.tasks.tags[id] = memoryTag{Tag: tag, workspace: ws}
+	return tag, nil
+}
+
+// DeleteTag deletes a tag and removes it from its tasks.
+func (mr *MemoryTagRepo) DeleteTag(ctx context.Context, id int) error {
+	ws, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+	mr.tasks.mu.Lock()
+	defer mr.tasks.mu.Unlock()
+
+	tag, err := mr.tag(ws, id)
+	if err != nil {
+		return err
+	}
+	mr.relabel(ws, tag.Name, func(tags []string) []string { return normalizeTags(tags) })
+	delete(mr.tasks.tags, id)
+	return nil
+}
+
+// relabel replaces the tags of every task of the workspace ws labelled
+// name by the result of change, which is passed the other tags, and
+// increments the version of those tasks. The caller must hold the lock of
+// the task repository.
+func (mr *MemoryTagRepo) relabel(ws int, name string, change func(tags []string) []string) {
+	for id, task := range mr.tasks.tasks {
+		if mr.tasks.workspaces[id] != ws || !contains(task.Tags, name) {
+			continue
+		}
+		var others []string
+		for _, tag := range task.Tags {
+			if tag != name {
+				others = append(others, tag)
+			}
+		}
+		task.Tags = change(others)
+		task.Version++
+		mr.tasks.put(task)
+	}
+}
@@ -5,103 +5,688 @@
 package repo
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/DimWebDev/task-manager-tool/internal/logging"
 	"github.com/DimWebDev/task-manager-tool/internal/model"
 )
 
 // TaskRepository defines the interface for task repository operations.
+// Implementations report failures with the sentinel errors declared in
+// errors.go (ErrNotFound, ErrConflict, ErrValidation, ErrUnavailable,
+// ErrCanceled, ErrTimeout).
+//
+// Every method is confined to the workspace named by its context, see
+// WithWorkspace: tasks of other workspaces are reported as not found, and
+// a context without a workspace fails with ErrNoWorkspace.
+//
+// Every method stops its work when ctx is done and then fails with
+// ErrCanceled or ErrTimeout, depending on why ctx ended.
 type TaskRepository interface {
-    Create(task model.Task) error
-    GetByID(id int) (model.Task, error)
-    GetAll() ([]model.Task, error)
-    Update(task model.Task) error
-    Delete(id int) error
+	Create(ctx context.Context, task model.Task) (model.Task, error)
+	GetByID(ctx context.Context, id int) (model.Task, error)
+	GetAll(ctx context.Context) ([]model.Task, error)
+	List(ctx context.Context, q TaskQuery) (TaskPage, error)
+	Update(ctx context.Context, task model.Task) (model.Task, error)
+	Patch(ctx context.Context, id int, patch TaskPatch) (model.Task, error)
+	// Delete deletes a task, doing with its subtasks what subtasks says. A
+	// task that still has subtasks is only deleted when subtasks says what
+	// to do with them; otherwise ErrHasSubtasks is returned.
+	Delete(ctx context.Context, id int, version int, subtasks TaskSubtasks) error
+	// Subtasks returns the subtasks of a task down to depth levels below
+	// it, or all of them when depth is zero, ordered by ID.
+	Subtasks(ctx context.Context, id, depth int) ([]model.Task, error)
+	// AddWatcher and RemoveWatcher change whether a user watches a task.
+	// Like Patch, they honour a non-zero version as the expected version
+	// and return the task; a change that is already in place leaves the
+	// task, and its version, as it is.
+	AddWatcher(ctx context.Context, id, userID, version int) (model.Task, error)
+	RemoveWatcher(ctx context.Context, id, userID, version int) (model.Task, error)
 }
 
 // Ensure TaskRepo implements TaskRepository.
 var _ TaskRepository = &TaskRepo{}
-// TaskRepo provides access to the task storage.
+
+// TaskRepo provides access to the task storage in a SQL database.
 type TaskRepo struct {
-	db *sql.DB
+	db      tracedDB
+	dialect dialect
 }
 
-// NewTaskRepo creates a new TaskRepo.
+// NewTaskRepo creates a new TaskRepo for a PostgreSQL database.
 func NewTaskRepo(db *sql.DB) *TaskRepo {
-	return &TaskRepo{db: db}
+	return &TaskRepo{db: tracedDB{DB: db, system: "postgresql"}, dialect: postgresDialect}
+}
+
+// dialect captures what TaskRepo does differently for each database. The
+// statements themselves are shared: both databases understand $n
+// placeholders, RETURNING and NULLS LAST.
+type dialect struct {
+	// date converts an optional due date into a statement argument.
+	date func(t *time.Time) any
+	// translate maps driver errors onto the repository sentinels.
+	translate func(err error) error
+	// forUpdate ends a SELECT whose rows stay locked until the transaction
+	// ends.
+	forUpdate string
+	// lockTrees is the statement that keeps other transactions from moving
+	// tasks within the trees of the workspace $1 until the transaction ends.
+	lockTrees string
+}
+
+// treeLockClass is the first key of the advisory locks taken by lockTrees;
+// the second is the workspace ID.
+const treeLockClass = 0x7461736b
+
+// postgresDialect stores due dates in a DATE column. Re-parenting takes an
+// advisory lock per workspace, so that two tasks made subtasks of each other
+// at the same time cannot both pass the cycle check.
+var postgresDialect = dialect{
+	date:      func(t *time.Time) any { return nullTime(t) },
+	translate: translateError,
+	forUpdate: " FOR UPDATE",
+	lockTrees: fmt.Sprintf("SELECT pg_advisory_xact_lock(%d, $1)", treeLockClass),
+}
+
+// taskColumns lists the task columns in the order expected by scanTask.
+// The watchers and the tag names are aggregated into comma-separated lists
+// by subqueries, so that a listing reads them without a statement per task;
+// the subtasks are counted likewise.
+const taskColumns = "id, title, description, duedate, priority, status, version, created_by, assignee_id, project_id, parent_id, " +
+	"(SELECT string_agg(CAST(user_id AS TEXT), ',') FROM task_watchers WHERE task_id = tasks.id) AS watchers, " +
+	"(SELECT string_agg(tags.name, ',') FROM task_tags JOIN tags ON tags.id = task_tags.tag_id WHERE task_tags.task_id = tasks.id) AS tags, " +
+	"(SELECT COUNT(*) FROM tasks AS subtasks WHERE subtasks.parent_id = tasks.id) AS subtasks, " +
+	"(SELECT COUNT(*) FROM tasks AS subtasks WHERE subtasks.parent_id = tasks.id AND subtasks.status = '" + string(model.StatusCompleted) + "') AS completed_subtasks"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTask reads a single task row selected with taskColumns.
+func scanTask(s rowScanner) (model.Task, error) {
+	// Use nullDate to handle NULL dates
+	var dueDate nullDate
+	var createdBy, assigneeID, projectID, parentID sql.NullInt64
+	var watchers, tags sql.NullString
+	var progress model.Progress
+	var task model.Task
+	if err := s.Scan(&task.ID, &task.Title, &task.Description, &dueDate, &task.Priority, &task.Status, &task.Version,
+		&createdBy, &assigneeID, &projectID, &parentID, &watchers, &tags, &progress.Total, &progress.Completed); err != nil {
+		return model.Task{}, err
+	}
+	// Set Task.DueDate only if dueDate.Valid is true
+	if dueDate.Valid {
+		task.DueDate = &dueDate.Time
+	}
+	task.CreatedBy, task.AssigneeID, task.ProjectID = nullID(createdBy), nullID(assigneeID), nullID(projectID)
+	task.ParentID = nullID(parentID)
+	if progress.Total > 0 {
+		task.Progress = &progress
+	}
+	if watchers.Valid {
+		for _, w := range strings.Split(watchers.String, ",") {
+			id, err := strconv.Atoi(w)
+			if err != nil {
+				return model.Task{}, fmt.Errorf("invalid watcher %q: %w", w, err)
+			}
+			task.Watchers = append(task.Watchers, id)
+		}
+		sort.Ints(task.Watchers)
+	}
+	if tags.Valid {
+		task.Tags = normalizeTags(strings.Split(tags.String, ","))
+	}
+	return task, nil
+}
+
+// nullID converts a scanned user, project or task ID into an optional one.
+func nullID(id sql.NullInt64) *int {
+	if !id.Valid {
+		return nil
+	}
+	v := int(id.Int64)
+	return &v
+}
+
+// nullInt converts an optional user, project or task ID into a value the driver can store.
+func nullInt(id *int) sql.NullInt64 {
+	if id == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*id), Valid: true}
+}
+
+// nullDate scans a due date. PostgreSQL returns DATE columns as time.Time,
+// SQLite returns the YYYY-MM-DD text the date is stored as.
+type nullDate struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements sql.Scanner.
+func (d *nullDate) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = nullDate{}
+		return nil
+	case time.Time:
+		*d = nullDate{Time: v, Valid: true}
+		return nil
+	case []byte:
+		src = string(v)
+	}
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into a date", src)
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return fmt.Errorf("invalid date %q: %w", s, err)
+	}
+	*d = nullDate{Time: t, Valid: true}
+	return nil
+}
+
+// nullTime converts an optional date into a value the driver can store.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
 }
 
-// Create inserts a new task into the database.
-func (tr *TaskRepo) Create(task model.Task) error {
-    // Use sql.NullTime to handle nil dates
-    dueDate := sql.NullTime{}
-    if task.DueDate != nil {
-        dueDate = sql.NullTime{Time: *task.DueDate, Valid: true}
-    }
-    _, err := tr.db.Exec("INSERT INTO tasks (title, description, duedate, priority, status) VALUES ($1, $2, $3, $4, $5)",
-        task.Title, task.Description, dueDate, task.Priority, task.Status)
-    return err
+// Create inserts a new task into the database and returns the stored task,
+// including the ID assigned by the database. Watchers are added afterwards
+// with AddWatcher. A task with tags, a project or a parent is inserted in a
+// transaction that also stores the tags, checks that the project is one of
+// the workspace and the parent a task of it, and gives the parent a new
+// version.
+func (tr *TaskRepo) Create(ctx context.Context, task model.Task) (model.Task, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := validateTask(task); err != nil {
+		return model.Task{}, err
+	}
+	dueDate := tr.dialect.date(task.DueDate)
+	query := "INSERT INTO tasks (title, description, duedate, priority, status, created_by, assignee_id, workspace_id, project_id, parent_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING " + taskColumns
+	args := []any{task.Title, task.Description, dueDate, task.Priority, task.Status, nullInt(task.CreatedBy), nullInt(task.AssigneeID), ws, nullInt(task.ProjectID), nullInt(task.ParentID)}
+	tags := normalizeTags(task.Tags)
+	if len(tags) == 0 && task.ProjectID == nil && task.ParentID == nil {
+		created, err := scanTask(tr.db.QueryRowContext(ctx, query, args...))
+		if err != nil {
+			return model.Task{}, tr.translate(ctx, err)
+		}
+		return created, nil
+	}
+
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	defer tx.Rollback()
+	if err := tr.checkRefs(ctx, tx, ws, 0, task.ProjectID, task.ParentID); err != nil {
+		return model.Task{}, err
+	}
+	created, err := scanTask(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	if err := replaceTags(ctx, tx, ws, created.ID, tags); err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	if err := bumpParents(ctx, tx, ws, changedParents(nil, &created)); err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	created.Tags = tags
+	return created, nil
 }
 
 // GetByID retrieves a task by its ID from the database.
-func (tr *TaskRepo) GetByID(id int) (model.Task, error) {
-    // Use sql.NullTime to handle NULL dates
-    var dueDate sql.NullTime
-    var task model.Task
-    err := tr.db.QueryRow("SELECT id, title, description, duedate, priority, status FROM tasks WHERE id = $1", id).
-        Scan(&task.ID, &task.Title, &task.Description, &dueDate, &task.Priority, &task.Status)
-    if err != nil {
-        return model.Task{}, err
-    }
-    // Set Task.DueDate only if dueDate.Valid is true
-    if dueDate.Valid {
-        task.DueDate = &dueDate.Time
-    } else {
-        task.DueDate = nil
-    }
-    return task, nil
-}
-
-// GetAll retrieves all tasks from the database.
-func (tr *TaskRepo) GetAll() ([]model.Task, error) {
-    rows, err := tr.db.Query("SELECT id, title, description, duedate, priority, status FROM tasks")
-    if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-
-    var tasks []model.Task
-    for rows.Next() {
-        var dueDate sql.NullTime
-        var task model.Task
-        if err := rows.Scan(&task.ID, &task.Title, &task.Description, &dueDate, &task.Priority, &task.Status); err != nil {
-            return nil, err
-        }
-        // Set Task.DueDate only if dueDate.Valid is true
-        if dueDate.Valid {
-            task.DueDate = &dueDate.Time
-        }
-        tasks = append(tasks, task)
-    }
-    return tasks, nil
-}
-
-// Update modifies an existing task in the database.
-func (tr *TaskRepo) Update(task model.Task) error {
-    // Use sql.NullTime to handle nil dates
-    dueDate := sql.NullTime{}
-    if task.DueDate != nil {
-        dueDate = sql.NullTime{Time: *task.DueDate, Valid: true}
-    }
-    _, err := tr.db.Exec(
-        "UPDATE tasks SET title = $1, description = $2, duedate = $3, priority = $4, status = $5 WHERE id = $6",
-        task.Title, task.Description, dueDate, task.Priority, task.Status, task.ID,
-    )
-    return err
-}
-
-// Delete removes a task by its ID from the database.
-func (tr *TaskRepo) Delete(id int) error {
-	_, err := tr.db.Exec("DELETE FROM tasks WHERE id = $1", id)
-	return err
+func (tr *TaskRepo) GetByID(ctx context.Context, id int) (model.Task, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	task, err := scanTask(tr.db.QueryRowContext(ctx, "SELECT "+taskColumns+" FROM tasks WHERE id = $1 AND workspace_id = $2", id, ws))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Task{}, notFound(id)
+	}
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	return task, nil
+}
+
+// GetAll retrieves all tasks of the workspace from the database.
+func (tr *TaskRepo) GetAll(ctx context.Context) ([]model.Task, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tr.db.QueryContext(ctx, "SELECT "+taskColumns+" FROM tasks WHERE workspace_id = $1", ws)
+	if err != nil {
+		return nil, tr.translate(ctx, err)
+	}
+	defer rows.Close()
+
+	var tasks []model.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, tr.translate(ctx, err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, tr.translate(ctx, err)
+	}
+	return tasks, nil
+}
+
+// List retrieves one page of tasks matching the query. Pages are chained
+// with keyset pagination: the returned Next cursor holds the sort key of the
+// last task, and passing it as q.After continues right after that task.
+func (tr *TaskRepo) List(ctx context.Context, q TaskQuery) (TaskPage, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return TaskPage{}, err
+	}
+	q, err = q.normalize()
+	if err != nil {
+		return TaskPage{}, err
+	}
+
+	query, args := listSQL(ws, q, tr.dialect)
+	rows, err := tr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return TaskPage{}, tr.translate(ctx, err)
+	}
+	defer rows.Close()
+
+	tasks := make([]model.Task, 0, q.Limit)
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return TaskPage{}, tr.translate(ctx, err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return TaskPage{}, tr.translate(ctx, err)
+	}
+	return newPage(tasks, q), nil
+}
+
+// newPage trims the extra row fetched by List and derives the next cursor.
+func newPage(tasks []model.Task, q TaskQuery) TaskPage {
+	if len(tasks) <= q.Limit {
+		return TaskPage{Tasks: tasks}
+	}
+	tasks = tasks[:q.Limit]
+	last := tasks[len(tasks)-1]
+	return TaskPage{
+		Tasks: tasks,
+		Next: &Cursor{
+			SortBy:     q.SortBy,
+			Descending: q.Descending,
+			Value:      sortValue(last, q.SortBy),
+			ID:         last.ID,
+		},
+	}
+}
+
+// Update replaces an existing task in the database and returns it with its
+// new version. Who created the task, its assignee, its project, its parent
+// and its watchers are kept: they are changed with Patch and AddWatcher. Its tags
+// are replaced when task.Tags is non-nil and kept otherwise. When
+// task.Version is set, the row is only written if it is still at that
+// version (compare-and-swap); otherwise ErrVersionMismatch is returned. It
+// returns ErrNotFound when no task has the given ID. A change of status
+// gives the parent of the task a new version in the same transaction.
+func (tr *TaskRepo) Update(ctx context.Context, task model.Task) (model.Task, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := validateTask(task); err != nil {
+		return model.Task{}, err
+	}
+	dueDate := tr.dialect.date(task.DueDate)
+	query := "UPDATE tasks SET title = $1, description = $2, duedate = $3, priority = $4, status = $5, version = version + 1 WHERE id = $6 AND workspace_id = $7"
+	args := []any{task.Title, task.Description, dueDate, task.Priority, task.Status, task.ID, ws}
+	if task.Version > 0 {
+		query += " AND version = $8"
+		args = append(args, task.Version)
+	}
+
+	w := taskWrite{progress: true, setTags: task.Tags != nil, tags: task.Tags}
+	updated, err := tr.write(ctx, ws, task.ID, w, query+" RETURNING "+taskColumns, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Task{}, tr.missingOrStale(ctx, ws, task.ID, task.Version)
+	}
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	return updated, nil
+}
+
+// Patch changes only the fields set in the patch and returns the updated
+// task. Like Update, it honours patch.Version as the expected version. It
+// returns ErrNotFound when no task has the given ID. A new project must be
+// one of the workspace, and a new parent a task of the workspace outside the
+// tree of the task; both are checked in the transaction of the write, which
+// also gives the old and the new parent a new version when the task moves
+// or changes status.
+func (tr *TaskRepo) Patch(ctx context.Context, id int, patch TaskPatch) (model.Task, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := validatePatch(patch); err != nil {
+		return model.Task{}, err
+	}
+	if patch.IsEmpty() {
+		task, err := tr.GetByID(ctx, id)
+		if err == nil && patch.Version > 0 && task.Version != patch.Version {
+			return model.Task{}, staleVersion(id, patch.Version)
+		}
+		return task, err
+	}
+
+	query, args := patchSQL(ws, id, patch, tr.dialect)
+	w := taskWrite{progress: patch.SetParent || patch.Status != nil, setTags: patch.SetTags, tags: patch.Tags}
+	w.project, w.parent = patch.refs()
+	task, err := tr.write(ctx, ws, id, w, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Task{}, tr.missingOrStale(ctx, ws, id, patch.Version)
+	}
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	return task, nil
+}
+
+// taskWrite says what write does around the statement of a write of a task.
+type taskWrite struct {
+	// project and parent, when set, are checked before the write.
+	project, parent *int
+	// progress is set when the write may change the status or the parent
+	// of the task, and so the progress of its old and new parent.
+	progress bool
+	// setTags replaces the tags of the task with tags.
+	setTags bool
+	tags    []string
+}
+
+// write runs query, which writes the task id of the workspace ws and
+// returns it. The project and parent the write names are checked first,
+// the tags replaced and the parents whose progress changed given a new
+// version, all in the same transaction, so that the task returned carries
+// the tags. A write that re-parents the task holds the tree lock of the
+// workspace from the start. Errors are returned as the driver reports them.
+func (tr *TaskRepo) write(ctx context.Context, ws, id int, w taskWrite, query string, args ...any) (model.Task, error) {
+	if !w.setTags && !w.progress && w.project == nil && w.parent == nil {
+		return scanTask(tr.db.QueryRowContext(ctx, query, args...))
+	}
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Task{}, err
+	}
+	defer tx.Rollback()
+	if w.parent != nil {
+		if err := tr.lockTrees(ctx, tx, ws); err != nil {
+			return model.Task{}, err
+		}
+	}
+	var before model.Task
+	if w.progress {
+		var parent sql.NullInt64
+		err := tx.QueryRowContext(ctx,
+			"SELECT status, parent_id FROM tasks WHERE id = $1 AND workspace_id = $2"+tr.dialect.forUpdate, id, ws,
+		).Scan(&before.Status, &parent)
+		if err != nil {
+			return model.Task{}, err
+		}
+		before.ParentID = nullID(parent)
+	}
+	if err := tr.checkRefs(ctx, tx, ws, id, w.project, w.parent); err != nil {
+		return model.Task{}, err
+	}
+	if w.setTags {
+		if err := replaceTags(ctx, tx, ws, id, normalizeTags(w.tags)); err != nil {
+			return model.Task{}, err
+		}
+	}
+	task, err := scanTask(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return model.Task{}, err
+	}
+	if w.progress {
+		if err := bumpParents(ctx, tx, ws, changedParents(&before, &task)); err != nil {
+			return model.Task{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Task{}, err
+	}
+	return task, nil
+}
+
+// Delete removes a task by its ID from the database, deleting or moving its
+// subtasks and giving its parent, and the task the subtasks move to, a new
+// version in the same transaction. A non-zero version makes the delete
+// conditional on the task still being at that version. It returns
+// ErrNotFound when no task has the given ID.
+func (tr *TaskRepo) Delete(ctx context.Context, id int, version int, subtasks TaskSubtasks) error {
+	ws, err := scope(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateTaskSubtasks(id, subtasks); err != nil {
+		return err
+	}
+	if !subtasks.Cascade && !subtasks.Move {
+		return tr.deleteLeaf(ctx, ws, id, version)
+	}
+
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return tr.translate(ctx, err)
+	}
+	defer tx.Rollback()
+	if subtasks.MoveTo != nil {
+		if err := tr.lockTrees(ctx, tx, ws); err != nil {
+			return tr.translate(ctx, err)
+		}
+	}
+
+	var current int
+	var parent sql.NullInt64
+	err = tx.QueryRowContext(ctx,
+		"SELECT version, parent_id FROM tasks WHERE id = $1 AND workspace_id = $2"+tr.dialect.forUpdate, id, ws,
+	).Scan(&current, &parent)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return notFound(id)
+	case err != nil:
+		return tr.translate(ctx, err)
+	case version > 0 && current != version:
+		return staleVersion(id, version)
+	}
+
+	parents := changedParents(&model.Task{ParentID: nullID(parent)}, nil)
+	if subtasks.Cascade {
+		_, err = tx.ExecContext(ctx, subtreeSQL+"DELETE FROM tasks WHERE id IN (SELECT id FROM subtree)", id, ws)
+	} else {
+		moveTo := nullID(parent)
+		if subtasks.MoveTo != nil {
+			if err := tr.checkParent(ctx, tx, ws, id, *subtasks.MoveTo, "moveTo"); err != nil {
+				return err
+			}
+			moveTo = subtasks.MoveTo
+		}
+		if moveTo != nil && !sameID(moveTo, nullID(parent)) {
+			parents = append(parents, *moveTo)
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE tasks SET parent_id = $1, version = version + 1 WHERE parent_id = $2 AND workspace_id = $3",
+			nullInt(moveTo), id, ws,
+		)
+	}
+	if err != nil {
+		return tr.translate(ctx, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tasks WHERE id = $1 AND workspace_id = $2", id, ws); err != nil {
+		return tr.translate(ctx, err)
+	}
+	if err := bumpParents(ctx, tx, ws, parents); err != nil {
+		return tr.translate(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return tr.translate(ctx, err)
+	}
+	return nil
+}
+
+// deleteLeaf deletes a task that has no subtasks and gives its parent a new
+// version, in one transaction.
+func (tr *TaskRepo) deleteLeaf(ctx context.Context, ws, id, version int) error {
+	query, args := "DELETE FROM tasks WHERE id = $1 AND workspace_id = $2", []any{id, ws}
+	if version > 0 {
+		query += " AND version = $3"
+		args = append(args, version)
+	}
+	query += " AND NOT EXISTS (SELECT 1 FROM tasks AS subtasks WHERE subtasks.parent_id = $1) RETURNING parent_id"
+
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return tr.translate(ctx, err)
+	}
+	defer tx.Rollback()
+	var parent sql.NullInt64
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&parent)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return tr.undeleted(ctx, ws, id, version)
+	}
+	if err != nil {
+		return tr.translate(ctx, err)
+	}
+	if err := bumpParents(ctx, tx, ws, changedParents(&model.Task{ParentID: nullID(parent)}, nil)); err != nil {
+		return tr.translate(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return tr.translate(ctx, err)
+	}
+	return nil
+}
+
+// AddWatcher makes a user watch a task. It returns ErrNotFound when no task
+// has the given ID.
+func (tr *TaskRepo) AddWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	return tr.setWatcher(ctx, id, userID, version,
+		"INSERT INTO task_watchers (task_id, user_id) SELECT id, CAST($2 AS INTEGER) FROM tasks WHERE id = $1 AND workspace_id = $3 ON CONFLICT DO NOTHING")
+}
+
+// RemoveWatcher stops a user from watching a task. It returns ErrNotFound
+// when no task has the given ID.
+func (tr *TaskRepo) RemoveWatcher(ctx context.Context, id, userID, version int) (model.Task, error) {
+	return tr.setWatcher(ctx, id, userID, version,
+		"DELETE FROM task_watchers WHERE task_id = $1 AND user_id = $2 AND task_id IN (SELECT id FROM tasks WHERE workspace_id = $3)")
+}
+
+// setWatcher runs the statement adding or removing a watcher, which takes
+// the task ID, the user ID and the workspace ID, and, when it changed a row,
+// increments the version of the task in the same transaction.
+func (tr *TaskRepo) setWatcher(ctx context.Context, id, userID, version int, statement string) (model.Task, error) {
+	ws, err := scope(ctx)
+	if err != nil {
+		return model.Task{}, err
+	}
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, statement, id, userID, ws)
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	if n == 0 {
+		// Nothing changed, or the task does not exist: an empty patch
+		// tells which, once the transaction has released its connection.
+		tx.Rollback()
+		return tr.Patch(ctx, id, TaskPatch{Version: version})
+	}
+
+	query, args := "UPDATE tasks SET version = version + 1 WHERE id = $1 AND workspace_id = $2", []any{id, ws}
+	if version > 0 {
+		query += " AND version = $3"
+		args = append(args, version)
+	}
+	task, err := scanTask(tx.QueryRowContext(ctx, query+" RETURNING "+taskColumns, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return model.Task{}, tr.missingOrStale(ctx, ws, id, version)
+	}
+	if err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Task{}, tr.translate(ctx, err)
+	}
+	return task, nil
+}
+
+// missingOrStale explains why a conditional write matched no row: either
+// the task does not exist, or it is no longer at the expected version.
+func (tr *TaskRepo) missingOrStale(ctx context.Context, ws, id, version int) error {
+	if version == 0 {
+		return notFound(id)
+	}
+	var current int
+	err := tr.db.QueryRowContext(ctx, "SELECT version FROM tasks WHERE id = $1 AND workspace_id = $2", id, ws).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound(id)
+	}
+	if err != nil {
+		return tr.translate(ctx, err)
+	}
+	return staleVersion(id, version)
+}
+
+// translate maps a failed statement onto the repository sentinels.
+func (tr *TaskRepo) translate(ctx context.Context, err error) error {
+	return tr.dialect.translateStatement(ctx, err)
+}
+
+// translateStatement maps a failed statement onto the repository sentinels.
+// Once ctx is done, whatever the driver reports is a consequence of that.
+// The driver error is logged at debug level with the logger of the request.
+func (d dialect) translateStatement(ctx context.Context, err error) error {
+	logging.FromContext(ctx).Debug("database statement failed", slog.Any("error", err))
+	if ctxErr := contextError(ctx, err); ctxErr != nil {
+		return ctxErr
+	}
+	return d.translate(err)
 }
@@ -1,16 +1,22 @@
 package repo
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"reflect"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/DimWebDev/task-manager-tool/internal/model"
+	"github.com/lib/pq"
 )
 
+// testCtx confines the repository calls of the tests to workspace 1.
+var testCtx = WithWorkspace(context.Background(), 1)
 
 func NewMock() (*sql.DB, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
@@ -29,21 +35,31 @@ func TestCreateTask(t *testing.T) {
     dueDate := time.Now()
 
     // Use sqlmock.AnyArg() or a matcher that can match a time.Time for DueDate
-    mock.ExpectExec("INSERT INTO tasks").
-        WithArgs("Test Task", "This is a test task", sqlmock.AnyArg(), "Medium", "Pending").
-        WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO tasks (title, description, duedate, priority, status, created_by, assignee_id, workspace_id, project_id, parent_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING "+taskColumns)).
+		WithArgs("Test Task", "This is a test task", sqlmock.AnyArg(), int64(2), "Pending", nil, nil, 1, nil, nil).
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow(42, "Test Task", "This is a test task", dueDate, 2, "Pending", 1, nil, nil, nil, nil, nil, nil, 0, 0))
 
     // Make sure to take the address of dueDate to get a *time.Time for DueDate
     task := model.Task{
         Title:       "Test Task",
         Description: "This is a test task",
         DueDate:     &dueDate, // Now you're passing a *time.Time, assuming that's what your struct expects
-        Priority:    "Medium",
+		Priority:    model.PriorityMedium,
         Status:      "Pending",
     }
 
-    if err := repo.Create(task); err != nil {
+	created, err := repo.Create(testCtx, task)
+	if err != nil {
         t.Errorf("error was not expected while creating task: %s", err)
+	}
+
+	// The returned task must carry the ID assigned by the database
+	expected := task
+	expected.ID = 42
+	expected.Version = 1
+	if !reflect.DeepEqual(created, expected) {
+		t.Errorf("expected task %v, got %v", expected, created)
     }
 
     if err := mock.ExpectationsWereMet(); err != nil {
@@ -59,12 +75,12 @@ func TestGetByID(t *testing.T) {
     fixedTime := time.Date(2024, 1, 10, 20, 50, 30, 0, time.UTC) // Example fixed time
 
     // Use a pointer to fixedTime in the mock response
-    mock.ExpectQuery("SELECT id, title, description, duedate, priority, status FROM tasks WHERE id = \\$1").
-        WithArgs(1).
-        WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "duedate", "priority", "status"}).
-            AddRow(1, "Test Task", "This is a test task", &fixedTime, "Medium", "Pending"))
+	mock.ExpectQuery("SELECT "+regexp.QuoteMeta(taskColumns)+" FROM tasks WHERE id = \\$1 AND workspace_id = \\$2").
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow(1, "Test Task", "This is a test task", &fixedTime, 2, "Pending", 1, nil, nil, nil, nil, nil, nil, 0, 0))
 
-    task, err := repo.GetByID(1)
+	task, err := repo.GetByID(testCtx, 1)
     if err != nil {
         t.Errorf("error was not expected while getting task by ID: %s", err)
     }
@@ -75,8 +91,9 @@ func TestGetByID(t *testing.T) {
         Title:       "Test Task",
         Description: "This is a test task",
         DueDate:     &fixedTime,
-        Priority:    "Medium",
+		Priority:    model.PriorityMedium,
         Status:      "Pending",
+		Version:     1,
     }
 
     if !reflect.DeepEqual(task, expected) {
@@ -97,15 +114,16 @@ func TestGetAll(t *testing.T) {
     fixedTime := time.Date(2024, 1, 10, 20, 50, 30, 0, time.UTC)
 
     // Mocking database response to return multiple rows of tasks
-    rows := sqlmock.NewRows([]string{"id", "title", "description", "duedate", "priority", "status"}).
-        AddRow(1, "Test Task 1", "This is the first test task", fixedTime, "High", "Pending").
-        AddRow(2, "Test Task 2", "This is the second test task", fixedTime, "Medium", "Completed")
+	rows := sqlmock.NewRows(listColumns).
+		AddRow(1, "Test Task 1", "This is the first test task", fixedTime, 3, "Pending", 1, nil, nil, nil, nil, nil, nil, 0, 0).
+		AddRow(2, "Test Task 2", "This is the second test task", fixedTime, 2, "Completed", 1, nil, nil, nil, nil, nil, nil, 0, 0)
 
-    mock.ExpectQuery("SELECT id, title, description, duedate, priority, status FROM tasks").
+	mock.ExpectQuery("SELECT " + regexp.QuoteMeta(taskColumns) + " FROM tasks WHERE workspace_id = \\$1").
+		WithArgs(1).
         WillReturnRows(rows)
 
     // Calling GetAll
-    tasks, err := repo.GetAll()
+	tasks, err := repo.GetAll(testCtx)
     if err != nil {
         t.Errorf("error was not expected while getting all tasks: %s", err)
     }
@@ -121,16 +139,18 @@ func TestGetAll(t *testing.T) {
             Title:       "Test Task 1",
             Description: "This is the first test task",
             DueDate:     &fixedTimePtr1,
-            Priority:    "High",
+			Priority:    model.PriorityHigh,
             Status:      "Pending",
+			Version:     1,
         },
         {
             ID:          2,
             Title:       "Test Task 2",
             Description: "This is the second test task",
             DueDate:     &fixedTimePtr2,
-            Priority:    "Medium",
+			Priority:    model.PriorityMedium,
             Status:      "Completed",
+			Version:     1,
         },
     }
 
@@ -158,9 +178,15 @@ func TestUpdate(t *testing.T) {
     // As we're passing fixedTime as a value, it is important to note that sqlmock will
     // match this based on the value passed, if your method sends it as a pointer,
     // you will need to match using sqlmock.AnyArg() instead.
-    mock.ExpectExec("UPDATE tasks SET title = \\$1, description = \\$2, duedate = \\$3, priority = \\$4, status = \\$5 WHERE id = \\$6").
-        WithArgs("Updated Test Task", "This is an updated test task", fixedTime, "High", "Completed", 1).
-        WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, parent_id FROM tasks WHERE id = \\$1 AND workspace_id = \\$2 FOR UPDATE").
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "parent_id"}).AddRow("Pending", nil))
+	mock.ExpectQuery("UPDATE tasks SET title = \\$1, description = \\$2, duedate = \\$3, priority = \\$4, status = \\$5, version = version \\+ 1 WHERE id = \\$6 AND workspace_id = \\$7 RETURNING").
+		WithArgs("Updated Test Task", "This is an updated test task", fixedTime, int64(3), "Completed", 1, 1).
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow(1, "Updated Test Task", "This is an updated test task", fixedTime, 3, "Completed", 4, nil, nil, nil, nil, nil, nil, 0, 0))
+	mock.ExpectCommit()
 
     // Creating a task struct with updated values
     // DueDate is a pointer to fixedTime
@@ -169,13 +195,17 @@ func TestUpdate(t *testing.T) {
         Title:       "Updated Test Task",
         Description: "This is an updated test task",
         DueDate:     &fixedTime,
-        Priority:    "High",
+		Priority:    model.PriorityHigh,
         Status:      "Completed",
     }
 
     // Calling Update
-    if err := repo.Update(updatedTask); err != nil {
+	stored, err := repo.Update(testCtx, updatedTask)
+	if err != nil {
         t.Errorf("error was not expected while updating task: %s", err)
+	}
+	if stored.Version != 4 {
+		t.Errorf("expected the new version 4, got %d", stored.Version)
     }
 
     // Ensure all expectations were met
@@ -190,12 +220,14 @@ func TestDelete(t *testing.T) {
     defer db.Close()
 
     // Mocking the database to expect a DELETE query with a specific task ID
-    mock.ExpectExec("DELETE FROM tasks WHERE id = \\$1").
-        WithArgs(1).
-        WillReturnResult(sqlmock.NewResult(0, 1)) // 1 row affected
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM tasks WHERE id = \\$1 AND workspace_id = \\$2 .* RETURNING parent_id").
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil)) // 1 row deleted
+	mock.ExpectCommit()
 
     // Calling Delete
-    if err := repo.Delete(1); err != nil {
+	if err := repo.Delete(testCtx, 1, 0, TaskSubtasks{}); err != nil {
         t.Errorf("error was not expected while deleting task: %s", err)
     }
 
@@ -205,7 +237,152 @@ func TestDelete(t *testing.T) {
     }
 }
 
+func TestGetByIDNotFound(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT "+regexp.QuoteMeta(taskColumns)+" FROM tasks WHERE id = \\$1 AND workspace_id = \\$2").
+		WithArgs(99, 1).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.GetByID(testCtx, 99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetByIDUnavailable(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT "+regexp.QuoteMeta(taskColumns)+" FROM tasks WHERE id = \\$1 AND workspace_id = \\$2").
+		WithArgs(1, 1).
+		WillReturnError(&pq.Error{Code: "08006"}) // connection_failure
+
+	_, err := repo.GetByID(testCtx, 1)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Errorf("a lost connection must not be reported as not found: %v", err)
+	}
+}
+
+func TestCreateRejectsEmptyTitle(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	if _, err := repo.Create(testCtx, model.Task{Title: "  "}); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected ErrValidation, got %v", err)
+	}
+
+	// The database must not be touched for invalid input
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateNotFound(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, parent_id FROM tasks").
+		WillReturnError(sql.ErrNoRows) // no such task
+	mock.ExpectRollback()
+
+	_, err := repo.Update(testCtx, model.Task{ID: 99, Title: "Missing"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDeleteNotFound(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM tasks WHERE id = \\$1 AND workspace_id = \\$2").
+		WithArgs(99, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"})) // no rows deleted
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT version, EXISTS .* FROM tasks WHERE id = \\$1 AND workspace_id = \\$2").
+		WithArgs(99, 1).
+		WillReturnError(sql.ErrNoRows)
+
+	if err := repo.Delete(testCtx, 99, 0, TaskSubtasks{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateWithStaleVersion(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	// The compare-and-swap matches no row...
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, parent_id FROM tasks").
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "parent_id"}).AddRow("Pending", nil))
+	mock.ExpectQuery("UPDATE tasks SET .* WHERE id = \\$6 AND workspace_id = \\$7 AND version = \\$8 RETURNING").
+		WithArgs("Title", "", nil, nil, "", 1, 1, 3).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	// ...because the task has moved on to another version.
+	mock.ExpectQuery("SELECT version FROM tasks WHERE id = \\$1 AND workspace_id = \\$2").
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+
+	_, err := repo.Update(testCtx, model.Task{ID: 1, Title: "Title", Version: 3})
+	if !errors.Is(err, ErrVersionMismatch) || !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrVersionMismatch, got %v", err)
+	}
 
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDeleteWithVersion(t *testing.T) {
+	db, mock := NewMock()
+	repo := NewTaskRepo(db)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM tasks WHERE id = \\$1 AND workspace_id = \\$2 AND version = \\$3").
+		WithArgs(1, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}))
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT version, EXISTS .* FROM tasks WHERE id = \\$1 AND workspace_id = \\$2").
+		WithArgs(1, 1).
+		WillReturnError(sql.ErrNoRows)
+
+	// A versioned delete of a missing task is still reported as not found
+	if err := repo.Delete(testCtx, 1, 2, TaskSubtasks{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
 
 func TestMain(m *testing.M) {
 	// Call flag.Parse() here if TestMain uses flags
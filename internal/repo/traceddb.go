@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/DimWebDev/task-manager-tool/internal/tracing"
+)
+
+// tracedDB runs the statements of TaskRepo in a client span each, with the
+// SQL text as attribute, when the context carries a span. Arguments are not
+// recorded: they hold user data.
+type tracedDB struct {
+	*sql.DB
+	// system is the db.system attribute, e.g. "postgresql".
+	system string
+}
+
+// startStatement starts the span of a statement run on a database of the
+// given system.
+func startStatement(ctx context.Context, system, query string) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "db.query", tracing.SpanKindClient,
+		tracing.String("db.system", system),
+		tracing.String("db.statement", query),
+	)
+}
+
+// QueryContext traces sql.DB.QueryContext. The span ends when the query
+// returns, before its rows are read.
+func (d tracedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatement(ctx, d.system, query)
+	defer span.End()
+	rows, err := d.DB.QueryContext(ctx, query, args...)
+	span.SetError(err)
+	return rows, err
+}
+
+// QueryRowContext traces sql.DB.QueryRowContext. Its error only surfaces on
+// Scan, so the span does not record it; the repository call span does.
+func (d tracedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatement(ctx, d.system, query)
+	defer span.End()
+	return d.DB.QueryRowContext(ctx, query, args...)
+}
+
+// ExecContext traces sql.DB.ExecContext.
+func (d tracedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatement(ctx, d.system, query)
+	defer span.End()
+	res, err := d.DB.ExecContext(ctx, query, args...)
+	span.SetError(err)
+	return res, err
+}
+
+// BeginTx starts a transaction whose statements are traced like those of
+// the database.
+func (d tracedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tracedTx, error) {
+	tx, err := d.DB.BeginTx(ctx, opts)
+	return tracedTx{Tx: tx, system: d.system}, err
+}
+
+// rowQuerier is implemented by both tracedDB and tracedTx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// tracedTx is the tracedDB of a transaction. The SQLite repositories use a
+// single connection, which the transaction holds until it ends: statements
+// must not go through the tracedDB meanwhile.
+type tracedTx struct {
+	*sql.Tx
+	system string
+}
+
+// QueryRowContext traces sql.Tx.QueryRowContext.
+func (t tracedTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatement(ctx, t.system, query)
+	defer span.End()
+	return t.Tx.QueryRowContext(ctx, query, args...)
+}
+
+// ExecContext traces sql.Tx.ExecContext.
+func (t tracedTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatement(ctx, t.system, query)
+	defer span.End()
+	res, err := t.Tx.ExecContext(ctx, query, args...)
+	span.SetError(err)
+	return res, err
+}
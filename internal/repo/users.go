@@ -0,0 +1,398 @@
+// internal/repo/users.go
+// The users.go stores the users tasks are created by, assigned to and
+// watched by, in the same database as the tasks.
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+// UserRepository stores users. It reports failures with the same sentinel
+// errors as TaskRepository.
+type UserRepository interface {
+	// EnsureUser returns the user with the given subject, recording it
+	// first if it is not known yet.
+	EnsureUser(ctx context.Context, subject string) (model.User, error)
+	GetUser(ctx context.Context, id int) (model.User, error)
+	// ListUsers returns every user, ordered by ID.
+	ListUsers(ctx context.Context) ([]model.User, error)
+	// SetRole changes the role of a user and returns the updated user.
+	SetRole(ctx context.Context, id int, role model.Role) (model.User, error)
+	// SetProjectRole grants a user a role in the project with the given
+	// ID, replacing any role granted there before, and returns the updated
+	// user. The empty role withdraws the grant.
+	SetProjectRole(ctx context.Context, id, project int, role model.Role) (model.User, error)
+}
+
+// Ensure UserRepo and MemoryUserRepo implement UserRepository.
+var (
+	_ UserRepository = &UserRepo{}
+	_ UserRepository = &MemoryUserRepo{}
+)
+
+// maxSubjectLength mirrors the VARCHAR(255) limit of the users.subject
+// column.
+const maxSubjectLength = 255
+
+// validateSubject checks the constraints the users table enforces.
+func validateSubject(subject string) error {
+	if strings.TrimSpace(subject) == "" {
+		return &ValidationError{Field: "subject", Message: "is required"}
+	}
+	if len(subject) > maxSubjectLength {
+		return &ValidationError{Field: "subject", Message: fmt.Sprintf("must be at most %d characters", maxSubjectLength)}
+	}
+	return nil
+}
+
+// validateRole rejects roles outside the model.Role enum.
+func validateRole(role model.Role) error {
+	if !role.Valid() {
+		return &ValidationError{Field: "role", Message: fmt.Sprintf("unknown role %q", role)}
+	}
+	return nil
+}
+
+// validateProjectRole is validateRole, except that the empty role, which
+// withdraws a grant, is allowed.
+func validateProjectRole(role model.Role) error {
+	if role == "" {
+		return nil
+	}
+	return validateRole(role)
+}
+
+// userNotFound builds the error returned when no user has the given ID.
+func userNotFound(id int) error {
+	return fmt.Errorf("%w: user %d", ErrNotFound, id)
+}
+
+// UserRepo provides access to the users table of a SQL database.
+type UserRepo struct {
+	db      tracedDB
+	dialect dialect
+}
+
+// NewUserRepo creates a UserRepo for a PostgreSQL database.
+func NewUserRepo(db *sql.DB) *UserRepo {
+	return &UserRepo{db: tracedDB{DB: db, system: "postgresql"}, dialect: postgresDialect}
+}
+
+// NewSQLiteUserRepo creates a UserRepo for a SQLite database migrated with
+// migrations.SQLite.
+func NewSQLiteUserRepo(db *sql.DB) *UserRepo {
+	return &UserRepo{db: tracedDB{DB: db, system: "sqlite"}, dialect: sqliteDialect}
+}
+
+// userColumns lists the users columns in the order expected by scanUser.
+const userColumns = "id, subject, role, created_at"
+
+// scanUser reads a single user row selected with userColumns.
+func scanUser(s rowScanner) (model.User, error) {
+	var user model.User
+	if err := s.Scan(&user.ID, &user.Subject, &user.Role, &user.CreatedAt); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// withProjectRoles adds the roles granted to user in projects.
+func (ur *UserRepo) withProjectRoles(ctx context.Context, user model.User) (model.User, error) {
+	rows, err := ur.db.QueryContext(ctx, "SELECT project_id, role FROM project_roles WHERE user_id = $1", user.ID)
+	if err != nil {
+		return model.User{}, ur.translate(ctx, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var project int
+		var role model.Role
+		if err := rows.Scan(&project, &role); err != nil {
+			return model.User{}, ur.translate(ctx, err)
+		}
+		if user.ProjectRoles == nil {
+			user.ProjectRoles = make(map[int]model.Role)
+		}
+		user.ProjectRoles[project] = role
+	}
+	if err := rows.Err(); err != nil {
+		return model.User{}, ur.translate(ctx, err)
+	}
+	return user, nil
+}
+
+// EnsureUser looks the subject up and inserts it when it is missing. The
+// insert does nothing when a concurrent request recorded the subject first,
+// so both end up with the same user.
+func (ur *UserRepo) EnsureUser(ctx context.Context, subject string) (model.User, error) {
+	if err := validateSubject(subject); err != nil {
+		return model.User{}, err
+	}
+	query := "SELECT " + userColumns + " FROM users WHERE subject = $1"
+	user, err := scanUser(ur.db.QueryRowContext(ctx, query, subject))
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = ur.db.ExecContext(ctx,
+			"INSERT INTO users (subject, created_at) VALUES ($1, $2) ON CONFLICT (subject) DO NOTHING",
+			subject, time.Now().UTC(),
+		)
+		if err != nil {
+			return model.User{}, ur.translate(ctx, err)
+		}
+		user, err = scanUser(ur.db.QueryRowContext(ctx, query, subject))
+	}
+	if err != nil {
+		return model.User{}, ur.translate(ctx, err)
+	}
+	return ur.withProjectRoles(ctx, user)
+}
+
+// GetUser retrieves a user by its ID.
+func (ur *UserRepo) GetUser(ctx context.Context, id int) (model.User, error) {
+	user, err := scanUser(ur.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, userNotFound(id)
+	}
+	if err != nil {
+		return model.User{}, ur.translate(ctx, err)
+	}
+	return ur.withProjectRoles(ctx, user)
+}
+
+// ListUsers retrieves every user, ordered by ID.
+func (ur *UserRepo) ListUsers(ctx context.Context) ([]model.User, error) {
+	rows, err := ur.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, ur.translate(ctx, err)
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, ur.translate(ctx, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, ur.translate(ctx, err)
+	}
+	rows.Close()
+
+	// The grants are few; one query reads all of them.
+	byID := make(map[int]*model.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	grants, err := ur.db.QueryContext(ctx, "SELECT user_id, project_id, role FROM project_roles")
+	if err != nil {
+		return nil, ur.translate(ctx, err)
+	}
+	defer grants.Close()
+	for grants.Next() {
+		var id, project int
+		var role model.Role
+		if err := grants.Scan(&id, &project, &role); err != nil {
+			return nil, ur.translate(ctx, err)
+		}
+		if user := byID[id]; user != nil {
+			if user.ProjectRoles == nil {
+				user.ProjectRoles = make(map[int]model.Role)
+			}
+			user.ProjectRoles[project] = role
+		}
+	}
+	if err := grants.Err(); err != nil {
+		return nil, ur.translate(ctx, err)
+	}
+	return users, nil
+}
+
+// SetRole changes the role of a user.
+func (ur *UserRepo) SetRole(ctx context.Context, id int, role model.Role) (model.User, error) {
+	if err := validateRole(role); err != nil {
+		return model.User{}, err
+	}
+	user, err := scanUser(ur.db.QueryRowContext(ctx,
+		"UPDATE users SET role = $1 WHERE id = $2 RETURNING "+userColumns, role, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, userNotFound(id)
+	}
+	if err != nil {
+		return model.User{}, ur.translate(ctx, err)
+	}
+	return ur.withProjectRoles(ctx, user)
+}
+
+// SetProjectRole grants a user a role in a project. It returns ErrNotFound
+// when no user or no project has the given ID.
+func (ur *UserRepo) SetProjectRole(ctx context.Context, id, project int, role model.Role) (model.User, error) {
+	if err := validateProjectRole(role); err != nil {
+		return model.User{}, err
+	}
+	var found bool
+	err := ur.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1)", project).Scan(&found)
+	if err != nil {
+		return model.User{}, ur.translate(ctx, err)
+	}
+	if !found {
+		return model.User{}, projectNotFound(project)
+	}
+	if _, err := ur.GetUser(ctx, id); err != nil {
+		return model.User{}, err
+	}
+	if role == "" {
+		_, err = ur.db.ExecContext(ctx, "DELETE FROM project_roles WHERE user_id = $1 AND project_id = $2", id, project)
+	} else {
+		_, err = ur.db.ExecContext(ctx,
+			"INSERT INTO project_roles (user_id, project_id, role) VALUES ($1, $2, $3) ON CONFLICT (user_id, project_id) DO UPDATE SET role = excluded.role",
+			id, project, role,
+		)
+	}
+	if err != nil {
+		return model.User{}, ur.translate(ctx, err)
+	}
+	return ur.GetUser(ctx, id)
+}
+
+// translate maps a failed statement onto the repository sentinels.
+func (ur *UserRepo) translate(ctx context.Context, err error) error {
+	return ur.dialect.translateStatement(ctx, err)
+}
+
+// MemoryUserRepo is a UserRepository keeping users in a map, to go with a
+// MemoryTaskRepo. It is safe for concurrent use.
+type MemoryUserRepo struct {
+	mu        sync.Mutex
+	users     map[int]model.User
+	bySubject map[string]int
+}
+
+// NewMemoryUserRepo creates an empty MemoryUserRepo.
+func NewMemoryUserRepo() *MemoryUserRepo {
+	return &MemoryUserRepo{users: make(map[int]model.User), bySubject: make(map[string]int)}
+}
+
+// EnsureUser returns the user with the given subject, recording it first if
+// needed. IDs are assigned in increasing order.
+func (mr *MemoryUserRepo) EnsureUser(ctx context.Context, subject string) (model.User, error) {
+	if err := checkContext(ctx); err != nil {
+		return model.User{}, err
+	}
+	if err := validateSubject(subject); err != nil {
+		return model.User{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if id, ok := mr.bySubject[subject]; ok {
+		return copyUser(mr.users[id]), nil
+	}
+	user := model.User{ID: len(mr.users) + 1, Subject: subject, Role: model.DefaultRole, CreatedAt: time.Now().UTC()}
+	mr.users[user.ID] = user
+	mr.bySubject[subject] = user.ID
+	return user, nil
+}
+
+// GetUser retrieves a user by its ID.
+func (mr *MemoryUserRepo) GetUser(ctx context.Context, id int) (model.User, error) {
+	if err := checkContext(ctx); err != nil {
+		return model.User{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[id]
+	if !ok {
+		return model.User{}, userNotFound(id)
+	}
+	return copyUser(user), nil
+}
+
+// ListUsers retrieves every user, ordered by ID.
+func (mr *MemoryUserRepo) ListUsers(ctx context.Context) ([]model.User, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	users := make([]model.User, 0, len(mr.users))
+	for _, user := range mr.users {
+		users = append(users, copyUser(user))
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+// SetRole changes the role of a user.
+func (mr *MemoryUserRepo) SetRole(ctx context.Context, id int, role model.Role) (model.User, error) {
+	if err := checkContext(ctx); err != nil {
+		return model.User{}, err
+	}
+	if err := validateRole(role); err != nil {
+		return model.User{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[id]
+	if !ok {
+		return model.User{}, userNotFound(id)
+	}
+	user.Role = role
+	mr.users[id] = user
+	return copyUser(user), nil
+}
+
+// SetProjectRole grants a user a role in a project. The projects of a
+// MemoryProjectRepo are not known here, so any project ID is accepted.
+func (mr *MemoryUserRepo) SetProjectRole(ctx context.Context, id, project int, role model.Role) (model.User, error) {
+	if err := checkContext(ctx); err != nil {
+		return model.User{}, err
+	}
+	if err := validateProjectRole(role); err != nil {
+		return model.User{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[id]
+	if !ok {
+		return model.User{}, userNotFound(id)
+	}
+	user = copyUser(user)
+	if role == "" {
+		delete(user.ProjectRoles, project)
+	} else {
+		if user.ProjectRoles == nil {
+			user.ProjectRoles = make(map[int]model.Role)
+		}
+		user.ProjectRoles[project] = role
+	}
+	if len(user.ProjectRoles) == 0 {
+		user.ProjectRoles = nil
+	}
+	mr.users[id] = user
+	return copyUser(user), nil
+}
+
+// copyUser returns a copy of user that shares no map with the stored one.
+func copyUser(user model.User) model.User {
+	if user.ProjectRoles != nil {
+		roles := make(map[int]model.Role, len(user.ProjectRoles))
+		for project, role := range user.ProjectRoles {
+			roles[project] = role
+		}
+		user.ProjectRoles = roles
+	}
+	return user
+}
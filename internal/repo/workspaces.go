@@ -0,0 +1,325 @@
+// internal/repo/workspaces.go
+// The workspaces.go stores the workspaces tasks are kept apart in, and
+// carries the workspace of a call in its context so that every
+// TaskRepository operation is confined to it.
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/model"
+)
+
+type workspaceKey struct{}
+
+// WithWorkspace returns a copy of ctx confining TaskRepository calls to the
+// workspace with the given ID. Calls whose context carries no workspace
+// fail with ErrNoWorkspace.
+func WithWorkspace(ctx context.Context, workspaceID int) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspaceID)
+}
+
+// WorkspaceFromContext returns the workspace ID stored in ctx by
+// WithWorkspace, and whether there is one.
+func WorkspaceFromContext(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(workspaceKey{}).(int)
+	return id, ok
+}
+
+// scope returns the workspace a TaskRepository call is confined to. Every
+// operation starts with it, so a call cannot forget its workspace: without
+// one it fails.
+func scope(ctx context.Context) (int, error) {
+	id, ok := WorkspaceFromContext(ctx)
+	if !ok || id < 1 {
+		return 0, ErrNoWorkspace
+	}
+	return id, nil
+}
+
+// WorkspaceRepository stores workspaces and their members. It reports
+// failures with the same sentinel errors as TaskRepository.
+type WorkspaceRepository interface {
+	// CreateWorkspace stores a new workspace and returns it with its ID.
+	// A slug already in use fails with ErrConflict.
+	CreateWorkspace(ctx context.Context, ws model.Workspace) (model.Workspace, error)
+	GetWorkspace(ctx context.Context, slug string) (model.Workspace, error)
+	// ListWorkspaces returns the workspaces the user may use, ordered by
+	// ID: the default workspace and those it is a member of. A zero userID
+	// lists every workspace.
+	ListWorkspaces(ctx context.Context, userID int) ([]model.Workspace, error)
+	// AddMember and RemoveMember change whether a user is a member of a
+	// workspace; a change that is already in place is not an error.
+	AddMember(ctx context.Context, workspaceID, userID int) error
+	RemoveMember(ctx context.Context, workspaceID, userID int) error
+	IsMember(ctx context.Context, workspaceID, userID int) (bool, error)
+}
+
+// Ensure WorkspaceRepo and MemoryWorkspaceRepo implement WorkspaceRepository.
+var (
+	_ WorkspaceRepository = &WorkspaceRepo{}
+	_ WorkspaceRepository = &MemoryWorkspaceRepo{}
+)
+
+// slugPattern matches the slugs usable in URLs, mirroring the 63 character
+// limit of the workspaces.slug column.
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// validateWorkspace checks the constraints the workspaces table enforces.
+func validateWorkspace(ws model.Workspace) error {
+	if !slugPattern.MatchString(ws.Slug) {
+		return &ValidationError{Field: "slug", Message: "must be 1 to 63 lower-case letters, digits and dashes"}
+	}
+	if strings.TrimSpace(ws.Name) == "" {
+		return &ValidationError{Field: "name", Message: "is required"}
+	}
+	if len(ws.Name) > maxTitleLength {
+		return &ValidationError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxTitleLength)}
+	}
+	return nil
+}
+
+// workspaceNotFound builds the error returned when no workspace has the
+// given slug.
+func workspaceNotFound(slug string) error {
+	return fmt.Errorf("%w: workspace %q", ErrNotFound, slug)
+}
+
+// WorkspaceRepo provides access to the workspaces of a SQL database.
+type WorkspaceRepo struct {
+	db      tracedDB
+	dialect dialect
+}
+
+// NewWorkspaceRepo creates a WorkspaceRepo for a PostgreSQL database.
+func NewWorkspaceRepo(db *sql.DB) *WorkspaceRepo {
+	return &WorkspaceRepo{db: tracedDB{DB: db, system: "postgresql"}, dialect: postgresDialect}
+}
+
+// NewSQLiteWorkspaceRepo creates a WorkspaceRepo for a SQLite database
+// migrated with migrations.SQLite.
+func NewSQLiteWorkspaceRepo(db *sql.DB) *WorkspaceRepo {
+	return &WorkspaceRepo{db: tracedDB{DB: db, system: "sqlite"}, dialect: sqliteDialect}
+}
+
+// workspaceColumns lists the workspaces columns in the order expected by
+// scanWorkspace.
+const workspaceColumns = "id, slug, name, created_at"
+
+// scanWorkspace reads a single workspace row selected with workspaceColumns.
+func scanWorkspace(s rowScanner) (model.Workspace, error) {
+	var ws model.Workspace
+	if err := s.Scan(&ws.ID, &ws.Slug, &ws.Name, &ws.CreatedAt); err != nil {
+		return model.Workspace{}, err
+	}
+	return ws, nil
+}
+
+// CreateWorkspace inserts a new workspace.
+func (wr *WorkspaceRepo) CreateWorkspace(ctx context.Context, ws model.Workspace) (model.Workspace, error) {
+	if err := validateWorkspace(ws); err != nil {
+		return model.Workspace{}, err
+	}
+	created, err := scanWorkspace(wr.db.QueryRowContext(ctx,
+		"INSERT INTO workspaces (slug, name, created_at) VALUES ($1, $2, $3) RETURNING "+workspaceColumns,
+		ws.Slug, ws.Name, time.Now().UTC(),
+	))
+	if err != nil {
+		return model.Workspace{}, wr.translate(ctx, err)
+	}
+	return created, nil
+}
+
+// GetWorkspace retrieves a workspace by its slug.
+func (wr *WorkspaceRepo) GetWorkspace(ctx context.Context, slug string) (model.Workspace, error) {
+	ws, err := scanWorkspace(wr.db.QueryRowContext(ctx, "SELECT "+workspaceColumns+" FROM workspaces WHERE slug = $1", slug))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Workspace{}, workspaceNotFound(slug)
+	}
+	if err != nil {
+		return model.Workspace{}, wr.translate(ctx, err)
+	}
+	return ws, nil
+}
+
+// ListWorkspaces retrieves the workspaces the user may use, ordered by ID.
+func (wr *WorkspaceRepo) ListWorkspaces(ctx context.Context, userID int) ([]model.Workspace, error) {
+	query, args := "SELECT "+workspaceColumns+" FROM workspaces", []any{}
+	if userID != 0 {
+		query += " WHERE slug = $1 OR id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $2)"
+		args = append(args, model.DefaultWorkspace, userID)
+	}
+	rows, err := wr.db.QueryContext(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, wr.translate(ctx, err)
+	}
+	defer rows.Close()
+
+	var list []model.Workspace
+	for rows.Next() {
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, wr.translate(ctx, err)
+		}
+		list = append(list, ws)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wr.translate(ctx, err)
+	}
+	return list, nil
+}
+
+// AddMember makes a user a member of a workspace.
+func (wr *WorkspaceRepo) AddMember(ctx context.Context, workspaceID, userID int) error {
+	_, err := wr.db.ExecContext(ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		workspaceID, userID,
+	)
+	return wr.translate(ctx, err)
+}
+
+// RemoveMember removes a user from a workspace.
+func (wr *WorkspaceRepo) RemoveMember(ctx context.Context, workspaceID, userID int) error {
+	_, err := wr.db.ExecContext(ctx,
+		"DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2",
+		workspaceID, userID,
+	)
+	return wr.translate(ctx, err)
+}
+
+// IsMember reports whether a user is a member of a workspace.
+func (wr *WorkspaceRepo) IsMember(ctx context.Context, workspaceID, userID int) (bool, error) {
+	var member bool
+	err := wr.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM workspace_members WHERE workspace_id = $1 AND user_id = $2)",
+		workspaceID, userID,
+	).Scan(&member)
+	if err != nil {
+		return false, wr.translate(ctx, err)
+	}
+	return member, nil
+}
+
+// translate maps a failed statement onto the repository sentinels.
+func (wr *WorkspaceRepo) translate(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	return wr.dialect.translateStatement(ctx, err)
+}
+
+// MemoryWorkspaceRepo is a WorkspaceRepository keeping workspaces in
+// memory, to go with a MemoryTaskRepo. Like a migrated database, it starts
+// with the default workspace. It is safe for concurrent use.
+type MemoryWorkspaceRepo struct {
+	mu         sync.Mutex
+	workspaces []model.Workspace
+	members    map[[2]int]bool
+}
+
+// NewMemoryWorkspaceRepo creates a MemoryWorkspaceRepo holding the default
+// workspace.
+func NewMemoryWorkspaceRepo() *MemoryWorkspaceRepo {
+	return &MemoryWorkspaceRepo{
+		workspaces: []model.Workspace{{ID: model.DefaultWorkspaceID, Slug: model.DefaultWorkspace, Name: "Default", CreatedAt: time.Now().UTC()}},
+		members:    make(map[[2]int]bool),
+	}
+}
+
+// CreateWorkspace stores a new workspace. IDs are assigned in increasing
+// order.
+func (mr *MemoryWorkspaceRepo) CreateWorkspace(ctx context.Context, ws model.Workspace) (model.Workspace, error) {
+	if err := checkContext(ctx); err != nil {
+		return model.Workspace{}, err
+	}
+	if err := validateWorkspace(ws); err != nil {
+		return model.Workspace{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for _, existing := range mr.workspaces {
+		if existing.Slug == ws.Slug {
+			return model.Workspace{}, fmt.Errorf("%w: workspace %q already exists", ErrConflict, ws.Slug)
+		}
+	}
+	ws.ID, ws.CreatedAt = len(mr.workspaces)+1, time.Now().UTC()
+	mr.workspaces = append(mr.workspaces, ws)
+	return ws, nil
+}
+
+// GetWorkspace retrieves a workspace by its slug.
+func (mr *MemoryWorkspaceRepo) GetWorkspace(ctx context.Context, slug string) (model.Workspace, error) {
+	if err := checkContext(ctx); err != nil {
+		return model.Workspace{}, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for _, ws := range mr.workspaces {
+		if ws.Slug == slug {
+			return ws, nil
+		}
+	}
+	return model.Workspace{}, workspaceNotFound(slug)
+}
+
+// ListWorkspaces retrieves the workspaces the user may use, in the order
+// they were created.
+func (mr *MemoryWorkspaceRepo) ListWorkspaces(ctx context.Context, userID int) ([]model.Workspace, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	var list []model.Workspace
+	for _, ws := range mr.workspaces {
+		if userID == 0 || ws.Slug == model.DefaultWorkspace || mr.members[[2]int{ws.ID, userID}] {
+			list = append(list, ws)
+		}
+	}
+	return list, nil
+}
+
+// AddMember makes a user a member of a workspace.
+func (mr *MemoryWorkspaceRepo) AddMember(ctx context.Context, workspaceID, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.members[[2]int{workspaceID, userID}] = true
+	return nil
+}
+
+// RemoveMember removes a user from a workspace.
+func (mr *MemoryWorkspaceRepo) RemoveMember(ctx context.Context, workspaceID, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	delete(mr.members, [2]int{workspaceID, userID})
+	return nil
+}
+
+// IsMember reports whether a user is a member of a workspace.
+func (mr *MemoryWorkspaceRepo) IsMember(ctx context.Context, workspaceID, userID int) (bool, error) {
+	if err := checkContext(ctx); err != nil {
+		return false, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.members[[2]int{workspaceID, userID}], nil
+}
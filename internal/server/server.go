@@ -0,0 +1,187 @@
+// Package server runs the HTTP server and shuts it down gracefully: it stops
+// accepting connections, lets in-flight requests finish within a deadline,
+// then stops background workers and releases resources such as the database
+// in order.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/config"
+)
+
+// Server is an http.Server with an orderly shutdown.
+type Server struct {
+	http            *http.Server
+	tls             config.TLS
+	shutdownTimeout time.Duration
+	shutdownDelay   time.Duration
+
+	// shuttingDown is set as soon as the shutdown starts.
+	shuttingDown atomic.Bool
+
+	workerCtx   context.Context
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
+
+	mu          sync.Mutex // guards workerNames and closers
+	workerNames []string
+	closers     []closer
+}
+
+// closer releases a resource at shutdown.
+type closer struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// New creates a Server serving handler with the limits and TLS settings of
+// cfg. The timeouts protect against clients that send or read slowly and
+// would otherwise hold connections forever.
+func New(cfg config.Server, handler http.Handler) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+			// Connection errors, e.g. failed TLS handshakes, go to the
+			// structured log.
+			ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		},
+		tls:             cfg.TLS,
+		shutdownTimeout: cfg.ShutdownTimeout,
+		shutdownDelay:   cfg.ShutdownDelay,
+		workerCtx:       ctx,
+		stopWorkers:     cancel,
+	}
+}
+
+// ShuttingDown reports whether the shutdown has started. Requests still
+// being served at that point are drained, but new work should be refused;
+// the readiness probe fails from then on.
+func (s *Server) ShuttingDown() bool {
+	return s.shuttingDown.Load()
+}
+
+// Go runs a background worker until the shutdown. The context passed to fn
+// is canceled once the in-flight requests are drained, and the shutdown
+// waits for fn to return before closing any resource, so workers may use
+// them until then.
+func (s *Server) Go(name string, fn func(ctx context.Context)) {
+	s.workers.Add(1)
+	s.mu.Lock()
+	s.workerNames = append(s.workerNames, name)
+	s.mu.Unlock()
+	go func() {
+		defer s.workers.Done()
+		fn(s.workerCtx)
+	}()
+}
+
+// OnShutdown registers fn to release a resource after the requests are
+// drained and the workers stopped. Closers run in reverse order of
+// registration, so a resource registered before the ones depending on it is
+// closed after them, as with defer.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closers = append(s.closers, closer{name: name, fn: fn})
+}
+
+// Run listens on the configured address and serves until ctx is done, then
+// shuts down. See Serve.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("server: %w", err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done, typically on SIGINT or SIGTERM, and
+// then shuts down: ShuttingDown reports true at once and the server keeps
+// serving for the shutdown delay, then in-flight requests get the shutdown
+// timeout to finish, connections still open after it are closed, and finally
+// the workers are stopped and the closers run. It returns nil after a clean
+// shutdown, and otherwise the serving error or the shutdown errors.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	served := make(chan error, 1)
+	go func() {
+		if s.tls.Enabled() {
+			served <- s.http.ServeTLS(ln, s.tls.CertFile, s.tls.KeyFile)
+		} else {
+			served <- s.http.Serve(ln)
+		}
+	}()
+
+	var errs []error
+	select {
+	case err := <-served:
+		// The listener failed; still release everything in order.
+		errs = append(errs, fmt.Errorf("server: %w", err))
+	case <-ctx.Done():
+		s.shuttingDown.Store(true)
+		if s.shutdownDelay > 0 {
+			// Give load balancers time to notice the failing readiness
+			// probe and route new requests elsewhere.
+			slog.Info("Shutting down; still serving until the delay ends", slog.String("delay", s.shutdownDelay.String()))
+			select {
+			case <-time.After(s.shutdownDelay):
+			case err := <-served:
+				errs = append(errs, fmt.Errorf("server: %w", err))
+			}
+		}
+		slog.Info("Shutting down; draining requests", slog.String("timeout", s.shutdownTimeout.String()))
+	}
+	return errors.Join(append(errs, s.shutdown()...)...)
+}
+
+// shutdown drains the requests, stops the workers and runs the closers, all
+// within the shutdown timeout.
+func (s *Server) shutdown() []error {
+	s.shuttingDown.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.http.Shutdown(ctx); err != nil {
+		// Requests still running at the deadline are cut off.
+		s.http.Close()
+		errs = append(errs, fmt.Errorf("server: draining requests: %w", err))
+	}
+
+	s.stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("server: stopping workers %v: %w", s.workerNames, ctx.Err()))
+	}
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		c := s.closers[i]
+		if err := c.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("server: closing %s: %w", c.name, err))
+		}
+	}
+	return errs
+}
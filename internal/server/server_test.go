@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DimWebDev/task-manager-tool/internal/config"
+)
+
+// start serves handler on a local port until the returned cancel function
+// is called. Serve's result is sent on the returned channel.
+func start(t *testing.T, s *Server) (addr string, cancel context.CancelFunc, result <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func testConfig(shutdownTimeout time.Duration) config.Server {
+	cfg := config.Default().Server
+	cfg.ShutdownTimeout = shutdownTimeout
+	return cfg
+}
+
+func TestShutdownDrainsRequestsThenStopsWorkersThenCloses(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	s := New(testConfig(5*time.Second), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		record("request done")
+		io.WriteString(w, "ok")
+	}))
+	s.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		record("worker stopped")
+	})
+	s.OnShutdown("database", func(ctx context.Context) error { record("database closed"); return nil })
+	s.OnShutdown("exporter", func(ctx context.Context) error { record("exporter closed"); return nil })
+
+	addr, cancel, result := start(t, s)
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(addr)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+
+	<-started
+	cancel()
+	// The shutdown starts while the request is still running.
+	for !s.ShuttingDown() {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if got := <-response; got != "ok" {
+		t.Errorf("expected the in-flight request to complete, got %q", got)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	want := "request done, worker stopped, exporter closed, database closed"
+	if got := strings.Join(events, ", "); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if _, err := http.Get(addr); err == nil {
+		t.Error("expected new connections to be refused after the shutdown")
+	}
+}
+
+func TestShutdownTimeoutCutsOffRequests(t *testing.T) {
+	started := make(chan struct{})
+	s := New(testConfig(50*time.Millisecond), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	closed := false
+	s.OnShutdown("database", func(ctx context.Context) error { closed = true; return nil })
+
+	addr, cancel, result := start(t, s)
+	go http.Get(addr)
+	<-started
+	cancel()
+
+	err := <-result
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the drain to time out, got %v", err)
+	}
+	if !closed {
+		t.Error("expected the closers to run after a timed out drain")
+	}
+}
+
+func TestServeReportsListenerErrorsAndCloses(t *testing.T) {
+	s := New(testConfig(time.Second), http.NotFoundHandler())
+	closeErr := errors.New("close failed")
+	s.OnShutdown("database", func(ctx context.Context) error { return closeErr })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	err = s.Serve(context.Background(), ln)
+	if !errors.Is(err, net.ErrClosed) || !errors.Is(err, closeErr) {
+		t.Errorf("expected the listener and closer errors, got %v", err)
+	}
+}
+
+func TestShutdownDelayKeepsServingWhileNotReady(t *testing.T) {
+	cfg := testConfig(time.Second)
+	cfg.ShutdownDelay = 200 * time.Millisecond
+	var s *Server
+	s = New(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.ShuttingDown() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	addr, cancel, result := start(t, s)
+	cancel()
+	for !s.ShuttingDown() {
+		time.Sleep(time.Millisecond)
+	}
+	resp, err := http.Get(addr)
+	if err != nil {
+		t.Fatalf("expected the server to keep serving during the delay, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 during the delay, got %d", resp.StatusCode)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+}
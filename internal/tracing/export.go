@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SpanData is an ended span, as handed to an Exporter.
+type SpanData struct {
+	Name       string
+	TraceID    TraceID
+	SpanID     SpanID
+	ParentID   SpanID // zero for a root span
+	Kind       SpanKind
+	Start, End time.Time
+	Attributes []Attribute
+	// Failed is set when the operation failed, Error then describes why.
+	Failed bool
+	Error  string
+}
+
+// Exporter sends ended spans to where they are stored or displayed.
+type Exporter interface {
+	// Export sends a batch of spans. It is not called concurrently.
+	Export(ctx context.Context, spans []SpanData) error
+	// Shutdown releases the resources of the exporter once the last batch
+	// is exported.
+	Shutdown(ctx context.Context) error
+}
+
+// WriterExporter writes every span as a JSON object on its own line, for
+// reading by humans or log shippers.
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+	// closer is closed by Shutdown, if set.
+	closer io.Closer
+}
+
+// NewWriterExporter returns an exporter writing to w, e.g. os.Stdout.
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// NewFileExporter returns an exporter appending to the file at path,
+// created if needed, and closing it on Shutdown.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterExporter{w: f, closer: f}, nil
+}
+
+// jsonSpan is the JSON line written for a span.
+type jsonSpan struct {
+	Name         string         `json:"name"`
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	Kind         string         `json:"kind"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	DurationMs   float64        `json:"durationMs"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+var kindNames = map[SpanKind]string{
+	SpanKindInternal: "internal",
+	SpanKindServer:   "server",
+	SpanKindClient:   "client",
+}
+
+// Export writes one line per span.
+func (e *WriterExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		line := jsonSpan{
+			Name:       s.Name,
+			TraceID:    s.TraceID.String(),
+			SpanID:     s.SpanID.String(),
+			Kind:       kindNames[s.Kind],
+			Start:      s.Start.UTC(),
+			End:        s.End.UTC(),
+			DurationMs: float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+		}
+		if s.ParentID.IsValid() {
+			line.ParentSpanID = s.ParentID.String()
+		}
+		if len(s.Attributes) > 0 {
+			line.Attributes = make(map[string]any, len(s.Attributes))
+			for _, a := range s.Attributes {
+				line.Attributes[a.Key] = a.Value
+			}
+		}
+		if s.Failed {
+			line.Error = s.Error
+			if line.Error == "" {
+				line.Error = "failed"
+			}
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown closes the file of a file exporter.
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
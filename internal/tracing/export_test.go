@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSpans() []SpanData {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	trace := TraceID{0x4b, 0xf9, 1}
+	return []SpanData{
+		{
+			Name:       "db.query",
+			TraceID:    trace,
+			SpanID:     SpanID{2},
+			ParentID:   SpanID{1},
+			Kind:       SpanKindClient,
+			Start:      start.Add(time.Millisecond),
+			End:        start.Add(3 * time.Millisecond),
+			Attributes: []Attribute{String("db.statement", "SELECT 1"), Int("rows", 3), Bool("cached", false)},
+			Failed:     true,
+			Error:      "connection reset",
+		},
+		{
+			Name:    "GET /tasks",
+			TraceID: trace,
+			SpanID:  SpanID{1},
+			Kind:    SpanKindServer,
+			Start:   start,
+			End:     start.Add(5 * time.Millisecond),
+		},
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exp := NewWriterExporter(&buf)
+	if err := exp.Export(context.Background(), testSpans()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per span, got %q", buf.String())
+	}
+	want := `{"name":"db.query","traceId":"4bf90100000000000000000000000000","spanId":"0200000000000000",` +
+		`"parentSpanId":"0100000000000000","kind":"client","start":"2024-05-01T10:00:00.001Z",` +
+		`"end":"2024-05-01T10:00:00.003Z","durationMs":2,` +
+		`"attributes":{"cached":false,"db.statement":"SELECT 1","rows":3},"error":"connection reset"}`
+	if lines[0] != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, lines[0])
+	}
+	if strings.Contains(lines[1], "parentSpanId") || strings.Contains(lines[1], "error") {
+		t.Errorf("expected a root span without error, got %s", lines[1])
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	for i := 0; i < 2; i++ {
+		exp, err := NewFileExporter(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp.Export(context.Background(), testSpans()[:1])
+		if err := exp.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Errorf("expected the file to be appended to, got %d lines", n)
+	}
+}
+
+// stubCollector is a minimal OTLP/HTTP collector.
+type stubCollector struct {
+	*httptest.Server
+	requests chan otlpRequest
+	status   int
+}
+
+func newStubCollector(t *testing.T, status int) *stubCollector {
+	c := &stubCollector{requests: make(chan otlpRequest, 1), status: status}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req otlpRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.requests <- req
+		if c.status != http.StatusOK {
+			http.Error(w, "collector overloaded", c.status)
+			return
+		}
+		io.WriteString(w, "{}")
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestOTLPExporter(t *testing.T) {
+	collector := newStubCollector(t, http.StatusOK)
+	exp := NewOTLPExporter(collector.URL+"/", "task-manager")
+	if err := exp.Export(context.Background(), testSpans()); err != nil {
+		t.Fatal(err)
+	}
+	exp.Shutdown(context.Background())
+
+	req := <-collector.requests
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	rs := req.ResourceSpans[0]
+	if attrs := rs.Resource.Attributes; len(attrs) != 1 || attrs[0].Key != "service.name" || *attrs[0].Value.StringValue != "task-manager" {
+		t.Errorf("expected the service name as resource attribute, got %+v", attrs)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	q := spans[0]
+	if q.TraceID != "4bf90100000000000000000000000000" || q.SpanID != "0200000000000000" || q.ParentSpanID != "0100000000000000" {
+		t.Errorf("expected hex IDs, got %+v", q)
+	}
+	if q.Kind != SpanKindClient || q.StartTimeUnixNano != "1714557600001000000" || q.EndTimeUnixNano != "1714557600003000000" {
+		t.Errorf("unexpected kind or times %+v", q)
+	}
+	if q.Status.Code != otlpStatusError || q.Status.Message != "connection reset" {
+		t.Errorf("expected an error status, got %+v", q.Status)
+	}
+	if a := q.Attributes; len(a) != 3 || *a[0].Value.StringValue != "SELECT 1" || *a[1].Value.IntValue != "3" || *a[2].Value.BoolValue {
+		t.Errorf("unexpected attributes %+v", a)
+	}
+	if spans[1].ParentSpanID != "" || spans[1].Status.Code != otlpStatusUnset {
+		t.Errorf("expected a root span without status, got %+v", spans[1])
+	}
+}
+
+func TestOTLPExporterReportsCollectorErrors(t *testing.T) {
+	collector := newStubCollector(t, http.StatusServiceUnavailable)
+	tracer := NewTracer(NewOTLPExporter(collector.URL, "task-manager"))
+	_, span := tracer.Start(context.Background(), "GET /tasks", SpanKindServer)
+	span.End()
+
+	err := tracer.Flush(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "collector overloaded") {
+		t.Errorf("expected the collector error, got %v", err)
+	}
+}
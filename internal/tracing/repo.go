@@ -0,0 +1,17 @@
+package tracing
+
+import "context"
+
+// Repo traces TaskRepository calls made on behalf of a traced request; it
+// implements repo.Observer. The SQL repositories add a span per statement
+// below it.
+type Repo struct{}
+
+// Observe starts a span for the operation and ends it with its error.
+func (Repo) Observe(ctx context.Context, op string) (context.Context, func(error)) {
+	ctx, span := Start(ctx, "TaskRepository."+op, SpanKindInternal, String("code.function", op))
+	return ctx, func(err error) {
+		span.SetError(err)
+		span.End()
+	}
+}
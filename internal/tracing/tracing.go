@@ -0,0 +1,377 @@
+// Package tracing records distributed traces in the spirit of OpenTelemetry,
+// without depending on its SDK. Inbound requests join the trace named by
+// their W3C traceparent header; the HTTP middleware opens a server span per
+// request, and handler and repository code open child spans with Start. Ended
+// spans are batched by the Tracer and handed to an Exporter, which writes
+// them as JSON lines or sends them to an OTLP/HTTP collector.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the lowercase hex form used by traceparent and OTLP.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// String returns the lowercase hex form used by traceparent and OTLP.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros, which W3C reserves.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// IsValid reports whether id is not all zeros, which W3C reserves.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Sampled reports whether the trace is recorded; spans of unsampled
+	// traces are propagated but not exported.
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// TraceparentHeader is the W3C Trace Context request header.
+const TraceparentHeader = "traceparent"
+
+// Traceparent formats sc as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value. Following the W3C
+// recommendation, a header that cannot be parsed is ignored by the caller,
+// which then starts a new trace.
+func ParseTraceparent(h string) (SpanContext, error) {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	if len(h) < 55 || h[2] != '-' || h[35] != '-' || h[52] != '-' {
+		return SpanContext{}, fmt.Errorf("tracing: malformed traceparent %q", h)
+	}
+	version, err := hex.DecodeString(h[:2])
+	switch {
+	case err != nil || version[0] == 0xff:
+		return SpanContext{}, fmt.Errorf("tracing: invalid traceparent version %q", h[:2])
+	case version[0] == 0 && len(h) != 55:
+		return SpanContext{}, fmt.Errorf("tracing: malformed traceparent %q", h)
+	case version[0] > 0 && len(h) > 55 && h[55] != '-':
+		// Later versions may only append fields.
+		return SpanContext{}, fmt.Errorf("tracing: malformed traceparent %q", h)
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	for _, part := range []struct {
+		dst []byte
+		src string
+	}{
+		{sc.TraceID[:], h[3:35]},
+		{sc.SpanID[:], h[36:52]},
+		{flags[:], h[53:55]},
+	} {
+		// Uppercase hex is not allowed.
+		if _, err := hex.Decode(part.dst, []byte(part.src)); err != nil || part.src != hex.EncodeToString(part.dst) {
+			return SpanContext{}, fmt.Errorf("tracing: malformed traceparent %q", h)
+		}
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("tracing: traceparent %q has a zero ID", h)
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+type remoteKey struct{}
+
+// Extract returns a copy of ctx carrying the span context of the traceparent
+// header in h, if it is valid, as the parent of the next span started.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanKind tells the role of a span in a trace. The values are those of
+// OTLP.
+type SpanKind int
+
+// Span kinds.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute annotates a span. Values are strings, int64s, float64s or bools.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{key, int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// Span is an operation within a trace. All its methods may be called on a
+// nil *Span, which records nothing, so instrumented code needs no checks.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   SpanKind
+	start  time.Time
+
+	mu     sync.Mutex
+	attrs  []Attribute
+	err    string
+	failed bool
+	ended  bool
+}
+
+// SpanContext returns the IDs of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes adds attributes to the span, replacing those with the same
+// key.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+next:
+	for _, a := range attrs {
+		for i := range s.attrs {
+			if s.attrs[i].Key == a.Key {
+				s.attrs[i] = a
+				continue next
+			}
+		}
+		s.attrs = append(s.attrs, a)
+	}
+}
+
+// SetError marks the span as failed with the message of err. A nil err is
+// ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetErrorMessage(err.Error())
+}
+
+// SetErrorMessage marks the span as failed with msg.
+func (s *Span) SetErrorMessage(msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed, s.err = true, msg
+}
+
+// End completes the span and queues it for export if its trace is sampled.
+// Calls after the first are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:       s.name,
+		TraceID:    s.sc.TraceID,
+		SpanID:     s.sc.SpanID,
+		ParentID:   s.parent,
+		Kind:       s.kind,
+		Start:      s.start,
+		End:        end,
+		Attributes: append([]Attribute(nil), s.attrs...),
+		Failed:     s.failed,
+		Error:      s.err,
+	}
+	s.mu.Unlock()
+	if s.sc.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span carried by ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SpanContextFromContext returns the span context of the span carried by
+// ctx, or else the remote parent stored by Extract.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Start starts a child of the span carried by ctx, recorded by the same
+// Tracer, and returns a context carrying the child. Without a span in ctx
+// it starts nothing and returns ctx and a nil span, so code below the HTTP
+// layer only traces work done on behalf of a traced request.
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, kind, attrs...)
+}
+
+// Tracer starts spans and batches the ended ones for its Exporter.
+type Tracer struct {
+	exporter Exporter
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []SpanData
+	dropped int
+	full    chan struct{}
+}
+
+// Batching limits. Spans ended while maxQueue spans wait for export are
+// dropped rather than letting a slow collector exhaust the memory.
+const (
+	batchSize     = 512
+	maxQueue      = 4096
+	flushInterval = 5 * time.Second
+)
+
+// NewTracer returns a Tracer exporting to exp. Run must be running for the
+// spans to be exported in the background, and Shutdown exports the rest.
+func NewTracer(exp Exporter) *Tracer {
+	return &Tracer{exporter: exp, interval: flushInterval, full: make(chan struct{}, 1)}
+}
+
+// Start starts a span as a child of the span or remote parent carried by
+// ctx, or as the root of a new, sampled trace, and returns a context
+// carrying it.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  append([]Attribute(nil), attrs...),
+	}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		s.sc = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		s.parent = parent.SpanID
+	} else {
+		rand.Read(s.sc.TraceID[:])
+		s.sc.Sampled = true
+	}
+	rand.Read(s.sc.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxQueue {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, data)
+	if len(t.pending) >= batchSize {
+		select {
+		case t.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run exports the ended spans every few seconds, or as soon as a batch is
+// full, until ctx is done.
+func (t *Tracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-t.full:
+		}
+		if err := t.Flush(ctx); err != nil {
+			slog.Warn("Exporting spans failed", slog.Any("error", err))
+		}
+	}
+}
+
+// Flush exports the spans ended so far.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	pending, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("Dropped spans; the exporter does not keep up", slog.Int("spans", dropped))
+	}
+	for len(pending) > 0 {
+		n := min(len(pending), batchSize)
+		if err := t.exporter.Export(ctx, pending[:n]); err != nil {
+			return fmt.Errorf("tracing: exporting %d spans: %w", len(pending), err)
+		}
+		pending = pending[n:]
+	}
+	return nil
+}
+
+// Shutdown exports the remaining spans and shuts the exporter down. Spans
+// ended afterwards are lost.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	err := t.Flush(ctx)
+	if shutdownErr := t.exporter.Shutdown(ctx); shutdownErr != nil && err == nil {
+		err = fmt.Errorf("tracing: %w", shutdownErr)
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// recorder is an Exporter keeping the spans in memory.
+type recorder struct {
+	mu       sync.Mutex
+	spans    []SpanData
+	batches  int
+	shutdown bool
+}
+
+func (r *recorder) Export(ctx context.Context, spans []SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	r.batches++
+	return nil
+}
+
+func (r *recorder) Shutdown(ctx context.Context) error {
+	r.shutdown = true
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("unexpected span context %+v", sc)
+	}
+	if got := sc.Traceparent(); got != valid {
+		t.Errorf("expected %s to round-trip, got %s", valid, got)
+	}
+
+	if sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"); err != nil || sc.Sampled {
+		t.Errorf("expected an unsampled span context, got %+v, %v", sc, err)
+	}
+	// A later version may append fields.
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Errorf("expected a future version to parse, got %v", err)
+	}
+
+	for _, h := range []string{
+		"",
+		"garbage",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		if _, err := ParseTraceparent(h); err == nil {
+			t.Errorf("expected %q to be rejected", h)
+		}
+	}
+}
+
+func TestSpansFormATree(t *testing.T) {
+	rec := &recorder{}
+	tracer := NewTracer(rec)
+
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, server := tracer.Start(Extract(context.Background(), h), "GET /tasks", SpanKindServer)
+	childCtx, child := Start(ctx, "TaskRepository.List", SpanKindInternal, String("code.function", "List"))
+	_, grandchild := Start(childCtx, "db.query", SpanKindClient)
+	grandchild.SetError(errors.New("connection reset"))
+	grandchild.End()
+	child.End()
+	child.End() // ignored
+	server.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.spans) != 3 || !rec.shutdown {
+		t.Fatalf("expected 3 exported spans and a shutdown, got %d, %v", len(rec.spans), rec.shutdown)
+	}
+	g, c, s := rec.spans[0], rec.spans[1], rec.spans[2]
+	if s.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || s.ParentID.String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the server span to join the remote trace, got %+v", s)
+	}
+	if c.TraceID != s.TraceID || c.ParentID != s.SpanID || g.ParentID != c.SpanID {
+		t.Error("expected the spans to form a tree")
+	}
+	if !g.Failed || g.Error != "connection reset" || c.Failed {
+		t.Errorf("expected only the query to fail, got %+v and %+v", g, c)
+	}
+	if len(c.Attributes) != 1 || c.Attributes[0] != String("code.function", "List") {
+		t.Errorf("unexpected attributes %v", c.Attributes)
+	}
+}
+
+func TestStartWithoutSpanDoesNothing(t *testing.T) {
+	ctx, span := Start(context.Background(), "orphan", SpanKindInternal)
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Error("expected no span outside a trace")
+	}
+	// A nil span is safe to use.
+	span.SetAttributes(Int("n", 1))
+	span.SetError(errors.New("boom"))
+	span.End()
+}
+
+func TestNewTraceAndUnsampledTrace(t *testing.T) {
+	rec := &recorder{}
+	tracer := NewTracer(rec)
+
+	_, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	if !root.SpanContext().IsValid() || !root.SpanContext().Sampled {
+		t.Errorf("expected a sampled new trace, got %+v", root.SpanContext())
+	}
+	root.End()
+
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, unsampled := tracer.Start(Extract(context.Background(), h), "unsampled", SpanKindServer)
+	_, child := Start(ctx, "child", SpanKindInternal)
+	child.End()
+	unsampled.End()
+
+	tracer.Flush(context.Background())
+	if len(rec.spans) != 1 || rec.spans[0].Name != "root" {
+		t.Errorf("expected only the sampled span to be exported, got %v", rec.spans)
+	}
+	if child.SpanContext().TraceID != unsampled.SpanContext().TraceID {
+		t.Error("expected unsampled spans to keep propagating the trace")
+	}
+}
+
+func TestFlushExportsInBatches(t *testing.T) {
+	rec := &recorder{}
+	tracer := NewTracer(rec)
+	for i := 0; i < batchSize+1; i++ {
+		_, span := tracer.Start(context.Background(), "span", SpanKindInternal)
+		span.End()
+	}
+	select {
+	case <-tracer.full:
+	default:
+		t.Error("expected a full batch to wake up Run")
+	}
+	tracer.Flush(context.Background())
+	if rec.batches != 2 || len(rec.spans) != batchSize+1 {
+		t.Errorf("expected 2 batches of %d spans, got %d batches of %d", batchSize+1, rec.batches, len(rec.spans))
+	}
+}